@@ -0,0 +1,180 @@
+package tnlcmd
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/TrailHuang/tnlcmd/internal/commandtree"
+)
+
+var (
+	durationType = reflect.TypeOf(time.Duration(0))
+	ipType       = reflect.TypeOf(net.IP(nil))
+	ipNetType    = reflect.TypeOf((*net.IPNet)(nil))
+)
+
+// Bind 按顺序将命令参数绑定到结构体的导出字段，v 必须是结构体指针
+//
+//	var p struct {
+//		Level int
+//		State string
+//	}
+//	if err := tnlcmd.Bind(ctx.Args, &p); err != nil {
+//		return err
+//	}
+//
+// 字段按类型转换：整数（0x 前缀按十六进制）、浮点数、bool、string、time.Duration、
+// net.IP、*net.IPNet（A.B.C.D/M）；最后一个字段为切片时接收剩余的全部参数，用于可重复参数。
+// 参数为空字符串（省略的可选参数）或少于字段时对应字段保持零值，标签为 `tnlcmd:"-"` 的字段跳过。
+// 命令树在调用处理函数前已经完成校验，转换失败说明结构体与命令规格不一致
+func Bind(args []string, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("bind: target must be a non-nil pointer to struct, got %T", v)
+	}
+
+	fields := bindFields(rv.Elem())
+	for i, field := range fields {
+		if i >= len(args) {
+			return nil
+		}
+
+		// 最后一个切片字段接收剩余的参数
+		if i == len(fields)-1 && field.value.Kind() == reflect.Slice && field.value.Type() != ipType {
+			var rest []string
+			for _, arg := range args[i:] {
+				if arg != "" {
+					rest = append(rest, arg)
+				}
+			}
+			slice := reflect.MakeSlice(field.value.Type(), len(rest), len(rest))
+			for j, arg := range rest {
+				if err := setValue(slice.Index(j), arg); err != nil {
+					return fmt.Errorf("bind: argument %d to field %s: %w", i+j+1, field.name, err)
+				}
+			}
+			field.value.Set(slice)
+			return nil
+		}
+
+		if args[i] == "" {
+			continue
+		}
+		if err := setValue(field.value, args[i]); err != nil {
+			return fmt.Errorf("bind: argument %d to field %s: %w", i+1, field.name, err)
+		}
+	}
+
+	if len(args) > len(fields) {
+		return fmt.Errorf("bind: too many arguments: %d arguments, %d fields", len(args), len(fields))
+	}
+	return nil
+}
+
+// bindField 参与绑定的结构体字段
+type bindField struct {
+	name  string
+	value reflect.Value
+}
+
+// bindFields 按声明顺序返回参与绑定的导出字段
+func bindFields(rv reflect.Value) []bindField {
+	var fields []bindField
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		f := rt.Field(i)
+		if f.PkgPath != "" || f.Tag.Get("tnlcmd") == "-" {
+			continue
+		}
+		fields = append(fields, bindField{name: f.Name, value: rv.Field(i)})
+	}
+	return fields
+}
+
+// setValue 将参数转换为字段的类型并赋值
+func setValue(field reflect.Value, arg string) error {
+	switch field.Type() {
+	case durationType:
+		d, err := commandtree.ParseDuration(arg)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(d))
+		return nil
+	case ipType:
+		ip := net.ParseIP(arg)
+		if ip == nil {
+			return fmt.Errorf("invalid IP address: %s", arg)
+		}
+		field.Set(reflect.ValueOf(ip))
+		return nil
+	case ipNetType:
+		prefix, err := commandtree.ParseIPv4Prefix(arg)
+		if err != nil {
+			return err
+		}
+		field.Set(reflect.ValueOf(prefix))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(arg)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var value int64
+		var err error
+		if isHex(arg) {
+			var u uint64
+			u, err = commandtree.ParseHex(arg)
+			value = int64(u)
+			if err == nil && (u > 1<<63-1 || field.OverflowInt(value)) {
+				err = fmt.Errorf("value out of range: %s", arg)
+			}
+		} else {
+			value, err = strconv.ParseInt(arg, 10, field.Type().Bits())
+		}
+		if err != nil {
+			return err
+		}
+		field.SetInt(value)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		var value uint64
+		var err error
+		if isHex(arg) {
+			value, err = commandtree.ParseHex(arg)
+			if err == nil && field.OverflowUint(value) {
+				err = fmt.Errorf("value out of range: %s", arg)
+			}
+		} else {
+			value, err = strconv.ParseUint(arg, 10, field.Type().Bits())
+		}
+		if err != nil {
+			return err
+		}
+		field.SetUint(value)
+	case reflect.Float32, reflect.Float64:
+		value, err := strconv.ParseFloat(arg, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetFloat(value)
+	case reflect.Bool:
+		value, err := strconv.ParseBool(arg)
+		if err != nil {
+			return err
+		}
+		field.SetBool(value)
+	default:
+		return fmt.Errorf("unsupported field type %s", field.Type())
+	}
+	return nil
+}
+
+// isHex 参数是否带 0x 前缀
+func isHex(arg string) bool {
+	return strings.HasPrefix(arg, "0x") || strings.HasPrefix(arg, "0X")
+}
@@ -0,0 +1,385 @@
+// Package client 通过 telnet 连接到 tnlcmd 命令行的脚本客户端：应答选项协商，发送命令或按键，
+// 等待提示符或与正则表达式匹配的输出，可用于集成测试和批量管理设备
+//
+//	c, err := client.Dial("192.0.2.1:2323", client.Options{})
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	defer c.Close()
+//	out, err := c.Run("show version")
+package client
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/TrailHuang/tnlcmd/internal/telnet"
+)
+
+// 常用按键
+const (
+	Enter     = "\r"
+	Tab       = "\t"
+	Help      = "?"
+	Backspace = "\x7f"
+	Up        = "\x1b[A"
+	Down      = "\x1b[B"
+	Right     = "\x1b[C"
+	Left      = "\x1b[D"
+	CtrlA     = "\x01"
+	CtrlC     = "\x03"
+	CtrlD     = "\x04"
+	CtrlE     = "\x05"
+	CtrlU     = "\x15"
+)
+
+// DefaultTimeout 等待输出的默认时长
+const DefaultTimeout = 5 * time.Second
+
+// DefaultPrompt 匹配提示符的默认正则表达式，如 "cmdline> " 和 "router(configure)# "
+const DefaultPrompt = `[>#] $`
+
+// newline 回显的换行
+var newline = regexp.MustCompile(`\n`)
+
+// ErrClosed 会话已经结束，没有更多的输出
+var ErrClosed = errors.New("client: session closed")
+
+// Options 客户端报告的终端属性和等待方式
+type Options struct {
+	// Width 和 Height 通过 NAWS 报告的窗口大小，为 0 时不报告，会话按 80x24 处理
+	Width  int
+	Height int
+
+	// TerminalType 通过 TERMINAL-TYPE 报告的终端类型，如 xterm，为空时不报告，会话不输出颜色
+	TerminalType string
+
+	// LineMode 为 true 时拒绝服务端回显和字符模式，会话回退到行模式，不回显输入，Tab 和 ? 随整行发送
+	LineMode bool
+
+	// Prompt 匹配提示符的正则表达式，为空时使用 DefaultPrompt
+	Prompt string
+
+	// Timeout 等待输出的时长，为 0 时使用 DefaultTimeout
+	Timeout time.Duration
+}
+
+// Client 连接到命令行的客户端，应答 telnet 选项协商并保存会话输出的数据，去掉其中的协议字节
+type Client struct {
+	conn    net.Conn
+	options Options
+	prompt  *regexp.Regexp
+
+	mu     sync.Mutex
+	cond   *sync.Cond
+	output []byte // 会话输出的全部数据
+	read   int    // Expect 已经读取到的位置
+	closed bool   // 连接已经关闭
+
+	writes chan []byte // 等待发送的数据，由写协程发送，避免与服务端的写入互相阻塞
+	done   chan struct{}
+}
+
+// Dial 通过 TCP 连接到 addr 并等待第一个提示符，连接超时为 options.Timeout
+func Dial(addr string, options Options) (*Client, error) {
+	timeout := options.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	conn, err := net.DialTimeout("tcp", addr, timeout)
+	if err != nil {
+		return nil, err
+	}
+	return New(conn, options)
+}
+
+// New 在已经建立的连接上开始会话并等待第一个提示符，失败时关闭连接
+func New(conn net.Conn, options Options) (*Client, error) {
+	if options.Prompt == "" {
+		options.Prompt = DefaultPrompt
+	}
+	if options.Timeout <= 0 {
+		options.Timeout = DefaultTimeout
+	}
+	prompt, err := regexp.Compile(options.Prompt)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	c := &Client{
+		conn:    conn,
+		options: options,
+		prompt:  prompt,
+		writes:  make(chan []byte, 64),
+		done:    make(chan struct{}),
+	}
+	c.cond = sync.NewCond(&c.mu)
+	go c.readLoop()
+	go c.writeLoop()
+
+	if _, err := c.WaitPrompt(); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// Send 发送按键或文本，如 "show ver" + client.Tab；不等待输出
+func (c *Client) Send(keys string) error {
+	c.mu.Lock()
+	closed := c.closed
+	c.mu.Unlock()
+	if closed {
+		return ErrClosed
+	}
+	c.queue([]byte(keys))
+	return nil
+}
+
+// Expect 等待尚未读取的输出中出现与 pattern 匹配的内容，返回到匹配结束为止的输出，并将其标为已读
+func (c *Client) Expect(pattern string) (string, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return "", err
+	}
+	return c.expect(re)
+}
+
+// WaitPrompt 等待下一个提示符，返回提示符所在行之前的输出；命令的输出通常以换行结束。
+// 字符模式下编辑命令行时服务端会重画提示符，这期间的输出也可能匹配
+func (c *Client) WaitPrompt() (string, error) {
+	out, err := c.expect(c.prompt)
+	if err != nil {
+		return out, err
+	}
+	loc := c.prompt.FindStringIndex(out)
+	return out[:lastLineStart(out, loc[0])], nil
+}
+
+// Run 输入一行命令并等待下一个提示符，返回命令的输出：去掉回显的命令行和 \r，不包括提示符
+func (c *Client) Run(command string) (string, error) {
+	if err := c.Send(command + Enter); err != nil {
+		return "", err
+	}
+	// 字符模式下服务端回显输入的命令并在回车时换行，编辑期间重画的提示符不能当作命令结束
+	if !c.options.LineMode {
+		if _, err := c.expect(newline); err != nil {
+			return "", err
+		}
+	}
+	out, err := c.WaitPrompt()
+	return strings.ReplaceAll(out, "\r", ""), err
+}
+
+// Output 返回会话到目前为止输出的全部数据，包括已经读取的部分
+func (c *Client) Output() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return string(c.output)
+}
+
+// Close 断开连接，会话随之结束
+func (c *Client) Close() error {
+	err := c.conn.Close()
+	<-c.done
+	return err
+}
+
+// expect 等待尚未读取的输出与 re 匹配，超时或连接关闭时返回已有的输出和错误
+func (c *Client) expect(re *regexp.Regexp) (string, error) {
+	timer := time.AfterFunc(c.options.Timeout, func() {
+		c.mu.Lock()
+		c.cond.Broadcast()
+		c.mu.Unlock()
+	})
+	defer timer.Stop()
+	deadline := time.Now().Add(c.options.Timeout)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for {
+		pending := string(c.output[c.read:])
+		if loc := re.FindStringIndex(pending); loc != nil {
+			c.read += loc[1]
+			return pending[:loc[1]], nil
+		}
+		if c.closed {
+			return pending, ErrClosed
+		}
+		if !time.Now().Before(deadline) {
+			return pending, fmt.Errorf("client: timed out waiting for %q, got %q", re.String(), pending)
+		}
+		c.cond.Wait()
+	}
+}
+
+// lastLineStart 返回 out 中位置 i 所在行的开始位置
+func lastLineStart(out string, i int) int {
+	return strings.LastIndexAny(out[:i], "\r\n") + 1
+}
+
+// queue 交给写协程发送
+func (c *Client) queue(data []byte) {
+	select {
+	case c.writes <- data:
+	case <-c.done:
+	}
+}
+
+// writeLoop 依次发送数据，连接关闭后丢弃
+func (c *Client) writeLoop() {
+	for {
+		select {
+		case data := <-c.writes:
+			if _, err := c.conn.Write(data); err != nil {
+				return
+			}
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// readLoop 读取会话的输出，应答选项协商，数据字节追加到输出中
+func (c *Client) readLoop() {
+	defer close(c.done)
+	defer func() {
+		c.mu.Lock()
+		c.closed = true
+		c.cond.Broadcast()
+		c.mu.Unlock()
+	}()
+
+	var p parser
+	buf := make([]byte, 4096)
+	for {
+		n, err := c.conn.Read(buf)
+		if n > 0 {
+			data := p.feed(buf[:n], c.negotiate)
+			c.mu.Lock()
+			c.output = append(c.output, data...)
+			c.cond.Broadcast()
+			c.mu.Unlock()
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// negotiate 应答服务端的选项协商和子协商
+func (c *Client) negotiate(verb, opt byte, data []byte) {
+	charMode := !c.options.LineMode
+	switch verb {
+	case telnet.WILL:
+		if (opt == telnet.OptEcho || opt == telnet.OptSGA) && charMode {
+			c.queue([]byte{telnet.IAC, telnet.DO, opt})
+		} else {
+			c.queue([]byte{telnet.IAC, telnet.DONT, opt})
+		}
+	case telnet.DO:
+		switch {
+		case opt == telnet.OptSGA && charMode:
+			c.queue([]byte{telnet.IAC, telnet.WILL, opt})
+		case opt == telnet.OptNAWS && c.options.Width > 0 && c.options.Height > 0:
+			w, h := c.options.Width, c.options.Height
+			c.queue([]byte{telnet.IAC, telnet.WILL, opt})
+			c.queue(escapeIAC([]byte{telnet.IAC, telnet.SB, opt}, []byte{byte(w >> 8), byte(w), byte(h >> 8), byte(h)}))
+		case opt == telnet.OptTType && c.options.TerminalType != "":
+			c.queue([]byte{telnet.IAC, telnet.WILL, opt})
+		default:
+			c.queue([]byte{telnet.IAC, telnet.WONT, opt})
+		}
+	case telnet.SB:
+		if opt == telnet.OptTType && len(data) > 0 && data[0] == telnet.TTypeSend {
+			c.queue(escapeIAC([]byte{telnet.IAC, telnet.SB, opt, telnet.TTypeIs}, []byte(c.options.TerminalType)))
+		}
+	}
+}
+
+// escapeIAC 在子协商的开头之后追加数据并结束子协商，数据中的 IAC 加倍
+func escapeIAC(head, data []byte) []byte {
+	for _, b := range data {
+		head = append(head, b)
+		if b == telnet.IAC {
+			head = append(head, b)
+		}
+	}
+	return append(head, telnet.IAC, telnet.SE)
+}
+
+// parser 从会话的输出中分离 telnet 协议字节，状态跨越多次读取
+type parser struct {
+	state  int
+	verb   byte
+	sbOpt  byte
+	sbData []byte
+}
+
+// 解析状态
+const (
+	stateData = iota
+	stateIAC
+	stateVerb
+	stateSB
+	stateSBData
+	stateSBIAC
+)
+
+// feed 解析一段输出，返回其中的数据字节，协商和子协商交给 negotiate
+func (p *parser) feed(in []byte, negotiate func(verb, opt byte, data []byte)) []byte {
+	var out []byte
+	for _, b := range in {
+		switch p.state {
+		case stateData:
+			if b == telnet.IAC {
+				p.state = stateIAC
+			} else {
+				out = append(out, b)
+			}
+		case stateIAC:
+			switch b {
+			case telnet.IAC:
+				out = append(out, b)
+				p.state = stateData
+			case telnet.WILL, telnet.WONT, telnet.DO, telnet.DONT:
+				p.verb = b
+				p.state = stateVerb
+			case telnet.SB:
+				p.state = stateSB
+			default:
+				p.state = stateData
+			}
+		case stateVerb:
+			negotiate(p.verb, b, nil)
+			p.state = stateData
+		case stateSB:
+			p.sbOpt, p.sbData = b, nil
+			p.state = stateSBData
+		case stateSBData:
+			if b == telnet.IAC {
+				p.state = stateSBIAC
+			} else {
+				p.sbData = append(p.sbData, b)
+			}
+		case stateSBIAC:
+			switch b {
+			case telnet.SE:
+				negotiate(telnet.SB, p.sbOpt, p.sbData)
+				p.state = stateData
+			case telnet.IAC:
+				p.sbData = append(p.sbData, b)
+				p.state = stateSBData
+			default:
+				p.state = stateData
+			}
+		}
+	}
+	return out
+}
@@ -1,15 +1,21 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"log"
+	"net"
 	"os"
 	"os/signal"
 	"strings"
+	"sync"
 	"syscall"
+	"time"
 
 	"github.com/TrailHuang/tnlcmd"
+	"github.com/TrailHuang/tnlcmd/color"
+	"github.com/TrailHuang/tnlcmd/table"
 )
 
 func main() {
@@ -22,23 +28,49 @@ func main() {
 	cmdline.SetConfig("welcome", "Welcome to  CLI!\r\nType '?' for available commands.\r\n")
 	cmdline.SetConfig("maxhistory", "50")
 
+	// Tab 没有普通的补全结果时模糊匹配命令，如 shrc 补全为 show running-config
+	cmdline.SetConfig("fuzzy", "true")
+	cmdline.SetConfig("autocorrect", "true")
+
+	// 含有口令的命令不记入历史
+	cmdline.AddHistoryExclude("(?i)password")
+
+	// 提示符包含主机名和当前视图，如 test(configure)#
+	cmdline.SetConfig("prompttemplate", "{{.Hostname}}{{.ModeSuffix}}")
+
+	// PATH 参数限制在当前目录之内，Tab 补全列出其中的文件
+	cmdline.SetConfig("fileroot", ".")
+
+	// write memory 将 show running-config 的内容保存到该文件
+	cmdline.SetConfig("startupconfig", "startup-config")
+
+	// VRF 参数只接受已经定义的 VRF 名称
+	cmdline.RegisterValueProvider("VRF", vrfNames)
+
+	// IFNAME 参数只接受本机存在的网络接口
+	cmdline.RegisterInterfaceProvider(tnlcmd.SystemInterfaces)
+
 	// 注册根模式命令（特权EXEC模式）
 	rootCommands := []struct {
 		name, desc   string
 		detailedDesc string
 		handler      func([]string) string
 	}{
-		{"show running-config", "Show running system information", "show configuration\ndisplay running config", showHandler},
 		{"show config", "Show running system information", "show configuration\ndisplay system config", showHandler},
-		{"ping IP", "Send echo messages", "send echo\ntest connectivity", pingHandler},
+		{"show log [level (info|warn|error)] [last <1-1000>]", "Show system log", "Show running system information\nSystem log\nFilter by severity\nLog level\nShow the most recent entries\nNumber of entries", showLogHandler},
+		{"backup create name WORD [compress (on|off)] [target STRING]", "Create a configuration backup", "backup\ncreate backup", backupHandler},
+		{"show vrf VRF", "Show a VRF", "Show running system information\nVRF information\nVRF name", showVrfHandler},
+		{"copy PATH PATH", "Copy a file", "Copy from one file to another\nSource file\nDestination file", copyHandler(cmdline)},
 		{"clear test1", "Reset functions", "clear test\nreset test1", clearHandler},
 		{"clear test2", "Reset functions", "clear test\nreset test2", clearHandler},
+		{"clear counters {interface IFNAME | all}", "Clear counters", "", clearHandler},
 		{"debug", "Debugging functions", "debug mode\nenable debugging", debugHandler},
-		{"set debug <1-10>", "Debugging functions", "set debug level\nconfigure debug", setValueHandler},
-		{"set debug2 <1-10> (on|off)", "Debugging functions", "set debug2\nconfigure debug2", setValueHandler},
-		{"set debug info STRING", "Debugging functions", "set debug info\nconfigure debug info", setValueHandler},
+		{"set debug <1-10>", "Debugging functions", "Set system parameters\nDebugging functions\nDebug level", setValueHandler},
+		{"set debug2 <1-10> (on|off)", "Debugging functions", "Set system parameters\nDebugging functions\nDebug level\nEnable or disable debugging", setValueHandler},
+		{"set debug info STRING", "Debugging functions", "Set system parameters\nDebugging functions\nDebug information\nDebug message", setValueHandler},
 		{"set name STRING", "Debugging functions", "set name\nconfigure name", setValueHandler},
-		{"set filter-switch (on|off)", "Debugging functions", "set filter\nconfigure filter switch", setValueHandler},
+		{"set filter-switch (on:Enable the packet filter|off:Disable the packet filter)", "Debugging functions", "set filter\nconfigure filter switch", setValueHandler},
+		{"set register <0x0-0xFFFF> <0x0-0xFFFFFFFF>", "Write a device register", "Set system parameters\nWrite a device register\nRegister offset\nRegister value", setRegisterHandler},
 		{"set test [STRRING]", "Debugging functions", "set test\nconfigure test", setValueHandler},
 	}
 
@@ -50,6 +82,47 @@ func main() {
 		}
 	}
 
+	// ping 在所有视图中都可以使用，包括之后创建的配置视图
+	cmdline.RegisterGlobalHandler("ping A.B.C.D", "Send echo messages", pingHandler, "send echo\ntest connectivity")
+
+	// banner motd 逐行读取横幅内容，直到只包含分隔符的一行
+	cmdline.RegisterModeHandler("configure", "banner motd WORD", "Set the message of the day banner", bannerMotdHandler,
+		"define banner\nmessage of the day\ndelimiting character")
+
+	// show file 打开文件失败时返回带提示的结构化错误
+	cmdline.RegisterHandler("show file PATH", "Show the contents of a file", showFileHandler(cmdline), "Show running system information\nDisplay a file\nFile to display")
+
+	// show users 列出所有连接的会话
+	cmdline.RegisterHandler("show users", "Display information about terminal lines", showUsersHandler(cmdline))
+
+	// show interfaces 同时支持文本和 JSON 输出，如 show interfaces | json
+	cmdline.RegisterDataHandler("show interfaces", "Interface status and configuration", tnlcmd.DataHandler{
+		Data: showInterfacesData,
+		Text: showInterfacesText,
+	})
+
+	// show environment 只返回数据，文本输出由模板渲染，可以用 SetTemplate 替换
+	cmdline.RegisterDataHandler("show environment", "Display temperature and fan status", tnlcmd.DataHandler{
+		Data:     showEnvironmentData,
+		Template: environmentTemplate,
+	})
+
+	// 单独输入一个 IP 地址时执行 ping
+	cmdline.SetNotFoundHandler(pingUnknownHandler)
+
+	// monitor start 启动后台监视任务，用 show jobs 查看
+	cmdline.RegisterHandler("monitor start", "Start monitoring interface counters in the background", monitorStartHandler)
+
+	// reload 执行前要求确认
+	cmdline.RegisterHandler("reload", "Halt and perform a cold restart", reloadHandler)
+
+	// show config 保留兼容，提示改用 show running-config
+	cmdline.DeprecateCommand("show config", "show running-config")
+
+	// 隐藏的工厂调试命令：可以执行，但不出现在帮助和补全中
+	cmdline.RegisterHiddenCommand("factory reset", "Restore factory defaults", factoryResetHandler)
+	cmdline.RegisterHiddenCommand("show tech-support", "Show diagnostic information", techSupportHandler)
+
 	// 创建配置模式
 	cmdline.CreateMode("configure", "global configuration")
 
@@ -60,8 +133,11 @@ func main() {
 		handler          func([]string) string
 	}{
 		{"configure", "router PROTOCOL", "Enable a routing process", "enable routing\nconfigure routing protocol", routerHandler},
-		{"configure", "hostname HOSTNAME", "Set system's network name", "set hostname\nconfigure system hostname", hostnameHandler},
-		{"configure", "banner BANNER", "Define a login banner", "define banner\nconfigure login banner", bannerHandler},
+		{"configure", "vrf definition WORD", "Define a VRF", "VRF configuration\nDefine a new VRF\nVRF name", vrfDefinitionHandler},
+		{"configure", "banner LINE", "Define a login banner", "define banner\nconfigure login banner", bannerHandler},
+		{"configure", "ip route A.B.C.D/M A.B.C.D", "Establish static routes", "IP information\nstatic route\ndestination prefix", ipRouteHandler},
+		{"configure", "exec-timeout <duration>", "Set the EXEC timeout", "Set the EXEC timeout\nTimeout in seconds or with a unit, e.g. 300 or 10m", execTimeoutHandler},
+		{"configure", "logging buffered <size>", "Set buffered logging size", "Logging control\nBuffered logging\nBuffer size, e.g. 512k or 4M", loggingBufferedHandler},
 		{"configure", "set debug3 <1-10>", "Debugging functions", "", setValueHandler},
 		{"configure", "set debug4 <1-10> (on|off)", "Debugging functions", "", setValueHandler},
 		{"configure", "set debug info2 STRING", "Debugging functions", "", setValueHandler},
@@ -77,19 +153,28 @@ func main() {
 			cmdline.RegisterModeCommand(cmd.mode, cmd.name, cmd.desc, cmd.handler)
 		}
 	}
+	// help 按功能分组列出配置模式的命令
+	cmdline.SetModeHelp("configure", "Global configuration commands apply to the whole system.")
+	cmdline.AddCommandCategory("configure", "System", "hostname", "banner", "exec-timeout", "logging")
+	cmdline.AddCommandCategory("configure", "Routing", "router", "ip", "vrf")
+	cmdline.AddCommandCategory("configure", "Interfaces", "interface")
+
 	// 创建接口配置模式
 	cmdline.CreateMode("interface", "interface configuration")
 
+	// 子接口配置模式嵌套在接口配置模式之下，在 interface 视图中输入 sub-interface 进入
+	cmdline.CreateMode("interface/sub-interface", "sub-interface configuration")
+	// 子接口沿用接口配置模式的 ip、description、shutdown 等命令
+	cmdline.SetModeInherit("interface/sub-interface", true)
+
 	// 注册接口配置模式命令
 	interfaceCommands := []struct {
 		mode, name, desc string
 		detailedDesc     string
 		handler          func([]string) string
 	}{
-		{"interface", "ip IPADDR MASK", "Interface Internet Protocol config commands", "configure ip\nset interface ip address", ipHandler},
-		{"interface", "description TEXT", "Interface specific description", "set description\nconfigure interface description", descriptionHandler},
-		{"interface", "shutdown", "Shutdown the selected interface", "shutdown interface\ndisable interface", shutdownHandler},
-		{"interface", "no COMMAND", "Negate a command or set its defaults", "negate command\nundo configuration", noHandler},
+		{"interface", "switchport allowed vlan <1-4094>...", "Set allowed VLANs on the interface", "switchport\nallowed VLANs\nVLAN list", vlanHandler},
+		{"interface/sub-interface", "encapsulation dot1q <1-4094>", "Set the 802.1Q VLAN of the sub-interface", "Set encapsulation type\nIEEE 802.1Q VLAN tagging\nVLAN ID", encapsulationHandler},
 	}
 
 	for _, cmd := range interfaceCommands {
@@ -100,22 +185,53 @@ func main() {
 		}
 	}
 
-	// 启动命令行服务
-	err := cmdline.Start()
+	// 可否定命令：同时注册 "no" 形式，如 no shutdown、no ip A.B.C.D A.B.C.D
+	negatableCommands := []struct {
+		mode, name, desc string
+		detailedDesc     string
+		handler          func([]string, bool) string
+	}{
+		{"configure", "hostname HOSTNAME", "Set system's network name", "Set system's network name\nThis system's network name", hostnameHandler(cmdline)},
+		{"interface", "ip A.B.C.D A.B.C.D", "Interface Internet Protocol config commands", "Interface Internet Protocol config commands\nIP address\nSubnet mask", ipHandler},
+		{"interface", "description LINE", "Interface specific description", "Interface specific description\nCharacters describing this interface", descriptionHandler},
+		{"interface", "shutdown", "Shutdown the selected interface", "Shutdown the selected interface", shutdownHandler},
+	}
+
+	for _, cmd := range negatableCommands {
+		cmdline.RegisterNegatableModeCommand(cmd.mode, cmd.name, cmd.desc, cmd.handler, cmd.detailedDesc)
+	}
+
+	// show running-config 由全局配置视图的渲染函数生成
+	cmdline.RegisterConfigRenderer("configure", globalConfigRenderer(cmdline))
+
+	// 启动命令行服务，收到中断信号时停止
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	err := cmdline.StartContext(ctx)
 	if err != nil {
 		log.Fatalf("Failed to start cmdline: %v", err)
 	}
 
 	fmt.Printf("Zebra-style CLI started on port %d\n", config.Port)
 
-	// 等待中断信号
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	// 模拟接口状态变化，执行了 terminal monitor 的会话会收到日志
+	go func() {
+		up := false
+		for range time.Tick(30 * time.Second) {
+			up = !up
+			state := color.Red("down")
+			if up {
+				state = color.Green("up")
+			}
+			cmdline.Notifyf("%s: %%LINK-3-UPDOWN: Interface eth1, changed state to %s", time.Now().Format("Jan 2 15:04:05"), state)
+		}
+	}()
 
-	<-sigChan
+	// 等待中断信号，取消 ctx 时命令行服务随之停止
+	<-ctx.Done()
 	fmt.Println("\nShutting down...")
 
-	// 停止命令行服务
+	// 等待停止完成，包括最后一次自动保存
 	cmdline.Stop()
 
 	fmt.Println("Zebra-style CLI stopped")
@@ -152,27 +268,85 @@ func showHandler(args []string) string {
 	return result.String()
 }
 
+// vrfs 已定义的 VRF 名称
+var (
+	vrfMu sync.Mutex
+	vrfs  []string
+)
+
+func vrfNames() []string {
+	vrfMu.Lock()
+	defer vrfMu.Unlock()
+	return append([]string(nil), vrfs...)
+}
+
+func showVrfHandler(args []string) string {
+	return fmt.Sprintf("VRF %s\r\n  Interfaces: none\r\n", args[0])
+}
+
+func showLogHandler(args []string) string {
+	return fmt.Sprintf("Log entries (level: %s, last: %s)\r\n", args[0], args[1])
+}
+
+func backupHandler(args []string) string {
+	return fmt.Sprintf("Backup %s created\r\n", args[0])
+}
+
 func setValueHandler(args []string) string {
-	if len(args) == 0 {
+	if len(args) == 0 || args[0] == "" {
 		return "Usage: set <parameter> <value>\r\n"
 	}
 
 	return fmt.Sprintf("Set %s to %s\r\n", args[0], strings.Join(args[1:], " "))
 }
 
-func pingHandler(args []string) string {
+func setRegisterHandler(args []string) string {
+	var reg struct {
+		Offset uint16
+		Value  uint32
+	}
+	if err := tnlcmd.Bind(args, &reg); err != nil {
+		return fmt.Sprintf("%% %v\r\n", err)
+	}
+	return fmt.Sprintf("Register 0x%04X set to 0x%08X\r\n", reg.Offset, reg.Value)
+}
+
+// pingHandler 每秒发送一个回显请求，共 5 个，会话断开时提前结束
+func pingHandler(ctx *tnlcmd.Ctx) error {
 	target := "8.8.8.8"
-	if len(args) > 0 {
-		target = args[0]
+	if len(ctx.Args) > 0 {
+		target = ctx.Args[0]
+	}
+
+	fmt.Fprintf(ctx.Writer, "PING %s: 64 data bytes\n", target)
+	sent := 0
+	for seq := 0; seq < 5; seq++ {
+		if seq > 0 {
+			select {
+			case <-ctx.Context.Done():
+				return pingStatistics(ctx.Writer, target, sent)
+			case <-time.After(time.Second):
+			}
+		}
+		sent++
+		fmt.Fprintf(ctx.Writer, "64 bytes from %s: icmp_seq=%d ttl=57 time=25.3 ms\n", target, seq)
 	}
+	return pingStatistics(ctx.Writer, target, sent)
+}
 
-	output := fmt.Sprintf("PING %s: 64 data bytes\r\n"+
-		"64 bytes from 8.8.8.8: icmp_seq=0 ttl=57 time=25.3 ms\r\n"+
-		"64 bytes from 8.8.8.8: icmp_seq=1 ttl=57 time=24.8 ms\r\n"+
-		"--- 8.8.8.8 ping statistics ---\r\n"+
-		"2 packets transmitted, 2 packets received, 0%% packet loss\r\n", target)
+// pingUnknownHandler 无法识别的输入只有一个 IP 地址时当作 ping 执行
+func pingUnknownHandler(ctx *tnlcmd.Ctx, line string) (bool, error) {
+	if len(ctx.Args) != 1 || net.ParseIP(ctx.Args[0]) == nil {
+		return false, nil
+	}
+	return true, pingHandler(ctx)
+}
 
-	return output
+// pingStatistics 输出 ping 的统计信息
+func pingStatistics(w io.Writer, target string, sent int) error {
+	_, err := fmt.Fprintf(w, "--- %s ping statistics ---\n"+
+		"%d packets transmitted, %d packets received, 0%% packet loss\n", target, sent, sent)
+	return err
 }
 
 func clearHandler(args []string) string {
@@ -193,6 +367,68 @@ func interfaceHandler(args []string, writer io.Writer) error {
 	return nil
 }
 
+func vrfDefinitionHandler(args []string) string {
+	vrfMu.Lock()
+	defer vrfMu.Unlock()
+	for _, name := range vrfs {
+		if name == args[0] {
+			return ""
+		}
+	}
+	vrfs = append(vrfs, args[0])
+	return ""
+}
+
+// globalConfigRenderer 返回全局配置视图的渲染函数，输出主机名和已定义的 VRF
+func globalConfigRenderer(cmdline *tnlcmd.CmdLine) tnlcmd.ConfigRenderer {
+	return func(parents []string) []tnlcmd.ConfigSection {
+		var lines []string
+		if hostname := cmdline.Hostname(); hostname != "" {
+			lines = append(lines, "hostname "+hostname)
+		}
+		for _, name := range vrfNames() {
+			lines = append(lines, "vrf definition "+name)
+		}
+		return []tnlcmd.ConfigSection{{Lines: lines}}
+	}
+}
+
+// monitorStartHandler 启动后台任务，每 5 秒记录一次接口计数，直到执行 kill job
+func monitorStartHandler(ctx *tnlcmd.Ctx) error {
+	id := ctx.Session.StartJob("monitor", func(job *tnlcmd.Ctx) error {
+		ticker := time.NewTicker(5 * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-job.Context.Done():
+				return job.Context.Err()
+			case now := <-ticker.C:
+				fmt.Fprintf(job.Writer, "%s eth0 rx 1024 pkts, tx 768 pkts\n", now.Format("15:04:05"))
+			}
+		}
+	})
+	fmt.Fprintf(ctx.Writer, "Monitor started as job %d\n", id)
+	return nil
+}
+
+// reloadHandler 确认后重启设备
+func reloadHandler(ctx *tnlcmd.Ctx) error {
+	if !ctx.Confirm("Proceed with reload? [y/N] ") {
+		fmt.Fprint(ctx.Writer, "Reload cancelled\n")
+		return nil
+	}
+	fmt.Fprint(ctx.Writer, "Reloading...\n")
+	return nil
+}
+
+func factoryResetHandler(args []string) string {
+	return "Factory defaults restored\r\n"
+}
+
+func techSupportHandler(args []string) string {
+	return "Tech-support information:\r\n  Goroutines: ok\r\n  Sessions: ok\r\n"
+}
+
 func routerHandler(args []string) string {
 	if len(args) == 0 {
 		return "Usage: router <protocol>\r\n"
@@ -200,42 +436,197 @@ func routerHandler(args []string) string {
 	return fmt.Sprintf("Enabling %s routing\r\n", args[0])
 }
 
-func hostnameHandler(args []string) string {
-	if len(args) == 0 {
-		return "Usage: hostname <name>\r\n"
+func showFileHandler(cmdline *tnlcmd.CmdLine) tnlcmd.HandlerFunc {
+	return func(ctx *tnlcmd.Ctx) error {
+		// PATH 参数是相对于 fileroot 的路径，需要转换为服务器上的路径
+		path := ctx.Param("PATH")
+		name, err := cmdline.ResolvePath(path)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(name)
+		if err != nil {
+			return &tnlcmd.Error{
+				Code:    3,
+				Message: fmt.Sprintf("Cannot open %s", path),
+				Hint:    "Paths are relative to the file root, press Tab to list files",
+			}
+		}
+		_, err = ctx.Writer.Write(data)
+		return err
+	}
+}
+
+func copyHandler(cmdline *tnlcmd.CmdLine) func([]string) string {
+	return func(args []string) string {
+		src, err := cmdline.ResolvePath(args[0])
+		if err != nil {
+			return fmt.Sprintf("%% %v\r\n", err)
+		}
+		dst, err := cmdline.ResolvePath(args[1])
+		if err != nil {
+			return fmt.Sprintf("%% %v\r\n", err)
+		}
+		data, err := os.ReadFile(src)
+		if err != nil {
+			return fmt.Sprintf("%% Cannot open %s\r\n", args[0])
+		}
+		if err := os.WriteFile(dst, data, 0644); err != nil {
+			return fmt.Sprintf("%% Cannot write %s\r\n", args[1])
+		}
+		return fmt.Sprintf("%d bytes copied to %s\r\n", len(data), args[1])
+	}
+}
+
+func ipRouteHandler(args []string) string {
+	prefix, err := tnlcmd.ParsePrefix(args[0])
+	if err != nil {
+		return fmt.Sprintf("%% %v\r\n", err)
+	}
+	return fmt.Sprintf("Static route %s via %s added\r\n", prefix, args[1])
+}
+
+func execTimeoutHandler(args []string) string {
+	// <duration> 参数已经转换为 time.Duration 的标准格式
+	timeout, err := tnlcmd.ParseDuration(args[0])
+	if err != nil {
+		return fmt.Sprintf("%% %v\r\n", err)
+	}
+	return fmt.Sprintf("EXEC timeout set to %v (%d seconds)\r\n", timeout, int(timeout.Seconds()))
+}
+
+func loggingBufferedHandler(args []string) string {
+	// <size> 参数已经换算为字节数
+	return fmt.Sprintf("Logging buffer set to %s bytes\r\n", args[0])
+}
+
+// showUsersHandler 返回 show users 命令的处理函数，当前会话以 * 标出
+func showUsersHandler(cmdline *tnlcmd.CmdLine) tnlcmd.HandlerFunc {
+	return func(ctx *tnlcmd.Ctx) error {
+		t := table.New("", color.Highlight("User"), color.Highlight("Host"), color.Highlight("Terminal"), color.Highlight("Login"), color.Highlight("Mode"))
+		for _, sess := range cmdline.Sessions() {
+			mark := " "
+			if sess.RemoteAddr() == ctx.Session.RemoteAddr() {
+				mark = "*"
+			}
+			user := sess.Username()
+			if user == "" {
+				user = "-"
+			}
+			mode := sess.CurrentMode()
+			if mode == "" {
+				mode = "exec"
+			}
+			width, height := sess.TerminalSize()
+			t.AddRow(mark, user, sess.RemoteAddr(), fmt.Sprintf("%dx%d", width, height), sess.LoginTime().Format("15:04:05"), mode)
+		}
+		return t.Print(ctx)
+	}
+}
+
+// interfaceStatus show interfaces 输出的一个接口
+type interfaceStatus struct {
+	Name        string `json:"name"`
+	Status      string `json:"status"`
+	Address     string `json:"address"`
+	Description string `json:"description,omitempty"`
+}
+
+// showInterfacesData 返回接口状态
+func showInterfacesData(ctx *tnlcmd.Ctx) (interface{}, error) {
+	return []interfaceStatus{
+		{Name: "eth0", Status: "up", Address: "192.168.1.1/24", Description: "uplink to core"},
+		{Name: "eth1", Status: "down", Address: "0.0.0.0/0"},
+	}, nil
+}
+
+// showInterfacesText 将接口状态排列为表格
+func showInterfacesText(w io.Writer, data interface{}) error {
+	t := table.New("Interface", "Status", "Address", "Description")
+	for _, ifc := range data.([]interfaceStatus) {
+		t.AddRow(ifc.Name, ifc.Status, ifc.Address, ifc.Description)
+	}
+	return t.Render(w, 0)
+}
+
+// sensor show environment 输出的一个传感器
+type sensor struct {
+	Name   string `json:"name"`
+	Value  int    `json:"value"`
+	Unit   string `json:"unit"`
+	Status string `json:"status"`
+}
+
+// environmentTemplate show environment 的默认输出模板
+const environmentTemplate = `{{range .}}{{pad 12 .Name}} {{pad 8 (printf "%d %s" .Value .Unit)}} {{upper .Status}}
+{{end}}`
+
+// showEnvironmentData 返回传感器读数
+func showEnvironmentData(ctx *tnlcmd.Ctx) (interface{}, error) {
+	return []sensor{
+		{Name: "inlet", Value: 31, Unit: "C", Status: "ok"},
+		{Name: "cpu", Value: 58, Unit: "C", Status: "ok"},
+		{Name: "fan1", Value: 4200, Unit: "rpm", Status: "ok"},
+	}, nil
+}
+
+// hostnameHandler 返回 hostname 命令的处理函数，修改所有会话提示符中的主机名
+func hostnameHandler(cmdline *tnlcmd.CmdLine) tnlcmd.NegatableHandler {
+	return func(args []string, negate bool) string {
+		if negate {
+			cmdline.SetHostname("")
+			return "Hostname reset to default\r\n"
+		}
+		cmdline.SetHostname(args[0])
+		return ""
 	}
-	return fmt.Sprintf("Hostname set to %s\r\n", args[0])
 }
 
 func bannerHandler(args []string) string {
 	if len(args) == 0 {
 		return "Usage: banner <message>\r\n"
 	}
-	return "Banner set\r\n"
+	return fmt.Sprintf("Banner set to \"%s\"\r\n", args[0])
+}
+
+// bannerMotdHandler 读取多行横幅，以命令中给出的分隔符结束
+func bannerMotdHandler(ctx *tnlcmd.Ctx) error {
+	delimiter := ctx.Args[0]
+	fmt.Fprintf(ctx.Writer, "Enter TEXT message.  End with the character '%s'.\n", delimiter)
+	lines, err := ctx.ReadUntil("", delimiter)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(ctx.Writer, "Banner set (%d lines)\n", len(lines))
+	return nil
 }
 
 // 接口配置模式命令处理函数
-func ipHandler(args []string) string {
-	if len(args) < 2 {
-		return "Usage: ip address <ip-address> <subnet-mask>\r\n"
+func ipHandler(args []string, negate bool) string {
+	if negate {
+		return "IP address removed\r\n"
 	}
 	return fmt.Sprintf("IP address %s/%s configured\r\n", args[0], args[1])
 }
 
-func descriptionHandler(args []string) string {
-	if len(args) == 0 {
-		return "Usage: description <text>\r\n"
+func descriptionHandler(args []string, negate bool) string {
+	if negate {
+		return "Description removed\r\n"
 	}
 	return "Description set\r\n"
 }
 
-func shutdownHandler(args []string) string {
-	return "Interface shutdown\r\n"
+func vlanHandler(args []string) string {
+	return fmt.Sprintf("Allowed VLANs: %s\r\n", strings.Join(args, ","))
 }
 
-func noHandler(args []string) string {
-	if len(args) == 0 {
-		return "Usage: no <command>\r\n"
+func encapsulationHandler(args []string) string {
+	return fmt.Sprintf("Encapsulation 802.1Q VLAN %s\r\n", args[0])
+}
+
+func shutdownHandler(args []string, negate bool) string {
+	if negate {
+		return "Interface enabled\r\n"
 	}
-	return fmt.Sprintf("Command '%s' negated\r\n", strings.Join(args, " "))
+	return "Interface shutdown\r\n"
 }
@@ -0,0 +1,84 @@
+// Package color 生成带 ANSI 颜色和样式的文本
+// 处理函数总是可以使用这些函数，终端不支持颜色或用户执行了 terminal no color 时会话在输出前去掉控制序列
+package color
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/TrailHuang/tnlcmd/internal/textwidth"
+)
+
+// Style ANSI SGR 样式代码
+type Style string
+
+// 常用的样式
+const (
+	Bold      Style = "1"
+	Dim       Style = "2"
+	Underline Style = "4"
+	Reverse   Style = "7"
+
+	FgBlack   Style = "30"
+	FgRed     Style = "31"
+	FgGreen   Style = "32"
+	FgYellow  Style = "33"
+	FgBlue    Style = "34"
+	FgMagenta Style = "35"
+	FgCyan    Style = "36"
+	FgWhite   Style = "37"
+
+	BgRed    Style = "41"
+	BgGreen  Style = "42"
+	BgYellow Style = "43"
+	BgBlue   Style = "44"
+)
+
+// reset 恢复默认样式的控制序列
+const reset = "\x1b[0m"
+
+// sequence 匹配 ANSI CSI 控制序列，如 \x1b[1;31m
+var sequence = regexp.MustCompile("\x1b\\[[0-9;?]*[ -/]*[@-~]")
+
+// Paint 返回以 styles 显示的 text，没有指定样式时原样返回
+func Paint(text string, styles ...Style) string {
+	if len(styles) == 0 || text == "" {
+		return text
+	}
+	codes := make([]string, len(styles))
+	for i, style := range styles {
+		codes[i] = string(style)
+	}
+	return "\x1b[" + strings.Join(codes, ";") + "m" + text + reset
+}
+
+// Red 红色文本，用于错误、down 状态等
+func Red(text string) string { return Paint(text, FgRed) }
+
+// Green 绿色文本，用于正常、up 状态等
+func Green(text string) string { return Paint(text, FgGreen) }
+
+// Yellow 黄色文本，用于警告
+func Yellow(text string) string { return Paint(text, FgYellow) }
+
+// Blue 蓝色文本
+func Blue(text string) string { return Paint(text, FgBlue) }
+
+// Cyan 青色文本
+func Cyan(text string) string { return Paint(text, FgCyan) }
+
+// Highlight 粗体文本，用于表头、关键字等
+func Highlight(text string) string { return Paint(text, Bold) }
+
+// Strip 去掉文本中的 ANSI 控制序列
+func Strip(text string) string {
+	if !strings.Contains(text, "\x1b[") {
+		return text
+	}
+	return sequence.ReplaceAllString(text, "")
+}
+
+// Len 返回去掉控制序列后文本的显示宽度，中文等全角字符占两列，用于对齐带颜色的文本
+func Len(text string) int {
+	return textwidth.Width(Strip(text))
+}
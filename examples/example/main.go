@@ -1,9 +1,9 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
-	"os"
 	"os/signal"
 	"strings"
 	"syscall"
@@ -43,8 +43,10 @@ func main() {
 		cmdline.RegisterCommand(cmd.name, cmd.desc, cmd.handler)
 	}
 
-	// 启动命令行服务
-	err := cmdline.Start()
+	// 启动命令行服务，收到中断信号时停止
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	err := cmdline.StartContext(ctx)
 	if err != nil {
 		log.Fatalf("Failed to start cmdline: %v", err)
 	}
@@ -52,14 +54,11 @@ func main() {
 	fmt.Printf("Command line interface started on port %d\n", config.Port)
 	fmt.Println("Connect with: telnet localhost 2324")
 
-	// 等待中断信号
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
-
-	<-sigChan
+	// 等待中断信号，取消 ctx 时命令行服务随之停止
+	<-ctx.Done()
 	fmt.Println("\nShutting down...")
 
-	// 停止命令行服务
+	// 等待停止完成，包括最后一次自动保存
 	cmdline.Stop()
 
 	fmt.Println("Command line interface stopped")
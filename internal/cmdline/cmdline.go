@@ -1,13 +1,26 @@
 package cmdline
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"slices"
 	"strconv"
+	"strings"
 	"sync"
+	"text/template"
+	"time"
 
 	"github.com/TrailHuang/tnlcmd/internal/commandtree"
 	"github.com/TrailHuang/tnlcmd/internal/mode"
+	"github.com/TrailHuang/tnlcmd/internal/runconfig"
 	"github.com/TrailHuang/tnlcmd/internal/server"
+	"github.com/TrailHuang/tnlcmd/internal/session"
+	"github.com/TrailHuang/tnlcmd/internal/settings"
 	"github.com/TrailHuang/tnlcmd/pkg/types"
 )
 
@@ -22,24 +35,39 @@ type Config = types.Config
 
 // CmdLine 命令行接口
 type CmdLine struct {
-	config      *Config
 	commands    map[string]CommandInfo   // 向后兼容的平面命令存储
 	commandTree *commandtree.CommandTree // 新的树形命令存储
 	mu          sync.RWMutex
 	server      *server.TelnetServer
+	serverMu    sync.Mutex // 保证服务器只创建一次，见 prepareServer
+	builtins    bool       // 已经注册了内置命令，停止后重新启动时不再注册，由 serverMu 保护
 	isRunning   bool
+	stopped     chan struct{} // 本次启动的服务停止时关闭，见 StartContext
+	stopMu      sync.Mutex    // 停止服务期间持有，Stop 等待正在进行的停止完成
 	rootMode    *mode.CommandMode
 	context     *mode.CommandContext
+	shared      *session.Shared      // 所有会话共用的状态，包括提交和历史配置
+	autoSave    *runconfig.AutoSaver // 没有开启自动保存时为 nil
+	globals     []globalCommand      // 所有视图共有的命令，新建的视图也会注册这些命令
 }
 
-// NewCmdLine 创建新的命令行接口
+// globalCommand RegisterGlobalCommand 注册的命令
+type globalCommand struct {
+	name                string
+	description         string
+	handler             types.Handler
+	detailedDescription []string
+}
+
+// NewCmdLine 创建新的命令行接口，使用 config 的副本，之后用 SetConfig 等方法修改配置
 func NewCmdLine(config *Config) *CmdLine {
 	if config == nil {
 		config = &Config{
-			Prompt:     "cmdline> ",
-			Port:       2323,
-			WelcomeMsg: "Welcome to Command Line Interface!\r\nType '?' for available commands.\r\n",
-			MaxHistory: 100,
+			Prompt:      "cmdline> ",
+			Port:        2323,
+			WelcomeMsg:  "Welcome to Command Line Interface!\r\nType '?' for available commands.\r\n",
+			MaxHistory:  100,
+			ArchiveSize: 10,
 		}
 	}
 
@@ -49,8 +77,9 @@ func NewCmdLine(config *Config) *CmdLine {
 	// 设置配置的根模式
 	config.RootMode = rootMode
 
-	// 创建命令树
+	// 创建命令树，与所有视图的命令树共用根视图的注册表
 	commandTree := commandtree.NewCommandTree()
+	commandTree.SetRegistry(rootMode.CommandTree.Registry())
 
 	// 创建命令上下文
 	context := &mode.CommandContext{
@@ -58,22 +87,108 @@ func NewCmdLine(config *Config) *CmdLine {
 		Path:        []string{},
 	}
 
-	return &CmdLine{
-		config:      config,
+	c := &CmdLine{
 		commands:    make(map[string]CommandInfo),
 		commandTree: commandTree,
 		rootMode:    rootMode,
 		context:     context,
+		shared:      session.NewShared(settings.New(config), rootMode),
 	}
+	c.applyStrict()
+	return c
+}
+
+// config 返回当前配置的快照，不能修改
+func (c *CmdLine) config() *Config {
+	return c.shared.Settings.Load()
+}
+
+// updateConfig 修改配置，会话在下一次读取配置时得到修改后的配置
+func (c *CmdLine) updateConfig(fn func(config *Config)) {
+	c.shared.Settings.Update(fn)
+}
+
+// lockRegistry 获取 CmdLine 和命令注册表的写锁，修改期间运行中的会话不会读取命令树
+func (c *CmdLine) lockRegistry() {
+	c.mu.Lock()
+	c.shared.Registry.Lock()
+}
+
+// unlockRegistry 更新视图继承的命令，然后释放 lockRegistry 获取的锁
+func (c *CmdLine) unlockRegistry() {
+	c.syncInheritance()
+	c.shared.Registry.Unlock()
+	c.mu.Unlock()
 }
 
 // RegisterCommand 注册命令到根模式
 func (c *CmdLine) RegisterCommand(name, description string, handler CommandHandler, detailedDescription ...string) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+	c.registerCommand(name, description, handler, detailedDescription...)
+}
+
+// RegisterHandler 注册使用标准处理函数的命令到根模式
+func (c *CmdLine) RegisterHandler(name, description string, handler types.HandlerFunc, detailedDescription ...string) {
+	c.registerCommand(name, description, handler, detailedDescription...)
+}
+
+// RegisterDataHandler 注册同时支持文本和 JSON 输出的命令到根模式
+func (c *CmdLine) RegisterDataHandler(name, description string, handler types.DataHandler, detailedDescription ...string) {
+	if !validDataHandler(name, handler) {
+		return
+	}
+	c.registerCommand(name, description, handler, detailedDescription...)
+}
+
+// validDataHandler 检查输出模板能否解析，不能解析时打印错误
+func validDataHandler(name string, handler types.DataHandler) bool {
+	if handler.Template == "" {
+		return true
+	}
+	if _, err := types.ParseTemplate(handler.Template); err != nil {
+		fmt.Printf("Error: Failed to register command %s: %v\n", name, err)
+		return false
+	}
+	return true
+}
+
+// SetTemplate 替换根模式中用 RegisterDataHandler 注册的命令的输出模板
+func (c *CmdLine) SetTemplate(name, text string) error {
+	c.lockRegistry()
+	defer c.unlockRegistry()
+
+	if err := c.rootMode.SetTemplate(name, text); err != nil {
+		return err
+	}
+	return c.commandTree.SetTemplate(name, text)
+}
+
+// SetModeTemplate 替换指定模式中用 RegisterModeDataHandler 注册的命令的输出模板
+func (c *CmdLine) SetModeTemplate(modePath string, name, text string) error {
+	c.lockRegistry()
+	defer c.unlockRegistry()
+
+	currentMode := c.findOrCreateMode(modePath, "")
+	if currentMode == nil {
+		return fmt.Errorf("mode not found: %s", modePath)
+	}
+	return currentMode.SetTemplate(name, text)
+}
+
+// registerCommand 注册命令到根模式
+func (c *CmdLine) registerCommand(name, description string, handler types.Handler, detailedDescription ...string) {
+	c.lockRegistry()
+	defer c.unlockRegistry()
+
+	if replacedBuiltin(c.rootMode, name, handler) {
+		return
+	}
 
 	// 向后兼容：添加到平面命令存储
-	c.rootMode.AddCommand(name, description, handler)
+	c.warnConflict(c.rootMode, name)
+	if err := c.rootMode.AddCommand(name, description, handler, detailedDescription...); err != nil {
+		fmt.Printf("Error: Failed to register command: %v\n", err)
+		return
+	}
 
 	// 新功能：添加到命令树
 	err := c.commandTree.AddCommand(name, description, handler, detailedDescription...)
@@ -82,50 +197,489 @@ func (c *CmdLine) RegisterCommand(name, description string, handler CommandHandl
 	}
 }
 
-// findOrCreateMode 查找或创建模式路径
+// RegisterGlobalCommand 注册在所有视图中都可以使用的命令，包括之后创建的视图
+func (c *CmdLine) RegisterGlobalCommand(name, description string, handler CommandHandler, detailedDescription ...string) {
+	c.registerGlobalCommand(name, description, handler, detailedDescription...)
+}
+
+// RegisterGlobalHandler 注册使用标准处理函数的全局命令
+func (c *CmdLine) RegisterGlobalHandler(name, description string, handler types.HandlerFunc, detailedDescription ...string) {
+	c.registerGlobalCommand(name, description, handler, detailedDescription...)
+}
+
+// registerGlobalCommand 注册在所有视图中都可以使用的命令
+func (c *CmdLine) registerGlobalCommand(name, description string, handler types.Handler, detailedDescription ...string) {
+	c.lockRegistry()
+	defer c.unlockRegistry()
+
+	cmd := globalCommand{
+		name:                name,
+		description:         description,
+		handler:             handler,
+		detailedDescription: detailedDescription,
+	}
+	c.globals = append(c.globals, cmd)
+	for _, m := range c.sortedModes() {
+		c.addGlobalCommand(m, cmd)
+	}
+
+	if _, builtin := handler.(commandtree.BuiltinHandler); builtin && c.commandTree.HasApplicationCommand(name) {
+		return
+	}
+	if err := c.commandTree.AddCommand(name, description, handler, detailedDescription...); err != nil {
+		fmt.Printf("Warning: Failed to add command to tree: %v\n", err)
+	}
+}
+
+// addGlobalCommand 将全局命令注册到视图，调用者需持有注册表写锁
+func (c *CmdLine) addGlobalCommand(m *mode.CommandMode, cmd globalCommand) {
+	if replacedBuiltin(m, cmd.name, cmd.handler) {
+		return
+	}
+	c.warnConflict(m, cmd.name)
+	if err := m.AddCommand(cmd.name, cmd.description, cmd.handler, cmd.detailedDescription...); err != nil {
+		fmt.Printf("Error: Failed to register command in mode %s: %v\n", modeName(m), err)
+		return
+	}
+	// 全局命令不修改视图的配置
+	_ = m.MarkOperational(cmd.name)
+}
+
+// RegisterHiddenCommand 注册隐藏命令到根模式
+func (c *CmdLine) RegisterHiddenCommand(name, description string, handler CommandHandler, detailedDescription ...string) {
+	c.lockRegistry()
+	defer c.unlockRegistry()
+
+	c.warnConflict(c.rootMode, name)
+	if err := c.rootMode.AddHiddenCommand(name, description, handler, detailedDescription...); err != nil {
+		fmt.Printf("Error: Failed to register command: %v\n", err)
+		return
+	}
+
+	err := c.commandTree.AddCommand(name, description, handler, detailedDescription...)
+	if err == nil {
+		err = c.commandTree.HideCommand(name)
+	}
+	if err != nil {
+		fmt.Printf("Warning: Failed to add command to tree: %v\n", err)
+	}
+}
+
+// RegisterNegatableCommand 注册可否定命令到根模式，同时注册 "no" 形式，两者调用同一个处理函数
+func (c *CmdLine) RegisterNegatableCommand(name, description string, handler types.NegatableHandler, detailedDescription ...string) {
+	c.RegisterCommand(name, description, func(args []string) string {
+		return handler(args, false)
+	}, detailedDescription...)
+	c.RegisterCommand(commandtree.NegatedCommand(name, true), description, func(args []string) string {
+		return handler(args, true)
+	}, commandtree.NegatedHelp(detailedDescription)...)
+}
+
+// RegisterNegation 注册根模式命令的否定处理函数，name 为 "no" 之后的命令规格
+func (c *CmdLine) RegisterNegation(name, description string, handler CommandHandler, detailedDescription ...string) {
+	c.RegisterCommand(commandtree.NegatedCommand(name, false), description, handler, commandtree.NegatedHelp(detailedDescription)...)
+}
+
+// warnConflict 非严格模式下，命令与模式中已注册的命令冲突时打印警告
+// 严格模式下由命令树拒绝注册
+func (c *CmdLine) warnConflict(m *mode.CommandMode, name string) {
+	if c.config().StrictRegistration {
+		return
+	}
+	if err := m.CommandTree.CheckCommand(name); err != nil {
+		fmt.Printf("Warning: %v\n", err)
+	}
+}
+
+// replacedBuiltin 检查内置命令是否已经由应用在视图中注册为自己的命令，如自定义的 help，这时不注册内置命令
+func replacedBuiltin(m *mode.CommandMode, name string, handler types.Handler) bool {
+	_, builtin := handler.(commandtree.BuiltinHandler)
+	return builtin && m.CommandTree.HasApplicationCommand(name)
+}
+
+// findOrCreateMode 查找或创建模式路径，路径中各级视图以 / 分隔，如 configure/interface
+// 路径中不存在的视图逐级创建，description 用于最后一级视图，为空时使用 "<视图名称> configuration"
 func (c *CmdLine) findOrCreateMode(modePath string, description string) *mode.CommandMode {
 	currentMode := c.rootMode
 	if modePath == "" {
 		return currentMode
 	}
 
-	modeName := modePath
-	if subMode, exists := currentMode.Children[modeName]; exists {
-		return subMode
+	names := strings.Split(modePath, mode.PathSeparator)
+	for i, modeName := range names {
+		if subMode, exists := currentMode.Children[modeName]; exists {
+			currentMode = subMode
+			continue
+		}
+
+		modeDescription := fmt.Sprintf("%s configuration", modeName)
+		if i == len(names)-1 && description != "" {
+			modeDescription = description
+		}
+		currentMode = c.createSubMode(currentMode, modeName, modeDescription)
+		if currentMode == nil {
+			return nil
+		}
+	}
+	return currentMode
+}
+
+// createSubMode 在 parent 下创建子视图
+// 根视图的子视图可以在任意视图中进入，更深的子视图只能在上一级视图中进入
+func (c *CmdLine) createSubMode(parent *mode.CommandMode, modeName, description string) *mode.CommandMode {
+	// 视图名称与上一级视图的关键字相同时，单独输入该关键字会切换视图
+	if err := parent.CommandTree.CheckModeName(modeName); err != nil {
+		if c.config().StrictRegistration {
+			fmt.Printf("Error: Failed to create mode: %v\n", err)
+			return nil
+		}
+		fmt.Printf("Warning: %v\n", err)
 	}
 
-	// 创建新的子模式
+	// 嵌套视图的提示符包含各级视图名称，如 configure-interface#
 	prompt := modeName
+	if parent != c.rootMode {
+		prompt = strings.ReplaceAll(parent.Path(), mode.PathSeparator, "-") + "-" + modeName
+	}
 	subMode := mode.NewCommandMode(modeName, prompt, description)
-	currentMode.AddSubMode(subMode)
+	subMode.CommandTree.Strict = c.config().StrictRegistration
+	parent.AddSubMode(subMode)
 
 	// 同时添加到命令树，使用专门的视图切换命令方法
-	_ = c.commandTree.AddModeCommand(modeName, fmt.Sprintf("Enter %s configuration mode B", description))
+	if parent == c.rootMode {
+		_ = c.commandTree.AddModeCommand(modeName, fmt.Sprintf("Enter %s configuration mode B", description))
+	} else {
+		_ = parent.CommandTree.AddSubModeCommand(modeName, subMode.Path(), fmt.Sprintf("Enter %s mode", description))
+	}
 
 	// 添加退出命令
 	subMode.AddCommand("exit", "Exit and close connection", c.CreateCloseConnectionHandler())
-	subMode.AddCommand("quit", "Exit to previous mode", c.CreateExitToRootHandler())
+	subMode.AddCommand("quit", "Exit to previous mode", c.CreateExitToParentHandler())
+	_ = subMode.MarkOperational("exit")
+	_ = subMode.MarkOperational("quit")
+	for _, cmd := range c.globals {
+		c.addGlobalCommand(subMode, cmd)
+	}
 
 	return subMode
 }
 
 // RegisterModeCommand 注册命令到指定模式
 func (c *CmdLine) RegisterModeCommand(modePath string, name, description string, handler CommandHandler, detailedDescription ...string) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+	c.registerModeCommand(modePath, name, description, handler, detailedDescription...)
+}
+
+// RegisterModeHandler 注册使用标准处理函数的命令到指定模式
+func (c *CmdLine) RegisterModeHandler(modePath string, name, description string, handler types.HandlerFunc, detailedDescription ...string) {
+	c.registerModeCommand(modePath, name, description, handler, detailedDescription...)
+}
+
+// RegisterModeDataHandler 注册同时支持文本和 JSON 输出的命令到指定模式
+func (c *CmdLine) RegisterModeDataHandler(modePath string, name, description string, handler types.DataHandler, detailedDescription ...string) {
+	if !validDataHandler(name, handler) {
+		return
+	}
+	c.registerModeCommand(modePath, name, description, handler, detailedDescription...)
+}
+
+// registerModeCommand 注册命令到指定模式
+func (c *CmdLine) registerModeCommand(modePath string, name, description string, handler types.Handler, detailedDescription ...string) {
+	c.lockRegistry()
+	defer c.unlockRegistry()
+
+	currentMode := c.findOrCreateMode(modePath, "")
+	if currentMode == nil {
+		return
+	}
+	c.warnConflict(currentMode, name)
+	if err := currentMode.AddCommand(name, description, handler, detailedDescription...); err != nil {
+		fmt.Printf("Error: Failed to register command: %v\n", err)
+	}
+}
+
+// RegisterNegatableModeCommand 注册可否定命令到指定模式，同时注册 "no" 形式，两者调用同一个处理函数
+func (c *CmdLine) RegisterNegatableModeCommand(modePath string, name, description string, handler types.NegatableHandler, detailedDescription ...string) {
+	c.RegisterModeCommand(modePath, name, description, func(args []string) string {
+		return handler(args, false)
+	}, detailedDescription...)
+	c.RegisterModeCommand(modePath, commandtree.NegatedCommand(name, true), description, func(args []string) string {
+		return handler(args, true)
+	}, commandtree.NegatedHelp(detailedDescription)...)
+}
+
+// RegisterModeNegation 注册指定模式命令的否定处理函数，name 为 "no" 之后的命令规格
+func (c *CmdLine) RegisterModeNegation(modePath string, name, description string, handler CommandHandler, detailedDescription ...string) {
+	c.RegisterModeCommand(modePath, commandtree.NegatedCommand(name, false), description, handler, commandtree.NegatedHelp(detailedDescription)...)
+}
+
+// RegisterHiddenModeCommand 注册隐藏命令到指定模式
+func (c *CmdLine) RegisterHiddenModeCommand(modePath string, name, description string, handler CommandHandler, detailedDescription ...string) {
+	c.lockRegistry()
+	defer c.unlockRegistry()
+
+	currentMode := c.findOrCreateMode(modePath, "")
+	if currentMode == nil {
+		return
+	}
+	c.warnConflict(currentMode, name)
+	if err := currentMode.AddHiddenCommand(name, description, handler, detailedDescription...); err != nil {
+		fmt.Printf("Error: Failed to register command: %v\n", err)
+	}
+}
+
+// DeprecateCommand 将根模式中已注册的命令标记为废弃
+func (c *CmdLine) DeprecateCommand(name, replacement string) error {
+	c.lockRegistry()
+	defer c.unlockRegistry()
+
+	if err := c.rootMode.DeprecateCommand(name, replacement); err != nil {
+		return err
+	}
+	return c.commandTree.DeprecateCommand(name, replacement)
+}
+
+// DeprecateModeCommand 将指定模式中已注册的命令标记为废弃
+func (c *CmdLine) DeprecateModeCommand(modePath string, name, replacement string) error {
+	c.lockRegistry()
+	defer c.unlockRegistry()
+
+	currentMode := c.findOrCreateMode(modePath, "")
+	if currentMode == nil {
+		return fmt.Errorf("mode not found: %s", modePath)
+	}
+	return currentMode.DeprecateCommand(name, replacement)
+}
+
+// MarkOperationalCommand 将指定模式中已注册的命令标记为不修改配置的命令，开启候选配置时直接执行
+func (c *CmdLine) MarkOperationalCommand(modePath string, name string) error {
+	c.lockRegistry()
+	defer c.unlockRegistry()
+
+	currentMode := c.findOrCreateMode(modePath, "")
+	if currentMode == nil {
+		return fmt.Errorf("mode not found: %s", modePath)
+	}
+	return currentMode.MarkOperational(name)
+}
+
+// SetModeInherit 设置视图是否继承上一级视图的命令
+func (c *CmdLine) SetModeInherit(modePath string, inherit bool) {
+	c.lockRegistry()
+	defer c.unlockRegistry()
+
+	if m := c.findOrCreateMode(modePath, ""); m != nil {
+		m.Inherit = inherit
+	}
+}
+
+// syncInheritance 按上一级视图当前的命令重新链接继承的命令，调用者需持有注册表写锁
+// 视图按深度优先顺序处理，上一级视图继承的命令也会传递给下一级
+func (c *CmdLine) syncInheritance() {
+	for _, m := range c.sortedModes() {
+		if m.Parent == nil {
+			continue
+		}
+		if m.Inherit {
+			m.CommandTree.InheritFrom(m.Parent.CommandTree)
+		} else {
+			m.CommandTree.InheritFrom(nil)
+		}
+	}
+}
+
+// SetModeHelp 设置视图中 help 命令在命令列表之前显示的说明
+func (c *CmdLine) SetModeHelp(modePath string, header string) {
+	c.lockRegistry()
+	defer c.unlockRegistry()
+
+	if m := c.findOrCreateMode(modePath, ""); m != nil {
+		m.HelpHeader = header
+	}
+}
+
+// AddCommandCategory 将视图中以 keywords 开头的命令归入 help 命令的分组
+func (c *CmdLine) AddCommandCategory(modePath string, category string, keywords ...string) {
+	c.lockRegistry()
+	defer c.unlockRegistry()
+
+	if m := c.findOrCreateMode(modePath, ""); m != nil {
+		m.AddCategory(category, keywords...)
+	}
+}
+
+// RegisterConfigRenderer 为视图注册 show running-config 的配置渲染函数
+func (c *CmdLine) RegisterConfigRenderer(modePath string, renderer types.ConfigRenderer) {
+	c.lockRegistry()
+	defer c.unlockRegistry()
+
+	if m := c.findOrCreateMode(modePath, ""); m != nil {
+		m.Renderers = append(m.Renderers, renderer)
+	}
+}
+
+// RunningConfig 调用所有视图的渲染函数，返回与 show running-config 相同的配置文本
+func (c *CmdLine) RunningConfig() string {
+	return runconfig.Running(c.rootMode)
+}
+
+// WriteStartupConfig 将当前配置保存到 Config.StartupConfig 指定的文件，与 write memory 相同
+func (c *CmdLine) WriteStartupConfig() error {
+	path := c.config().StartupConfig
+	if path == "" {
+		return runconfig.ErrNoStartupConfig
+	}
+	return runconfig.WriteFile(path, runconfig.Running(c.rootMode))
+}
+
+// ExportConfig 将所有视图渲染得到的配置编码为 JSON 或 YAML，与 show running-config json/yaml 相同
+func (c *CmdLine) ExportConfig(format string) ([]byte, error) {
+	return runconfig.Marshal(runconfig.Sections(c.rootMode), format)
+}
+
+// ImportConfig 将 ExportConfig 格式的配置转换为命令执行，replace 为 true 时删除文档中没有的配置
+func (c *CmdLine) ImportConfig(data []byte, replace bool) error {
+	target, err := runconfig.Unmarshal(data)
+	if err != nil {
+		return err
+	}
+	current := runconfig.Sections(c.rootMode)
+	changes := runconfig.Merge(current, target)
+	if replace {
+		changes = runconfig.Diff(current, target)
+	}
+	commands, err := runconfig.ResolveAll(c.rootMode, changes)
+	if err != nil || len(commands) == 0 {
+		return err
+	}
+	ctx := &types.Ctx{Context: context.Background(), Writer: io.Discard, Format: types.OutputText}
+	return c.shared.Store.Commit(ctx, commands)
+}
 
-	currentMode := c.findOrCreateMode(modePath, fmt.Sprintf("%s configuration", modePath))
-	currentMode.AddCommand(name, description, handler, detailedDescription...)
+// RunScript 在根视图中逐行执行 r 中的命令，输出和执行结果的摘要写到 w
+func (c *CmdLine) RunScript(r io.Reader, w io.Writer, options types.ScriptOptions) (types.ScriptResult, error) {
+	c.shared.Registry.RLock()
+	commandCtx := mode.NewCommandContext(c.context.GetRootMode(), c.context.CommandTree)
+	c.shared.Registry.RUnlock()
+
+	s := session.NewScriptSession(c.shared, commandCtx, w)
+	defer s.Close()
+	return s.RunScript(r, options)
 }
 
 // CreateMode 创建新的命令模式
 func (c *CmdLine) CreateMode(modePath string, description string) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+	c.lockRegistry()
+	defer c.unlockRegistry()
 
 	c.findOrCreateMode(modePath, description)
 }
 
+// RegisterValueProvider 为字符串参数记号注册取值提供者
+func (c *CmdLine) RegisterValueProvider(name string, provider types.ValueProvider) {
+	c.shared.Registry.RegisterValueProvider(name, provider)
+}
+
+// RegisterInterfaceProvider 为 IFNAME 参数注册接口名提供者
+func (c *CmdLine) RegisterInterfaceProvider(provider types.ValueProvider) {
+	c.shared.Registry.RegisterInterfaceProvider(provider)
+}
+
+// ResolvePath 返回 PATH 参数在服务器上对应的路径，路径限制在当前配置的 FileRoot 之内
+func (c *CmdLine) ResolvePath(arg string) (string, error) {
+	return commandtree.ResolvePath(c.config().FileRoot, arg)
+}
+
+// sortedModes 按深度优先顺序返回所有视图，根视图在前，同一级子视图按名称排序，调用者需持有 c.mu
+func (c *CmdLine) sortedModes() []*mode.CommandMode {
+	return appendModes(nil, c.rootMode)
+}
+
+// modeName 返回视图在文档和 JSON 中的名称，根视图为 root，其他视图为完整路径
+func modeName(m *mode.CommandMode) string {
+	if m.Parent == nil {
+		return m.Name
+	}
+	return m.Path()
+}
+
+// appendModes 将视图及其子孙视图按深度优先顺序追加到 modes
+func appendModes(modes []*mode.CommandMode, m *mode.CommandMode) []*mode.CommandMode {
+	modes = append(modes, m)
+	for _, subMode := range m.SortedSubModes() {
+		modes = appendModes(modes, subMode)
+	}
+	return modes
+}
+
+// Walk 遍历所有视图中注册的命令，先遍历根视图，再按深度优先顺序遍历子视图，同一级子视图按名称排序
+// 遍历前先复制节点信息，回调中可以安全地调用 CmdLine 的其他方法
+func (c *CmdLine) Walk(fn func(node types.NodeInfo) error) error {
+	c.mu.RLock()
+	var nodes []types.NodeInfo
+	for _, m := range c.sortedModes() {
+		modePath := m.Path()
+		m.CommandTree.Walk(func(node *commandtree.CommandNode, depth int) error {
+			info := node.Info(depth)
+			info.Mode = modePath
+			nodes = append(nodes, info)
+			return nil
+		})
+	}
+	c.mu.RUnlock()
+
+	// skipDepth 大于 0 时跳过比它更深的节点
+	skipDepth := 0
+	for _, node := range nodes {
+		if skipDepth > 0 && node.Depth > skipDepth {
+			continue
+		}
+		skipDepth = 0
+
+		err := fn(node)
+		if errors.Is(err, commandtree.SkipChildren) {
+			skipDepth = node.Depth
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// modeJSON 视图的 JSON 描述
+type modeJSON struct {
+	Name        string                   `json:"name"`
+	Prompt      string                   `json:"prompt"`
+	Description string                   `json:"description,omitempty"`
+	Inherits    string                   `json:"inherits,omitempty"` // 继承命令的上一级视图
+	Commands    *commandtree.CommandTree `json:"commands"`
+}
+
+// MarshalJSON 将所有视图及其命令树导出为 JSON，视图顺序与 Walk 相同，嵌套视图的名称为完整路径
+func (c *CmdLine) MarshalJSON() ([]byte, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var modes []modeJSON
+	for _, m := range c.sortedModes() {
+		mj := modeJSON{
+			Name:        modeName(m),
+			Prompt:      m.Prompt,
+			Description: m.Description,
+			Commands:    m.CommandTree,
+		}
+		if m.Inherit && m.Parent != nil {
+			mj.Inherits = modeName(m.Parent)
+		}
+		modes = append(modes, mj)
+	}
+
+	return json.Marshal(struct {
+		Modes []modeJSON `json:"modes"`
+	}{modes})
+}
+
 // SetConfig 动态设置配置参数
 func (c *CmdLine) SetConfig(key, value string) error {
 	c.mu.Lock()
@@ -133,15 +687,82 @@ func (c *CmdLine) SetConfig(key, value string) error {
 
 	switch key {
 	case "prompt":
-		c.config.Prompt = value
-		c.rootMode.SetPrompt(value)
+		c.SetPrompt(value)
 	case "welcome":
-		c.config.WelcomeMsg = value
+		c.updateConfig(func(config *Config) { config.WelcomeMsg = value })
 	case "maxhistory":
 		// 这里可以添加类型转换逻辑
-		c.config.MaxHistory, _ = strconv.Atoi(value)
+		maxHistory, _ := strconv.Atoi(value)
+		c.updateConfig(func(config *Config) { config.MaxHistory = maxHistory })
 	case "port":
-		c.config.Port, _ = strconv.Atoi(value)
+		port, _ := strconv.Atoi(value)
+		c.updateConfig(func(config *Config) { config.Port = port })
+	case "strict":
+		strict, _ := strconv.ParseBool(value)
+		c.updateConfig(func(config *Config) { config.StrictRegistration = strict })
+		c.applyStrict()
+	case "fileroot":
+		c.updateConfig(func(config *Config) { config.FileRoot = value })
+	case "prompttemplate":
+		if _, err := template.New("prompt").Parse(value); err != nil {
+			return fmt.Errorf("invalid prompt template: %w", err)
+		}
+		c.updateConfig(func(config *Config) { config.PromptTemplate = value })
+	case "hostname":
+		c.SetHostname(value)
+	case "fuzzy":
+		fuzzy, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid fuzzy completion setting: %s", value)
+		}
+		c.updateConfig(func(config *Config) { config.FuzzyCompletion = fuzzy })
+	case "completion", "helpkey":
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid %s setting: %s", key, value)
+		}
+		c.updateConfig(func(config *Config) {
+			if key == "completion" {
+				config.DisableCompletion = !enabled
+			} else {
+				config.DisableHelpKey = !enabled
+			}
+		})
+	case "autocorrect":
+		autoCorrect, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid autocorrect setting: %s", value)
+		}
+		c.updateConfig(func(config *Config) { config.AutoCorrect = autoCorrect })
+	case "candidate":
+		candidate, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid candidate setting: %s", value)
+		}
+		c.updateConfig(func(config *Config) { config.CandidateConfig = candidate })
+	case "archivesize":
+		size, err := strconv.Atoi(value)
+		if err != nil || size < 0 {
+			return fmt.Errorf("invalid archive size: %s", value)
+		}
+		c.updateConfig(func(config *Config) { config.ArchiveSize = size })
+	case "autosaveinterval", "autosavedelay", "autosavejitter":
+		d, err := time.ParseDuration(value)
+		if err != nil || d < 0 {
+			return fmt.Errorf("invalid %s: %s", key, value)
+		}
+		c.updateConfig(func(config *Config) {
+			switch key {
+			case "autosaveinterval":
+				config.AutoSaveInterval = d
+			case "autosavedelay":
+				config.AutoSaveDelay = d
+			default:
+				config.AutoSaveJitter = d
+			}
+		})
+	case "startupconfig":
+		c.updateConfig(func(config *Config) { config.StartupConfig = value })
 	default:
 		return fmt.Errorf("unknown config key: %s", key)
 	}
@@ -149,22 +770,140 @@ func (c *CmdLine) SetConfig(key, value string) error {
 	return nil
 }
 
+// SetHostname 设置主机名，所有会话在下一次显示提示符时生效，name 为空时恢复为由 Prompt 得到的主机名
+// 可以在处理函数中调用
+func (c *CmdLine) SetHostname(name string) {
+	c.updateConfig(func(config *Config) { config.Hostname = name })
+}
+
+// Hostname 返回当前的主机名，没有设置时返回空字符串
+func (c *CmdLine) Hostname() string {
+	return c.config().Hostname
+}
+
+// SetPrompt 设置根视图的提示符，所有会话在下一次显示提示符时生效，可以在处理函数中调用
+func (c *CmdLine) SetPrompt(prompt string) {
+	c.updateConfig(func(config *Config) { config.Prompt = prompt })
+	// 视图的提示符与命令树一样由注册表保护
+	c.shared.Registry.Lock()
+	defer c.shared.Registry.Unlock()
+	c.rootMode.SetPrompt(prompt)
+}
+
+// SetPromptFunc 设置动态提示符回调，fn 为 nil 时取消
+func (c *CmdLine) SetPromptFunc(fn types.PromptFunc) {
+	c.updateConfig(func(config *Config) { config.PromptFunc = fn })
+}
+
+// AddHistoryExclude 添加不记入命令历史的输入行的正则表达式，对所有会话之后输入的行生效
+func (c *CmdLine) AddHistoryExclude(pattern string) error {
+	if _, err := regexp.Compile(pattern); err != nil {
+		return fmt.Errorf("invalid history exclude pattern: %w", err)
+	}
+	c.updateConfig(func(config *Config) {
+		// 快照之间共用切片，追加到新的切片中
+		config.HistoryExclude = append(slices.Clip(config.HistoryExclude), pattern)
+	})
+	return nil
+}
+
+// SetCommitFunc 设置提交候选配置时的回调，fn 为 nil 时直接执行候选配置中的命令
+func (c *CmdLine) SetCommitFunc(fn types.CommitFunc) {
+	c.updateConfig(func(config *Config) { config.Commit = fn })
+}
+
+// SubscribeConfigChanges 订阅配置视图中命令执行成功的事件，返回取消订阅的函数
+func (c *CmdLine) SubscribeConfigChanges(fn types.ConfigEventFunc) func() {
+	return c.shared.Store.Events.Subscribe(fn)
+}
+
+// SetNotFoundHandler 设置输入无法匹配命令时的回调，fn 为 nil 时取消
+func (c *CmdLine) SetNotFoundHandler(fn types.NotFoundFunc) {
+	c.updateConfig(func(config *Config) { config.NotFound = fn })
+}
+
+// SetObserver 设置接收会话事件的 Observer，o 为 nil 时取消
+func (c *CmdLine) SetObserver(o types.Observer) {
+	c.updateConfig(func(config *Config) { config.Observer = o })
+}
+
+// applyStrict 将严格注册模式应用到所有命令树
+func (c *CmdLine) applyStrict() {
+	strict := c.config().StrictRegistration
+	c.commandTree.Strict = strict
+	for _, m := range c.sortedModes() {
+		m.CommandTree.Strict = strict
+	}
+}
+
 // Start 启动命令行服务
 func (c *CmdLine) Start() error {
+	return c.StartContext(context.Background())
+}
+
+// StartContext 启动命令行服务，ctx 取消时停止服务并关闭所有会话，与调用 Stop 相同
+func (c *CmdLine) StartContext(ctx context.Context) error {
 	c.mu.Lock()
 
 	if c.isRunning {
 		c.mu.Unlock()
 		return fmt.Errorf("cmdline is already running")
 	}
-	fmt.Printf("Config: %v\n", c.config)
+	fmt.Printf("Config: %v\n", c.config())
 
 	c.isRunning = true
+	stopped := make(chan struct{})
+	c.stopped = stopped
 	c.mu.Unlock() // 释放锁，避免死锁
 
-	// 注册内置命令（在锁外执行，避免死锁）
-	c.registerBuiltinCommands()
-	fmt.Printf("registered commands: %v\n", c.commands)
+	// 启动服务器
+	err := c.prepareServer().Start()
+	if err != nil {
+		fmt.Printf("Error starting server: %v\n", err)
+		c.mu.Lock()
+		c.isRunning = false
+		c.stopped = nil
+		c.mu.Unlock()
+		return err
+	}
+	fmt.Printf("Command line interface started on port %d\n", c.config().Port)
+
+	autoSave := c.shared.Store.StartAutoSave()
+	c.mu.Lock()
+	c.autoSave = autoSave
+	c.mu.Unlock()
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			c.stop(stopped)
+		case <-stopped:
+		}
+	}()
+	return nil
+}
+
+// prepareServer 注册内置命令并创建服务器，已经创建时直接返回，由 Start 和 ServeConn 共用
+func (c *CmdLine) prepareServer() *server.TelnetServer {
+	c.serverMu.Lock()
+	defer c.serverMu.Unlock()
+
+	c.mu.RLock()
+	srv := c.server
+	c.mu.RUnlock()
+	if srv != nil {
+		return srv
+	}
+
+	// 注册内置命令（在锁外执行，避免死锁），每个 CmdLine 只注册一次
+	if !c.builtins {
+		c.registerBuiltinCommands()
+		c.builtins = true
+		fmt.Printf("registered commands: %v\n", c.commands)
+	}
+
+	// 启动后仍可以注册命令，读取命令树时持有注册表读锁
+	c.shared.Registry.RLock()
 
 	// 打印命令树结构
 	if c.commandTree != nil {
@@ -174,40 +913,82 @@ func (c *CmdLine) Start() error {
 	}
 
 	// 创建telnet服务器
-	c.server = server.NewTelnetServerWithContext(c.config, c.context)
+	srv = server.NewTelnetServerWithContext(c.shared, c.context)
+	c.shared.Registry.RUnlock()
 	fmt.Printf("Telnet server created, starting...\n")
 
-	// 启动服务器
-	err := c.server.Start()
-	if err != nil {
-		fmt.Printf("Error starting server: %v\n", err)
-		c.mu.Lock()
-		c.isRunning = false
-		c.mu.Unlock()
-		return err
-	}
-	fmt.Printf("Command line interface started on port %d\n", c.config.Port)
+	c.mu.Lock()
+	c.server = srv
+	c.mu.Unlock()
+	return srv
+}
 
-	return nil
+// ServeConn 在已经建立的连接上运行一个会话，会话结束时返回并关闭连接，不需要调用 Start
+func (c *CmdLine) ServeConn(conn net.Conn) {
+	c.prepareServer().ServeConn(conn)
+}
+
+// ServeStream 在数据流上运行一个会话，会话结束时返回，不需要调用 Start
+func (c *CmdLine) ServeStream(rw io.ReadWriter, options types.StreamOptions) {
+	c.prepareServer().ServeStream(rw, options)
 }
 
 // Stop 停止命令行服务
 func (c *CmdLine) Stop() error {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+	return c.stop(nil)
+}
 
-	if !c.isRunning {
+// stop 停止服务，run 不为 nil 时只停止该次启动的服务，已经停止或重新启动时不做任何事
+func (c *CmdLine) stop(run chan struct{}) error {
+	c.stopMu.Lock()
+	defer c.stopMu.Unlock()
+
+	c.mu.Lock()
+	if !c.isRunning || (run != nil && run != c.stopped) {
+		c.mu.Unlock()
 		return fmt.Errorf("cmdline is not running")
 	}
 
 	if c.server != nil {
 		c.server.Stop()
+		c.server = nil
 	}
-
+	autoSave := c.autoSave
+	c.autoSave = nil
 	c.isRunning = false
+	close(c.stopped)
+	c.stopped = nil
+	c.mu.Unlock()
+
+	// 最后一次保存调用渲染函数，渲染函数可能调用 CmdLine 的方法，不能持有 c.mu
+	if autoSave != nil {
+		autoSave.Stop()
+	}
+	c.shared.Store.Close()
 	return nil
 }
 
+// Sessions 返回所有活动的会话，服务没有启动时为空
+func (c *CmdLine) Sessions() []types.Session {
+	c.mu.RLock()
+	srv := c.server
+	c.mu.RUnlock()
+	if srv == nil {
+		return nil
+	}
+	return srv.Sessions()
+}
+
+// Notify 向开启了 terminal monitor 的会话发送通知，服务没有启动时忽略
+func (c *CmdLine) Notify(message string) {
+	c.mu.RLock()
+	srv := c.server
+	c.mu.RUnlock()
+	if srv != nil {
+		srv.Notify(message)
+	}
+}
+
 // CreateExitToRootHandler 创建退出到根模式处理函数
 func (c *CmdLine) CreateExitToRootHandler() types.CommandHandler {
 	return func(args []string) string {
@@ -216,6 +997,13 @@ func (c *CmdLine) CreateExitToRootHandler() types.CommandHandler {
 	}
 }
 
+// CreateExitToParentHandler 创建返回来源模式的处理函数，会话返回进入当前模式之前所在的模式
+func (c *CmdLine) CreateExitToParentHandler() types.CommandHandler {
+	return func(args []string) string {
+		return "__EXIT_TO_PARENT__"
+	}
+}
+
 // CreateCloseConnectionHandler 创建关闭连接处理函数
 func (c *CmdLine) CreateCloseConnectionHandler() types.CommandHandler {
 	return func(args []string) string {
@@ -231,5 +1019,13 @@ func (c *CmdLine) registerBuiltinCommands() {
 	// 添加退出命令
 	c.RegisterCommand("exit", "Exit and close connection", c.CreateCloseConnectionHandler())
 	c.RegisterCommand("quit", "Exit to previous mode", c.CreateCloseConnectionHandler())
+
+	// 会话内置命令
+	for _, cmd := range session.BuiltinCommands() {
+		c.registerCommand(cmd.Name, cmd.Description, cmd.Handler)
+	}
+	for _, cmd := range session.GlobalBuiltinCommands() {
+		c.registerGlobalCommand(cmd.Name, cmd.Description, cmd.Handler)
+	}
 	fmt.Printf("Builtin commands registration completed\n")
 }
@@ -0,0 +1,256 @@
+package cmdline
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/TrailHuang/tnlcmd/internal/commandtree"
+	"github.com/TrailHuang/tnlcmd/internal/mode"
+)
+
+// modeDoc 一个视图的文档
+type modeDoc struct {
+	name        string // 视图路径
+	keyword     string // 进入视图输入的关键字
+	parent      string // 嵌套视图的上一级视图路径，根视图的子视图为空
+	inherits    string // 继承命令的上一级视图
+	prompt      string
+	description string
+	root        bool
+	commands    []commandDoc
+}
+
+// commandDoc 一条可执行命令的文档
+type commandDoc struct {
+	syntax      string
+	description string
+	deprecated  bool
+	replacement string
+	params      []paramDoc
+}
+
+// paramDoc 命令中一个参数的文档
+type paramDoc struct {
+	name     string
+	typeName string
+	values   string
+	help     string
+}
+
+// WriteMarkdown 将所有视图中注册的命令生成 Markdown 格式的命令参考，隐藏命令不输出
+func (c *CmdLine) WriteMarkdown(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	for _, m := range c.collectDocs() {
+		fmt.Fprintf(bw, "## %s\n\n", m.name)
+		if m.description != "" {
+			fmt.Fprintf(bw, "%s\n\n", m.description)
+		}
+		if m.inherits != "" {
+			fmt.Fprintf(bw, "Inherits the commands of `%s`.\n\n", m.inherits)
+		}
+		fmt.Fprintf(bw, "Prompt: `%s`", strings.TrimSpace(m.prompt))
+		if !m.root {
+			fmt.Fprintf(bw, ", enter with `%s`", m.keyword)
+			if m.parent != "" {
+				fmt.Fprintf(bw, " in `%s`", m.parent)
+			}
+		}
+		fmt.Fprint(bw, "\n\n")
+
+		for _, cmd := range m.commands {
+			fmt.Fprintf(bw, "### `%s`\n\n", cmd.syntax)
+			if cmd.description != "" {
+				fmt.Fprintf(bw, "%s\n\n", cmd.description)
+			}
+			if cmd.deprecated {
+				fmt.Fprint(bw, "*Deprecated.")
+				if cmd.replacement != "" {
+					fmt.Fprintf(bw, " Use `%s` instead.", cmd.replacement)
+				}
+				fmt.Fprint(bw, "*\n\n")
+			}
+			if len(cmd.params) == 0 {
+				continue
+			}
+			fmt.Fprint(bw, "| Parameter | Type | Values | Description |\n")
+			fmt.Fprint(bw, "|-----------|------|--------|-------------|\n")
+			for _, p := range cmd.params {
+				fmt.Fprintf(bw, "| `%s` | %s | %s | %s |\n",
+					markdownCell(p.name), p.typeName, markdownCell(p.values), markdownCell(p.help))
+			}
+			fmt.Fprint(bw, "\n")
+		}
+	}
+	return bw.Flush()
+}
+
+// WriteManPage 将所有视图中注册的命令生成 troff 格式的手册页，name 为手册页名称
+func (c *CmdLine) WriteManPage(w io.Writer, name string) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, ".TH %s 7\n", troffText(strings.ToUpper(name)))
+	fmt.Fprint(bw, ".SH NAME\n")
+	fmt.Fprintf(bw, "%s \\- command reference\n", troffText(name))
+
+	for _, m := range c.collectDocs() {
+		fmt.Fprintf(bw, ".SH %s\n", troffText(strings.ToUpper(m.name)))
+		if m.description != "" {
+			fmt.Fprintf(bw, "%s\n", troffLine(m.description))
+		}
+		if m.inherits != "" {
+			fmt.Fprintf(bw, ".PP\nInherits the commands of \\fB%s\\fR.\n", troffText(m.inherits))
+		}
+		fmt.Fprintf(bw, ".PP\nPrompt: \\fB%s\\fR", troffText(strings.TrimSpace(m.prompt)))
+		if !m.root {
+			fmt.Fprintf(bw, ", enter with \\fB%s\\fR", troffText(m.keyword))
+			if m.parent != "" {
+				fmt.Fprintf(bw, " in \\fB%s\\fR", troffText(m.parent))
+			}
+		}
+		fmt.Fprint(bw, "\n")
+
+		for _, cmd := range m.commands {
+			fmt.Fprintf(bw, ".SS %s\n", troffText(cmd.syntax))
+			if cmd.description != "" {
+				fmt.Fprintf(bw, "%s\n", troffLine(cmd.description))
+			}
+			if cmd.deprecated {
+				fmt.Fprint(bw, ".PP\nDeprecated.")
+				if cmd.replacement != "" {
+					fmt.Fprintf(bw, " Use \\fB%s\\fR instead.", troffText(cmd.replacement))
+				}
+				fmt.Fprint(bw, "\n")
+			}
+			for _, p := range cmd.params {
+				fmt.Fprintf(bw, ".TP\n\\fB%s\\fR\n", troffText(p.name))
+				text := p.typeName
+				if p.values != "" {
+					text += ": " + p.values
+				}
+				if p.help != "" {
+					text += ". " + p.help
+				}
+				fmt.Fprintf(bw, "%s\n", troffLine(text))
+			}
+		}
+	}
+	return bw.Flush()
+}
+
+// collectDocs 按视图收集可执行命令及其参数，视图顺序与 Walk 相同
+func (c *CmdLine) collectDocs() []modeDoc {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var docs []modeDoc
+	for _, m := range c.sortedModes() {
+		doc := modeDoc{
+			name:        modeName(m),
+			keyword:     m.Name,
+			prompt:      m.Prompt,
+			description: m.Description,
+			root:        m == c.rootMode,
+			commands:    commandDocs(m),
+		}
+		if m.Parent != nil {
+			doc.parent = m.Parent.Path()
+			if m.Inherit {
+				doc.inherits = modeName(m.Parent)
+			}
+		}
+		docs = append(docs, doc)
+	}
+	return docs
+}
+
+// commandDocs 收集视图中所有可执行的非隐藏命令
+func commandDocs(m *mode.CommandMode) []commandDoc {
+	var commands []commandDoc
+	m.CommandTree.Walk(func(node *commandtree.CommandNode, depth int) error {
+		if node.Hidden {
+			return commandtree.SkipChildren
+		}
+		if node.Handler == nil {
+			return nil
+		}
+
+		cmd := commandDoc{
+			syntax:      node.Path(),
+			description: node.Description,
+			deprecated:  node.Deprecated,
+			replacement: node.Replacement,
+		}
+		for n := node; n.Parent != nil; n = n.Parent {
+			if n.Type == commandtree.NodeTypeCommand || n.Type == commandtree.NodeTypeModeSwitch {
+				continue
+			}
+			cmd.params = append([]paramDoc{newParamDoc(n)}, cmd.params...)
+		}
+		commands = append(commands, cmd)
+		return nil
+	})
+	return commands
+}
+
+// newParamDoc 生成参数节点的文档
+func newParamDoc(n *commandtree.CommandNode) paramDoc {
+	p := paramDoc{
+		name:     commandtree.DisplayName(n),
+		typeName: n.Info(0).TypeName,
+		help:     n.Help,
+	}
+
+	switch n.Type {
+	case commandtree.NodeTypeEnum:
+		var values []string
+		for _, value := range n.EnumValues {
+			if help, ok := n.EnumHelp[value]; ok {
+				value += " (" + help + ")"
+			}
+			values = append(values, value)
+		}
+		p.values = strings.Join(values, ", ")
+	case commandtree.NodeTypeNum:
+		if n.Unsigned {
+			p.values = fmt.Sprintf("%d-%d", n.URangeMin, n.URangeMax)
+		} else {
+			p.values = fmt.Sprintf("%d-%d", n.RangeMin, n.RangeMax)
+		}
+	case commandtree.NodeTypeHex:
+		p.values = fmt.Sprintf("0x%X-0x%X", n.URangeMin, n.URangeMax)
+	case commandtree.NodeTypeString:
+		if commandtree.HasValueProvider(n) {
+			p.values = "dynamic"
+		}
+	case commandtree.NodeTypeSize:
+		p.values = "integer with optional k/M/G/T suffix"
+	case commandtree.NodeTypeDuration:
+		p.values = "seconds or Go duration such as 1h30m"
+	case commandtree.NodeTypePath:
+		p.values = "file path"
+	case commandtree.NodeTypeLine:
+		p.values = "rest of line"
+	}
+	return p
+}
+
+// markdownCell 转义表格单元格中的竖线，换行替换为空格
+func markdownCell(text string) string {
+	text = strings.ReplaceAll(text, "\n", " ")
+	return strings.ReplaceAll(text, "|", "\\|")
+}
+
+// troffText 转义 troff 正文中的反斜杠
+func troffText(text string) string {
+	return strings.ReplaceAll(text, "\\", "\\e")
+}
+
+// troffLine 转义整行文本，行首的 . 和 ' 会被 troff 当作请求
+func troffLine(text string) string {
+	text = troffText(text)
+	if strings.HasPrefix(text, ".") || strings.HasPrefix(text, "'") {
+		text = "\\&" + text
+	}
+	return text
+}
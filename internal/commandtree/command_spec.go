@@ -0,0 +1,370 @@
+package commandtree
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ExpandAlternatives 展开命令规格中的分支组 {a | b} 和可选组 [...]，返回所有分支对应的命令字符串
+// 分支可以包含多个记号和嵌套分组，例如 "clear counters {interface NAME | all}"
+// 展开为 "clear counters interface NAME" 和 "clear counters all"；
+// "show log [level (info|warn)]" 展开为 "show log" 和 "show log level (info|warn)"
+func ExpandAlternatives(command string) ([]string, error) {
+	return expandGroups(reorderNamedGroups(command))
+}
+
+// expandGroups 递归展开第一个分组
+func expandGroups(command string) ([]string, error) {
+	start := strings.IndexAny(command, "{[")
+	if start < 0 {
+		if strings.ContainsAny(command, "}]") {
+			return nil, fmt.Errorf("unbalanced group in command: %s", command)
+		}
+		return []string{strings.Join(splitTopLevelFields(command), " ")}, nil
+	}
+
+	end, err := matchingBracket(command, start)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := command[:start]
+	suffix := command[end+1:]
+	inner := command[start+1 : end]
+
+	var alternatives []string
+	if command[start] == '{' {
+		alternatives = splitTopLevel(inner, '|')
+	} else {
+		// 可选组：包含或省略组内全部记号
+		alternatives = []string{"", inner}
+	}
+
+	var result []string
+	for _, alternative := range alternatives {
+		expanded, err := expandGroups(prefix + " " + alternative + " " + suffix)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, expanded...)
+	}
+	return result, nil
+}
+
+// matchingBracket 找到与 start 位置的 '{' 或 '[' 匹配的右括号
+func matchingBracket(command string, start int) (int, error) {
+	depth := 0
+	for i := start; i < len(command); i++ {
+		switch command[i] {
+		case '{', '[':
+			depth++
+		case '}', ']':
+			depth--
+			if depth == 0 {
+				if (command[start] == '{') != (command[i] == '}') {
+					return 0, fmt.Errorf("mismatched brackets in command: %s", command)
+				}
+				return i, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("unbalanced %q in command: %s", command[start], command)
+}
+
+// splitTopLevel 按分隔符拆分字符串，忽略括号内部的分隔符
+func splitTopLevel(text string, sep byte) []string {
+	var parts []string
+	depth := 0
+	last := 0
+	for i := 0; i < len(text); i++ {
+		switch text[i] {
+		case '{', '(', '[':
+			depth++
+		case '}', ')', ']':
+			depth--
+		case sep:
+			if depth == 0 {
+				parts = append(parts, text[last:i])
+				last = i + 1
+			}
+		}
+	}
+	return append(parts, text[last:])
+}
+
+// reorderNamedGroups 将相邻的命名可选组改写为任意顺序的分支组
+// 以关键字开头的可选组视为命名参数，如 "[compress (on|off)] [target PATH]"，
+// 两个及以上相邻的命名参数可以按任意顺序出现，每个最多出现一次
+func reorderNamedGroups(command string) string {
+	items := splitTopLevelFields(command)
+
+	var result []string
+	for i := 0; i < len(items); {
+		j := i
+		for j < len(items) && isNamedGroup(items[j]) {
+			j++
+		}
+		if j-i < 2 {
+			result = append(result, items[i])
+			i++
+			continue
+		}
+
+		var inners []string
+		for _, item := range items[i:j] {
+			inners = append(inners, item[1:len(item)-1])
+		}
+		var orders []string
+		for _, seq := range orderedSubsets(len(inners)) {
+			var tokens []string
+			for _, index := range seq {
+				tokens = append(tokens, inners[index])
+			}
+			orders = append(orders, strings.Join(tokens, " "))
+		}
+		result = append(result, "{"+strings.Join(orders, " | ")+"}")
+		i = j
+	}
+
+	return strings.Join(result, " ")
+}
+
+// namedKeywords 返回命令规格中命名参数开头的关键字，如 "[compress (on|off)] [target PATH]" 中的 compress 和 target
+func namedKeywords(command string) map[string]bool {
+	keywords := make(map[string]bool)
+	items := splitTopLevelFields(command)
+	for i := 0; i < len(items); {
+		j := i
+		for j < len(items) && isNamedGroup(items[j]) {
+			j++
+		}
+		if j-i >= 2 {
+			for _, item := range items[i:j] {
+				keywords[strings.Fields(item[1 : len(item)-1])[0]] = true
+			}
+			i = j
+		} else {
+			i++
+		}
+	}
+	return keywords
+}
+
+// isNamedGroup 检查条目是否为以关键字开头的可选组
+func isNamedGroup(item string) bool {
+	if !strings.HasPrefix(item, "[") || !strings.HasSuffix(item, "]") {
+		return false
+	}
+	fields := strings.Fields(item[1 : len(item)-1])
+	return len(fields) > 0 && fields[0][0] >= 'a' && fields[0][0] <= 'z'
+}
+
+// orderedSubsets 返回 0..n-1 的所有有序子集（包括空集）
+func orderedSubsets(n int) [][]int {
+	result := [][]int{{}}
+	var walk func(seq []int, used []bool)
+	walk = func(seq []int, used []bool) {
+		for i := 0; i < n; i++ {
+			if used[i] {
+				continue
+			}
+			next := append(append([]int{}, seq...), i)
+			result = append(result, next)
+			used[i] = true
+			walk(next, used)
+			used[i] = false
+		}
+	}
+	walk(nil, make([]bool, n))
+	return result
+}
+
+// splitTopLevelFields 按空白拆分命令规格，括号内的空白不拆分（如枚举值说明中的空格）
+func splitTopLevelFields(command string) []string {
+	var fields []string
+	depth := 0
+	start := -1
+	for i := 0; i < len(command); i++ {
+		c := command[i]
+		switch c {
+		case '{', '(', '[':
+			depth++
+		case '}', ')', ']':
+			depth--
+		}
+		if (c == ' ' || c == '\t') && depth == 0 {
+			if start >= 0 {
+				fields = append(fields, command[start:i])
+				start = -1
+			}
+		} else if start < 0 {
+			start = i
+		}
+	}
+	if start >= 0 {
+		fields = append(fields, command[start:])
+	}
+	return fields
+}
+
+// tokenHelps 将帮助行依次对应到命令规格中的记号（按出现顺序，忽略分组符号）
+// 空行表示该记号没有帮助，同名记号使用第一次出现时的帮助
+func tokenHelps(command string, lines []string) map[string]string {
+	helps := make(map[string]string)
+	for i, token := range specTokens(command) {
+		if i >= len(lines) {
+			break
+		}
+		line := strings.TrimSpace(lines[i])
+		if _, exists := helps[token]; line != "" && !exists {
+			helps[token] = line
+		}
+	}
+	return helps
+}
+
+// specTokens 返回命令规格中的所有记号，去掉 {} [] 分组和分支分隔符，枚举参数保持完整
+func specTokens(command string) []string {
+	var b strings.Builder
+	depth := 0
+	for i := 0; i < len(command); i++ {
+		c := command[i]
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case '{', '}', '[', ']', '|':
+			if depth == 0 {
+				c = ' '
+			}
+		}
+		b.WriteByte(c)
+	}
+	return splitTopLevelFields(b.String())
+}
+
+// slotMark 展开分组之前附加在参数记号之后的标记，后接该参数在命令规格中的序号，见 numberParams
+const slotMark = "\x00"
+
+// numberParams 为命令规格中的每个参数记号附加按出现顺序的序号，返回改写后的规格和参数个数；
+// 展开分组和调整命名参数顺序之后，各分支仍然可以按序号将参数放回命令规格中的位置
+func numberParams(command string) (string, int) {
+	var b strings.Builder
+	count := 0
+	depth := 0
+	start := -1
+	end := func(i int) {
+		if start >= 0 && isParameterToken(command[start:i]) {
+			b.WriteString(slotMark + strconv.Itoa(count))
+			count++
+		}
+		start = -1
+	}
+	for i := 0; i < len(command); i++ {
+		c := command[i]
+		switch {
+		case c == '(':
+			if start < 0 {
+				start = i
+			}
+			depth++
+		case c == ')':
+			depth--
+		case depth > 0:
+		case c == ' ' || c == '\t' || strings.IndexByte("{}[]|", c) >= 0:
+			end(i)
+		case start < 0:
+			start = i
+		}
+		b.WriteByte(c)
+	}
+	end(len(command))
+	return b.String(), count
+}
+
+// splitSlot 去掉 numberParams 附加的序号，没有序号时返回 -1
+func splitSlot(token string) (string, int) {
+	index := strings.Index(token, slotMark)
+	if index < 0 {
+		return token, -1
+	}
+	slot, _ := strconv.Atoi(token[index+len(slotMark):])
+	return token[:index], slot
+}
+
+// 否定命令
+const (
+	NegateKeyword = "no"                                   // 否定命令前缀关键字
+	negateHelp    = "Negate a command or set its defaults" // 否定关键字的帮助
+)
+
+// NegatedCommand 返回命令规格对应的否定形式，如 "hostname HOSTNAME" 返回 "no hostname [HOSTNAME]"
+// optionalParams 为 true 时末尾连续的参数变为可选，否定时可以只输入关键字
+func NegatedCommand(command string, optionalParams bool) string {
+	fields := splitTopLevelFields(command)
+	if optionalParams {
+		start := len(fields)
+		for start > 0 && isParameterToken(fields[start-1]) {
+			start--
+		}
+		if start < len(fields) && start > 0 {
+			tail := strings.Join(fields[start:], " ")
+			fields = append(fields[:start], "["+tail+"]")
+		}
+	}
+	return NegateKeyword + " " + strings.Join(fields, " ")
+}
+
+// NegatedHelp 在记号帮助前加上否定关键字的帮助行
+func NegatedHelp(detailedDescription []string) []string {
+	if len(detailedDescription) == 0 || detailedDescription[0] == "" {
+		return []string{negateHelp}
+	}
+	return []string{negateHelp + "\n" + detailedDescription[0]}
+}
+
+// isParameterToken 检查命令规格中的记号是否为参数（而不是关键字或分组）
+func isParameterToken(token string) bool {
+	if token == "" || strings.ContainsAny(token[:1], "[{") {
+		return false
+	}
+	base, _ := trimRepeatSuffix(token)
+	return strings.HasPrefix(base, "(") || strings.HasPrefix(base, "<") ||
+		base == ipv4Token || base == ipv4PrefixToken || isAllUppercase(base)
+}
+
+// splitLabel 拆分带名称的参数记号 <name:TOKEN>，返回名称和去掉名称后的记号，
+// 如 <level:1-10> 返回 "level" 和 "<1-10>"，<addr:A.B.C.D> 返回 "addr" 和 "A.B.C.D"
+// 记号不带名称时返回空名称和原记号
+func splitLabel(token string) (string, string) {
+	base, _ := trimRepeatSuffix(token)
+	suffix := token[len(base):]
+	if !strings.HasPrefix(base, "<") || !strings.HasSuffix(base, ">") {
+		return "", token
+	}
+
+	inner := base[1 : len(base)-1]
+	colon := strings.IndexByte(inner, ':')
+	if colon <= 0 || !isLabel(inner[:colon]) {
+		return "", token
+	}
+
+	rest := inner[colon+1:]
+	if !isParameterToken(rest) {
+		rest = "<" + rest + ">"
+	}
+	return inner[:colon], rest + suffix
+}
+
+// isLabel 检查参数名称是否只包含字母、数字、- 和 _
+func isLabel(name string) bool {
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '-' && r != '_' {
+			return false
+		}
+	}
+	return name != ""
+}
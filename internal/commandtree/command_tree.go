@@ -22,6 +22,13 @@ const (
 	NodeTypeNum                        = types.NodeTypeNum        // 数值范围节点 <>
 	NodeTypeString                     = types.NodeTypeString     // 字符串参数节点（大写字母）
 	NodeTypeModeSwitch                 = types.NodeTypeModeSwitch // 视图切换命令节点
+	NodeTypeIPv4                       = types.NodeTypeIPv4       // IPv4 地址参数节点 A.B.C.D
+	NodeTypeIPv4Prefix                 = types.NodeTypeIPv4Prefix // IPv4 前缀参数节点 A.B.C.D/M
+	NodeTypeHex                        = types.NodeTypeHex        // 十六进制范围节点 <0x0-0xFFFF>
+	NodeTypeLine                       = types.NodeTypeLine       // 行尾文本参数节点 LINE
+	NodeTypeSize                       = types.NodeTypeSize       // 容量参数节点 <size>
+	NodeTypeDuration                   = types.NodeTypeDuration   // 时长参数节点 <duration>
+	NodeTypePath                       = types.NodeTypePath       // 文件路径参数节点 PATH
 )
 
 // CommandNode 命令树节点
@@ -29,18 +36,54 @@ type CommandNode struct {
 	Name        string
 	Type        CommandNodeType
 	Description string
-	Handler     types.CommandHandler
+	Help        string // 记号帮助，? 提示时优先于 Description 显示
+	Handler     types.Handler
 	Children    map[string]*CommandNode
 	Parent      *CommandNode
 
+	// order 与 Children 对应的有序索引，见 SortedChildren
+	order []*CommandNode
+
 	// 参数特定字段
-	EnumValues []string // 枚举值列表
-	RangeMin   int      // 范围最小值
-	RangeMax   int      // 范围最大值
-	IsRequired bool     // 是否必需参数
+	EnumValues []string          // 枚举值列表
+	EnumHelp   map[string]string // 枚举值的说明，? 提示时逐个列出
+	RangeMin   int64             // 范围最小值
+	RangeMax   int64             // 范围最大值
+	IsRequired bool              // 是否必需参数
+
+	// 无符号范围，上限超出 int64 的范围和十六进制范围使用
+	Unsigned  bool
+	URangeMin uint64
+	URangeMax uint64
+
+	// 可重复参数，如 <1-65535>... 或 PORT+，只能出现在命令末尾
+	Repeat bool
+
+	// 隐藏命令可以正常执行，但不出现在帮助和补全中
+	Hidden bool
+
+	// 废弃命令仍然可以执行，执行时提示改用 Replacement
+	Deprecated  bool
+	Replacement string
+
+	// 不修改配置的命令（全局命令、exit、quit 等），开启候选配置时直接执行而不记入候选配置
+	Operational bool
 
 	// 视图切换特定字段
 	ModeName string // 要切换到的视图名称
+
+	// 可执行节点从根到该节点路径上各参数的名称，由命令规格中的 <name:TOKEN> 指定，
+	// 未指定名称的参数对应空字符串；没有指定任何名称时为 nil，见 NamedParams
+	ParamLabels []string
+
+	// 可执行节点路径上各参数在命令规格中的位置和命令规格中的参数个数，见 SpecArgs
+	ParamSlots []int
+	SpecParams int
+
+	// 命名参数开头的关键字，如 "[compress (on|off)] [target PATH]" 中的 compress，见 RepeatedKeyword
+	Named bool
+
+	registry *Registry // 命令树所属的注册表，只在根节点上设置，见 CommandTree.Registry
 }
 
 // PathNode 路径节点，包含节点名称和类型信息
@@ -52,6 +95,11 @@ type PathNode struct {
 // CommandTree 命令树
 type CommandTree struct {
 	Root *CommandNode
+
+	// Strict 严格模式下 AddCommand 拒绝与已注册命令冲突的命令，见 CheckCommand
+	Strict bool
+
+	inherited map[string]bool // 从上一级视图继承的顶层关键字，见 InheritFrom
 }
 
 var ModeCommands = make(map[string]*CommandNode) // 全局视图切换命令存储
@@ -63,6 +111,7 @@ func NewCommandTree() *CommandTree {
 			Name:     "root",
 			Type:     NodeTypeCommand,
 			Children: make(map[string]*CommandNode),
+			registry: &Registry{},
 		},
 	}
 }
@@ -77,84 +126,310 @@ func NewCommandNode(name string, nodeType CommandNodeType, description string) *
 	}
 }
 
-// GetModeCommandKeys 获取ModeCommands中的所有key，按数组返回
+// childRanks 子节点的排列顺序：关键字在前，参数按取值从严格到宽松排列
+// 同一位置的输入可以匹配多个参数时，排在前面的参数优先，如 5 优先匹配 <1-10> 而不是 WORD
+var childRanks = map[CommandNodeType]int{
+	NodeTypeCommand:    0,
+	NodeTypeModeSwitch: 0,
+	NodeTypeEnum:       1,
+	NodeTypeNum:        2,
+	NodeTypeHex:        3,
+	NodeTypeSize:       4,
+	NodeTypeDuration:   5,
+	NodeTypeIPv4:       6,
+	NodeTypeIPv4Prefix: 7,
+	NodeTypeString:     8,
+	NodeTypePath:       8,
+	NodeTypeOptional:   9,
+	NodeTypeLine:       10,
+}
+
+// childLess 比较两个子节点的先后顺序，类型相同时按名称排序
+func childLess(a, b *CommandNode) bool {
+	if childRanks[a.Type] != childRanks[b.Type] {
+		return childRanks[a.Type] < childRanks[b.Type]
+	}
+	return a.Name < b.Name
+}
+
+// addChild 添加子节点并维护有序索引，同名的子节点被替换
+func (n *CommandNode) addChild(child *CommandNode) {
+	child.Parent = n
+	n.insertChild(child)
+}
+
+// insertChild 将节点插入子节点表和有序索引，不修改节点的 Parent
+func (n *CommandNode) insertChild(child *CommandNode) {
+	n.removeChild(child.Name)
+	n.Children[child.Name] = child
+
+	i := sort.Search(len(n.order), func(i int) bool { return childLess(child, n.order[i]) })
+	n.order = append(n.order, nil)
+	copy(n.order[i+1:], n.order[i:])
+	n.order[i] = child
+}
+
+// removeChild 删除子节点及其有序索引
+func (n *CommandNode) removeChild(name string) {
+	old, exists := n.Children[name]
+	if !exists {
+		return
+	}
+	delete(n.Children, name)
+	for i, c := range n.order {
+		if c == old {
+			n.order = append(n.order[:i], n.order[i+1:]...)
+			break
+		}
+	}
+}
+
+// SortedChildren 返回有序的子节点：关键字在前并按名称排序，参数按取值从严格到宽松排列
+// 匹配、补全、帮助和遍历都按这个顺序进行，返回的切片不能修改
+func SortedChildren(n *CommandNode) []*CommandNode {
+	return n.order
+}
+
+// GetModeCommandKeys 获取ModeCommands中的所有key，按名称排序
 func (t *CommandTree) GetModeCommandKeys() []string {
 	keys := make([]string, 0, len(ModeCommands))
 	for key := range ModeCommands {
 		keys = append(keys, key)
 	}
+	sort.Strings(keys)
 	return keys
 }
 
 // AddCommand 添加命令到命令树
-func (t *CommandTree) AddCommand(command string, description string, handler types.CommandHandler, detailedDescription ...string) error {
+func (t *CommandTree) AddCommand(command string, description string, handler types.Handler, detailedDescription ...string) error {
+	// 多行详细描述按记号顺序对应命令规格中的每个记号，在展开分组之前建立对应关系
+	var helps map[string]string
+	if len(detailedDescription) > 0 && detailedDescription[0] != "" {
+		helps = tokenHelps(command, strings.Split(detailedDescription[0], "\n"))
+	}
+
+	if t.Strict {
+		if err := t.CheckCommand(command); err != nil {
+			return err
+		}
+	}
+
+	// 展开关键字分支组和可选组，如 clear counters {interface NAME | all}，每个分支注册为一条命令；
+	// 展开前为参数编号，各分支的参数可以按命令规格中的位置传给处理函数
+	numbered, params := numberParams(command)
+	branches, err := ExpandAlternatives(numbered)
+	if err != nil {
+		return err
+	}
+	named := namedKeywords(command)
+	for _, branch := range branches {
+		if err := t.addBranch(branch, description, handler, helps, params, named); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addBranch 注册展开后的单条命令，params 为命令规格中的参数个数，named 为命名参数的关键字
+func (t *CommandTree) addBranch(command string, description string, handler types.Handler, helps map[string]string, params int, named map[string]bool) error {
+	var slots []int
+	tokens := splitTopLevelFields(command)
+	for i, token := range tokens {
+		var slot int
+		if tokens[i], slot = splitSlot(token); slot >= 0 {
+			slots = append(slots, slot)
+		}
+	}
+	command = strings.Join(tokens, " ")
+
 	// 解析完整的命令字符串，包括参数
 	nodes, err := t.parseCommandString(command)
 	if err != nil {
 		return err
 	}
 
+	t.dropInherited(nodes[0].Name)
+	var labels []string
+	labeled := false
 	current := t.Root
-	for _, node := range nodes {
+	for i, node := range nodes {
+		if isParamNode(node) {
+			label, _ := splitLabel(tokens[i])
+			labels = append(labels, label)
+			labeled = labeled || label != ""
+		}
 		if existing, exists := current.Children[node.Name]; exists {
 			current = existing
 		} else {
-			node.Parent = current
-			current.Children[node.Name] = node
+			current.addChild(node)
 			current = node
 		}
+		if help, exists := helps[tokens[i]]; exists {
+			current.Help = help
+		}
+		if current.Type == NodeTypeCommand && named[current.Name] {
+			current.Named = true
+		}
+	}
+
+	// 内置命令与应用注册的命令互不覆盖，先注册的保留
+	if _, builtin := handler.(BuiltinHandler); current.Handler != nil {
+		if _, existing := current.Handler.(BuiltinHandler); existing != builtin {
+			reason := "a builtin command is registered with the same syntax"
+			if !existing {
+				reason = "the application registered a command with the same syntax"
+			}
+			return &ConflictError{Command: command, Existing: current.Path(), Reason: reason}
+		}
 	}
 
 	// 设置叶子节点的处理函数和描述（叶子节点包含完整的命令信息）
 	current.Handler = handler
 	current.Description = description
+	current.ParamLabels = nil
+	if labeled {
+		current.ParamLabels = labels
+	}
+	current.ParamSlots, current.SpecParams = slots, params
 
-	// 处理多行详细描述
-	if len(detailedDescription) > 0 && detailedDescription[0] != "" {
-		// 将多行描述按\n分割，并保存到每个对应的命令节点
-		lines := strings.Split(detailedDescription[0], "\n")
+	return nil
+}
 
-		// 从根节点开始，为路径上的每个节点设置对应的描述行
-		pathNodes := t.getCommandPathNodes(command)
-		for i, node := range pathNodes {
-			if i < len(lines) && lines[i] != "" {
-				node.Description = lines[i]
-			}
+// HideCommand 将已注册的命令标记为隐藏，命令规格与注册时相同
+// 只被隐藏命令使用的中间关键字也不会出现在帮助和补全中
+func (t *CommandTree) HideCommand(command string) error {
+	leaves, err := t.findLeaves(command)
+	if err != nil {
+		return err
+	}
+	for _, leaf := range leaves {
+		leaf.Hidden = true
+	}
+	return nil
+}
+
+// MarkOperational 将已注册的命令标记为不修改配置的命令，开启候选配置时直接执行
+// 省略可选参数时在中间节点执行，命令路径上的节点都被标记
+func (t *CommandTree) MarkOperational(command string) error {
+	leaves, err := t.findLeaves(command)
+	if err != nil {
+		return err
+	}
+	for _, leaf := range leaves {
+		for n := leaf; n != nil && n != t.Root; n = n.Parent {
+			n.Operational = true
 		}
 	}
+	return nil
+}
 
+// DeprecateCommand 将已注册的命令标记为废弃，replacement 为建议改用的命令，可以为空
+func (t *CommandTree) DeprecateCommand(command string, replacement string) error {
+	leaves, err := t.findLeaves(command)
+	if err != nil {
+		return err
+	}
+	for _, leaf := range leaves {
+		leaf.Deprecated = true
+		leaf.Replacement = replacement
+	}
 	return nil
 }
 
-// getCommandPathNodes 获取命令路径上的所有节点
-func (t *CommandTree) getCommandPathNodes(command string) []*CommandNode {
-	var pathNodes []*CommandNode
+// SetTemplate 替换用 DataHandler 注册的命令的输出模板，text 为空时恢复为 DataHandler 的 Text 输出
+func (t *CommandTree) SetTemplate(command string, text string) error {
+	if text != "" {
+		if _, err := types.ParseTemplate(text); err != nil {
+			return err
+		}
+	}
+	leaves, err := t.findLeaves(command)
+	if err != nil {
+		return err
+	}
+	for _, leaf := range leaves {
+		if _, ok := leaf.Handler.(types.DataHandler); !ok {
+			return fmt.Errorf("command does not return structured data: %s", command)
+		}
+	}
+	for _, leaf := range leaves {
+		handler := leaf.Handler.(types.DataHandler)
+		handler.Template = text
+		leaf.Handler = handler
+	}
+	return nil
+}
 
-	// 解析命令字符串
-	nodes, err := t.parseCommandString(command)
+// findLeaves 按命令规格查找已注册命令的叶子节点，分支组的每个分支对应一个叶子
+func (t *CommandTree) findLeaves(command string) ([]*CommandNode, error) {
+	branches, err := ExpandAlternatives(command)
 	if err != nil {
-		return pathNodes
+		return nil, err
 	}
 
-	// 从根节点开始遍历路径
-	current := t.Root
-	pathNodes = append(pathNodes, current)
+	var leaves []*CommandNode
+	for _, branch := range branches {
+		nodes, err := t.parseCommandString(branch)
+		if err != nil {
+			return nil, err
+		}
+		if t.isInherited(nodes[0].Name) {
+			return nil, fmt.Errorf("command inherited from parent mode: %s", branch)
+		}
+		current := t.Root
+		for _, node := range nodes {
+			child, exists := current.Children[node.Name]
+			if !exists {
+				return nil, fmt.Errorf("command not found: %s", branch)
+			}
+			current = child
+		}
+		leaves = append(leaves, current)
+	}
+	return leaves, nil
+}
 
-	for _, node := range nodes {
-		if existing, exists := current.Children[node.Name]; exists {
-			current = existing
-			pathNodes = append(pathNodes, current)
-		} else {
-			// 如果节点不存在，停止遍历
-			break
+// IsDeprecated 检查节点是否为废弃命令，或者它下面的所有命令都已废弃
+func IsDeprecated(n *CommandNode) bool {
+	if n.Deprecated {
+		return true
+	}
+	if n.Handler != nil || len(n.Children) == 0 {
+		return false
+	}
+	for _, child := range SortedChildren(n) {
+		if !IsDeprecated(child) {
+			return false
 		}
 	}
+	return true
+}
 
-	return pathNodes
+// IsHidden 检查节点是否应从帮助和补全中隐藏
+// 节点本身被标记为隐藏，或者它下面的所有命令都是隐藏命令
+func IsHidden(n *CommandNode) bool {
+	if n.Hidden {
+		return true
+	}
+	if n.Handler != nil || len(n.Children) == 0 {
+		return false
+	}
+	for _, child := range SortedChildren(n) {
+		if !IsHidden(child) {
+			return false
+		}
+	}
+	return true
 }
 
 // AddModeCommand 添加视图切换命令到命令树
 func (t *CommandTree) AddModeCommand(modeName string, description string) error {
+	if t.Strict {
+		if err := t.CheckModeName(modeName); err != nil {
+			return err
+		}
+	}
+
 	// 创建视图切换命令节点
 	node := NewCommandNode(modeName, NodeTypeModeSwitch, description)
 	node.ModeName = modeName
@@ -162,8 +437,8 @@ func (t *CommandTree) AddModeCommand(modeName string, description string) error
 	node.Type = NodeTypeModeSwitch
 
 	// 添加到根节点
-	t.Root.Children[modeName] = node
-	node.Parent = t.Root
+	t.dropInherited(modeName)
+	t.Root.addChild(node)
 
 	// 同时添加到全局视图切换命令存储
 	ModeCommands[modeName] = node
@@ -171,18 +446,42 @@ func (t *CommandTree) AddModeCommand(modeName string, description string) error
 	return nil
 }
 
+// AddSubModeCommand 添加只在本视图中可用的视图切换命令，用于进入嵌套的子视图
+// name 为输入的关键字，modePath 为子视图的完整路径，如 configure/interface
+func (t *CommandTree) AddSubModeCommand(name, modePath, description string) error {
+	if t.Strict {
+		if err := t.CheckModeName(name); err != nil {
+			return err
+		}
+	}
+
+	node := NewCommandNode(name, NodeTypeModeSwitch, description)
+	node.ModeName = modePath
+	node.IsRequired = true
+	t.dropInherited(name)
+	t.Root.addChild(node)
+	return nil
+}
+
 // parseCommandString 解析命令字符串，构建完整的树结构
 func (t *CommandTree) parseCommandString(command string) ([]*CommandNode, error) {
 	var nodes []*CommandNode
 
-	// 按空格分割命令
-	parts := strings.Fields(command)
+	// 按空格分割命令，枚举值说明中的空格不拆分
+	parts := splitTopLevelFields(command)
 
-	for _, part := range parts {
+	for i, part := range parts {
+		_, part = splitLabel(part)
 		node, err := t.parseCommandPart(part)
 		if err != nil {
 			return nil, err
 		}
+		if node.Repeat && i != len(parts)-1 {
+			return nil, fmt.Errorf("repeated parameter must be the last token: %s", part)
+		}
+		if node.Type == NodeTypeLine && i != len(parts)-1 {
+			return nil, fmt.Errorf("LINE parameter must be the last token: %s", part)
+		}
 
 		nodes = append(nodes, node)
 	}
@@ -192,6 +491,19 @@ func (t *CommandTree) parseCommandString(command string) ([]*CommandNode, error)
 
 // parseCommandPart 解析命令部分，支持参数语法
 func (t *CommandTree) parseCommandPart(part string) (*CommandNode, error) {
+	// 可重复参数：去掉 ... 或 + 后缀解析基础参数
+	if base, ok := trimRepeatSuffix(part); ok {
+		node, err := t.parseCommandPart(base)
+		if err != nil {
+			return nil, err
+		}
+		if node.Type == NodeTypeCommand || node.Type == NodeTypeOptional || node.Type == NodeTypeLine {
+			return nil, fmt.Errorf("only parameters can repeat: %s", part)
+		}
+		node.Repeat = true
+		return node, nil
+	}
+
 	// 定义参数类型解析器
 	parsers := []struct {
 		prefix, suffix string
@@ -200,7 +512,6 @@ func (t *CommandTree) parseCommandPart(part string) (*CommandNode, error) {
 		isRequired     bool
 		parser         func(string) (*CommandNode, bool)
 	}{
-		{"[", "]", NodeTypeOptional, "Optional parameter", false, t.parseOptionalParam},
 		{"(", ")", NodeTypeEnum, "Enum parameter", true, t.parseEnumParam},
 		{"<", ">", NodeTypeNum, "Range parameter", true, t.parseRangeParam},
 	}
@@ -214,6 +525,48 @@ func (t *CommandTree) parseCommandPart(part string) (*CommandNode, error) {
 		}
 	}
 
+	// 容量参数，如 10k、512M
+	if part == sizeToken {
+		node := NewCommandNode(part, NodeTypeSize, "Size with optional unit suffix")
+		node.IsRequired = true
+		return node, nil
+	}
+
+	// 时长参数，如 30s、1h30m
+	if part == durationToken {
+		node := NewCommandNode(part, NodeTypeDuration, "Duration such as 30s, 5m or 1h30m")
+		node.IsRequired = true
+		return node, nil
+	}
+
+	// IPv4 地址参数
+	if part == ipv4Token {
+		node := NewCommandNode(part, NodeTypeIPv4, "IPv4 address")
+		node.IsRequired = true
+		return node, nil
+	}
+
+	// IPv4 前缀参数
+	if part == ipv4PrefixToken {
+		node := NewCommandNode(part, NodeTypeIPv4Prefix, "IPv4 prefix")
+		node.IsRequired = true
+		return node, nil
+	}
+
+	// 行尾文本参数，必须是命令的最后一个记号
+	if part == lineToken {
+		node := NewCommandNode(part, NodeTypeLine, "Text to the end of the line")
+		node.IsRequired = true
+		return node, nil
+	}
+
+	// 文件路径参数
+	if part == pathToken {
+		node := NewCommandNode(part, NodeTypePath, "File path")
+		node.IsRequired = true
+		return node, nil
+	}
+
 	// 字符串参数（全大写字母）
 	if isAllUppercase(part) {
 		return NewCommandNode(part, NodeTypeString, "String parameter"), nil
@@ -223,20 +576,29 @@ func (t *CommandTree) parseCommandPart(part string) (*CommandNode, error) {
 	return NewCommandNode(part, NodeTypeCommand, "Command"), nil
 }
 
-// parseOptionalParam 解析可选参数
-func (t *CommandTree) parseOptionalParam(part string) (*CommandNode, bool) {
-	param := strings.Trim(part, "[]")
-	node := NewCommandNode(param, NodeTypeOptional, "Optional parameter")
-	node.IsRequired = false
-	return node, true
-}
-
-// parseEnumParam 解析枚举参数
+// parseEnumParam 解析枚举参数，取值可以带说明，如 (on:Enable feature|off:Disable feature)
+// 带说明的枚举节点名称只保留取值，如 (on|off)
 func (t *CommandTree) parseEnumParam(part string) (*CommandNode, bool) {
 	param := strings.Trim(part, "()")
-	values := strings.Split(param, "|")
-	node := NewCommandNode(part, NodeTypeEnum, "Enum parameter")
+	var values []string
+	helps := make(map[string]string)
+	for _, value := range strings.Split(param, "|") {
+		if colon := strings.IndexByte(value, ':'); colon > 0 {
+			helps[strings.TrimSpace(value[:colon])] = strings.TrimSpace(value[colon+1:])
+			value = value[:colon]
+		}
+		values = append(values, strings.TrimSpace(value))
+	}
+
+	name := part
+	if len(helps) > 0 {
+		name = "(" + strings.Join(values, "|") + ")"
+	}
+	node := NewCommandNode(name, NodeTypeEnum, "Enum parameter")
 	node.EnumValues = values
+	if len(helps) > 0 {
+		node.EnumHelp = helps
+	}
 	node.IsRequired = true
 	return node, true
 }
@@ -244,28 +606,53 @@ func (t *CommandTree) parseEnumParam(part string) (*CommandNode, bool) {
 // parseRangeParam 解析数值范围参数
 func (t *CommandTree) parseRangeParam(part string) (*CommandNode, bool) {
 	param := strings.Trim(part, "<>")
-	if !strings.Contains(param, "-") {
-		return nil, false
-	}
 
-	rangeParts := strings.Split(param, "-")
-	if len(rangeParts) != 2 {
-		return nil, false
+	// 十六进制范围，如 <0x0-0xFFFF>
+	if rangeParts := strings.Split(param, "-"); len(rangeParts) == 2 && isHexBound(rangeParts[0]) && isHexBound(rangeParts[1]) {
+		return t.parseHexRangeParam(part, rangeParts[0], rangeParts[1])
 	}
 
-	min, err1 := strconv.Atoi(rangeParts[0])
-	max, err2 := strconv.Atoi(rangeParts[1])
-	if err1 != nil || err2 != nil {
+	// 十进制范围，下限允许为负数，如 <-100-100>
+	matches := rangePattern.FindStringSubmatch(param)
+	if matches == nil {
 		return nil, false
 	}
 
 	node := NewCommandNode(part, NodeTypeNum, "Range parameter")
-	node.RangeMin = min
-	node.RangeMax = max
+	if !setRangeBounds(node, matches[1], matches[2]) {
+		return nil, false
+	}
 	node.IsRequired = true
 	return node, true
 }
 
+// rangePattern 十进制范围参数的边界格式
+var rangePattern = regexp.MustCompile(`^(-?\d+)-(-?\d+)$`)
+
+// setRangeBounds 解析并设置范围边界，超出 int64 的非负范围改用 uint64 存储
+func setRangeBounds(node *CommandNode, minBound, maxBound string) bool {
+	min, err1 := strconv.ParseInt(minBound, 10, 64)
+	max, err2 := strconv.ParseInt(maxBound, 10, 64)
+	if err1 == nil && err2 == nil {
+		if min > max {
+			return false
+		}
+		node.RangeMin = min
+		node.RangeMax = max
+		return true
+	}
+
+	umin, err1 := strconv.ParseUint(minBound, 10, 64)
+	umax, err2 := strconv.ParseUint(maxBound, 10, 64)
+	if err1 != nil || err2 != nil || umin > umax {
+		return false
+	}
+	node.Unsigned = true
+	node.URangeMin = umin
+	node.URangeMax = umax
+	return true
+}
+
 // isAllUppercase 检查字符串是否全大写字母
 func isAllUppercase(s string) bool {
 	if s == "" {
@@ -290,19 +677,117 @@ func (t *CommandTree) FindCommand(args []string) (*CommandNode, []string, []stri
 		}
 	}
 
+	// 视图切换命令的缩写，如 conf 匹配 configure
+	if len(args) == 1 {
+		candidates := make(map[string]bool)
+		for name := range ModeCommands {
+			if strings.HasPrefix(name, args[0]) {
+				candidates[name] = true
+			}
+		}
+		matches := MatchKeyword(t.Root, args[0])
+		if len(matches) == 1 && matches[0].Name == args[0] {
+			// 精确匹配的关键字优先于缩写
+			candidates = nil
+		}
+		if len(candidates) > 0 {
+			for _, child := range matches {
+				candidates[child.Name] = true
+			}
+			if len(candidates) > 1 {
+				return nil, nil, nil, newAmbiguousError(0, args[0], candidates)
+			}
+			for name := range candidates {
+				return ModeCommands[name], []string{name}, []string{}, nil
+			}
+		}
+	}
+
 	// 否则使用正常的命令查找逻辑
 	return t.Root.findCommand(args, nil, nil)
 }
 
+// AmbiguousError 输入的缩写同时匹配多个关键字
+type AmbiguousError struct {
+	Index      int      // 缩写在输入中的位置
+	Token      string   // 用户输入的缩写
+	Candidates []string // 匹配的关键字，按字母排序
+	Expansions []string // 输入可能对应的完整命令，如 "clear test1"，按字母排序
+}
+
+// Error 实现 error 接口
+func (e *AmbiguousError) Error() string {
+	return fmt.Sprintf("ambiguous command: %s (%s)", e.Token, strings.Join(e.Candidates, ", "))
+}
+
+// newAmbiguousError 根据候选关键字集合创建歧义错误
+func newAmbiguousError(index int, token string, candidates map[string]bool) *AmbiguousError {
+	err := &AmbiguousError{Index: index, Token: token}
+	for name := range candidates {
+		err.Candidates = append(err.Candidates, name)
+	}
+	sort.Strings(err.Candidates)
+	err.Expansions = err.Candidates
+	return err
+}
+
+// expand 返回后续输入在该节点之下所有可能的完整形式，关键字缩写展开为完整关键字，参数保持原样
+func (n *CommandNode) expand(args []string) [][]string {
+	if len(args) == 0 {
+		return [][]string{{}}
+	}
+
+	var result [][]string
+	for _, child := range MatchKeyword(n, args[0]) {
+		for _, seq := range child.expand(args[1:]) {
+			result = append(result, append([]string{child.Name}, seq...))
+		}
+	}
+	for _, child := range SortedChildren(n) {
+		if child.Type == NodeTypeCommand || child.Type == NodeTypeModeSwitch || child.Type == NodeTypeOptional {
+			continue
+		}
+		if child.Type == NodeTypeLine || (child.Repeat && IsParameterMatch(child, args[0])) {
+			result = append(result, append([]string{}, args...))
+			continue
+		}
+		if IsParameterMatch(child, args[0]) {
+			for _, seq := range child.expand(args[1:]) {
+				result = append(result, append([]string{args[0]}, seq...))
+			}
+		}
+	}
+	return result
+}
+
+// MatchKeyword 返回与输入匹配的关键字子节点
+// 精确匹配优先，否则返回所有以输入为前缀的关键字，只有一个时即为唯一缩写
+func MatchKeyword(n *CommandNode, arg string) []*CommandNode {
+	var matches []*CommandNode
+	for _, child := range SortedChildren(n) {
+		if child.Type != NodeTypeCommand && child.Type != NodeTypeModeSwitch {
+			continue
+		}
+		if child.Name == arg {
+			return []*CommandNode{child}
+		}
+		// 隐藏命令必须完整输入
+		if strings.HasPrefix(child.Name, arg) && !IsHidden(child) {
+			matches = append(matches, child)
+		}
+	}
+	return matches
+}
+
 // findCommand 递归查找匹配的命令
 func (n *CommandNode) findCommand(args []string, path []string, matchArgs []string) (*CommandNode, []string, []string, error) {
 	if len(args) == 0 {
-		// 到达命令末尾，返回当前节点
-		if n.Handler != nil {
+		// 到达命令末尾，返回当前节点；嵌套视图的切换命令没有处理函数，由会话切换视图
+		if n.Handler != nil || n.Type == NodeTypeModeSwitch {
 			return n, path, matchArgs, nil
 		}
 		// 如果没有处理函数，继续查找可选参数
-		for _, child := range n.Children {
+		for _, child := range SortedChildren(n) {
 			if child.Type == NodeTypeOptional {
 				return child.findCommand(args, path, matchArgs)
 			}
@@ -313,15 +798,35 @@ func (n *CommandNode) findCommand(args []string, path []string, matchArgs []stri
 	currentArg := args[0]
 	remainingArgs := args[1:]
 
-	// 首先尝试精确匹配命令节点
-	for _, child := range n.Children {
-		if (child.Type == NodeTypeCommand || child.Type == NodeTypeModeSwitch) && child.Name == currentArg {
-			return child.findCommand(remainingArgs, append(path, currentArg), matchArgs)
+	// 首先尝试匹配命令节点，精确匹配优先，其次是唯一的前缀缩写（如 sh 匹配 show）
+	if matches := MatchKeyword(n, currentArg); len(matches) == 1 {
+		return matches[0].findCommand(remainingArgs, append(path, matches[0].Name), matchArgs)
+	} else if len(matches) > 1 {
+		candidates := make(map[string]bool)
+		var expansions []string
+		for _, child := range matches {
+			candidates[child.Name] = true
+			for _, seq := range child.expand(remainingArgs) {
+				tokens := append(append(append([]string{}, path...), child.Name), seq...)
+				expansions = append(expansions, strings.Join(tokens, " "))
+			}
 		}
+		err := newAmbiguousError(len(path), currentArg, candidates)
+		if len(expansions) > 0 {
+			// 只列出能匹配全部输入的展开，全部不能匹配时列出候选关键字
+			sort.Strings(expansions)
+			err.Expansions = expansions
+		} else {
+			err.Expansions = nil
+			for _, name := range err.Candidates {
+				err.Expansions = append(err.Expansions, strings.Join(append(append([]string{}, path...), name), " "))
+			}
+		}
+		return nil, path, matchArgs, err
 	}
 
 	// 如果没有精确匹配，尝试参数节点匹配
-	for _, child := range n.Children {
+	for _, child := range SortedChildren(n) {
 		// 参数节点匹配：基于参数类型验证值
 		// 首先检查节点是否是参数节点（非命令节点）
 		if child.Type != types.NodeTypeCommand {
@@ -330,6 +835,18 @@ func (n *CommandNode) findCommand(args []string, path []string, matchArgs []stri
 				if matchedNode, matchedPath, tmpargs, err := child.findCommand(args, path, matchArgs); err == nil {
 					return matchedNode, matchedPath, tmpargs, nil
 				}
+			} else if child.Repeat {
+				// 可重复参数消耗剩余全部输入，每个值单独校验
+				for _, arg := range args {
+					if !IsParameterMatch(child, arg) {
+						return nil, path, matchArgs, fmt.Errorf("invalid parameter value: %s", arg)
+					}
+				}
+				return child.findCommand(nil, append(path, args...), append(matchArgs, args...))
+			} else if child.Type == NodeTypeLine {
+				// 行尾文本参数消耗剩余全部输入，作为一个参数传给处理函数
+				text := strings.Join(args, " ")
+				return child.findCommand(nil, append(path, text), append(matchArgs, text))
 			} else if IsParameterMatch(child, currentArg) {
 				// 参数节点匹配成功，返回当前节点，剩余参数作为处理函数的参数
 				return child.findCommand(remainingArgs, append(path, currentArg), append(matchArgs, currentArg))
@@ -340,21 +857,23 @@ func (n *CommandNode) findCommand(args []string, path []string, matchArgs []stri
 	// 如果没有匹配的子节点，检查当前节点是否有处理函数
 	if n.Handler != nil {
 		// 当前节点有处理函数，但还有未匹配的参数
-		// 将这些参数传递给处理函数
-		return n, path, matchArgs, nil
+		// 将这些参数传递给处理函数，由参数校验决定是否接受
+		return n, path, append(matchArgs, args...), nil
 	}
 
 	return nil, path, matchArgs, fmt.Errorf("unknown command: %s", currentArg)
 }
 
-// GetCompletions 获取补全建议
-func (n *CommandNode) GetCompletions(args []string) []string {
+// GetCompletions 获取补全建议，fileRoot 为路径参数的根目录，为空时路径参数只给出参数提示
+func (n *CommandNode) GetCompletions(fileRoot string, args []string) []string {
 	var completions []string
 
 	if len(args) == 0 {
 		// 返回所有子节点的名称
-		for name := range n.Children {
-			completions = append(completions, name)
+		for _, child := range SortedChildren(n) {
+			if !IsHidden(child) {
+				completions = append(completions, child.Name)
+			}
 		}
 		return completions
 	}
@@ -363,14 +882,17 @@ func (n *CommandNode) GetCompletions(args []string) []string {
 	remainingArgs := args[1:]
 
 	// 查找匹配的子节点
-	for _, child := range n.Children {
+	for _, child := range SortedChildren(n) {
+		if IsHidden(child) {
+			continue
+		}
 		switch child.Type {
 		case NodeTypeCommand, NodeTypeModeSwitch:
 			if strings.HasPrefix(child.Name, currentArg) {
 				if len(remainingArgs) == 0 {
 					completions = append(completions, child.Name)
 				} else {
-					completions = append(completions, child.GetCompletions(remainingArgs)...)
+					completions = append(completions, child.GetCompletions(fileRoot, remainingArgs)...)
 				}
 			}
 		case NodeTypeEnum:
@@ -384,16 +906,38 @@ func (n *CommandNode) GetCompletions(args []string) []string {
 		case NodeTypeNum:
 			if len(remainingArgs) == 0 {
 				// 返回范围提示
-				completions = append(completions, fmt.Sprintf("<%d-%d>", child.RangeMin, child.RangeMax))
+				completions = append(completions, child.Name)
 			}
-		case NodeTypeString:
-			if len(remainingArgs) == 0 {
+		case NodeTypeString, NodeTypeLine:
+			if values, ok := DynamicValues(child); ok {
+				if len(remainingArgs) == 0 {
+					completions = append(completions, GetDynamicCompletions(values, currentArg)...)
+				} else if isValidDynamicValue(values, currentArg) {
+					completions = append(completions, child.GetCompletions(fileRoot, remainingArgs)...)
+				}
+			} else if len(remainingArgs) == 0 || child.Type == NodeTypeLine {
 				completions = append(completions, child.Name)
 			}
+		case NodeTypePath:
+			if len(remainingArgs) == 0 {
+				if fileRoot != "" {
+					completions = append(completions, PathCompletions(fileRoot, currentArg)...)
+				} else {
+					completions = append(completions, child.Name)
+				}
+			} else {
+				completions = append(completions, child.GetCompletions(fileRoot, remainingArgs)...)
+			}
+		case NodeTypeIPv4, NodeTypeIPv4Prefix, NodeTypeHex, NodeTypeSize, NodeTypeDuration:
+			if len(remainingArgs) == 0 {
+				completions = append(completions, ParameterHint(child))
+			} else if IsParameterMatch(child, currentArg) {
+				completions = append(completions, child.GetCompletions(fileRoot, remainingArgs)...)
+			}
 		case NodeTypeOptional:
 			// 可选参数：同时考虑包含和不包含的情况
-			completions = append(completions, child.GetCompletions(args)...)
-			completions = append(completions, child.GetCompletions(remainingArgs)...)
+			completions = append(completions, child.GetCompletions(fileRoot, args)...)
+			completions = append(completions, child.GetCompletions(fileRoot, remainingArgs)...)
 		}
 	}
 
@@ -404,7 +948,7 @@ func (n *CommandNode) GetCompletions(args []string) []string {
 func (n *CommandNode) ValidateCommand(args []string) error {
 	if len(args) == 0 {
 		// 检查必需参数
-		for _, child := range n.Children {
+		for _, child := range SortedChildren(n) {
 			if child.IsRequired && child.Type != NodeTypeOptional {
 				return fmt.Errorf("missing required parameter: %s", child.Name)
 			}
@@ -416,7 +960,7 @@ func (n *CommandNode) ValidateCommand(args []string) error {
 	remainingArgs := args[1:]
 
 	// 验证当前参数
-	for _, child := range n.Children {
+	for _, child := range SortedChildren(n) {
 		switch child.Type {
 		case NodeTypeCommand:
 			if child.Name == currentArg {
@@ -435,13 +979,43 @@ func (n *CommandNode) ValidateCommand(args []string) error {
 			}
 			return child.ValidateCommand(remainingArgs)
 		case NodeTypeNum:
-			if num, err := strconv.Atoi(currentArg); err != nil {
-				return fmt.Errorf("invalid number: %s", currentArg)
-			} else if num < child.RangeMin || num > child.RangeMax {
-				return fmt.Errorf("number out of range: %d, expected %d-%d", num, child.RangeMin, child.RangeMax)
+			if !isValidNumberInRange(child, currentArg) {
+				return fmt.Errorf("invalid number: %s, expected %s", currentArg, child.Name)
 			}
 			return child.ValidateCommand(remainingArgs)
 		case NodeTypeString:
+			if values, ok := DynamicValues(child); ok && !isValidDynamicValue(values, currentArg) {
+				return fmt.Errorf("invalid value: %s, expected one of: %v", currentArg, values)
+			}
+			return child.ValidateCommand(remainingArgs)
+		case NodeTypeLine:
+			return nil
+		case NodeTypeIPv4:
+			if !isValidIPv4(currentArg) {
+				return fmt.Errorf("invalid IPv4 address: %s", currentArg)
+			}
+			return child.ValidateCommand(remainingArgs)
+		case NodeTypeIPv4Prefix:
+			if _, err := ParseIPv4Prefix(currentArg); err != nil {
+				return err
+			}
+			return child.ValidateCommand(remainingArgs)
+		case NodeTypeHex:
+			if !isValidHexInRange(child, currentArg) {
+				return fmt.Errorf("invalid hex value: %s, expected %s", currentArg, child.Name)
+			}
+			return child.ValidateCommand(remainingArgs)
+		case NodeTypeSize:
+			if _, err := ParseSize(currentArg); err != nil {
+				return err
+			}
+			return child.ValidateCommand(remainingArgs)
+		case NodeTypeDuration:
+			if _, err := ParseDuration(currentArg); err != nil {
+				return err
+			}
+			return child.ValidateCommand(remainingArgs)
+		case NodeTypePath:
 			return child.ValidateCommand(remainingArgs)
 		case NodeTypeOptional:
 			// 可选参数：尝试验证，如果失败则跳过
@@ -471,6 +1045,15 @@ func (n *CommandNode) GetCommandDescription() string {
 	return strings.Join(parts, " ")
 }
 
+// Path 返回从根节点到当前节点的命令路径，如 "show running-config"
+func (n *CommandNode) Path() string {
+	var parts []string
+	for current := n; current != nil && current.Parent != nil; current = current.Parent {
+		parts = append([]string{current.Name}, parts...)
+	}
+	return strings.Join(parts, " ")
+}
+
 // PrintTree 打印命令树结构
 func (t *CommandTree) PrintTree() string {
 	var result strings.Builder
@@ -493,13 +1076,20 @@ func (t *CommandTree) printNode(node *CommandNode, prefix string, isLast bool, r
 		if node.Handler != nil {
 			// 获取处理函数的名称
 			handlerName := getFunctionName(node.Handler)
-			result.WriteString(fmt.Sprintf("%s [Handler: %s] (%s)", node.Name, handlerName, getNodeTypeString(node.Type)))
+			result.WriteString(fmt.Sprintf("%s [Handler: %s] (%s)", DisplayName(node), handlerName, getNodeTypeString(node.Type)))
 		} else {
-			result.WriteString(fmt.Sprintf("%s (%s)", node.Name, getNodeTypeString(node.Type)))
+			result.WriteString(fmt.Sprintf("%s (%s)", DisplayName(node), getNodeTypeString(node.Type)))
+		}
+
+		if node.Hidden {
+			result.WriteString(" [hidden]")
+		}
+		if node.Deprecated {
+			result.WriteString(" [deprecated]")
 		}
 
-		if node.Description != "" && node.Description != "Command" {
-			result.WriteString(fmt.Sprintf(" - %s", node.Description))
+		if help := HelpText(node); help != "" && help != "Command" {
+			result.WriteString(fmt.Sprintf(" - %s", help))
 		}
 		result.WriteString("\n")
 	}
@@ -515,16 +1105,7 @@ func (t *CommandTree) printNode(node *CommandNode, prefix string, isLast bool, r
 			}
 		}
 
-		children := make([]*CommandNode, 0, len(node.Children))
-		for _, child := range node.Children {
-			children = append(children, child)
-		}
-
-		// 按名称排序子节点，确保输出一致
-		sort.Slice(children, func(i, j int) bool {
-			return children[i].Name < children[j].Name
-		})
-
+		children := SortedChildren(node)
 		for i, child := range children {
 			isLastChild := i == len(children)-1
 			t.printNode(child, childPrefix, isLastChild, result)
@@ -533,13 +1114,23 @@ func (t *CommandTree) printNode(node *CommandNode, prefix string, isLast bool, r
 }
 
 // getFunctionName 获取函数名称
-func getFunctionName(handler types.CommandHandler) string {
+func getFunctionName(handler types.Handler) string {
 	if handler == nil {
 		return "nil"
 	}
 
+	if builtin, ok := handler.(BuiltinHandler); ok {
+		return "builtin " + string(builtin)
+	}
+
+	// DataHandler 以返回数据的函数命名
+	var fn interface{} = handler
+	if data, ok := handler.(types.DataHandler); ok {
+		fn = data.Data
+	}
+
 	// 使用反射获取函数指针
-	funcValue := reflect.ValueOf(handler)
+	funcValue := reflect.ValueOf(fn)
 	if funcValue.Kind() != reflect.Func {
 		return "unknown"
 	}
@@ -578,6 +1169,22 @@ func getNodeTypeString(nodeType CommandNodeType) string {
 		return "Range"
 	case NodeTypeString:
 		return "String"
+	case NodeTypeIPv4:
+		return "IPv4"
+	case NodeTypeIPv4Prefix:
+		return "IPv4Prefix"
+	case NodeTypeHex:
+		return "Hex"
+	case NodeTypeLine:
+		return "Line"
+	case NodeTypeSize:
+		return "Size"
+	case NodeTypeDuration:
+		return "Duration"
+	case NodeTypePath:
+		return "Path"
+	case NodeTypeModeSwitch:
+		return "ModeSwitch"
 	default:
 		return "Unknown"
 	}
@@ -594,10 +1201,28 @@ func IsParameterMatch(node *CommandNode, input string) bool {
 		return isValidNumberInRange(node, input)
 	case NodeTypeEnum: // 枚举参数，如 (on|off)
 		return isValidEnumValue(node, input)
-	case NodeTypeString:
+	case NodeTypeString, NodeTypeLine:
+		if values, ok := DynamicValues(node); ok {
+			return isValidDynamicValue(values, input)
+		}
 		if isString(input) {
 			return true
 		}
+	case NodeTypeIPv4:
+		return isValidIPv4(input)
+	case NodeTypeIPv4Prefix:
+		_, err := ParseIPv4Prefix(input)
+		return err == nil
+	case NodeTypeHex:
+		return isValidHexInRange(node, input)
+	case NodeTypeSize:
+		_, err := ParseSize(input)
+		return err == nil
+	case NodeTypeDuration:
+		_, err := ParseDuration(input)
+		return err == nil
+	case NodeTypePath:
+		return isString(input)
 	default:
 		// 默认情况下，如果参数名包含输入，则认为匹配
 		return false
@@ -605,95 +1230,136 @@ func IsParameterMatch(node *CommandNode, input string) bool {
 	return false
 }
 
-// isValidNumberInRange 检查数字参数值是否在指定范围内
-func isValidNumberInRange(node *CommandNode, input string) bool {
-	// 首先检查是否是有效数字
-	num, err := strconv.Atoi(input)
-	if err != nil {
-		return false
-	}
-
-	// 从节点描述中提取范围信息
-	// 范围参数格式如: <1-10> 或 [1-100]
-	min, max := extractNumberRange(node.Name)
-
-	// 如果没有指定范围，接受任何有效数字
-	if min == 0 && max == 0 {
-		return true
+// GetParameterValidationError 获取参数验证错误信息
+func GetParameterValidationError(node *CommandNode, input string) string {
+	switch node.Type {
+	case NodeTypeEnum:
+		return GetEnumValidationError(node, input)
+	case NodeTypeNum:
+		return GetNumberValidationError(node, input)
+	case NodeTypeIPv4:
+		return GetIPv4ValidationError(node, input)
+	case NodeTypeIPv4Prefix:
+		return GetIPv4PrefixValidationError(node, input)
+	case NodeTypeHex:
+		return GetHexValidationError(node, input)
+	case NodeTypeSize:
+		return GetSizeValidationError(node, input)
+	case NodeTypeDuration:
+		return GetDurationValidationError(node, input)
+	case NodeTypeString:
+		if values, ok := DynamicValues(node); ok {
+			return GetDynamicValidationError(node, values, input)
+		}
+		return fmt.Sprintf("无效的参数值: '%s'", input)
+	default:
+		return fmt.Sprintf("无效的参数值: '%s'", input)
 	}
+}
 
-	// 检查数字是否在指定范围内
-	return num >= min && num <= max
+// ExplainMismatch 沿命令树匹配输入，找到第一个不合法的参数值
+// 返回该参数在输入中的位置和验证错误信息；如果失败原因不是参数值非法则返回空信息
+func (t *CommandTree) ExplainMismatch(args []string) (int, string) {
+	index, _, msg := t.mismatch(args)
+	return index, msg
 }
 
-// extractNumberRange 从描述中提取数字范围
-func extractNumberRange(Name string) (int, int) {
-	// 范围通常用尖括号或方括号括起来，如 <1-10> 或 [1-100]
-	re := regexp.MustCompile(`[<\[](\d+)-(\d+)[>\]]`)
-	matches := re.FindStringSubmatch(Name)
-	if len(matches) == 3 {
-		min, err1 := strconv.Atoi(matches[1])
-		max, err2 := strconv.Atoi(matches[2])
-		if err1 == nil && err2 == nil {
-			return min, max
+// mismatch 返回第一个无法匹配的记号的位置、该记号之前的输入到达的节点和原因，全部匹配时位置为 len(args)
+func (t *CommandTree) mismatch(args []string) (int, *CommandNode, string) {
+	node := t.Root
+	for i, arg := range args {
+		var next, param *CommandNode
+		matches := MatchKeyword(node, arg)
+		if len(matches) == 1 {
+			next = matches[0]
+		} else if len(matches) > 1 {
+			return i, node, ""
 		}
+		if next == nil {
+			for _, child := range SortedChildren(node) {
+				if child.Type == NodeTypeCommand || child.Type == NodeTypeModeSwitch || child.Type == NodeTypeOptional {
+					continue
+				}
+				if IsParameterMatch(child, arg) {
+					next = child
+					break
+				}
+				if param == nil {
+					param = child
+				}
+			}
+		}
+		if next == nil {
+			if param != nil {
+				return i, node, GetParameterValidationError(param, arg)
+			}
+			return i, node, ""
+		}
+		if next.Type == NodeTypeLine {
+			break
+		}
+		if next.Repeat {
+			for j := i + 1; j < len(args); j++ {
+				if !IsParameterMatch(next, args[j]) {
+					return j, next, GetParameterValidationError(next, args[j])
+				}
+			}
+			break
+		}
+		node = next
 	}
+	return len(args), node, ""
+}
 
-	// 如果没有找到范围，返回0表示无范围限制
-	return 0, 0
+// isValidNumberInRange 检查数字参数值是否在指定范围内
+func isValidNumberInRange(node *CommandNode, input string) bool {
+	return GetNumberValidationError(node, input) == ""
 }
 
 // GetNumberValidationError 获取数字参数验证错误信息
+// 解析使用 64 位整数，超出可表示范围的输入按越界处理，不会溢出回绕
 func GetNumberValidationError(node *CommandNode, input string) string {
-	// 首先检查是否是有效数字
-	num, err := strconv.Atoi(input)
-	if err != nil {
-		return fmt.Sprintf("无效的数字格式: '%s'", input)
-	}
-
-	// 从节点描述中提取范围信息
-	min, max := extractNumberRange(node.Name)
-
-	// 如果没有指定范围，接受任何有效数字
-	if min == 0 && max == 0 {
+	if node.Unsigned {
+		num, err := strconv.ParseUint(input, 10, 64)
+		if err != nil {
+			return numberParseError(node, input, err)
+		}
+		if num < node.URangeMin {
+			return fmt.Sprintf("数字太小: %d，有效范围: %d-%d", num, node.URangeMin, node.URangeMax)
+		}
+		if num > node.URangeMax {
+			return fmt.Sprintf("数字太大: %d，有效范围: %d-%d", num, node.URangeMin, node.URangeMax)
+		}
 		return ""
 	}
 
-	// 检查数字是否在指定范围内
-	if num >= min && num <= max {
-		return ""
+	num, err := strconv.ParseInt(input, 10, 64)
+	if err != nil {
+		return numberParseError(node, input, err)
 	}
-
-	// 生成错误消息
-	if num < min {
-		return fmt.Sprintf("数字太小: %d，有效范围: %d-%d", num, min, max)
+	if num < node.RangeMin {
+		return fmt.Sprintf("数字太小: %d，有效范围: %d-%d", num, node.RangeMin, node.RangeMax)
 	}
-	if num > max {
-		return fmt.Sprintf("数字太大: %d，有效范围: %d-%d", num, min, max)
+	if num > node.RangeMax {
+		return fmt.Sprintf("数字太大: %d，有效范围: %d-%d", num, node.RangeMin, node.RangeMax)
 	}
+	return ""
+}
 
-	return fmt.Sprintf("数字 %d 超出有效范围: %d-%d", num, min, max)
+// numberParseError 生成数字解析失败的错误信息
+func numberParseError(node *CommandNode, input string, err error) string {
+	if numErr, ok := err.(*strconv.NumError); ok && numErr.Err == strconv.ErrRange {
+		return fmt.Sprintf("数字超出有效范围: %s，有效范围: %s", input, strings.Trim(node.Name, "<>"))
+	}
+	return fmt.Sprintf("无效的数字格式: '%s'", input)
 }
 
 // GetNumberCompletions 获取数字参数的补全选项
 func GetNumberCompletions(node *CommandNode, input string) []string {
-	// 数字参数通常不需要补全，但可以显示范围提示
-	min, max := extractNumberRange(node.Description)
-	if min == 0 && max == 0 {
-		return nil
-	}
-
-	// 如果输入为空，显示范围提示
+	// 数字参数不需要补全，输入为空时显示范围提示
 	if len(input) == 0 {
-		return []string{fmt.Sprintf("<范围: %d-%d>", min, max)}
+		return []string{node.Name}
 	}
-
-	// 检查输入是否为部分数字
-	if _, err := strconv.Atoi(input); err == nil {
-		// 输入已经是有效数字，不需要补全
-		return nil
-	}
-
 	return nil
 }
 
@@ -0,0 +1,121 @@
+package commandtree
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/TrailHuang/tnlcmd/pkg/types"
+)
+
+func TestNumberRangeBounds(t *testing.T) {
+	tests := []struct {
+		spec     string
+		input    string
+		unsigned bool   // 范围超出 int64，按无符号整数解析
+		wantErr  string // 期望的错误信息片段，为空时应当通过验证
+	}{
+		{spec: "<1-4294967295>", input: "1"},
+		{spec: "<1-4294967295>", input: "4294967295"},
+		{spec: "<1-4294967295>", input: "0", wantErr: "数字太小"},
+		{spec: "<1-4294967295>", input: "4294967296", wantErr: "数字太大"},
+		{spec: "<1-4294967295>", input: "-1", wantErr: "数字太小"},
+		{spec: "<-10-10>", input: "-10"},
+		{spec: "<-10-10>", input: "-11", wantErr: "数字太小"},
+		{spec: "<0-9223372036854775807>", input: "9223372036854775807"},
+		{spec: "<0-9223372036854775807>", input: "9223372036854775808", wantErr: "数字超出有效范围"},
+		{spec: "<0-18446744073709551615>", input: "18446744073709551615", unsigned: true},
+		{spec: "<0-18446744073709551615>", input: "9223372036854775808", unsigned: true},
+		{spec: "<0-18446744073709551615>", input: "18446744073709551616", unsigned: true, wantErr: "数字超出有效范围"},
+		{spec: "<0-18446744073709551615>", input: "-1", unsigned: true, wantErr: "无效的数字格式"},
+		{spec: "<9223372036854775808-18446744073709551615>", input: "9223372036854775807", unsigned: true, wantErr: "数字太小"},
+		{spec: "<9223372036854775808-18446744073709551615>", input: "9223372036854775808", unsigned: true},
+		{spec: "<1-4294967295>", input: "99999999999999999999", wantErr: "数字超出有效范围"},
+		{spec: "<1-4294967295>", input: "12a", wantErr: "无效的数字格式"},
+	}
+
+	tree := NewCommandTree()
+	for _, tt := range tests {
+		node, err := tree.parseCommandPart(tt.spec)
+		if err != nil {
+			t.Fatalf("parseCommandPart(%q): %v", tt.spec, err)
+		}
+		if node.Type != NodeTypeNum {
+			t.Fatalf("parseCommandPart(%q): type %v, want number", tt.spec, node.Type)
+		}
+		if node.Unsigned != tt.unsigned {
+			t.Errorf("%s: Unsigned = %v, want %v", tt.spec, node.Unsigned, tt.unsigned)
+		}
+
+		got := GetNumberValidationError(node, tt.input)
+		switch {
+		case tt.wantErr == "" && got != "":
+			t.Errorf("%s %s: unexpected error %q", tt.spec, tt.input, got)
+		case tt.wantErr != "" && !strings.Contains(got, tt.wantErr):
+			t.Errorf("%s %s: error %q, want %q", tt.spec, tt.input, got, tt.wantErr)
+		}
+		if match := IsParameterMatch(node, tt.input); match != (tt.wantErr == "") {
+			t.Errorf("%s %s: IsParameterMatch = %v", tt.spec, tt.input, match)
+		}
+	}
+}
+
+func TestInvalidNumberRange(t *testing.T) {
+	tree := NewCommandTree()
+	for _, spec := range []string{"<10-1>", "<0-18446744073709551616>", "<18446744073709551615-0>"} {
+		if node, err := tree.parseCommandPart(spec); err == nil && node.Type == NodeTypeNum {
+			t.Errorf("parseCommandPart(%q) accepted an invalid range", spec)
+		}
+	}
+}
+
+func TestTrailingTokens(t *testing.T) {
+	tests := []struct {
+		command string
+		wantErr bool
+	}{
+		{command: "description LINE"},
+		{command: "banner motd <text:LINE>"},
+		{command: "foo LINE bar", wantErr: true},
+		{command: "foo <text:LINE> <1-10>", wantErr: true},
+		{command: "ping WORD...", wantErr: false},
+		{command: "ping WORD... count", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		tree := NewCommandTree()
+		err := tree.AddCommand(tt.command, "test", nil)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("AddCommand(%q) error = %v, want error %v", tt.command, err, tt.wantErr)
+		}
+	}
+}
+
+func TestSpecArgs(t *testing.T) {
+	tests := []struct {
+		command string
+		input   string
+		want    []string
+	}{
+		{command: "cmd [a WORD] [b WORD]", input: "cmd b y a x", want: []string{"x", "y"}},
+		{command: "cmd [a WORD] [b WORD]", input: "cmd b y", want: []string{"", "y"}},
+		{command: "cmd [a WORD] [b WORD]", input: "cmd", want: []string{"", ""}},
+		{command: "cmd WORD [x (on|off)] [y WORD]", input: "cmd n y v x on", want: []string{"n", "on", "v"}},
+		{command: "cmd {a <1-10> | b WORD}", input: "cmd b w", want: []string{"", "w"}},
+		{command: "cmd <1-10>...", input: "cmd 1 2 3", want: []string{"1", "2", "3"}},
+	}
+
+	for _, tt := range tests {
+		tree := NewCommandTree()
+		if err := tree.AddCommand(tt.command, "test", types.HandlerFunc(func(ctx *types.Ctx) error { return nil })); err != nil {
+			t.Fatalf("AddCommand(%q): %v", tt.command, err)
+		}
+		node, _, args, err := tree.FindCommand(strings.Fields(tt.input))
+		if err != nil {
+			t.Fatalf("FindCommand(%q): %v", tt.input, err)
+		}
+		if got := SpecArgs(node, args); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q: SpecArgs = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
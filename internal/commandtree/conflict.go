@@ -0,0 +1,199 @@
+package commandtree
+
+import (
+	"fmt"
+
+	"github.com/TrailHuang/tnlcmd/pkg/types"
+)
+
+// ConflictError 注册的命令与已注册的命令冲突，会导致输入无法唯一匹配
+type ConflictError struct {
+	Command  string // 正在注册的命令
+	Existing string // 冲突的已注册命令路径
+	Reason   string
+}
+
+// Error 实现 error 接口
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("command '%s' conflicts with '%s': %s", e.Command, e.Existing, e.Reason)
+}
+
+// CheckCommand 检查命令规格是否会与已注册的命令产生歧义，不修改命令树
+// 检测同一位置取值重叠的不同参数、同一参数的可重复标记不一致、首个关键字与视图名称相同以及重复注册
+func (t *CommandTree) CheckCommand(command string) error {
+	branches, err := ExpandAlternatives(command)
+	if err != nil {
+		return err
+	}
+
+	for _, branch := range branches {
+		nodes, err := t.parseCommandString(branch)
+		if err != nil {
+			return err
+		}
+
+		if first := nodes[0]; first.Type == NodeTypeCommand {
+			if _, exists := ModeCommands[first.Name]; exists {
+				return &ConflictError{Command: branch, Existing: first.Name, Reason: "keyword collides with a mode name"}
+			}
+			if child, exists := t.Root.Children[first.Name]; exists && child.Type == NodeTypeModeSwitch && !t.isInherited(first.Name) {
+				return &ConflictError{Command: branch, Existing: first.Name, Reason: "keyword collides with a mode name"}
+			}
+		}
+
+		// 以继承的关键字开头的命令覆盖上一级视图的命令，不与之比较
+		if t.isInherited(nodes[0].Name) {
+			continue
+		}
+
+		current := t.Root
+		for i, node := range nodes {
+			existing, exists := current.Children[node.Name]
+			if !exists {
+				siblings := SortedChildren(current)
+				if current == t.Root {
+					siblings = t.ownChildren()
+				}
+				for _, sibling := range siblings {
+					if reason := paramsOverlap(sibling, node); reason != "" {
+						return &ConflictError{Command: branch, Existing: sibling.Path(), Reason: reason}
+					}
+				}
+				break
+			}
+			if existing.Repeat != node.Repeat {
+				return &ConflictError{Command: branch, Existing: existing.Path(), Reason: "parameter repeats in one command but not the other"}
+			}
+			if i == len(nodes)-1 && existing.Handler != nil {
+				return &ConflictError{Command: branch, Existing: existing.Path(), Reason: "command already registered"}
+			}
+			current = existing
+		}
+	}
+	return nil
+}
+
+// CheckModeName 检查视图名称是否与根节点下的关键字冲突
+func (t *CommandTree) CheckModeName(modeName string) error {
+	if child, exists := t.Root.Children[modeName]; exists && child.Type == NodeTypeCommand && !t.isInherited(modeName) {
+		return &ConflictError{Command: modeName, Existing: child.Path(), Reason: "mode name collides with a keyword"}
+	}
+	return nil
+}
+
+// paramsOverlap 检查同一位置的两个不同参数节点是否可能接受相同的输入，返回冲突原因
+func paramsOverlap(a, b *CommandNode) string {
+	if a.Type == NodeTypeCommand || a.Type == NodeTypeModeSwitch || b.Type == NodeTypeCommand || b.Type == NodeTypeModeSwitch {
+		return ""
+	}
+	if a.Type == NodeTypeOptional || b.Type == NodeTypeOptional {
+		return ""
+	}
+
+	// 按类型排序，减少需要比较的组合
+	if a.Type > b.Type {
+		a, b = b, a
+	}
+
+	switch {
+	case a.Type == NodeTypeString || b.Type == NodeTypeString || a.Type == NodeTypeLine || b.Type == NodeTypeLine ||
+		a.Type == NodeTypePath || b.Type == NodeTypePath:
+		return fmt.Sprintf("%s and %s both accept free text", a.Name, b.Name)
+	case a.Type == NodeTypeNum && b.Type == NodeTypeNum:
+		if rangesOverlap(a, b) {
+			return fmt.Sprintf("ranges %s and %s overlap", a.Name, b.Name)
+		}
+	case a.Type == NodeTypeHex && b.Type == NodeTypeHex:
+		if a.URangeMin <= b.URangeMax && b.URangeMin <= a.URangeMax {
+			return fmt.Sprintf("ranges %s and %s overlap", a.Name, b.Name)
+		}
+	case a.Type == NodeTypeNum && b.Type == NodeTypeHex:
+		// 十六进制参数的 0x 前缀可选，纯数字输入可能同时匹配两者
+		return fmt.Sprintf("%s and %s both accept plain digits", a.Name, b.Name)
+	case (b.Type == NodeTypeSize || b.Type == NodeTypeDuration) &&
+		(a.Type == NodeTypeHex || a.Type == NodeTypeSize || a.Type == NodeTypeDuration ||
+			(a.Type == NodeTypeNum && (a.Unsigned || a.RangeMax >= 0))):
+		// 不带单位的容量和时长（秒数）都是非负整数
+		return fmt.Sprintf("%s and %s both accept plain digits", a.Name, b.Name)
+	case a.Type == NodeTypeEnum:
+		for _, value := range a.EnumValues {
+			if IsParameterMatch(b, value) {
+				return fmt.Sprintf("value '%s' matches both %s and %s", value, a.Name, b.Name)
+			}
+		}
+		if b.Type == NodeTypeEnum {
+			for _, value := range b.EnumValues {
+				if IsParameterMatch(a, value) {
+					return fmt.Sprintf("value '%s' matches both %s and %s", value, a.Name, b.Name)
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// rangesOverlap 检查两个十进制范围是否重叠
+func rangesOverlap(a, b *CommandNode) bool {
+	lowA, highA := rangeBounds(a)
+	lowB, highB := rangeBounds(b)
+	return !lessThan(highA, lowB) && !lessThan(highB, lowA)
+}
+
+// bound 十进制范围边界，统一表示有符号和无符号范围
+type bound struct {
+	negative bool
+	abs      uint64
+}
+
+// rangeBounds 返回范围参数的上下界
+func rangeBounds(n *CommandNode) (bound, bound) {
+	if n.Unsigned {
+		return bound{abs: n.URangeMin}, bound{abs: n.URangeMax}
+	}
+	return signedBound(n.RangeMin), signedBound(n.RangeMax)
+}
+
+// signedBound 将有符号数转换为边界
+func signedBound(v int64) bound {
+	if v < 0 {
+		return bound{negative: true, abs: uint64(-(v + 1)) + 1}
+	}
+	return bound{abs: uint64(v)}
+}
+
+// lessThan 比较两个边界
+func lessThan(x, y bound) bool {
+	switch {
+	case x.negative && !y.negative:
+		return true
+	case !x.negative && y.negative:
+		return false
+	case x.negative:
+		return x.abs > y.abs
+	default:
+		return x.abs < y.abs
+	}
+}
+
+// BuiltinHandler 会话内置命令在命令树中的处理函数，值为内置命令的名称；只用于标记节点，
+// 命令由会话执行。内置命令与应用注册的同一命令互不覆盖，后注册的返回 ConflictError
+type BuiltinHandler string
+
+// Run 内置命令由会话执行，不会调用
+func (BuiltinHandler) Run(ctx *types.Ctx) error {
+	return nil
+}
+
+// HasApplicationCommand 检查命令规格是否已经注册为应用的命令，内置命令不取代这些命令
+func (t *CommandTree) HasApplicationCommand(command string) bool {
+	leaves, err := t.findLeaves(command)
+	if err != nil {
+		return false
+	}
+	for _, leaf := range leaves {
+		if _, builtin := leaf.Handler.(BuiltinHandler); leaf.Handler == nil || builtin {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,54 @@
+package commandtree
+
+// InheritFrom 将 parent 根节点下本树没有的顶层关键字链接到本树，本树因此可以执行、补全上一级视图的命令
+// 链接的节点与 parent 共享；本树注册以同一关键字开头的命令时，整个继承的关键字被本树的命令覆盖。
+// parent 为 nil 时取消继承。parent 的顶层关键字改变后需要重新调用
+func (t *CommandTree) InheritFrom(parent *CommandTree) {
+	for name := range t.inherited {
+		t.Root.removeChild(name)
+	}
+	t.inherited = nil
+	if parent == nil {
+		return
+	}
+
+	for _, child := range SortedChildren(parent.Root) {
+		if _, exists := t.Root.Children[child.Name]; exists {
+			continue
+		}
+		// 不修改 Parent，节点的路径和参数仍然属于 parent
+		t.Root.insertChild(child)
+		if t.inherited == nil {
+			t.inherited = make(map[string]bool)
+		}
+		t.inherited[child.Name] = true
+	}
+}
+
+// isInherited 检查根节点下的关键字是否继承自上一级视图
+func (t *CommandTree) isInherited(name string) bool {
+	return t.inherited[name]
+}
+
+// dropInherited 移除继承的顶层关键字，本树随后注册的同名关键字覆盖它
+func (t *CommandTree) dropInherited(name string) {
+	if t.inherited[name] {
+		t.Root.removeChild(name)
+		delete(t.inherited, name)
+	}
+}
+
+// ownChildren 返回根节点下本树注册的子节点，不包括继承的关键字
+func (t *CommandTree) ownChildren() []*CommandNode {
+	if len(t.inherited) == 0 {
+		return SortedChildren(t.Root)
+	}
+
+	var children []*CommandNode
+	for _, child := range SortedChildren(t.Root) {
+		if !t.inherited[child.Name] {
+			children = append(children, child)
+		}
+	}
+	return children
+}
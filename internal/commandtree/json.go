@@ -0,0 +1,83 @@
+package commandtree
+
+import (
+	"encoding/json"
+	"strconv"
+)
+
+// nodeJSON 命令树节点的 JSON 描述
+type nodeJSON struct {
+	Name        string            `json:"name"`
+	Type        string            `json:"type"`
+	Description string            `json:"description,omitempty"`
+	Help        string            `json:"help,omitempty"`
+	Values      []string          `json:"values,omitempty"`    // 枚举参数的取值
+	ValueHelp   map[string]string `json:"valueHelp,omitempty"` // 枚举值的说明
+	Min         json.Number       `json:"min,omitempty"`       // 范围参数的下限
+	Max         json.Number       `json:"max,omitempty"`       // 范围参数的上限
+	Dynamic     bool              `json:"dynamic,omitempty"`   // 取值由提供者动态给出
+	Repeat      bool              `json:"repeat,omitempty"`
+	Mode        string            `json:"mode,omitempty"` // 视图切换命令进入的视图
+	Executable  bool              `json:"executable,omitempty"`
+	Hidden      bool              `json:"hidden,omitempty"`
+	Deprecated  bool              `json:"deprecated,omitempty"`
+	Replacement string            `json:"replacement,omitempty"`
+	Children    []nodeJSON        `json:"children,omitempty"`
+}
+
+// MarshalJSON 将命令树导出为 JSON 数组，每个元素描述一个顶层记号及其子节点，
+// 包括类型、描述、枚举值、范围和可执行标记，供自动化脚本、Web 界面等外部工具使用
+func (t *CommandTree) MarshalJSON() ([]byte, error) {
+	children := []nodeJSON{}
+	for _, child := range t.ownChildren() {
+		children = append(children, child.toJSON())
+	}
+	return json.Marshal(children)
+}
+
+// childrenJSON 按名称顺序导出子节点
+func childrenJSON(n *CommandNode) []nodeJSON {
+	children := []nodeJSON{}
+	for _, child := range SortedChildren(n) {
+		children = append(children, child.toJSON())
+	}
+	return children
+}
+
+// toJSON 导出单个节点及其子树
+func (n *CommandNode) toJSON() nodeJSON {
+	node := nodeJSON{
+		Name:        n.Name,
+		Type:        getNodeTypeString(n.Type),
+		Description: n.Description,
+		Help:        n.Help,
+		Repeat:      n.Repeat,
+		Executable:  n.Handler != nil,
+		Hidden:      n.Hidden,
+		Deprecated:  n.Deprecated,
+		Replacement: n.Replacement,
+	}
+
+	switch n.Type {
+	case NodeTypeEnum:
+		node.Values = append([]string(nil), n.EnumValues...)
+		node.ValueHelp = copyHelps(n.EnumHelp)
+	case NodeTypeNum, NodeTypeHex:
+		if n.Unsigned {
+			node.Min = json.Number(strconv.FormatUint(n.URangeMin, 10))
+			node.Max = json.Number(strconv.FormatUint(n.URangeMax, 10))
+		} else {
+			node.Min = json.Number(strconv.FormatInt(n.RangeMin, 10))
+			node.Max = json.Number(strconv.FormatInt(n.RangeMax, 10))
+		}
+	case NodeTypeString:
+		node.Dynamic = HasValueProvider(n)
+	case NodeTypeModeSwitch:
+		node.Mode = n.ModeName
+	}
+
+	if len(n.Children) > 0 {
+		node.Children = childrenJSON(n)
+	}
+	return node
+}
@@ -0,0 +1,399 @@
+package commandtree
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// 命令规格中表示特殊参数类型的记号
+const (
+	ipv4Token       = "A.B.C.D"    // IPv4 地址
+	ipv4PrefixToken = "A.B.C.D/M"  // IPv4 前缀
+	lineToken       = "LINE"       // 行尾文本
+	sizeToken       = "<size>"     // 带单位后缀的容量
+	durationToken   = "<duration>" // 时长
+	pathToken       = "PATH"       // 文件路径
+)
+
+// repeatSuffixes 可重复参数的后缀
+var repeatSuffixes = []string{"...", "+"}
+
+// trimRepeatSuffix 去掉可重复参数后缀，返回基础记号
+func trimRepeatSuffix(part string) (string, bool) {
+	for _, suffix := range repeatSuffixes {
+		if len(part) > len(suffix) && strings.HasSuffix(part, suffix) {
+			return strings.TrimSuffix(part, suffix), true
+		}
+	}
+	return part, false
+}
+
+// DisplayName 返回节点在帮助中显示的名称，可重复参数带 ... 后缀
+func DisplayName(node *CommandNode) string {
+	if node.Repeat {
+		return node.Name + "..."
+	}
+	return node.Name
+}
+
+// HelpText 返回节点在帮助中显示的说明，优先使用记号帮助
+func HelpText(node *CommandNode) string {
+	if node.Help != "" {
+		return node.Help
+	}
+	return node.Description
+}
+
+// ParameterHint 返回参数节点在补全中显示的提示，如 "<A.B.C.D>"
+func ParameterHint(node *CommandNode) string {
+	hint := node.Name
+	if node.Type == NodeTypeIPv4 || node.Type == NodeTypeIPv4Prefix {
+		hint = "<" + node.Name + ">"
+	}
+	if node.Repeat {
+		hint += "..."
+	}
+	return hint
+}
+
+// isValidIPv4 检查输入是否为点分十进制 IPv4 地址
+func isValidIPv4(input string) bool {
+	if strings.Count(input, ".") != 3 {
+		return false
+	}
+	ip := net.ParseIP(input)
+	return ip != nil && ip.To4() != nil
+}
+
+// GetIPv4ValidationError 获取 IPv4 地址参数验证错误信息
+func GetIPv4ValidationError(node *CommandNode, input string) string {
+	if isValidIPv4(input) {
+		return ""
+	}
+
+	octets := strings.Split(input, ".")
+	if len(octets) != 4 {
+		return fmt.Sprintf("无效的IPv4地址: '%s'，格式应为 %s", input, ipv4Token)
+	}
+
+	for _, octet := range octets {
+		value, err := strconv.Atoi(octet)
+		if err != nil || octet == "" {
+			return fmt.Sprintf("无效的IPv4地址: '%s'，地址段 '%s' 不是数字", input, octet)
+		}
+		if value < 0 || value > 255 {
+			return fmt.Sprintf("无效的IPv4地址: '%s'，地址段 %d 超出范围 0-255", input, value)
+		}
+	}
+
+	return fmt.Sprintf("无效的IPv4地址: '%s'", input)
+}
+
+// ParseIPv4Prefix 解析 A.B.C.D/M 形式的 IPv4 前缀，返回按掩码对齐后的网段
+func ParseIPv4Prefix(input string) (*net.IPNet, error) {
+	slash := strings.IndexByte(input, '/')
+	if slash < 0 {
+		return nil, fmt.Errorf("missing prefix length: %s", input)
+	}
+	if !isValidIPv4(input[:slash]) {
+		return nil, fmt.Errorf("invalid IPv4 address: %s", input[:slash])
+	}
+
+	length, err := strconv.Atoi(input[slash+1:])
+	if err != nil || length < 0 || length > 32 {
+		return nil, fmt.Errorf("invalid prefix length: %s", input[slash+1:])
+	}
+
+	_, ipNet, err := net.ParseCIDR(input)
+	if err != nil {
+		return nil, err
+	}
+	return ipNet, nil
+}
+
+// GetIPv4PrefixValidationError 获取 IPv4 前缀参数验证错误信息
+func GetIPv4PrefixValidationError(node *CommandNode, input string) string {
+	if _, err := ParseIPv4Prefix(input); err == nil {
+		return ""
+	}
+
+	slash := strings.IndexByte(input, '/')
+	if slash < 0 {
+		return fmt.Sprintf("缺少前缀长度: '%s'，格式应为 %s", input, ipv4PrefixToken)
+	}
+	if msg := GetIPv4ValidationError(node, input[:slash]); msg != "" {
+		return msg
+	}
+	return fmt.Sprintf("无效的前缀长度: '%s'，有效范围: 0-32", input[slash+1:])
+}
+
+// isHexBound 检查范围边界是否为 0x 开头的十六进制数
+func isHexBound(bound string) bool {
+	if !strings.HasPrefix(bound, "0x") && !strings.HasPrefix(bound, "0X") {
+		return false
+	}
+	_, err := strconv.ParseUint(bound[2:], 16, 64)
+	return err == nil
+}
+
+// parseHexRangeParam 解析十六进制范围参数
+func (t *CommandTree) parseHexRangeParam(part, minBound, maxBound string) (*CommandNode, bool) {
+	min, err1 := ParseHex(minBound)
+	max, err2 := ParseHex(maxBound)
+	if err1 != nil || err2 != nil || min > max {
+		return nil, false
+	}
+
+	node := NewCommandNode(part, NodeTypeHex, "Hexadecimal parameter")
+	node.Unsigned = true
+	node.URangeMin = min
+	node.URangeMax = max
+	node.IsRequired = true
+	return node, true
+}
+
+// ParseHex 解析十六进制数，0x 前缀可选
+func ParseHex(input string) (uint64, error) {
+	digits := input
+	if strings.HasPrefix(digits, "0x") || strings.HasPrefix(digits, "0X") {
+		digits = digits[2:]
+	}
+	if digits == "" {
+		return 0, fmt.Errorf("invalid hex value: %s", input)
+	}
+	value, err := strconv.ParseUint(digits, 16, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid hex value: %s", input)
+	}
+	return value, nil
+}
+
+// isValidHexInRange 检查十六进制参数值是否在指定范围内
+func isValidHexInRange(node *CommandNode, input string) bool {
+	value, err := ParseHex(input)
+	if err != nil {
+		return false
+	}
+	return value >= node.URangeMin && value <= node.URangeMax
+}
+
+// GetHexValidationError 获取十六进制参数验证错误信息
+func GetHexValidationError(node *CommandNode, input string) string {
+	value, err := ParseHex(input)
+	if err != nil {
+		return fmt.Sprintf("无效的十六进制数: '%s'", input)
+	}
+	if value < node.URangeMin || value > node.URangeMax {
+		return fmt.Sprintf("数值 0x%X 超出有效范围: 0x%X-0x%X", value, node.URangeMin, node.URangeMax)
+	}
+	return ""
+}
+
+// sizeUnits 容量单位后缀，按 1024 进制换算，大小写均可
+var sizeUnits = map[byte]uint64{
+	'k': 1 << 10,
+	'm': 1 << 20,
+	'g': 1 << 30,
+	't': 1 << 40,
+}
+
+// ParseSize 解析带单位后缀的容量，如 10k、512M、2G，单位按 1024 进制换算，不带后缀时为原值
+func ParseSize(input string) (uint64, error) {
+	digits, multiplier := splitSize(input)
+	value, err := strconv.ParseUint(digits, 10, 64)
+	if errors.Is(err, strconv.ErrRange) || value > math.MaxUint64/multiplier {
+		return 0, fmt.Errorf("size out of range: %s", input)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("invalid size: %s", input)
+	}
+	return value * multiplier, nil
+}
+
+// splitSize 拆分容量的数字部分和单位倍数
+func splitSize(input string) (string, uint64) {
+	if n := len(input); n > 0 {
+		if unit, ok := sizeUnits[byte(unicode.ToLower(rune(input[n-1])))]; ok {
+			return input[:n-1], unit
+		}
+	}
+	return input, 1
+}
+
+// GetSizeValidationError 获取容量参数验证错误信息
+func GetSizeValidationError(node *CommandNode, input string) string {
+	if _, err := ParseSize(input); err == nil {
+		return ""
+	}
+	digits, _ := splitSize(input)
+	if _, err := strconv.ParseUint(digits, 10, 64); err == nil || errors.Is(err, strconv.ErrRange) {
+		return fmt.Sprintf("容量超出范围: '%s'", input)
+	}
+	return fmt.Sprintf("无效的容量: '%s'，格式应为整数加可选单位 k/M/G/T，如 512M", input)
+}
+
+// ParseDuration 解析时长，支持 Go 时长格式（如 30s、5m、1h30m）和表示秒数的整数，不接受负数
+func ParseDuration(input string) (time.Duration, error) {
+	if seconds, err := strconv.ParseUint(input, 10, 64); err == nil {
+		if seconds > uint64(math.MaxInt64/int64(time.Second)) {
+			return 0, fmt.Errorf("duration out of range: %s", input)
+		}
+		return time.Duration(seconds) * time.Second, nil
+	}
+
+	duration, err := time.ParseDuration(input)
+	if err != nil || duration < 0 || strings.HasPrefix(input, "-") {
+		return 0, fmt.Errorf("invalid duration: %s", input)
+	}
+	return duration, nil
+}
+
+// GetDurationValidationError 获取时长参数验证错误信息
+func GetDurationValidationError(node *CommandNode, input string) string {
+	if _, err := ParseDuration(input); err == nil {
+		return ""
+	}
+	return fmt.Sprintf("无效的时长: '%s'，格式应为秒数或带单位的时长，如 30s、5m、1h30m", input)
+}
+
+// NormalizeParameter 返回传给处理函数的参数值，容量参数换算为字节数，
+// 时长参数转换为 time.Duration 的标准格式（如 1h30m0s），fileRoot 不为空时路径参数规范为
+// 以 / 开头的根目录内路径，其他参数保持原样
+// 调用前参数值应已通过 IsParameterMatch 校验
+func NormalizeParameter(node *CommandNode, input, fileRoot string) string {
+	switch node.Type {
+	case NodeTypeSize:
+		if value, err := ParseSize(input); err == nil {
+			return strconv.FormatUint(value, 10)
+		}
+	case NodeTypeDuration:
+		if duration, err := ParseDuration(input); err == nil {
+			return duration.String()
+		}
+	case NodeTypePath:
+		if fileRoot != "" {
+			return CleanPath(input)
+		}
+	}
+	return input
+}
+
+// SplitFields 按空白拆分输入，同时返回每个字段在原字符串中的起始偏移
+func SplitFields(line string) ([]string, []int) {
+	var fields []string
+	var offsets []int
+
+	start := -1
+	for i, r := range line {
+		if unicode.IsSpace(r) {
+			if start >= 0 {
+				fields = append(fields, line[start:i])
+				offsets = append(offsets, start)
+				start = -1
+			}
+		} else if start < 0 {
+			start = i
+		}
+	}
+	if start >= 0 {
+		fields = append(fields, line[start:])
+		offsets = append(offsets, start)
+	}
+
+	return fields, offsets
+}
+
+// RestOfLine 返回从第 index 个字段开始的原始输入（保留字段间的空白）
+func RestOfLine(line string, index int) string {
+	_, offsets := SplitFields(line)
+	if index < 0 || index >= len(offsets) {
+		return ""
+	}
+	return strings.TrimRightFunc(line[offsets[index]:], unicode.IsSpace)
+}
+
+// isParamNode 检查节点是否为参数节点，参数节点的值依次传给处理函数
+func isParamNode(n *CommandNode) bool {
+	return n.Type != NodeTypeCommand && n.Type != NodeTypeModeSwitch
+}
+
+// NamedParams 按名称返回可执行节点 leaf 的参数值，args 为 FindCommand 返回的按路径排列的参数
+// 名称为命令规格中 <name:TOKEN> 指定的名称，未指定时为去掉尖括号的记号，如 "1-10"、"WORD"；
+// 紧跟在关键字之后的参数同时可以用该关键字访问，如 "target PATH" 中的参数也可以用 "target" 访问。
+// 可重复参数的多个值以空格连接，同名的参数取第一个，省略的可选参数不出现在结果中。
+// 之后没有参数的关键字对应关键字本身，如 "show log [detail]" 输入了 detail 时结果包括 "detail": "detail"
+func NamedParams(leaf *CommandNode, args []string) map[string]string {
+	var params []*CommandNode
+	for n := leaf; n != nil && n.Parent != nil; n = n.Parent {
+		if isParamNode(n) {
+			params = append([]*CommandNode{n}, params...)
+		}
+	}
+
+	named := make(map[string]string, len(params))
+	for i, n := range params {
+		if i >= len(args) {
+			break
+		}
+		name := strings.TrimSuffix(strings.TrimPrefix(n.Name, "<"), ">")
+		if i < len(leaf.ParamLabels) && leaf.ParamLabels[i] != "" {
+			name = leaf.ParamLabels[i]
+		}
+		value := args[i]
+		if n.Repeat {
+			value = strings.Join(args[i:], " ")
+		}
+		if _, exists := named[name]; !exists {
+			named[name] = value
+		}
+		if n.Parent.Type == NodeTypeCommand && n.Parent.Parent != nil {
+			if _, exists := named[n.Parent.Name]; !exists {
+				named[n.Parent.Name] = value
+			}
+		}
+	}
+	for n := leaf; n != nil && n.Parent != nil; n = n.Parent {
+		if n.Type != NodeTypeCommand {
+			continue
+		}
+		if _, exists := named[n.Name]; !exists {
+			named[n.Name] = n.Name
+		}
+	}
+	return named
+}
+
+// SpecArgs 将 FindCommand 返回的按路径排列的参数换算为传给处理函数的参数：
+// 按命令规格中的位置排列，省略的可选参数为空字符串，如 "cmd [a X] [b Y]" 输入 "cmd b 2 a 1" 时为 ["1", "2"]，
+// 输入 "cmd b 2" 时为 ["", "2"]；末尾可重复参数的多个值依次排在最后
+func SpecArgs(leaf *CommandNode, args []string) []string {
+	if leaf.SpecParams == 0 {
+		return args
+	}
+	result := make([]string, leaf.SpecParams)
+	for i, arg := range args {
+		if i < len(leaf.ParamSlots) {
+			result[leaf.ParamSlots[i]] = arg
+		} else {
+			result = append(result, arg)
+		}
+	}
+	return result
+}
+
+// RepeatedKeyword 检查可执行节点 leaf 之后多出的输入 arg 是否为路径上已经使用过的命名参数关键字，
+// 如 "backup create name b1 compress on compress off" 中第二个 compress，返回该关键字，否则返回空字符串
+func RepeatedKeyword(leaf *CommandNode, arg string) string {
+	for n := leaf; n != nil && n.Parent != nil; n = n.Parent {
+		if n.Named && strings.HasPrefix(n.Name, arg) {
+			return n.Name
+		}
+	}
+	return ""
+}
@@ -0,0 +1,88 @@
+package commandtree
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// CleanPath 将 PATH 参数规范为以 / 开头、相对于根目录的路径，.. 不能越过根目录
+func CleanPath(input string) string {
+	return path.Clean("/" + input)
+}
+
+// ResolvePath 返回 PATH 参数在根目录 root 之下对应的服务器路径，符号链接不能指向根目录之外；
+// 路径还不存在时检查其中已经存在的部分
+func ResolvePath(root, input string) (string, error) {
+	if root == "" {
+		return "", fmt.Errorf("file root not configured")
+	}
+
+	full := filepath.Join(root, filepath.FromSlash(CleanPath(input)))
+	real, err := evalExisting(full)
+	if err != nil {
+		return "", fmt.Errorf("invalid path: %s", input)
+	}
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return "", err
+	}
+	if rel, err := filepath.Rel(realRoot, real); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("path outside file root: %s", input)
+	}
+	return full, nil
+}
+
+// evalExisting 解析 full 中已经存在的部分的符号链接，不存在的部分原样追加在其后；
+// 指向不存在的目标的符号链接无法确定最终位置，返回错误
+func evalExisting(full string) (string, error) {
+	real, err := filepath.EvalSymlinks(full)
+	if err == nil || !os.IsNotExist(err) {
+		return real, err
+	}
+	if _, lerr := os.Lstat(full); lerr == nil {
+		return "", fmt.Errorf("dangling symlink: %s", full)
+	}
+	parent := filepath.Dir(full)
+	if parent == full {
+		return "", err
+	}
+	real, err = evalExisting(parent)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(real, filepath.Base(full)), nil
+}
+
+// PathCompletions 返回根目录 root 中以 input 开头的文件和目录，目录带 / 后缀
+// 以 . 开头的文件只在 input 的最后一段也以 . 开头时列出；root 为空时返回 nil
+func PathCompletions(root, input string) []string {
+	if root == "" {
+		return nil
+	}
+
+	dir, prefix := path.Split(input)
+	resolved, err := ResolvePath(root, dir)
+	if err != nil {
+		return nil
+	}
+	entries, err := os.ReadDir(resolved)
+	if err != nil {
+		return nil
+	}
+
+	var completions []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, prefix) || (strings.HasPrefix(name, ".") && !strings.HasPrefix(prefix, ".")) {
+			continue
+		}
+		if entry.IsDir() {
+			name += "/"
+		}
+		completions = append(completions, dir+name)
+	}
+	return completions
+}
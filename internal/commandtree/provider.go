@@ -0,0 +1,120 @@
+package commandtree
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/TrailHuang/tnlcmd/pkg/types"
+)
+
+// ValueProvider 返回参数当前合法取值的回调
+type ValueProvider = types.ValueProvider
+
+// ifNameToken 接口名参数记号，取值由 RegisterInterfaceProvider 注册的提供者给出
+const ifNameToken = "IFNAME"
+
+// RegisterValueProvider 为字符串参数记号注册取值提供者，如 IFNAME
+// 命令规格中使用该记号的参数只接受回调返回的值，补全也基于这些值
+// 提供者在每次校验和补全时调用，因此可以反映应用的实时状态；provider 为 nil 时取消注册
+func (r *Registry) RegisterValueProvider(name string, provider ValueProvider) {
+	r.providersMu.Lock()
+	defer r.providersMu.Unlock()
+
+	if provider == nil {
+		delete(r.providers, name)
+		return
+	}
+	if r.providers == nil {
+		r.providers = make(map[string]ValueProvider)
+	}
+	r.providers[name] = provider
+}
+
+// RegisterInterfaceProvider 为 IFNAME 参数注册接口名提供者，provider 为 nil 时取消注册
+// 没有注册提供者时 IFNAME 按普通字符串参数处理
+func (r *Registry) RegisterInterfaceProvider(provider ValueProvider) {
+	r.RegisterValueProvider(ifNameToken, provider)
+}
+
+// provider 返回参数记号的取值提供者，r 为 nil 时没有提供者
+func (r *Registry) provider(name string) (ValueProvider, bool) {
+	if r == nil {
+		return nil, false
+	}
+
+	r.providersMu.RLock()
+	defer r.providersMu.RUnlock()
+	provider, exists := r.providers[name]
+	return provider, exists
+}
+
+// SystemInterfaces 返回本机网络接口名，可以直接作为 IFNAME 的提供者
+func SystemInterfaces() []string {
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return nil
+	}
+
+	names := make([]string, 0, len(interfaces))
+	for _, iface := range interfaces {
+		names = append(names, iface.Name)
+	}
+	return names
+}
+
+// HasValueProvider 检查参数节点所在注册表是否为它绑定了取值提供者，不调用提供者
+func HasValueProvider(node *CommandNode) bool {
+	if node.Type != NodeTypeString {
+		return false
+	}
+	_, exists := node.treeRegistry().provider(node.Name)
+	return exists
+}
+
+// DynamicValues 返回参数节点当前的合法取值，节点没有提供者时第二个返回值为 false
+func DynamicValues(node *CommandNode) ([]string, bool) {
+	if node.Type != NodeTypeString {
+		return nil, false
+	}
+	provider, exists := node.treeRegistry().provider(node.Name)
+	if !exists {
+		return nil, false
+	}
+	return provider(), true
+}
+
+// isValidDynamicValue 检查输入是否为提供者返回的值之一
+func isValidDynamicValue(values []string, input string) bool {
+	for _, value := range values {
+		if value == input {
+			return true
+		}
+	}
+	return false
+}
+
+// GetDynamicValidationError 获取动态取值参数验证错误信息
+func GetDynamicValidationError(node *CommandNode, values []string, input string) string {
+	if isValidDynamicValue(values, input) {
+		return ""
+	}
+	if node.Name == ifNameToken {
+		return fmt.Sprintf("接口 '%s' 不存在", input)
+	}
+	if len(values) == 0 {
+		return fmt.Sprintf("无效的参数值 '%s'，%s 当前没有可用的值", input, node.Name)
+	}
+	return fmt.Sprintf("无效的参数值 '%s'，有效值: %s", input, strings.Join(values, ", "))
+}
+
+// GetDynamicCompletions 获取动态取值参数的补全选项
+func GetDynamicCompletions(values []string, input string) []string {
+	var completions []string
+	for _, value := range values {
+		if strings.HasPrefix(value, input) {
+			completions = append(completions, value)
+		}
+	}
+	return completions
+}
@@ -0,0 +1,31 @@
+package commandtree
+
+import "sync"
+
+// Registry 一个 CmdLine 的命令注册表，保护它的所有命令树和视图
+// 注册命令时持有写锁；会话查找命令、补全和显示帮助时持有读锁，执行处理函数前释放，
+// 因此处理函数中也可以注册新命令。同一进程中的多个 CmdLine 互不影响
+type Registry struct {
+	sync.RWMutex
+
+	providersMu sync.RWMutex
+	providers   map[string]ValueProvider // 参数取值提供者，按参数记号索引
+}
+
+// Registry 返回命令树所属的注册表，没有调用 SetRegistry 时为创建命令树时新建的注册表
+func (t *CommandTree) Registry() *Registry {
+	return t.Root.registry
+}
+
+// SetRegistry 将命令树加入注册表 r，同一个 CmdLine 的所有命令树共用一个注册表
+func (t *CommandTree) SetRegistry(r *Registry) {
+	t.Root.registry = r
+}
+
+// treeRegistry 返回节点所在命令树的注册表，节点不在命令树中时为 nil
+func (n *CommandNode) treeRegistry() *Registry {
+	for n.Parent != nil {
+		n = n.Parent
+	}
+	return n.registry
+}
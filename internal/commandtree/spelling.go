@@ -0,0 +1,143 @@
+package commandtree
+
+import (
+	"sort"
+	"strings"
+)
+
+// spellLimit 拼写建议最多列出的命令数
+const spellLimit = 3
+
+// correction 纠正拼写后的输入及其与原输入的编辑距离之和
+type correction struct {
+	args     []string
+	distance int
+}
+
+// Corrections 将输入中拼错的关键字替换为编辑距离相近的关键字，返回替换后可以执行的命令，
+// 如 shwo runing-config 返回 show running-config；按编辑距离从小到大排列，最多 spellLimit 条
+func (t *CommandTree) Corrections(args []string) []string {
+	var found []correction
+	t.correct(append([]string(nil), args...), 0, &found)
+
+	sort.Slice(found, func(i, j int) bool {
+		if found[i].distance != found[j].distance {
+			return found[i].distance < found[j].distance
+		}
+		return strings.Join(found[i].args, " ") < strings.Join(found[j].args, " ")
+	})
+	var result []string
+	seen := make(map[string]bool)
+	for _, c := range found {
+		line := strings.Join(c.args, " ")
+		if seen[line] {
+			continue
+		}
+		seen[line] = true
+		result = append(result, line)
+		if len(result) == spellLimit {
+			break
+		}
+	}
+	return result
+}
+
+// correct 从第一个无法匹配的记号开始逐个尝试相近的关键字，distance 为已经替换的编辑距离之和
+func (t *CommandTree) correct(args []string, distance int, found *[]correction) {
+	if distance > 0 && t.executable(args) {
+		*found = append(*found, correction{args: args, distance: distance})
+		return
+	}
+	index, node, _ := t.mismatch(args)
+	if index >= len(args) {
+		return
+	}
+
+	candidates := keywordNames(node)
+	if index == 0 {
+		for name := range ModeCommands {
+			candidates = append(candidates, name)
+		}
+	}
+	for _, name := range candidates {
+		d, ok := spellingDistance(args[index], name)
+		if !ok {
+			continue
+		}
+		corrected := append([]string(nil), args...)
+		corrected[index] = name
+		t.correct(corrected, distance+d, found)
+	}
+}
+
+// executable 检查输入是否为可以执行的完整命令
+func (t *CommandTree) executable(args []string) bool {
+	node, _, _, err := t.FindCommand(args)
+	return err == nil && node != nil && (node.Handler != nil || node.Type == NodeTypeModeSwitch)
+}
+
+// keywordNames 返回节点下可见的关键字
+func keywordNames(n *CommandNode) []string {
+	var names []string
+	for _, child := range SortedChildren(n) {
+		if child.Type != NodeTypeCommand && child.Type != NodeTypeModeSwitch {
+			continue
+		}
+		if IsHidden(child) || IsDeprecated(child) {
+			continue
+		}
+		names = append(names, child.Name)
+	}
+	return names
+}
+
+// spellingDistance 返回输入与关键字的编辑距离，输入可能是缩写，也与关键字等长的前缀比较；
+// 距离超过输入长度允许的范围时返回 false，少于 3 个字符的输入不纠正
+func spellingDistance(input, keyword string) (int, bool) {
+	n := len([]rune(input))
+	limit := 2
+	switch {
+	case n < 3:
+		return 0, false
+	case n <= 5:
+		limit = 1
+	}
+
+	d := editDistance(input, keyword)
+	if prefix := []rune(keyword); len(prefix) > n {
+		if p := editDistance(input, string(prefix[:n])); p < d {
+			d = p
+		}
+	}
+	if d == 0 || d > limit {
+		return 0, false
+	}
+	return d, true
+}
+
+// editDistance 返回两个字符串的编辑距离，相邻字符交换算一次编辑
+func editDistance(a, b string) int {
+	s, t := []rune(a), []rune(b)
+	// d[i][j] 为 s[:i] 与 t[:j] 的距离
+	d := make([][]int, len(s)+1)
+	for i := range d {
+		d[i] = make([]int, len(t)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(s); i++ {
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+			if s[i-1] == t[j-1] {
+				cost = 0
+			}
+			d[i][j] = min(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && s[i-1] == t[j-2] && s[i-2] == t[j-1] {
+				d[i][j] = min(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(s)][len(t)]
+}
@@ -0,0 +1,71 @@
+package commandtree
+
+import "strings"
+
+// syntaxWidth 可选部分的多个选择合并为 [a | b] 时允许的最大长度，超过时每个选择单独列为一行
+const syntaxWidth = 60
+
+// Syntax 返回节点之下所有可以执行的命令的完整语法，如 "show interfaces [IFNAME]"
+// 节点可以执行而之后还可以继续输入时，之后的部分用 [] 标为可选，同一位置的多个选择用 | 分隔
+func Syntax(n *CommandNode) []string {
+	path := n.Path()
+	var lines []string
+	for _, suffix := range syntaxSuffixes(n) {
+		if suffix == "" {
+			lines = append(lines, path)
+		} else {
+			lines = append(lines, path+" "+suffix)
+		}
+	}
+	return lines
+}
+
+// SyntaxNodes 返回节点之下可见的关键字和参数，按深度优先的顺序，同名的记号只返回第一个
+func SyntaxNodes(n *CommandNode) []*CommandNode {
+	var nodes []*CommandNode
+	seen := make(map[string]bool)
+	var visit func(node *CommandNode)
+	visit = func(node *CommandNode) {
+		for _, child := range SortedChildren(node) {
+			if IsHidden(child) || child.Type == NodeTypeOptional {
+				continue
+			}
+			if !seen[child.Name] {
+				seen[child.Name] = true
+				nodes = append(nodes, child)
+			}
+			visit(child)
+		}
+	}
+	visit(n)
+	return nodes
+}
+
+// syntaxSuffixes 返回节点之后各种输入方式的语法，空字符串表示可以在该节点结束
+func syntaxSuffixes(n *CommandNode) []string {
+	var alternatives []string
+	for _, child := range SortedChildren(n) {
+		if IsHidden(child) || child.Type == NodeTypeOptional {
+			continue
+		}
+		token := DisplayName(child)
+		for _, rest := range syntaxSuffixes(child) {
+			if rest == "" {
+				alternatives = append(alternatives, token)
+			} else {
+				alternatives = append(alternatives, token+" "+rest)
+			}
+		}
+	}
+
+	if n.Handler == nil && n.Type != NodeTypeModeSwitch {
+		return alternatives
+	}
+	if len(alternatives) == 0 {
+		return []string{""}
+	}
+	if optional := "[" + strings.Join(alternatives, " | ") + "]"; len(alternatives) == 1 || len(optional) <= syntaxWidth {
+		return []string{optional}
+	}
+	return append([]string{""}, alternatives...)
+}
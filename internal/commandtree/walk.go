@@ -0,0 +1,82 @@
+package commandtree
+
+import (
+	"errors"
+
+	"github.com/TrailHuang/tnlcmd/pkg/types"
+)
+
+// SkipChildren 遍历回调返回该错误时跳过当前节点的子节点，继续遍历其他节点
+var SkipChildren = errors.New("skip children")
+
+// Walk 深度优先遍历命令树（不包括根节点），同一层的子节点按 SortedChildren 的顺序遍历
+// 回调返回 SkipChildren 时跳过该节点的子节点，返回其他错误时停止遍历并返回该错误
+func (t *CommandTree) Walk(fn func(node *CommandNode, depth int) error) error {
+	for _, child := range t.ownChildren() {
+		err := fn(child, 1)
+		if errors.Is(err, SkipChildren) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err := walkChildren(child, 2, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// walkChildren 递归遍历子节点
+func walkChildren(n *CommandNode, depth int, fn func(node *CommandNode, depth int) error) error {
+	for _, child := range SortedChildren(n) {
+		err := fn(child, depth)
+		if errors.Is(err, SkipChildren) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err := walkChildren(child, depth+1, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Info 返回节点的只读描述
+func (n *CommandNode) Info(depth int) types.NodeInfo {
+	return types.NodeInfo{
+		Path:        n.Path(),
+		Name:        n.Name,
+		Depth:       depth,
+		Type:        n.Type,
+		TypeName:    getNodeTypeString(n.Type),
+		Description: n.Description,
+		Help:        n.Help,
+		EnumValues:  append([]string(nil), n.EnumValues...),
+		EnumHelp:    copyHelps(n.EnumHelp),
+		RangeMin:    n.RangeMin,
+		RangeMax:    n.RangeMax,
+		Unsigned:    n.Unsigned,
+		URangeMin:   n.URangeMin,
+		URangeMax:   n.URangeMax,
+		Repeat:      n.Repeat,
+		Hidden:      n.Hidden,
+		Deprecated:  n.Deprecated,
+		Replacement: n.Replacement,
+		Handler:     n.Handler,
+	}
+}
+
+// copyHelps 复制枚举值说明，原值为空时返回 nil
+func copyHelps(helps map[string]string) map[string]string {
+	if len(helps) == 0 {
+		return nil
+	}
+	result := make(map[string]string, len(helps))
+	for value, help := range helps {
+		result[value] = help
+	}
+	return result
+}
@@ -2,6 +2,7 @@ package completer
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/TrailHuang/tnlcmd/internal/commandtree"
@@ -9,10 +10,17 @@ import (
 	"github.com/TrailHuang/tnlcmd/pkg/types"
 )
 
+// helpColumn 帮助中命令名称列的宽度，按显示宽度对齐
+const helpColumn = 32
+
+// crIndicator 帮助中表示可以直接回车执行的条目
+const crIndicator = "<cr>"
+
 // CommandCompleter 命令补全器
 type CommandCompleter struct {
 	commandTree *commandtree.CommandTree // 树形命令存储（向后兼容）
 	context     *mode.CommandContext     // 命令上下文，用于访问当前视图的独立命令树
+	fileRoot    func() string            // 返回路径参数的根目录，为空或返回空字符串时不补全文件
 }
 
 // NewCommandCompleter 创建新的命令补全器
@@ -39,6 +47,19 @@ func (c *CommandCompleter) UpdateContext(context *mode.CommandContext) {
 	c.context = context
 }
 
+// SetFileRoot 设置返回路径参数根目录的函数，每次补全时调用，因此可以反映配置的修改
+func (c *CommandCompleter) SetFileRoot(fileRoot func() string) {
+	c.fileRoot = fileRoot
+}
+
+// rootDir 返回路径参数的根目录
+func (c *CommandCompleter) rootDir() string {
+	if c.fileRoot == nil {
+		return ""
+	}
+	return c.fileRoot()
+}
+
 // UpdateCommandTree 更新命令树
 func (c *CommandCompleter) UpdateCommandTree(tree *commandtree.CommandTree) {
 	c.commandTree = tree
@@ -69,10 +90,10 @@ func (c *CommandCompleter) Complete(input string) []string {
 			var matchingChildren []string
 
 			// 收集所有匹配的子节点（包括视图切换命令）
-			for name, child := range node.Children {
+			for _, child := range commandtree.SortedChildren(node) {
 				// 补全命令节点和视图切换命令节点
-				if (child.Type == types.NodeTypeCommand || child.Type == types.NodeTypeModeSwitch) && strings.HasPrefix(name, currentInput) {
-					matchingChildren = append(matchingChildren, name)
+				if (child.Type == types.NodeTypeCommand || child.Type == types.NodeTypeModeSwitch) && strings.HasPrefix(child.Name, currentInput) && !commandtree.IsHidden(child) {
+					matchingChildren = append(matchingChildren, child.Name)
 				}
 			}
 
@@ -99,9 +120,9 @@ func (c *CommandCompleter) Complete(input string) []string {
 			}
 		} else {
 			// 空输入，返回所有一级命令（包括视图切换命令）
-			for name, child := range node.Children {
-				if child.Type == types.NodeTypeCommand || child.Type == types.NodeTypeModeSwitch {
-					completions = append(completions, name)
+			for _, child := range commandtree.SortedChildren(node) {
+				if (child.Type == types.NodeTypeCommand || child.Type == types.NodeTypeModeSwitch) && !commandtree.IsHidden(child) {
+					completions = append(completions, child.Name)
 				}
 			}
 		}
@@ -114,6 +135,9 @@ func (c *CommandCompleter) Complete(input string) []string {
 }
 
 // GetNextLevelCompletions 获取下一级补全选项（基于当前视图的命令树）
+// 输入以空格结尾时列出下一个记号的所有取值，否则补全最后一个记号；
+// 之前的记号可以是关键字的缩写或参数，枚举参数补全为其取值。
+// 只有一个候选项时返回补全后的整行，下一个记号还可以是需要输入的参数时同时列出参数提示
 func (c *CommandCompleter) GetNextLevelCompletions(input string) []string {
 	var nextLevel []string
 
@@ -121,54 +145,56 @@ func (c *CommandCompleter) GetNextLevelCompletions(input string) []string {
 	if c.context == nil || c.context.CurrentMode == nil || c.context.CurrentMode.CommandTree == nil {
 		return nextLevel
 	}
-	currentNode := c.context.CurrentMode.CommandTree.Root
-
-	inputParts := strings.Fields(input)
-	node := currentNode
 
-	for i := 0; i < len(inputParts)-1; i++ {
-		if child, exists := node.Children[inputParts[i]]; exists {
-			node = child
-		} else {
-			return nextLevel
-		}
-	}
-
-	var matchingChildren []string
-	lastPart := ""
-	if len(inputParts) > 0 {
-		lastPart = inputParts[len(inputParts)-1]
+	inputParts, lastPart := splitCompletionInput(input)
+	node, ok := descend(c.context.CurrentMode.CommandTree.Root, inputParts)
+	if !ok {
+		return nextLevel
 	}
 
-	// 补全当前视图命令树中的命令
-	for name := range node.Children {
-		if strings.HasPrefix(name, lastPart) {
-			matchingChildren = append(matchingChildren, name)
+	// 补全当前视图命令树中的命令，有取值提供者的参数补全为当前合法取值
+	var matching, hints suggestionList
+	fileRoot := c.rootDir()
+	for _, child := range completionNodes(node, len(inputParts) > 0) {
+		if values, ok := commandtree.DynamicValues(child); ok {
+			for _, value := range commandtree.GetDynamicCompletions(values, lastPart) {
+				matching.addParam(value, "")
+			}
+		} else if child.Type == types.NodeTypePath && fileRoot != "" {
+			// 路径参数补全为根目录中的文件和目录
+			for _, entry := range commandtree.PathCompletions(fileRoot, lastPart) {
+				matching.addParam(entry, "")
+			}
+		} else if child.Type == types.NodeTypeEnum {
+			for _, value := range child.EnumValues {
+				if strings.HasPrefix(value, lastPart) {
+					matching.addParam(value, "")
+				}
+			}
+		} else if child.Type == types.NodeTypeCommand || child.Type == types.NodeTypeModeSwitch {
+			if strings.HasPrefix(child.Name, lastPart) {
+				matching.addKeyword(child.Name, "")
+			}
+		} else if lastPart == "" {
+			hints.addParam(commandtree.ParameterHint(child), "")
 		}
 	}
 
 	// 补全视图切换命令（从任意视图都可以切换到其他视图）
-	if len(inputParts) == 1 && c.context != nil && c.context.CurrentMode != nil {
+	if len(inputParts) == 0 && lastPart != "" && c.context != nil && c.context.CurrentMode != nil {
 		rootMode := c.context.GetRootMode()
-		for name, subMode := range rootMode.Children {
+		for _, subMode := range rootMode.SortedSubModes() {
 			// 如果当前不是该子模式，则添加切换命令
-			if c.context.CurrentMode != subMode && strings.HasPrefix(name, lastPart) {
-				matchingChildren = append(matchingChildren, name)
+			if c.context.CurrentMode != subMode && strings.HasPrefix(subMode.Name, lastPart) {
+				matching.addKeyword(subMode.Name, "")
 			}
 		}
 	}
 
-	if len(matchingChildren) == 1 {
-		baseParts := inputParts[:len(inputParts)-1]
-		var fullCommand string
-		if len(baseParts) > 0 {
-			fullCommand = strings.Join(baseParts, " ") + " " + matchingChildren[0]
-		} else {
-			fullCommand = matchingChildren[0]
-		}
-		nextLevel = []string{fullCommand}
-	} else if len(matchingChildren) > 1 {
-		nextLevel = matchingChildren
+	if matching.len() == 1 && hints.len() == 0 {
+		nextLevel = []string{strings.Join(append(inputParts, matching.names()[0]), " ")}
+	} else if matching.len()+hints.len() > 1 {
+		nextLevel = append(matching.names(), hints.names()...)
 	}
 
 	return nextLevel
@@ -195,9 +221,9 @@ func (c *CommandCompleter) GetCompletions(input string) []string {
 	}
 
 	if len(inputParts) == 0 {
-		for name, child := range node.Children {
-			if child.Type == types.NodeTypeCommand {
-				completions = append(completions, name)
+		for _, child := range commandtree.SortedChildren(node) {
+			if child.Type == types.NodeTypeCommand && !commandtree.IsHidden(child) {
+				completions = append(completions, child.Name)
 			}
 		}
 		return completions
@@ -206,9 +232,9 @@ func (c *CommandCompleter) GetCompletions(input string) []string {
 	currentInput := inputParts[len(inputParts)-1]
 	var matchingChildren []string
 
-	for name, child := range node.Children {
-		if child.Type == types.NodeTypeCommand && strings.HasPrefix(name, currentInput) {
-			matchingChildren = append(matchingChildren, name)
+	for _, child := range commandtree.SortedChildren(node) {
+		if child.Type == types.NodeTypeCommand && strings.HasPrefix(child.Name, currentInput) && !commandtree.IsHidden(child) {
+			matchingChildren = append(matchingChildren, child.Name)
 		}
 	}
 
@@ -236,6 +262,7 @@ func (c *CommandCompleter) GetCompletions(input string) []string {
 }
 
 // GetParameterCompletions 获取参数补全选项（基于当前视图的命令树）
+// 返回正在输入的记号位置可以输入的参数的提示，如 <1-10>、WORD
 func (c *CommandCompleter) GetParameterCompletions(input string) []string {
 	var completions []string
 
@@ -244,29 +271,73 @@ func (c *CommandCompleter) GetParameterCompletions(input string) []string {
 		return completions
 	}
 
-	inputParts := strings.Fields(input)
-	node := c.context.CurrentMode.CommandTree.Root
+	inputParts, _ := splitCompletionInput(input)
+	node, ok := descend(c.context.CurrentMode.CommandTree.Root, inputParts)
+	if !ok {
+		return completions
+	}
 
-	for i := 0; i < len(inputParts); i++ {
-		if child, exists := node.Children[inputParts[i]]; exists {
-			node = child
-		} else {
-			return completions
+	for _, child := range completionNodes(node, len(inputParts) > 0) {
+		if child.Type != types.NodeTypeCommand && child.Type != types.NodeTypeModeSwitch {
+			completions = append(completions, commandtree.ParameterHint(child))
 		}
 	}
 
-	var lastPart string
-	if len(inputParts) > 0 {
-		lastPart = inputParts[len(inputParts)-1]
+	return completions
+}
+
+// splitCompletionInput 将补全的输入拆分为已输入完的记号和正在输入的记号，输入以空格结尾时正在输入的记号为空
+func splitCompletionInput(input string) ([]string, string) {
+	parts := strings.Fields(input)
+	if len(parts) == 0 || strings.HasSuffix(input, " ") {
+		return parts, ""
 	}
+	return parts[:len(parts)-1], parts[len(parts)-1]
+}
 
-	for name, child := range node.Children {
-		if child.Type != types.NodeTypeCommand && strings.HasPrefix(name, lastPart) {
-			completions = append(completions, name)
+// descend 按已输入的记号从 node 向下查找，记号可以是关键字的唯一缩写、参数的取值或可重复参数的又一个取值
+// Tab 补全和 ? 帮助使用相同的查找方式
+func descend(node *commandtree.CommandNode, parts []string) (*commandtree.CommandNode, bool) {
+	for _, part := range parts {
+		if matches := commandtree.MatchKeyword(node, part); len(matches) == 1 {
+			// 关键字可以是唯一的前缀缩写
+			node = matches[0]
+			continue
+		}
+		if node.Repeat && commandtree.IsParameterMatch(node, part) {
+			// 可重复参数可以继续接受同类型的值
+			continue
+		}
+		// 检查是否是参数节点匹配
+		matched := false
+		for _, child := range commandtree.SortedChildren(node) {
+			// 如果是参数节点，检查参数类型是否匹配
+			if child.Type != types.NodeTypeCommand && child.Type != types.NodeTypeModeSwitch && commandtree.IsParameterMatch(child, part) {
+				node = child
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return nil, false
 		}
 	}
+	return node, true
+}
 
-	return completions
+// completionNodes 返回节点之后可以输入的节点，不包括隐藏的节点；
+// 可重复参数之后（afterInput 为 true 时）还可以输入同类型的值
+func completionNodes(node *commandtree.CommandNode, afterInput bool) []*commandtree.CommandNode {
+	var nodes []*commandtree.CommandNode
+	if node.Repeat && afterInput {
+		nodes = append(nodes, node)
+	}
+	for _, child := range commandtree.SortedChildren(node) {
+		if !commandtree.IsHidden(child) {
+			nodes = append(nodes, child)
+		}
+	}
+	return nodes
 }
 
 // GetCurrentViewCommands 获取当前视图的命令列表（包括内置命令）
@@ -283,10 +354,12 @@ func (c *CommandCompleter) GetCurrentViewCommands() []string {
 		}
 	}
 
+	sort.Strings(commands)
 	return commands
 }
 
 // GetCommandTreeSuggestions 基于命令树获取当前节点的所有子节点作为建议
+// 关键字按名称排序列在前面，参数取值和参数提示按注册的顺序列在之后，重复的条目只列出一次
 func (c *CommandCompleter) GetCommandTreeSuggestions(input string) []string {
 	var suggestions []string
 
@@ -296,45 +369,117 @@ func (c *CommandCompleter) GetCommandTreeSuggestions(input string) []string {
 	}
 
 	inputParts := strings.Fields(input)
-	node := c.context.CurrentMode.CommandTree.Root
-
-	// 遍历到当前层级
-	for i := 0; i < len(inputParts); i++ {
-		if child, exists := node.Children[inputParts[i]]; exists {
-			node = child
-		} else {
-			// 检查是否是参数节点匹配
-			paramMatched := false
-			for _, child := range node.Children {
-				// 如果是参数节点，检查参数类型是否匹配
-				if child.Type != types.NodeTypeCommand && commandtree.IsParameterMatch(child, inputParts[i]) {
-					node = child
-					paramMatched = true
-					break
+	node, ok := descend(c.context.CurrentMode.CommandTree.Root, inputParts)
+	if !ok {
+		// 视图切换命令不在当前视图的命令树中，完整输入时可以直接回车
+		if len(inputParts) == 1 && c.isModeCommand(inputParts[0]) {
+			return []string{crIndicator}
+		}
+		// 最后一个记号没有输入完时，列出以它开头的关键字和参数取值
+		if parts, lastPart := splitCompletionInput(input); lastPart != "" {
+			if parent, ok := descend(c.context.CurrentMode.CommandTree.Root, parts); ok {
+				list := prefixSuggestions(parent, lastPart)
+				if len(parts) == 0 {
+					c.addModeCommands(list, lastPart)
 				}
-			}
-			if !paramMatched {
-				// 找不到匹配节点，返回空建议
-				return suggestions
+				return list.lines()
 			}
 		}
+		// 找不到匹配节点，返回空建议
+		return suggestions
 	}
 
 	// 显示当前节点的所有子节点（包括参数节点），返回命令和描述的组合
-	for name, child := range node.Children {
-		// 格式："命令名称（固定32宽度左对齐） - 描述"
-		suggestion := fmt.Sprintf("%-32s %s", name, child.Description)
-		suggestions = append(suggestions, suggestion)
-	}
-	//将视图切换命令也添加到建议中
-	if len(inputParts) <= 1 {
-		for _, key := range c.context.CurrentMode.CommandTree.GetModeCommandKeys() {
-			if strings.HasPrefix(key, input) {
-				// 对于视图切换命令，使用默认描述
-				suggestion := fmt.Sprintf("%-32s Switch to %s mode", key, key)
-				suggestions = append(suggestions, suggestion)
+	var list suggestionList
+	var deprecated suggestionList
+	for _, child := range completionNodes(node, len(inputParts) > 0) {
+		// 废弃命令单独列在最后
+		if child != node && commandtree.IsDeprecated(child) {
+			help := commandtree.HelpText(child)
+			if child.Replacement != "" {
+				help += fmt.Sprintf(" (use '%s')", child.Replacement)
 			}
+			deprecated.addKeyword(commandtree.DisplayName(child), help)
+			continue
 		}
+		addNodeSuggestions(&list, child, "")
+	}
+	// 将视图切换命令也添加到建议中
+	if len(inputParts) == 0 {
+		c.addModeCommands(&list, "")
+	}
+	suggestions = list.lines()
+
+	// 已输入的记号构成完整的命令时提示可以直接回车执行
+	if len(inputParts) > 0 && (node.Handler != nil || node.Type == types.NodeTypeModeSwitch) {
+		suggestions = append(suggestions, crIndicator)
+	}
+	if deprecated.len() > 0 {
+		suggestions = append(suggestions, "Deprecated commands:")
+		suggestions = append(suggestions, deprecated.lines()...)
 	}
 	return suggestions
 }
+
+// addNodeSuggestions 将节点以 prefix 开头的关键字或参数取值及其说明加入 list
+// 有取值提供者的参数和带说明的枚举参数逐个列出取值，prefix 为空时其他参数列出参数名称
+func addNodeSuggestions(list *suggestionList, node *commandtree.CommandNode, prefix string) {
+	help := commandtree.HelpText(node)
+	if node.Type == types.NodeTypeCommand || node.Type == types.NodeTypeModeSwitch {
+		if strings.HasPrefix(node.Name, prefix) {
+			list.addKeyword(commandtree.DisplayName(node), help)
+		}
+		return
+	}
+
+	var values []string
+	if dynamic, ok := commandtree.DynamicValues(node); ok && len(dynamic) > 0 {
+		values = dynamic
+	} else if node.Type == types.NodeTypeEnum && (len(node.EnumHelp) > 0 || prefix != "") {
+		values = node.EnumValues
+	} else {
+		if prefix == "" {
+			list.addParam(commandtree.DisplayName(node), help)
+		}
+		return
+	}
+	for _, value := range values {
+		if !strings.HasPrefix(value, prefix) {
+			continue
+		}
+		valueHelp, ok := node.EnumHelp[value]
+		if !ok {
+			valueHelp = help
+		}
+		list.addParam(value, valueHelp)
+	}
+}
+
+// addModeCommands 将以 prefix 开头的视图切换命令加入 list
+func (c *CommandCompleter) addModeCommands(list *suggestionList, prefix string) {
+	for _, key := range c.context.CurrentMode.CommandTree.GetModeCommandKeys() {
+		if strings.HasPrefix(key, prefix) {
+			// 对于视图切换命令，使用默认描述
+			list.addKeyword(key, fmt.Sprintf("Switch to %s mode", key))
+		}
+	}
+}
+
+// prefixSuggestions 返回节点之后以 prefix 开头的关键字和参数取值及其说明
+func prefixSuggestions(node *commandtree.CommandNode, prefix string) *suggestionList {
+	var list suggestionList
+	for _, child := range completionNodes(node, true) {
+		addNodeSuggestions(&list, child, prefix)
+	}
+	return &list
+}
+
+// isModeCommand 检查 name 是否为视图切换命令
+func (c *CommandCompleter) isModeCommand(name string) bool {
+	for _, key := range c.context.CurrentMode.CommandTree.GetModeCommandKeys() {
+		if key == name {
+			return true
+		}
+	}
+	return false
+}
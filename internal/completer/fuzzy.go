@@ -0,0 +1,121 @@
+package completer
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/TrailHuang/tnlcmd/internal/commandtree"
+	"github.com/TrailHuang/tnlcmd/pkg/types"
+)
+
+// fuzzyLimit 模糊补全最多返回的候选项数
+const fuzzyLimit = 20
+
+// fuzzyMatch 模糊匹配的候选命令
+type fuzzyMatch struct {
+	command string
+	score   int // 落在单词开头的字符数，越大越接近
+}
+
+// FuzzyCompletions 按子序列模糊匹配当前视图中的命令，供普通补全没有结果时使用
+// 输入去掉空格后的字符按顺序出现在命令的关键字中、且首字母相同即匹配，如 shrc 匹配 show running-config；
+// 候选项为命令开头的关键字部分，优先列出输入的字符落在单词开头（如关键字和 - 之后的首字母）较多的命令
+func (c *CommandCompleter) FuzzyCompletions(input string) []string {
+	query := []rune(strings.Join(strings.Fields(input), ""))
+	if len(query) == 0 || c.context == nil || c.context.CurrentMode == nil || c.context.CurrentMode.CommandTree == nil {
+		return nil
+	}
+
+	var matches []fuzzyMatch
+	var visit func(node *commandtree.CommandNode, path []string)
+	visit = func(node *commandtree.CommandNode, path []string) {
+		for _, child := range commandtree.SortedChildren(node) {
+			if child.Type != types.NodeTypeCommand && child.Type != types.NodeTypeModeSwitch {
+				continue
+			}
+			if commandtree.IsHidden(child) || commandtree.IsDeprecated(child) {
+				continue
+			}
+			childPath := append(append([]string(nil), path...), child.Name)
+			if isCommandPrefix(child) {
+				if score, ok := fuzzyScore(query, childPath); ok {
+					matches = append(matches, fuzzyMatch{command: strings.Join(childPath, " "), score: score})
+				}
+			}
+			visit(child, childPath)
+		}
+	}
+	visit(c.context.CurrentMode.CommandTree.Root, nil)
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].score != matches[j].score {
+			return matches[i].score > matches[j].score
+		}
+		if len(matches[i].command) != len(matches[j].command) {
+			return len(matches[i].command) < len(matches[j].command)
+		}
+		return matches[i].command < matches[j].command
+	})
+	if len(matches) > fuzzyLimit {
+		matches = matches[:fuzzyLimit]
+	}
+	completions := make([]string, len(matches))
+	for i, match := range matches {
+		completions[i] = match.command
+	}
+	return completions
+}
+
+// isCommandPrefix 检查关键字节点是否可以执行，或者之后紧跟参数
+func isCommandPrefix(node *commandtree.CommandNode) bool {
+	if node.Handler != nil || node.Type == types.NodeTypeModeSwitch {
+		return true
+	}
+	for _, child := range commandtree.SortedChildren(node) {
+		if child.Type != types.NodeTypeCommand {
+			return true
+		}
+	}
+	return false
+}
+
+// fuzzyScore 检查 query 是否为关键字序列 words 连接后的子序列且首字符相同，
+// 返回所有匹配方式中落在单词开头的字符数的最大值
+func fuzzyScore(query []rune, words []string) (int, bool) {
+	var text []rune
+	var starts []bool
+	for _, word := range words {
+		runes := []rune(word)
+		for i, r := range runes {
+			text = append(text, r)
+			starts = append(starts, i == 0 || runes[i-1] == '-')
+		}
+	}
+	if len(text) == 0 || text[0] != query[0] {
+		return 0, false
+	}
+
+	// best[i] 为匹配 query[:i] 时的最高得分，-1 表示还不能匹配
+	best := make([]int, len(query)+1)
+	for i := 1; i <= len(query); i++ {
+		best[i] = -1
+	}
+	for j, r := range text {
+		for i := len(query); i >= 1; i-- {
+			if r != query[i-1] || best[i-1] < 0 || (i == 1 && j != 0) {
+				continue
+			}
+			score := best[i-1]
+			if starts[j] {
+				score++
+			}
+			if score > best[i] {
+				best[i] = score
+			}
+		}
+	}
+	if best[len(query)] < 0 {
+		return 0, false
+	}
+	return best[len(query)], true
+}
@@ -0,0 +1,85 @@
+package completer
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/TrailHuang/tnlcmd/internal/textwidth"
+)
+
+// suggestion 补全或帮助中的一个条目
+type suggestion struct {
+	name    string // 关键字或参数取值，用于排序和去重
+	text    string // 显示的内容
+	keyword bool
+}
+
+// suggestionList 收集补全和帮助的条目，去掉重复的条目，关键字按名称排序列在参数之前
+type suggestionList struct {
+	entries []suggestion
+	seen    map[string]bool
+}
+
+// addKeyword 添加关键字，help 为空时只显示名称
+func (l *suggestionList) addKeyword(name, help string) {
+	l.add(suggestion{name: name, text: helpLine(name, help), keyword: true})
+}
+
+// addParam 添加参数取值或参数提示，按添加的顺序列在关键字之后
+func (l *suggestionList) addParam(name, help string) {
+	l.add(suggestion{name: name, text: helpLine(name, help)})
+}
+
+func (l *suggestionList) add(entry suggestion) {
+	if l.seen == nil {
+		l.seen = make(map[string]bool)
+	}
+	if l.seen[entry.name] {
+		return
+	}
+	l.seen[entry.name] = true
+	l.entries = append(l.entries, entry)
+}
+
+// len 返回条目数
+func (l *suggestionList) len() int {
+	return len(l.entries)
+}
+
+// names 返回排序后的关键字和参数取值
+func (l *suggestionList) names() []string {
+	var names []string
+	for _, entry := range l.sorted() {
+		names = append(names, entry.name)
+	}
+	return names
+}
+
+// lines 返回排序后的显示内容
+func (l *suggestionList) lines() []string {
+	var lines []string
+	for _, entry := range l.sorted() {
+		lines = append(lines, entry.text)
+	}
+	return lines
+}
+
+// sorted 返回关键字在前并按名称排序、参数按添加顺序排列的条目
+func (l *suggestionList) sorted() []suggestion {
+	entries := append([]suggestion(nil), l.entries...)
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].keyword != entries[j].keyword {
+			return entries[i].keyword
+		}
+		return entries[i].keyword && entries[i].name < entries[j].name
+	})
+	return entries
+}
+
+// helpLine 返回帮助中的一行：名称按显示宽度对齐，之后是说明
+func helpLine(name, help string) string {
+	if help == "" {
+		return name
+	}
+	return fmt.Sprintf("%s %s", textwidth.Pad(name, helpColumn), help)
+}
@@ -0,0 +1,107 @@
+package completer
+
+import (
+	"strings"
+
+	"github.com/TrailHuang/tnlcmd/internal/commandtree"
+	"github.com/TrailHuang/tnlcmd/pkg/types"
+)
+
+// CommandUsage 返回输入的命令的详细用法：完整语法、命令说明以及各个关键字和参数的说明
+// 输入按 ? 帮助相同的方式查找，关键字可以缩写；找不到命令时返回 false
+func (c *CommandCompleter) CommandUsage(input string) (string, bool) {
+	parts := strings.Fields(input)
+	if len(parts) == 0 || c.context == nil || c.context.CurrentMode == nil || c.context.CurrentMode.CommandTree == nil {
+		return "", false
+	}
+	node, ok := descend(c.context.CurrentMode.CommandTree.Root, parts)
+	if !ok && len(parts) == 1 && c.isModeCommand(parts[0]) {
+		// 视图切换命令不在当前视图的命令树中
+		node, ok = commandtree.ModeCommands[parts[0]], true
+	}
+	if !ok || commandtree.IsHidden(node) {
+		return "", false
+	}
+
+	var b strings.Builder
+	b.WriteString("Usage:\n")
+	for _, line := range commandtree.Syntax(node) {
+		b.WriteString("  " + line + "\n")
+	}
+
+	if descriptions := commandDescriptions(node); len(descriptions) > 0 {
+		b.WriteString("\nDescription:\n")
+		for _, description := range descriptions {
+			b.WriteString("  " + description + "\n")
+		}
+	}
+
+	var list suggestionList
+	for _, token := range commandtree.SyntaxNodes(node) {
+		list.addParam(commandtree.DisplayName(token), commandtree.HelpText(token))
+		if token.Type == types.NodeTypeEnum {
+			for _, value := range token.EnumValues {
+				if help := token.EnumHelp[value]; help != "" {
+					list.addParam("  "+value, help)
+				}
+			}
+		}
+	}
+	if list.len() > 0 {
+		b.WriteString("\nKeywords and parameters:\n")
+		for _, line := range list.lines() {
+			b.WriteString("  " + line + "\n")
+		}
+	}
+	return b.String(), true
+}
+
+// commandDescriptions 返回节点及其之下可以执行的命令的说明，相同的说明只返回一次
+func commandDescriptions(node *commandtree.CommandNode) []string {
+	var descriptions []string
+	seen := make(map[string]bool)
+	var visit func(n *commandtree.CommandNode)
+	visit = func(n *commandtree.CommandNode) {
+		if commandtree.IsHidden(n) {
+			return
+		}
+		if (n.Handler != nil || n.Type == types.NodeTypeModeSwitch) && n.Description != "" && !seen[n.Description] {
+			seen[n.Description] = true
+			descriptions = append(descriptions, n.Description)
+		}
+		for _, child := range commandtree.SortedChildren(n) {
+			visit(child)
+		}
+	}
+	visit(node)
+	return descriptions
+}
+
+// SearchCommands 返回当前视图中命令、说明或记号帮助包含 word 的命令（不区分大小写），每行为命令及其说明
+func (c *CommandCompleter) SearchCommands(word string) []string {
+	word = strings.ToLower(strings.TrimSpace(word))
+	if word == "" || c.context == nil || c.context.CurrentMode == nil || c.context.CurrentMode.CommandTree == nil {
+		return nil
+	}
+
+	var list suggestionList
+	var visit func(n *commandtree.CommandNode, matched bool)
+	visit = func(n *commandtree.CommandNode, matched bool) {
+		for _, child := range commandtree.SortedChildren(n) {
+			if commandtree.IsHidden(child) {
+				continue
+			}
+			// 路径上任何记号匹配时，之下的命令都算匹配
+			childMatched := matched || strings.Contains(strings.ToLower(child.Name), word) ||
+				strings.Contains(strings.ToLower(child.Help), word)
+			if child.Handler != nil || child.Type == types.NodeTypeModeSwitch {
+				if childMatched || strings.Contains(strings.ToLower(child.Description), word) {
+					list.addParam(child.Path(), child.Description)
+				}
+			}
+			visit(child, childMatched)
+		}
+	}
+	visit(c.context.CurrentMode.CommandTree.Root, false)
+	return list.lines()
+}
@@ -2,39 +2,95 @@ package history
 
 import (
 	"sync"
+	"time"
+
+	"github.com/TrailHuang/tnlcmd/pkg/types"
 )
 
+// Entry 一条历史记录
+type Entry = types.HistoryEntry
+
 type CommandHistory struct {
 	mu       sync.RWMutex
-	history  []string
+	history  []Entry
 	maxSize  int
 	position int
+	dropped  int // 超出容量而丢弃和被清除的条目数，用于计算条目的编号
 }
 
 func NewCommandHistory(maxSize int) *CommandHistory {
 	return &CommandHistory{
-		history:  make([]string, 0, maxSize),
+		history:  make([]Entry, 0, maxSize),
 		maxSize:  maxSize,
 		position: -1,
 	}
 }
 
 func (h *CommandHistory) Add(cmd string) {
+	h.Record(cmd)
+}
+
+// Record 记录开始执行的命令并返回其编号，执行完成后用 Finish 记录结果
+// 与上一条相同的命令不重复记录，更新上一条的时间并返回它的编号
+func (h *CommandHistory) Record(cmd string) int {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
-	if len(h.history) > 0 && h.history[len(h.history)-1] == cmd {
-		return
+	now := time.Now()
+	if n := len(h.history); n > 0 && h.history[n-1].Command == cmd {
+		last := &h.history[n-1]
+		last.Time, last.Duration, last.Status, last.Done = now, 0, 0, false
+		return last.Number
 	}
 
 	if len(h.history) >= h.maxSize {
 		h.history = h.history[1:]
+		h.dropped++
 	}
 
-	h.history = append(h.history, cmd)
+	number := h.dropped + len(h.history) + 1
+	h.history = append(h.history, Entry{Number: number, Command: cmd, Time: now})
+	h.position = len(h.history) - 1
+	return number
+}
+
+// Inject 追加已经执行完成的命令，不与上一条比较，用于预先放入或导入的历史
+func (h *CommandHistory) Inject(cmd string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if len(h.history) >= h.maxSize {
+		h.history = h.history[1:]
+		h.dropped++
+	}
+	number := h.dropped + len(h.history) + 1
+	h.history = append(h.history, Entry{Number: number, Command: cmd, Time: time.Now(), Done: true})
 	h.position = len(h.history) - 1
 }
 
+// Finish 记录编号为 number 的命令的执行状态和所用的时间，记录已被丢弃时忽略
+func (h *CommandHistory) Finish(number int, status int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	index := number - h.dropped - 1
+	if index < 0 || index >= len(h.history) {
+		return
+	}
+	entry := &h.history[index]
+	entry.Duration = time.Since(entry.Time)
+	entry.Status = status
+	entry.Done = true
+}
+
+// Entries 返回所有记录，最早的在前
+func (h *CommandHistory) Entries() []Entry {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	result := make([]Entry, len(h.history))
+	copy(result, h.history)
+	return result
+}
+
 func (h *CommandHistory) Get(index int) string {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
@@ -43,7 +99,7 @@ func (h *CommandHistory) Get(index int) string {
 		return ""
 	}
 
-	return h.history[index]
+	return h.history[index].Command
 }
 
 func (h *CommandHistory) GetAll() []string {
@@ -51,7 +107,9 @@ func (h *CommandHistory) GetAll() []string {
 	defer h.mu.RUnlock()
 
 	result := make([]string, len(h.history))
-	copy(result, h.history)
+	for i, entry := range h.history {
+		result[i] = entry.Command
+	}
 	return result
 }
 
@@ -75,7 +133,7 @@ func (h *CommandHistory) Previous() string {
 		h.position--
 	}
 
-	return h.history[h.position]
+	return h.history[h.position].Command
 }
 
 func (h *CommandHistory) Next() string {
@@ -88,13 +146,40 @@ func (h *CommandHistory) Next() string {
 
 	if h.position < len(h.history)-1 {
 		h.position++
-		return h.history[h.position]
+		return h.history[h.position].Command
 	} else {
 		h.position = -1
 		return ""
 	}
 }
 
+// FirstNumber 返回最早一条记录的编号，编号从 1 开始，丢弃和清除的记录不重新编号
+func (h *CommandHistory) FirstNumber() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.dropped + 1
+}
+
+// Number 返回编号为 n 的记录，记录已被丢弃或不存在时返回 false
+func (h *CommandHistory) Number(n int) (string, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	index := n - h.dropped - 1
+	if index < 0 || index >= len(h.history) {
+		return "", false
+	}
+	return h.history[index].Command, true
+}
+
+// Clear 清除所有记录
+func (h *CommandHistory) Clear() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.dropped += len(h.history)
+	h.history = h.history[:0]
+	h.position = -1
+}
+
 func (h *CommandHistory) ResetPosition() {
 	h.mu.Lock()
 	defer h.mu.Unlock()
@@ -2,12 +2,16 @@ package mode
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/TrailHuang/tnlcmd/internal/commandtree"
 	"github.com/TrailHuang/tnlcmd/pkg/types"
 )
 
+// PathSeparator 视图路径中各级视图名称的分隔符，如 configure/interface
+const PathSeparator = "/"
+
 // CommandMode 命令模式
 type CommandMode struct {
 	Name        string
@@ -17,6 +21,16 @@ type CommandMode struct {
 	Children    map[string]*CommandMode
 	Parent      *CommandMode
 	CommandTree *commandtree.CommandTree // 每个视图的独立命令树
+	Inherit     bool                     // 继承上一级视图的命令，本视图以同一关键字开头的命令覆盖继承的命令
+	HelpHeader  string                   // help 命令在命令列表之前显示的说明
+	Categories  []CommandCategory        // help 命令中的命令分组，按添加顺序显示
+	Renderers   []types.ConfigRenderer   // show running-config 的配置渲染函数，按注册顺序调用
+}
+
+// CommandCategory help 命令中的一组命令
+type CommandCategory struct {
+	Name     string
+	Keywords []string // 组内命令的首个关键字，按显示顺序排列
 }
 
 // NewCommandMode 创建新的命令模式
@@ -50,24 +64,116 @@ func (m *CommandMode) SetPrompt(prompt string) {
 	m.Prompt = prompt
 }
 
-// AddCommand 添加命令到模式
-func (m *CommandMode) AddCommand(name, description string, handler types.CommandHandler, detailedDescription ...string) {
+// AddCommand 添加命令到模式，命令树处于严格模式且命令冲突时返回错误
+func (m *CommandMode) AddCommand(name, description string, handler types.Handler, detailedDescription ...string) error {
+	// 同时添加到当前视图的独立命令树
+	if m.CommandTree != nil {
+		if err := m.CommandTree.AddCommand(name, description, handler, detailedDescription...); err != nil {
+			return err
+		}
+	}
+
 	m.Commands[name] = types.CommandInfo{
 		Name:        name,
 		Description: description,
 		Handler:     handler,
 	}
+	return nil
+}
 
-	// 同时添加到当前视图的独立命令树
+// AddHiddenCommand 添加隐藏命令到模式，命令可以执行但不出现在帮助和补全中
+func (m *CommandMode) AddHiddenCommand(name, description string, handler types.Handler, detailedDescription ...string) error {
+	if err := m.AddCommand(name, description, handler, detailedDescription...); err != nil {
+		return err
+	}
 	if m.CommandTree != nil {
-		_ = m.CommandTree.AddCommand(name, description, handler, detailedDescription...)
+		return m.CommandTree.HideCommand(name)
+	}
+	return nil
+}
+
+// DeprecateCommand 将模式中已注册的命令标记为废弃
+func (m *CommandMode) DeprecateCommand(name, replacement string) error {
+	if m.CommandTree == nil {
+		return fmt.Errorf("mode %s has no command tree", m.Name)
+	}
+	return m.CommandTree.DeprecateCommand(name, replacement)
+}
+
+// MarkOperational 将模式中已注册的命令标记为不修改配置的命令，开启候选配置时直接执行
+func (m *CommandMode) MarkOperational(name string) error {
+	if m.CommandTree == nil {
+		return fmt.Errorf("mode %s has no command tree", m.Name)
 	}
+	return m.CommandTree.MarkOperational(name)
 }
 
-// AddSubMode 添加子模式
+// SetTemplate 替换视图中用 DataHandler 注册的命令的输出模板
+func (m *CommandMode) SetTemplate(name, text string) error {
+	if m.CommandTree == nil {
+		return fmt.Errorf("mode %s has no command tree", m.Name)
+	}
+	return m.CommandTree.SetTemplate(name, text)
+}
+
+// AddCategory 将以 keywords 开头的命令加入 help 中的分组，分组不存在时追加到末尾
+func (m *CommandMode) AddCategory(name string, keywords ...string) {
+	for i := range m.Categories {
+		if m.Categories[i].Name == name {
+			m.Categories[i].Keywords = append(m.Categories[i].Keywords, keywords...)
+			return
+		}
+	}
+	m.Categories = append(m.Categories, CommandCategory{Name: name, Keywords: keywords})
+}
+
+// AddSubMode 添加子模式，子模式的命令树加入本视图命令树所属的注册表
 func (m *CommandMode) AddSubMode(subMode *CommandMode) {
 	subMode.Parent = m
 	m.Children[subMode.Name] = subMode
+	if m.CommandTree != nil && subMode.CommandTree != nil {
+		subMode.CommandTree.SetRegistry(m.CommandTree.Registry())
+	}
+}
+
+// Path 返回视图从根视图开始的路径，如 configure/interface，根视图返回空字符串
+func (m *CommandMode) Path() string {
+	var names []string
+	for current := m; current.Parent != nil; current = current.Parent {
+		names = append([]string{current.Name}, names...)
+	}
+	return strings.Join(names, PathSeparator)
+}
+
+// FindMode 按路径查找子孙视图，path 为空时返回自身，不存在时返回 nil
+func (m *CommandMode) FindMode(path string) *CommandMode {
+	current := m
+	if path == "" {
+		return current
+	}
+	for _, name := range strings.Split(path, PathSeparator) {
+		subMode, exists := current.Children[name]
+		if !exists {
+			return nil
+		}
+		current = subMode
+	}
+	return current
+}
+
+// SortedSubModes 返回按名称排序的子模式
+func (m *CommandMode) SortedSubModes() []*CommandMode {
+	names := make([]string, 0, len(m.Children))
+	for name := range m.Children {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	subModes := make([]*CommandMode, 0, len(names))
+	for _, name := range names {
+		subModes = append(subModes, m.Children[name])
+	}
+	return subModes
 }
 
 // CommandContext 命令上下文
@@ -75,10 +181,100 @@ type CommandContext struct {
 	CurrentMode *CommandMode
 	Path        []string
 	CommandTree *commandtree.CommandTree
+	history     []modeFrame            // 进入当前视图之前依次所在的视图，LeaveMode 按相反顺序返回
+	values      map[string]interface{} // 当前视图实例的会话数据，离开视图时丢弃
+}
+
+// modeFrame 来源记录中的一个视图实例及其会话数据
+type modeFrame struct {
+	mode   *CommandMode
+	values map[string]interface{}
+}
+
+// NewCommandContext 创建位于根视图的命令上下文，每个会话使用独立的上下文
+func NewCommandContext(rootMode *CommandMode, tree *commandtree.CommandTree) *CommandContext {
+	c := &CommandContext{CommandTree: tree}
+	c.ChangeMode(rootMode)
+	return c
+}
+
+// Clone 复制上下文，副本中进入和离开视图不影响原来的上下文
+func (c *CommandContext) Clone() *CommandContext {
+	clone := *c
+	clone.Path = append([]string(nil), c.Path...)
+	clone.history = append([]modeFrame(nil), c.history...)
+	clone.values = make(map[string]interface{}, len(c.values))
+	for key, value := range c.values {
+		clone.values[key] = value
+	}
+	return &clone
+}
+
+// EnterMode 进入视图并记录来源视图，LeaveMode 时返回来源视图
+// 进入已经在来源记录中的视图时，丢弃该视图之后的记录，相当于逐级返回到该视图
+func (c *CommandContext) EnterMode(newMode *CommandMode) {
+	if newMode == c.CurrentMode {
+		return
+	}
+
+	for i, frame := range c.history {
+		if frame.mode == newMode {
+			c.history = c.history[:i]
+			c.values = frame.values
+			c.setMode(newMode)
+			return
+		}
+	}
+	c.history = append(c.history, modeFrame{mode: c.CurrentMode, values: c.values})
+	c.values = nil
+	c.setMode(newMode)
+}
+
+// LeaveMode 返回进入当前视图之前所在的视图，没有来源记录时返回上一级视图，返回切换后的视图
+// 当前视图实例的会话数据被丢弃，返回的视图恢复离开时的数据
+func (c *CommandContext) LeaveMode() *CommandMode {
+	if n := len(c.history); n > 0 {
+		previous := c.history[n-1]
+		c.history = c.history[:n-1]
+		c.values = previous.values
+		c.setMode(previous.mode)
+		return previous.mode
+	}
+
+	c.values = nil
+	if c.CurrentMode.Parent != nil {
+		c.setMode(c.CurrentMode.Parent)
+	}
+	return c.CurrentMode
 }
 
-// ChangeMode 切换模式
+// ChangeMode 切换模式，清除来源记录和所有视图实例的会话数据
 func (c *CommandContext) ChangeMode(newMode *CommandMode) {
+	c.setMode(newMode)
+	c.history = nil
+	c.values = nil
+}
+
+// Value 返回当前视图实例中保存的会话数据
+func (c *CommandContext) Value(key string) (interface{}, bool) {
+	value, exists := c.values[key]
+	return value, exists
+}
+
+// SetValue 在当前视图实例中保存会话数据，value 为 nil 时删除
+func (c *CommandContext) SetValue(key string, value interface{}) {
+	if value == nil {
+		delete(c.values, key)
+		return
+	}
+	if c.values == nil {
+		c.values = make(map[string]interface{})
+	}
+	c.values[key] = value
+}
+
+// setMode 设置当前视图并更新路径
+func (c *CommandContext) setMode(newMode *CommandMode) {
 	c.CurrentMode = newMode
 
 	// 更新路径
@@ -0,0 +1,134 @@
+package runconfig
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/TrailHuang/tnlcmd/internal/commandtree"
+	"github.com/TrailHuang/tnlcmd/internal/mode"
+	"github.com/TrailHuang/tnlcmd/pkg/types"
+)
+
+// Command 可以执行的配置命令及其处理函数和参数
+type Command struct {
+	Change  types.ConfigChange
+	Handler types.Handler
+	Args    []string
+	Params  map[string]string
+}
+
+// NewCommand 由已经匹配的命令树节点创建配置命令，args 为校验过的参数
+func NewCommand(change types.ConfigChange, node *commandtree.CommandNode, args []string) Command {
+	return Command{Change: change, Handler: node.Handler, Args: commandtree.SpecArgs(node, args), Params: commandtree.NamedParams(node, args)}
+}
+
+// Resolve 在 root 之下的视图 change.Mode 中查找命令，调用者需持有注册表读锁
+func Resolve(root *mode.CommandMode, change types.ConfigChange) (Command, error) {
+	m := root.FindMode(change.Mode)
+	if m == nil {
+		return Command{}, fmt.Errorf("mode not found: %s", change.Mode)
+	}
+	fields := strings.Fields(change.Command)
+	node, _, args, err := m.CommandTree.FindCommand(fields)
+	if err != nil {
+		return Command{}, err
+	}
+	if node == nil || node.Handler == nil {
+		return Command{}, fmt.Errorf("incomplete command")
+	}
+	// 行尾文本参数保留原始空白
+	if node.Type == types.NodeTypeLine && len(args) > 0 {
+		consumed := len(strings.Fields(args[len(args)-1]))
+		args[len(args)-1] = commandtree.RestOfLine(change.Command, len(fields)-consumed)
+	}
+	return NewCommand(change, node, args), nil
+}
+
+// ResolveAll 查找 changes 中的所有命令，任何一条无法执行时返回错误，调用者不能持有注册表锁
+func ResolveAll(root *mode.CommandMode, changes []types.ConfigChange) ([]Command, error) {
+	registry := root.CommandTree.Registry()
+	registry.RLock()
+	defer registry.RUnlock()
+
+	commands := make([]Command, 0, len(changes))
+	for _, change := range changes {
+		cmd, err := Resolve(root, change)
+		if err != nil {
+			return nil, fmt.Errorf("[edit %s] %s: %w", change.Mode, change.Command, err)
+		}
+		commands = append(commands, cmd)
+	}
+	return commands, nil
+}
+
+// Apply 以 ctx 为模板依次执行命令的处理函数，ctx.Mode 为输入命令时所在的视图，遇到第一个错误时停止
+func Apply(ctx *types.Ctx, commands []Command) error {
+	for _, c := range commands {
+		hctx := *ctx
+		hctx.Args, hctx.Params, hctx.Mode = c.Args, c.Params, c.Change.Mode
+		if err := c.Handler.Run(&hctx); err != nil {
+			return fmt.Errorf("%s: %w", c.Change.Command, err)
+		}
+	}
+	return nil
+}
+
+// Changes 返回命令对应的配置修改
+func Changes(commands []Command) []types.ConfigChange {
+	changes := make([]types.ConfigChange, len(commands))
+	for i, c := range commands {
+		changes[i] = c.Change
+	}
+	return changes
+}
+
+// Commit 执行一次提交：依次执行命令的处理函数，设置了 Config.Commit 时由提交回调决定是否整体生效；
+// 成功后将提交之前的配置保存到历史配置中，并发布各条命令的配置修改事件。调用者不能持有注册表锁
+func (s *Store) Commit(ctx *types.Ctx, commands []Command) error {
+	s.commitMu.Lock()
+	defer s.commitMu.Unlock()
+	_, err := s.commit(ctx, commands)
+	return err
+}
+
+// commit 执行一次提交，返回提交之前的配置，调用者需持有 commitMu
+func (s *Store) commit(ctx *types.Ctx, commands []Command) ([]Section, error) {
+	config := s.settings.Load()
+	commitFunc, archiveSize := config.Commit, config.ArchiveSize
+
+	before := Sections(s.root)
+	// 提交回调可能多次调用 apply，只发布最后一次成功执行的命令的事件
+	var pending []types.ConfigEvent
+	apply := func() error {
+		pending = pending[:0]
+		for _, c := range commands {
+			ev, err := s.Events.record(s.root, c.Change, func() error {
+				return Apply(ctx, []Command{c})
+			})
+			if err != nil {
+				return err
+			}
+			pending = append(pending, ev)
+		}
+		return nil
+	}
+	changes := Changes(commands)
+	var err error
+	if commitFunc == nil {
+		err = apply()
+	} else {
+		err = commitFunc(changes, apply)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	user := ""
+	if len(changes) > 0 {
+		user = changes[0].User
+	}
+	s.Archive.Add(Version{Time: time.Now(), User: user, Changes: changes, Sections: before}, archiveSize)
+	s.Events.Publish(pending)
+	return before, nil
+}
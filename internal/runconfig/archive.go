@@ -0,0 +1,64 @@
+package runconfig
+
+import (
+	"sync"
+	"time"
+
+	"github.com/TrailHuang/tnlcmd/pkg/types"
+)
+
+// MaxArchive 最多保存的历史配置数，与 rollback 命令的范围一致
+const MaxArchive = 50
+
+// Version 一次提交之前的配置，回滚到该版本即撤销这次及之后的提交
+type Version struct {
+	Time     time.Time            // 提交的时间
+	User     string               // 提交的会话的用户名
+	Changes  []types.ConfigChange // 这次提交的命令
+	Sections []Section            // 提交之前的配置
+}
+
+// Archive 按提交顺序保存的历史配置，最新的在前
+type Archive struct {
+	mu       sync.Mutex
+	versions []Version
+}
+
+// Add 保存一个版本，超过 limit（最多 MaxArchive）个时丢弃最早的版本，limit 不大于 0 时不保存
+func (a *Archive) Add(v Version, limit int) {
+	if limit <= 0 {
+		return
+	}
+	limit = min(limit, MaxArchive)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.versions = append([]Version{v}, a.versions...)
+	if len(a.versions) > limit {
+		a.versions = a.versions[:limit]
+	}
+}
+
+// Versions 返回保存的所有版本，最新的在前，编号为下标加 1
+func (a *Archive) Versions() []Version {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]Version(nil), a.versions...)
+}
+
+// Get 返回编号为 n 的版本，1 为最近一次提交之前的配置
+func (a *Archive) Get(n int) (Version, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if n < 1 || n > len(a.versions) {
+		return Version{}, false
+	}
+	return a.versions[n-1], true
+}
+
+// clear 丢弃保存的所有版本
+func (a *Archive) clear() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.versions = nil
+}
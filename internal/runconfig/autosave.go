@@ -0,0 +1,117 @@
+package runconfig
+
+import (
+	"bytes"
+	"log"
+	"math/rand/v2"
+	"os"
+	"time"
+)
+
+// AutoSaver 定时或在配置修改后将当前配置保存到启动配置文件
+type AutoSaver struct {
+	store   *Store
+	changed chan struct{}
+	stop    chan struct{}
+	done    chan struct{}
+	unwatch func()
+}
+
+// StartAutoSave 按配置中的 AutoSaveInterval、AutoSaveDelay 和 AutoSaveJitter 开始自动保存，
+// 两者都没有设置时返回 nil
+func (s *Store) StartAutoSave() *AutoSaver {
+	config := s.settings.Load()
+	interval, delay, jitter := config.AutoSaveInterval, config.AutoSaveDelay, config.AutoSaveJitter
+	if interval <= 0 && delay <= 0 {
+		return nil
+	}
+
+	a := &AutoSaver{
+		store:   s,
+		changed: make(chan struct{}, 1),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	if delay > 0 {
+		a.unwatch = s.Events.Watch(func() {
+			select {
+			case a.changed <- struct{}{}:
+			default:
+			}
+		})
+	}
+	go a.run(interval, delay, jitter)
+	return a
+}
+
+// Stop 停止自动保存，修改后等待保存的配置立即保存
+func (a *AutoSaver) Stop() {
+	if a.unwatch != nil {
+		a.unwatch()
+	}
+	close(a.stop)
+	<-a.done
+}
+
+// run 在单独的协程中等待定时器，interval 或 delay 为 0 时对应的定时器保持停止
+func (a *AutoSaver) run(interval, delay, jitter time.Duration) {
+	defer close(a.done)
+
+	periodic := time.NewTimer(withJitter(interval, jitter))
+	if interval <= 0 {
+		periodic.Stop()
+	}
+	defer periodic.Stop()
+	pending := time.NewTimer(delay)
+	pending.Stop()
+	defer pending.Stop()
+	waiting := false
+
+	for {
+		select {
+		case <-a.stop:
+			if waiting {
+				a.save()
+			}
+			return
+		case <-a.changed:
+			// 每次修改都重新计时，连续修改结束之后才保存
+			waiting = true
+			pending.Reset(withJitter(delay, jitter))
+		case <-pending.C:
+			waiting = false
+			a.save()
+		case <-periodic.C:
+			a.save()
+			periodic.Reset(withJitter(interval, jitter))
+		}
+	}
+}
+
+// save 配置与启动配置文件的内容不同时保存，失败时记录日志
+func (a *AutoSaver) save() {
+	path := a.store.settings.Load().StartupConfig
+	if path == "" {
+		log.Printf("Auto-save failed: %v", ErrNoStartupConfig)
+		return
+	}
+
+	// 不保存提交到一半的配置
+	a.store.commitMu.Lock()
+	text := Running(a.store.root)
+	a.store.commitMu.Unlock()
+	if data, err := os.ReadFile(path); err == nil && bytes.Equal(data, []byte(text)) {
+		return
+	}
+	if err := WriteFile(path, text); err != nil {
+		log.Printf("Auto-save to %s failed: %v", path, err)
+	}
+}
+
+// withJitter 返回 d 加上 0 到 jitter 之间的随机时长，使多台设备不在同一时刻保存
+func withJitter(d, jitter time.Duration) time.Duration {
+	if jitter <= 0 {
+		return d
+	}
+	return d + rand.N(jitter)
+}
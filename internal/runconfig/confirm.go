@@ -0,0 +1,94 @@
+package runconfig
+
+import (
+	"context"
+	"io"
+	"log"
+	"time"
+
+	"github.com/TrailHuang/tnlcmd/pkg/types"
+)
+
+// MaxConfirmMinutes commit confirmed 等待确认的最长分钟数
+const MaxConfirmMinutes = 120
+
+// confirmation 等待确认的提交，到期时恢复为 sections
+type confirmation struct {
+	timer    *time.Timer
+	deadline time.Time
+	sections []Section // 第一次 commit confirmed 之前的配置
+}
+
+// CommitConfirmed 执行一次提交，timeout 之内没有调用 Confirm 时自动恢复为提交之前的配置；
+// 已经有等待确认的提交时重新计时，到期时恢复为第一次等待确认的提交之前的配置。调用者不能持有注册表锁
+func (s *Store) CommitConfirmed(ctx *types.Ctx, commands []Command, timeout time.Duration) error {
+	s.commitMu.Lock()
+	defer s.commitMu.Unlock()
+	before, err := s.commit(ctx, commands)
+	if err != nil {
+		return err
+	}
+
+	s.confirmMu.Lock()
+	defer s.confirmMu.Unlock()
+	if previous := s.pending; previous != nil {
+		previous.timer.Stop()
+		before = previous.sections
+	}
+	// 每次计时使用新的记录，已经开始执行的旧定时器发现记录被替换后放弃
+	c := &confirmation{deadline: time.Now().Add(timeout), sections: before}
+	c.timer = time.AfterFunc(timeout, func() {
+		s.expire(c)
+	})
+	s.pending = c
+	return nil
+}
+
+// Confirm 确认等待确认的提交，取消自动恢复；没有等待确认的提交时返回 false
+func (s *Store) Confirm() bool {
+	s.confirmMu.Lock()
+	defer s.confirmMu.Unlock()
+	if s.pending == nil {
+		return false
+	}
+	s.pending.timer.Stop()
+	s.pending = nil
+	return true
+}
+
+// PendingConfirm 返回等待确认的提交自动恢复的时间
+func (s *Store) PendingConfirm() (time.Time, bool) {
+	s.confirmMu.Lock()
+	defer s.confirmMu.Unlock()
+	if s.pending == nil {
+		return time.Time{}, false
+	}
+	return s.pending.deadline, true
+}
+
+// expire 到期时将配置恢复为等待确认的提交之前的配置，恢复也是一次提交，结果记录日志
+func (s *Store) expire(c *confirmation) {
+	s.confirmMu.Lock()
+	if s.pending != c {
+		// 到期的同时被确认、重新计时或者 CmdLine 已经停止
+		s.confirmMu.Unlock()
+		return
+	}
+	s.pending = nil
+	s.confirmMu.Unlock()
+
+	commands, err := ResolveAll(s.root, Diff(Sections(s.root), c.sections))
+	if err != nil {
+		log.Printf("Commit was not confirmed, rollback failed: %v", err)
+		return
+	}
+	if len(commands) == 0 {
+		return
+	}
+	ctx := &types.Ctx{Context: context.Background(), Writer: io.Discard, Format: types.OutputText}
+	if err := s.Commit(ctx, commands); err != nil {
+		log.Printf("Commit was not confirmed, rollback failed: %v", err)
+		return
+	}
+	log.Printf("Commit was not confirmed, rolled back %d change(s)", len(commands))
+}
@@ -0,0 +1,110 @@
+package runconfig
+
+import (
+	"strings"
+
+	"github.com/TrailHuang/tnlcmd/internal/mode"
+	"github.com/TrailHuang/tnlcmd/pkg/types"
+)
+
+// Diff 返回将配置 current 变为 target 需要执行的命令，按执行顺序排列
+// 每个视图先用 no 删除 target 中没有的配置行，再添加 current 中没有的配置行；
+// 进入视图实例的命令在上一级视图中执行，target 中没有的视图实例用 no 加进入命令删除
+func Diff(current, target []Section) []types.ConfigChange {
+	var changes []types.ConfigChange
+	diffSections(current, target, true, &changes)
+	return changes
+}
+
+// Merge 返回将 target 中的配置加入 current 需要执行的命令，current 中多出的配置保留
+func Merge(current, target []Section) []types.ConfigChange {
+	var changes []types.ConfigChange
+	diffSections(current, target, false, &changes)
+	return changes
+}
+
+// diffSections 比较同一层的各段，段按视图和进入命令对应；replace 为 false 时不删除 target 中没有的配置
+func diffSections(current, target []Section, replace bool, changes *[]types.ConfigChange) {
+	currentByKey := groupSections(current)
+	targetByKey := groupSections(target)
+
+	for _, s := range current {
+		if _, exists := targetByKey[sectionKey(s)]; exists || s.Command == "" || !replace {
+			continue
+		}
+		*changes = append(*changes, types.ConfigChange{Mode: parentMode(s.Mode), Command: negate(s.Command)})
+	}
+
+	done := make(map[string]bool)
+	for _, s := range append(append([]Section(nil), current...), target...) {
+		key := sectionKey(s)
+		if done[key] {
+			continue
+		}
+		done[key] = true
+		cur, inCurrent := currentByKey[key]
+		tgt, inTarget := targetByKey[key]
+		if s.Command != "" && !inTarget {
+			continue
+		}
+		if s.Command != "" && !inCurrent {
+			*changes = append(*changes, types.ConfigChange{Mode: parentMode(s.Mode), Command: s.Command})
+		}
+
+		want := make(map[string]bool)
+		for _, line := range tgt.Lines {
+			want[line] = true
+		}
+		have := make(map[string]bool)
+		for _, line := range cur.Lines {
+			have[line] = true
+			if !want[line] && replace {
+				*changes = append(*changes, types.ConfigChange{Mode: s.Mode, Command: negate(line)})
+			}
+		}
+		for _, line := range tgt.Lines {
+			if !have[line] {
+				*changes = append(*changes, types.ConfigChange{Mode: s.Mode, Command: line})
+			}
+		}
+		diffSections(cur.Sections, tgt.Sections, replace, changes)
+	}
+}
+
+// groupSections 按 sectionKey 合并同一层的各段，同一视图中没有进入命令的多段合并为一段
+func groupSections(sections []Section) map[string]Section {
+	groups := make(map[string]Section)
+	for _, s := range sections {
+		key := sectionKey(s)
+		group, exists := groups[key]
+		if !exists {
+			groups[key] = Section{Mode: s.Mode, Command: s.Command, Lines: s.Lines, Sections: s.Sections}
+			continue
+		}
+		group.Lines = append(append([]string(nil), group.Lines...), s.Lines...)
+		group.Sections = append(append([]Section(nil), group.Sections...), s.Sections...)
+		groups[key] = group
+	}
+	return groups
+}
+
+// sectionKey 段的对应关系，视图和进入命令都相同的段是同一个视图实例
+func sectionKey(s Section) string {
+	return s.Mode + "\x00" + s.Command
+}
+
+// parentMode 返回上一级视图的路径，根视图的子视图返回空字符串
+func parentMode(path string) string {
+	if i := strings.LastIndex(path, mode.PathSeparator); i >= 0 {
+		return path[:i]
+	}
+	return ""
+}
+
+// negate 返回撤销配置行的命令：no 开头的行去掉 no，其他的行加上 no
+func negate(line string) string {
+	if rest, ok := strings.CutPrefix(line, "no "); ok {
+		return rest
+	}
+	return "no " + line
+}
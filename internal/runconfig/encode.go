@@ -0,0 +1,298 @@
+package runconfig
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// 导出配置的格式
+const (
+	FormatJSON = "json"
+	FormatYAML = "yaml"
+)
+
+// Marshal 将配置编码为 JSON 或 YAML 文档，文档是各段组成的列表，每段包括 mode、command、lines 和 sections
+func Marshal(sections []Section, format string) ([]byte, error) {
+	if sections == nil {
+		sections = []Section{}
+	}
+	switch format {
+	case FormatJSON:
+		data, err := json.MarshalIndent(sections, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return append(data, '\n'), nil
+	case FormatYAML:
+		var b bytes.Buffer
+		if len(sections) == 0 {
+			b.WriteString("[]\n")
+		}
+		writeYAML(&b, sections, "")
+		return b.Bytes(), nil
+	}
+	return nil, fmt.Errorf("unknown configuration format: %s", format)
+}
+
+// Unmarshal 解析 Marshal 生成的文档，以 [ 开头的文档按 JSON 解析，其他的按 YAML 解析
+func Unmarshal(data []byte) ([]Section, error) {
+	var sections []Section
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		if err := json.Unmarshal(trimmed, &sections); err != nil {
+			return nil, err
+		}
+		return sections, nil
+	}
+
+	p, err := newYAMLParser(string(data))
+	if err != nil {
+		return nil, err
+	}
+	if len(p.lines) == 0 {
+		return nil, nil
+	}
+	if sections, err = p.sections(p.lines[0].indent); err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.lines) {
+		return nil, p.errorf("unexpected indentation")
+	}
+	return sections, nil
+}
+
+// writeYAML 按块格式写出各段，indent 为列表项的缩进
+func writeYAML(b *bytes.Buffer, sections []Section, indent string) {
+	for _, s := range sections {
+		fmt.Fprintf(b, "%s- mode: %s\n", indent, yamlString(s.Mode))
+		if s.Command != "" {
+			fmt.Fprintf(b, "%s  command: %s\n", indent, yamlString(s.Command))
+		}
+		if len(s.Lines) > 0 {
+			fmt.Fprintf(b, "%s  lines:\n", indent)
+			for _, line := range s.Lines {
+				fmt.Fprintf(b, "%s    - %s\n", indent, yamlString(line))
+			}
+		}
+		if len(s.Sections) > 0 {
+			fmt.Fprintf(b, "%s  sections:\n", indent)
+			writeYAML(b, s.Sections, indent+"    ")
+		}
+	}
+}
+
+// yamlString 返回字符串的 YAML 标量，可能被解析为其他类型或含有特殊字符时加双引号
+func yamlString(v string) string {
+	if v == "" || strings.TrimSpace(v) != v || strings.ContainsAny(v[:1], "-?:,[]{}#&*!|>'\"%@`") ||
+		strings.Contains(v, ": ") || strings.Contains(v, " #") || strings.HasSuffix(v, ":") {
+		return quote(v)
+	}
+	for _, r := range v {
+		if r < ' ' || r == 0x7f {
+			return quote(v)
+		}
+	}
+	switch strings.ToLower(v) {
+	case "true", "false", "yes", "no", "on", "off", "null", "~":
+		return quote(v)
+	}
+	if _, err := strconv.ParseFloat(v, 64); err == nil {
+		return quote(v)
+	}
+	return v
+}
+
+// quote 返回 JSON 形式的双引号字符串，也是合法的 YAML 双引号标量
+func quote(v string) string {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	enc.Encode(v)
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// yamlLine 去掉空行和注释后的一行
+type yamlLine struct {
+	number int    // 行号，从 1 开始
+	indent int    // 行首空格数
+	text   string // 缩进之后的内容
+}
+
+// yamlParser 解析 Marshal 输出所用的 YAML 子集：块格式的列表和映射、三种标量、空列表 []
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+}
+
+// newYAMLParser 将文档拆分为行，缩进不能使用制表符
+func newYAMLParser(doc string) (*yamlParser, error) {
+	p := &yamlParser{}
+	for i, raw := range strings.Split(doc, "\n") {
+		raw = strings.TrimRight(raw, " \r")
+		text := strings.TrimLeft(raw, " ")
+		if text == "" || strings.HasPrefix(text, "#") || text == "---" {
+			continue
+		}
+		if strings.HasPrefix(text, "\t") {
+			return nil, fmt.Errorf("line %d: tabs are not allowed in indentation", i+1)
+		}
+		p.lines = append(p.lines, yamlLine{number: i + 1, indent: len(raw) - len(text), text: text})
+	}
+	return p, nil
+}
+
+// errorf 返回带当前行号的错误
+func (p *yamlParser) errorf(format string, args ...interface{}) error {
+	number := 0
+	if p.pos < len(p.lines) {
+		number = p.lines[p.pos].number
+	} else if len(p.lines) > 0 {
+		number = p.lines[len(p.lines)-1].number
+	}
+	return fmt.Errorf("line %d: %s", number, fmt.Sprintf(format, args...))
+}
+
+// item 检查当前行是否为缩进为 indent 的列表项，返回 - 之后的内容及其缩进
+func (p *yamlParser) item(indent int) (string, int, bool) {
+	if p.pos >= len(p.lines) {
+		return "", 0, false
+	}
+	line := p.lines[p.pos]
+	if line.indent != indent || (line.text != "-" && !strings.HasPrefix(line.text, "- ")) {
+		return "", 0, false
+	}
+	rest := strings.TrimLeft(line.text[1:], " ")
+	return rest, indent + len(line.text) - len(rest), true
+}
+
+// sections 解析缩进为 indent 的各段
+func (p *yamlParser) sections(indent int) ([]Section, error) {
+	if p.pos < len(p.lines) && p.lines[p.pos].text == "[]" {
+		p.pos++
+		return nil, nil
+	}
+	var sections []Section
+	for {
+		rest, itemIndent, ok := p.item(indent)
+		if !ok {
+			return sections, nil
+		}
+		if rest == "" {
+			return nil, p.errorf("empty section")
+		}
+		// 列表项的第一个键与之后的键对齐
+		p.lines[p.pos] = yamlLine{number: p.lines[p.pos].number, indent: itemIndent, text: rest}
+		s, err := p.section(itemIndent)
+		if err != nil {
+			return nil, err
+		}
+		sections = append(sections, s)
+	}
+}
+
+// section 解析缩进为 indent 的映射
+func (p *yamlParser) section(indent int) (Section, error) {
+	var s Section
+	for p.pos < len(p.lines) && p.lines[p.pos].indent == indent {
+		if _, _, ok := p.item(indent); ok {
+			break
+		}
+		key, value, found := strings.Cut(p.lines[p.pos].text, ":")
+		if !found || (value != "" && value[0] != ' ') {
+			return s, p.errorf("expected key: value")
+		}
+		value = strings.TrimSpace(value)
+		p.pos++
+
+		var err error
+		switch key {
+		case "mode":
+			s.Mode, err = p.scalar(value)
+		case "command":
+			s.Command, err = p.scalar(value)
+		case "lines":
+			s.Lines, err = p.strings(indent, value)
+		case "sections":
+			if value == "[]" {
+				break
+			}
+			if value != "" {
+				return s, p.errorf("expected a list of sections")
+			}
+			if childIndent, ok := p.blockIndent(indent); ok {
+				s.Sections, err = p.sections(childIndent)
+			}
+		default:
+			p.pos--
+			return s, p.errorf("unknown key: %s", key)
+		}
+		if err != nil {
+			return s, err
+		}
+	}
+	return s, nil
+}
+
+// blockIndent 返回键之后块格式列表的缩进，列表可以与键对齐
+func (p *yamlParser) blockIndent(keyIndent int) (int, bool) {
+	if p.pos >= len(p.lines) {
+		return 0, false
+	}
+	line := p.lines[p.pos]
+	if line.indent > keyIndent || (line.indent == keyIndent && strings.HasPrefix(line.text, "-")) {
+		return line.indent, true
+	}
+	return 0, false
+}
+
+// strings 解析字符串列表，value 为键之后同一行的内容，可以是 [] 或 JSON 形式的列表
+func (p *yamlParser) strings(keyIndent int, value string) ([]string, error) {
+	if value != "" {
+		var list []string
+		if err := json.Unmarshal([]byte(value), &list); err != nil {
+			p.pos--
+			return nil, p.errorf("expected a list of strings")
+		}
+		return list, nil
+	}
+	indent, ok := p.blockIndent(keyIndent)
+	if !ok {
+		return nil, nil
+	}
+	var list []string
+	for {
+		rest, _, ok := p.item(indent)
+		if !ok {
+			return list, nil
+		}
+		v, err := p.scalar(rest)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, v)
+		p.pos++
+	}
+}
+
+// scalar 解析标量：双引号按 JSON 转义，单引号中连续两个单引号表示一个单引号，其他按原样并去掉行尾注释
+func (p *yamlParser) scalar(v string) (string, error) {
+	switch {
+	case strings.HasPrefix(v, `"`):
+		var s string
+		if err := json.Unmarshal([]byte(v), &s); err != nil {
+			return "", p.errorf("invalid quoted string: %s", v)
+		}
+		return s, nil
+	case strings.HasPrefix(v, "'"):
+		if len(v) < 2 || !strings.HasSuffix(v, "'") {
+			return "", p.errorf("invalid quoted string: %s", v)
+		}
+		return strings.ReplaceAll(v[1:len(v)-1], "''", "'"), nil
+	}
+	if i := strings.Index(v, " #"); i >= 0 {
+		v = v[:i]
+	}
+	return strings.TrimSpace(v), nil
+}
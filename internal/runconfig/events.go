@@ -0,0 +1,141 @@
+package runconfig
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/TrailHuang/tnlcmd/internal/mode"
+	"github.com/TrailHuang/tnlcmd/pkg/types"
+)
+
+// Events 配置修改事件的订阅者
+type Events struct {
+	mu          sync.Mutex
+	nextID      int
+	subscribers []subscriber
+}
+
+// subscriber 一个订阅函数及其编号，编号用于取消订阅
+type subscriber struct {
+	id     int
+	fn     types.ConfigEventFunc
+	detail bool // 需要事件中变化的配置行
+}
+
+// Subscribe 添加订阅函数，返回取消订阅的函数
+func (e *Events) Subscribe(fn types.ConfigEventFunc) func() {
+	return e.subscribe(fn, true)
+}
+
+// Watch 添加只关心配置是否修改的订阅函数，事件中不计算变化的配置行，返回取消订阅的函数
+func (e *Events) Watch(fn func()) func() {
+	return e.subscribe(func(types.ConfigEvent) { fn() }, false)
+}
+
+// subscribe 添加订阅函数，返回取消订阅的函数
+func (e *Events) subscribe(fn types.ConfigEventFunc, detail bool) func() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.nextID++
+	id := e.nextID
+	e.subscribers = append(e.subscribers, subscriber{id: id, fn: fn, detail: detail})
+	return func() {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		for i, sub := range e.subscribers {
+			if sub.id == id {
+				e.subscribers = append(e.subscribers[:i:i], e.subscribers[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// detailed 返回是否有订阅者需要变化的配置行
+func (e *Events) detailed() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, sub := range e.subscribers {
+		if sub.detail {
+			return true
+		}
+	}
+	return false
+}
+
+// Publish 按订阅顺序将事件依次发给每个订阅函数，调用期间不持有锁，订阅函数可以取消订阅
+func (e *Events) Publish(list []types.ConfigEvent) {
+	e.mu.Lock()
+	subscribers := e.subscribers
+	e.mu.Unlock()
+	for _, ev := range list {
+		for _, sub := range subscribers {
+			sub.fn(ev)
+		}
+	}
+}
+
+// Track 执行配置命令，成功后发布事件，调用者不能持有注册表锁
+func (e *Events) Track(root *mode.CommandMode, change types.ConfigChange, run func() error) error {
+	ev, err := e.record(root, change, run)
+	if err == nil {
+		e.Publish([]types.ConfigEvent{ev})
+	}
+	return err
+}
+
+// record 执行配置命令，返回待发布的事件；有订阅者需要时比较执行前后的配置得到变化的配置行
+func (e *Events) record(root *mode.CommandMode, change types.ConfigChange, run func() error) (types.ConfigEvent, error) {
+	if !e.detailed() {
+		err := run()
+		return types.ConfigEvent{ConfigChange: change, Time: time.Now()}, err
+	}
+	before := Sections(root)
+	if err := run(); err != nil {
+		return types.ConfigEvent{}, err
+	}
+	after := Sections(root)
+	return types.ConfigEvent{
+		ConfigChange: change,
+		Time:         time.Now(),
+		Old:          missingLines(before, after),
+		New:          missingLines(after, before),
+	}, nil
+}
+
+// missingLines 返回 a 中有而 b 中没有的配置行，按 a 中的顺序排列
+func missingLines(a, b []Section) []types.ConfigLine {
+	have := make(map[string]bool)
+	for _, line := range flatten(b, nil, nil) {
+		have[lineKey(line)] = true
+	}
+	var missing []types.ConfigLine
+	for _, line := range flatten(a, nil, nil) {
+		if !have[lineKey(line)] {
+			missing = append(missing, line)
+		}
+	}
+	return missing
+}
+
+// flatten 按输出顺序列出各段的配置行，parents 为外层视图实例的进入命令
+func flatten(sections []Section, parents []string, lines []types.ConfigLine) []types.ConfigLine {
+	for _, s := range sections {
+		inner := parents
+		if s.Command != "" {
+			lines = append(lines, types.ConfigLine{Parents: parents, Line: s.Command})
+			inner = append(append([]string(nil), parents...), s.Command)
+		}
+		for _, line := range s.Lines {
+			lines = append(lines, types.ConfigLine{Parents: inner, Line: line})
+		}
+		lines = flatten(s.Sections, inner, lines)
+	}
+	return lines
+}
+
+// lineKey 返回区分配置行所在位置的键
+func lineKey(line types.ConfigLine) string {
+	return strings.Join(append(append([]string(nil), line.Parents...), line.Line), "\x00")
+}
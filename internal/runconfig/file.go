@@ -0,0 +1,32 @@
+package runconfig
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// ErrNoStartupConfig 没有设置启动配置文件时保存配置返回的错误
+var ErrNoStartupConfig = errors.New("startup configuration file is not configured")
+
+// WriteFile 将配置写到 path，先写到同一目录的临时文件再改名，写入失败时原有的文件保持不变
+func WriteFile(path, text string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.WriteString(text); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
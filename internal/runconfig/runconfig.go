@@ -0,0 +1,117 @@
+// Package runconfig 按视图注册的渲染函数生成 show running-config 输出的配置
+package runconfig
+
+import (
+	"strings"
+
+	"github.com/TrailHuang/tnlcmd/internal/mode"
+	"github.com/TrailHuang/tnlcmd/pkg/types"
+)
+
+// Section 渲染后的一段配置
+type Section struct {
+	Mode     string    `json:"mode"`               // 生成该段的视图路径，如 interface/sub-interface
+	Command  string    `json:"command,omitempty"`  // 进入视图实例的命令，为空时配置行直接属于上一级
+	Lines    []string  `json:"lines,omitempty"`    // 视图中的配置命令
+	Sections []Section `json:"sections,omitempty"` // 嵌套视图在该视图实例中的配置
+}
+
+// Renderer 视图树中渲染函数的快照，渲染期间不持有注册表锁，渲染函数可以调用 CmdLine 的方法
+type Renderer struct {
+	path      string
+	renderers []types.ConfigRenderer
+	children  []*Renderer
+}
+
+// Running 返回 root 之下所有视图渲染得到的配置文本，调用者不能持有注册表锁
+func Running(root *mode.CommandMode) string {
+	return Format(Sections(root))
+}
+
+// Sections 返回 root 之下所有视图渲染得到的配置
+// 渲染函数可能调用 CmdLine 的方法，只在复制渲染函数期间持有注册表读锁，调用者不能持有注册表锁
+func Sections(root *mode.CommandMode) []Section {
+	registry := root.CommandTree.Registry()
+	registry.RLock()
+	renderer := Snapshot(root)
+	registry.RUnlock()
+	return renderer.Render()
+}
+
+// Snapshot 复制 root 及其子孙视图的渲染函数，调用者需持有注册表读锁
+// 子视图按名称排序，没有渲染函数的视图分支被省略
+func Snapshot(root *mode.CommandMode) *Renderer {
+	r := &Renderer{
+		path:      root.Path(),
+		renderers: append([]types.ConfigRenderer(nil), root.Renderers...),
+	}
+	for _, subMode := range root.SortedSubModes() {
+		if child := Snapshot(subMode); len(child.renderers) > 0 || len(child.children) > 0 {
+			r.children = append(r.children, child)
+		}
+	}
+	return r
+}
+
+// Render 调用各视图的渲染函数，返回按视图嵌套的配置
+func (r *Renderer) Render() []Section {
+	return r.render(nil)
+}
+
+// render 渲染本视图及其子视图，parents 为外层视图实例的进入命令
+// 本视图生成了带进入命令的段时，子视图对每一段分别渲染并嵌套在其中，否则与本视图的配置并列
+func (r *Renderer) render(parents []string) []Section {
+	var sections []Section
+	for _, renderer := range r.renderers {
+		for _, s := range renderer(parents) {
+			sections = append(sections, Section{Mode: r.path, Command: s.Command, Lines: s.Lines})
+		}
+	}
+
+	nested := false
+	for i := range sections {
+		if sections[i].Command == "" {
+			continue
+		}
+		nested = true
+		inner := append(append([]string(nil), parents...), sections[i].Command)
+		for _, child := range r.children {
+			sections[i].Sections = append(sections[i].Sections, child.render(inner)...)
+		}
+	}
+	if !nested {
+		for _, child := range r.children {
+			sections = append(sections, child.render(parents)...)
+		}
+	}
+	return sections
+}
+
+// Format 将配置排列为文本，嵌套视图的配置行每层缩进一格，顶层的各段之间以 ! 分隔，以 end 结束
+func Format(sections []Section) string {
+	var b strings.Builder
+	b.WriteString("!\n")
+	for _, s := range sections {
+		if len(s.Lines) == 0 && len(s.Sections) == 0 && s.Command == "" {
+			continue
+		}
+		writeSection(&b, s, 0)
+		b.WriteString("!\n")
+	}
+	b.WriteString("end\n")
+	return b.String()
+}
+
+// writeSection 按缩进层次 depth 写出一段配置
+func writeSection(b *strings.Builder, s Section, depth int) {
+	if s.Command != "" {
+		b.WriteString(strings.Repeat(" ", depth) + s.Command + "\n")
+		depth++
+	}
+	for _, line := range s.Lines {
+		b.WriteString(strings.Repeat(" ", depth) + line + "\n")
+	}
+	for _, child := range s.Sections {
+		writeSection(b, child, depth)
+	}
+}
@@ -0,0 +1,39 @@
+package runconfig
+
+import (
+	"sync"
+
+	"github.com/TrailHuang/tnlcmd/internal/mode"
+	"github.com/TrailHuang/tnlcmd/internal/settings"
+)
+
+// Store 一个 CmdLine 的配置提交状态：历史配置、配置修改事件的订阅者和等待确认的提交，
+// 由 CmdLine 创建并传给它的所有会话，同一进程中的多个 CmdLine 互不影响
+type Store struct {
+	settings *settings.Settings
+	root     *mode.CommandMode
+
+	commitMu sync.Mutex // 使会话的 commit 和应用导入的配置等所有提交依次进行
+
+	Archive Archive // 历史配置，commit 时保存，rollback 时读取
+	Events  Events  // 配置修改事件的订阅者
+
+	confirmMu sync.Mutex
+	pending   *confirmation // 等待确认的提交，没有时为 nil
+}
+
+// NewStore 创建配置 settings 和根视图 root 对应的提交状态
+func NewStore(settings *settings.Settings, root *mode.CommandMode) *Store {
+	return &Store{settings: settings, root: root}
+}
+
+// Close 取消等待确认的提交的自动恢复并丢弃历史配置，CmdLine 停止时调用；订阅者保持不变
+func (s *Store) Close() {
+	s.confirmMu.Lock()
+	if s.pending != nil {
+		s.pending.timer.Stop()
+		s.pending = nil
+	}
+	s.confirmMu.Unlock()
+	s.Archive.clear()
+}
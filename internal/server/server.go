@@ -5,22 +5,24 @@ import (
 	"fmt"
 	"io"
 	"net"
+	"sort"
 	"sync"
 
 	"github.com/TrailHuang/tnlcmd/internal/commandtree"
 	"github.com/TrailHuang/tnlcmd/internal/mode"
 	"github.com/TrailHuang/tnlcmd/internal/session"
+	"github.com/TrailHuang/tnlcmd/internal/settings"
 	"github.com/TrailHuang/tnlcmd/pkg/types"
 )
 
 // TelnetServer telnet服务器
 type TelnetServer struct {
-	config      *types.Config
+	shared      *session.Shared // 传给每个会话的共用状态，包括配置
 	commands    map[string]types.CommandInfo
 	commandTree *commandtree.CommandTree
 	context     *mode.CommandContext
 	listener    net.Listener
-	sessions    map[net.Conn]*session.Session
+	sessions    map[*session.Session]bool
 	mu          sync.RWMutex
 	ctx         context.Context
 	cancel      context.CancelFunc
@@ -31,24 +33,24 @@ func NewTelnetServer(config *types.Config, commands map[string]types.CommandInfo
 	ctx, cancel := context.WithCancel(context.Background())
 
 	return &TelnetServer{
-		config:   config,
+		shared:   session.NewShared(settings.New(config), config.RootMode.(*mode.CommandMode)),
 		commands: commands,
-		sessions: make(map[net.Conn]*session.Session),
+		sessions: make(map[*session.Session]bool),
 		ctx:      ctx,
 		cancel:   cancel,
 	}
 }
 
-// NewTelnetServerWithContext 创建带上下文的telnet服务器
-func NewTelnetServerWithContext(config *types.Config, commandctx *mode.CommandContext) *TelnetServer {
+// NewTelnetServerWithContext 创建带上下文的telnet服务器，shared 为所属 CmdLine 的所有会话共用的状态
+func NewTelnetServerWithContext(shared *session.Shared, commandctx *mode.CommandContext) *TelnetServer {
 	ctx, cancel := context.WithCancel(context.Background())
 
 	return &TelnetServer{
-		config:      config,
+		shared:      shared,
 		commands:    commandctx.GetAvailableCommands(),
 		commandTree: commandctx.CommandTree,
 		context:     commandctx,
-		sessions:    make(map[net.Conn]*session.Session),
+		sessions:    make(map[*session.Session]bool),
 		ctx:         ctx,
 		cancel:      cancel,
 	}
@@ -57,17 +59,18 @@ func NewTelnetServerWithContext(config *types.Config, commandctx *mode.CommandCo
 // Start 启动telnet服务器
 func (ts *TelnetServer) Start() error {
 	var err error
-	fmt.Printf("Attempting to listen on port %d...\n", ts.config.Port)
-	ts.listener, err = net.Listen("tcp", fmt.Sprintf(":%d", ts.config.Port))
+	port := ts.shared.Settings.Load().Port
+	fmt.Printf("Attempting to listen on port %d...\n", port)
+	ts.listener, err = net.Listen("tcp", fmt.Sprintf(":%d", port))
 	if err != nil {
-		fmt.Printf("Failed to listen on port %d: %v\n", ts.config.Port, err)
+		fmt.Printf("Failed to listen on port %d: %v\n", port, err)
 		return fmt.Errorf("failed to start server: %w", err)
 	}
 
-	fmt.Printf("Successfully listening on port %d, starting accept connections...\n", ts.config.Port)
+	fmt.Printf("Successfully listening on port %d, starting accept connections...\n", port)
 	go ts.acceptConnections()
 
-	fmt.Printf("Telnet server started on port %d\n", ts.config.Port)
+	fmt.Printf("Telnet server started on port %d\n", port)
 	return nil
 }
 
@@ -83,9 +86,9 @@ func (ts *TelnetServer) Stop() {
 
 	// 关闭所有会话
 	ts.mu.Lock()
-	for conn, session := range ts.sessions {
+	for session := range ts.sessions {
 		session.Close()
-		delete(ts.sessions, conn)
+		delete(ts.sessions, session)
 	}
 	ts.mu.Unlock()
 }
@@ -111,32 +114,33 @@ func (ts *TelnetServer) acceptConnections() {
 	}
 }
 
+// ServeConn 在已经建立的连接上运行一个会话，会话结束时返回并关闭连接；服务器不需要监听端口
+func (ts *TelnetServer) ServeConn(conn net.Conn) {
+	ts.handleConnection(conn)
+}
+
 // handleConnection 处理连接
 func (ts *TelnetServer) handleConnection(conn net.Conn) {
-	// 使用服务器中的上下文（如果可用）
+	ts.ServeStream(conn, types.StreamOptions{RemoteAddr: conn.RemoteAddr().String(), Telnet: true})
+}
+
+// ServeStream 在数据流上运行一个会话，会话结束时返回，rw 实现了 io.Closer 时将其关闭
+func (ts *TelnetServer) ServeStream(rw io.ReadWriter, options types.StreamOptions) {
+	// 每个连接使用独立的上下文，从根视图开始，会话之间不共享当前视图和路径
 	var context *mode.CommandContext
 	if ts.context != nil {
-		// 复制上下文，但每个连接使用独立的实例
-		context = &mode.CommandContext{
-			CurrentMode: ts.context.CurrentMode,
-			Path:        make([]string, len(ts.context.Path)),
-			CommandTree: ts.context.CommandTree,
-		}
-		copy(context.Path, ts.context.Path)
+		context = mode.NewCommandContext(ts.context.GetRootMode(), ts.context.CommandTree)
 	} else {
 		// 向后兼容：创建新的上下文
-		context = &mode.CommandContext{
-			CurrentMode: ts.config.RootMode.(*mode.CommandMode),
-			Path:        []string{},
-		}
+		context = mode.NewCommandContext(ts.shared.Settings.Load().RootMode.(*mode.CommandMode), nil)
 	}
 
 	// 创建会话
-	session := session.NewSessionWithContext(conn, ts.config, context)
+	session := session.NewStreamSession(rw, ts.shared, context, options)
 
 	// 注册会话
 	ts.mu.Lock()
-	ts.sessions[conn] = session
+	ts.sessions[session] = true
 	ts.mu.Unlock()
 
 	// 处理会话
@@ -147,9 +151,34 @@ func (ts *TelnetServer) handleConnection(conn net.Conn) {
 
 	// 会话结束，清理
 	ts.mu.Lock()
-	delete(ts.sessions, conn)
+	delete(ts.sessions, session)
 	ts.mu.Unlock()
-	conn.Close()
+	if closer, ok := rw.(io.Closer); ok {
+		closer.Close()
+	}
+}
+
+// Sessions 按连接建立的时间返回所有活动的会话
+func (ts *TelnetServer) Sessions() []types.Session {
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+	sessions := make([]types.Session, 0, len(ts.sessions))
+	for session := range ts.sessions {
+		sessions = append(sessions, session)
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LoginTime().Before(sessions[j].LoginTime())
+	})
+	return sessions
+}
+
+// Notify 向开启了 terminal monitor 的会话发送通知
+func (ts *TelnetServer) Notify(message string) {
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+	for session := range ts.sessions {
+		session.Notify(message)
+	}
 }
 
 // UpdateAllSessionsPrompt 更新所有活动会话的提示符
@@ -157,7 +186,7 @@ func (ts *TelnetServer) UpdateAllSessionsPrompt(prompt string) {
 	ts.mu.RLock()
 	defer ts.mu.RUnlock()
 
-	for _, session := range ts.sessions {
+	for session := range ts.sessions {
 		session.UpdatePrompt(prompt)
 	}
 }
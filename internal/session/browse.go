@@ -0,0 +1,53 @@
+package session
+
+import (
+	"strings"
+
+	"github.com/TrailHuang/tnlcmd/internal/history"
+)
+
+// historyBrowser 用上下键浏览历史命令的状态
+// 开始浏览时已经输入了内容，则只浏览以该内容开头的记录，输入为空时浏览全部记录；
+// 开始浏览时的输入作为最新的一条，浏览到最新的记录之后再按下键时恢复
+type historyBrowser struct {
+	hist   *history.CommandHistory
+	index  int    // 正在显示的记录，-1 表示没有在浏览
+	prefix string // 开始浏览时的输入，结束浏览时恢复
+}
+
+// newHistoryBrowser 创建浏览 hist 的状态
+func newHistoryBrowser(hist *history.CommandHistory) *historyBrowser {
+	return &historyBrowser{hist: hist, index: -1}
+}
+
+// previous 返回更早的一条匹配的记录，current 为当前的输入行；没有更早的记录时返回 false
+// 与当前显示的内容相同的记录被跳过
+func (b *historyBrowser) previous(current string) (string, bool) {
+	start := b.index
+	if b.index < 0 {
+		b.prefix = current
+		start = b.hist.Len()
+	}
+	for i := start - 1; i >= 0; i-- {
+		if entry := b.hist.Get(i); strings.HasPrefix(entry, b.prefix) && entry != current {
+			b.index = i
+			return entry, true
+		}
+	}
+	return "", false
+}
+
+// next 返回更新的一条匹配的记录，已经是最新的记录时结束浏览并返回开始浏览时的输入；没有在浏览时返回 false
+func (b *historyBrowser) next(current string) (string, bool) {
+	if b.index < 0 {
+		return "", false
+	}
+	for i := b.index + 1; i < b.hist.Len(); i++ {
+		if entry := b.hist.Get(i); strings.HasPrefix(entry, b.prefix) && entry != current {
+			b.index = i
+			return entry, true
+		}
+	}
+	b.index = -1
+	return b.prefix, true
+}
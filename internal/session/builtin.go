@@ -0,0 +1,200 @@
+package session
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/TrailHuang/tnlcmd/internal/commandtree"
+	"github.com/TrailHuang/tnlcmd/internal/telnet"
+	"github.com/TrailHuang/tnlcmd/pkg/types"
+)
+
+// builtinCommand 会话内置命令，处理函数可以访问会话状态
+type builtinCommand struct {
+	name        string
+	description string
+	handler     func(s *Session, args []string) string
+	global      bool // 在所有视图中注册
+	tree        bool // 只读取命令树，在注册表读锁下执行，见 registerHelpBuiltin
+}
+
+// builtinCommands 会话内置命令表，按命令路径索引
+var builtinCommands = map[string]builtinCommand{}
+
+// registerBuiltin 注册会话内置命令
+func registerBuiltin(name, description string, handler func(s *Session, args []string) string) {
+	builtinCommands[name] = builtinCommand{name: name, description: description, handler: handler}
+}
+
+// lookupBuiltin 返回节点对应的内置命令，应用注册的同名命令不是内置命令
+func lookupBuiltin(node *commandtree.CommandNode) (builtinCommand, bool) {
+	name, ok := node.Handler.(commandtree.BuiltinHandler)
+	if !ok {
+		return builtinCommand{}, false
+	}
+	builtin, exists := builtinCommands[string(name)]
+	return builtin, exists
+}
+
+// registerGlobalBuiltin 注册在所有视图中都可以使用的会话内置命令
+func registerGlobalBuiltin(name, description string, handler func(s *Session, args []string) string) {
+	builtinCommands[name] = builtinCommand{name: name, description: description, handler: handler, global: true}
+}
+
+// registerHelpBuiltin 注册在所有视图中都可以使用、只读取命令树的内置命令，会话在持有注册表读锁时调用处理函数
+func registerHelpBuiltin(name, description string, handler func(s *Session, args []string) string) {
+	builtinCommands[name] = builtinCommand{name: name, description: description, handler: handler, global: true, tree: true}
+}
+
+func init() {
+	registerBuiltin("debug telnet", "Show telnet option negotiation of this session", (*Session).debugTelnet)
+	registerHelpBuiltin("help", "Show commands available in the current mode", (*Session).help)
+	registerHelpBuiltin("help LINE", "Show the syntax and description of a command", (*Session).helpCommand)
+	registerHelpBuiltin("apropos LINE", "Search commands of the current mode by name or description", (*Session).apropos)
+}
+
+// BuiltinCommands 返回需要注册到根视图命令树的内置命令
+// 命令树中的处理函数为 commandtree.BuiltinHandler，只用于标记节点，实际执行由会话完成
+func BuiltinCommands() []types.CommandInfo {
+	return builtinInfos(false)
+}
+
+// GlobalBuiltinCommands 返回需要注册到所有视图的内置命令
+func GlobalBuiltinCommands() []types.CommandInfo {
+	return builtinInfos(true)
+}
+
+// builtinInfos 按名称顺序返回内置命令的注册信息
+func builtinInfos(global bool) []types.CommandInfo {
+	names := make([]string, 0, len(builtinCommands))
+	for name, cmd := range builtinCommands {
+		if cmd.global == global {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	commands := make([]types.CommandInfo, 0, len(names))
+	for _, name := range names {
+		commands = append(commands, types.CommandInfo{
+			Name:        name,
+			Description: builtinCommands[name].description,
+			Handler:     commandtree.BuiltinHandler(name),
+		})
+	}
+	return commands
+}
+
+// debugTelnet 显示本会话的 telnet 选项状态和协商记录
+func (s *Session) debugTelnet(args []string) string {
+	var result strings.Builder
+
+	mode := "character"
+	if s.lineMode {
+		mode = "line"
+	}
+	if s.raw {
+		result.WriteString(fmt.Sprintf("Session %s, %s mode, not a telnet connection\n", s.remoteAddr, mode))
+		return result.String()
+	}
+	result.WriteString(fmt.Sprintf("Telnet session %s, %s mode\n", s.remoteAddr, mode))
+
+	result.WriteString("Options:\n")
+	result.WriteString(fmt.Sprintf("  %-20s %-8s %s\n", "Option", "Local", "Remote"))
+	for _, st := range s.telnet.Status() {
+		result.WriteString(fmt.Sprintf("  %-20s %-8s %s\n", telnet.OptionName(st.Option), onOff(st.Local), onOff(st.Remote)))
+	}
+
+	result.WriteString("Negotiation log:\n")
+	for _, entry := range s.telnet.Log() {
+		result.WriteString(fmt.Sprintf("  %s %s\n", entry.Time.Format("15:04:05.000"), entry))
+	}
+	return result.String()
+}
+
+// help 按视图定义的分组列出当前视图的命令，未分组的命令按名称排在最后
+func (s *Session) help(args []string) string {
+	current := s.context.CurrentMode
+	descriptions := make(map[string]string)
+	var names []string
+	for _, child := range commandtree.SortedChildren(current.CommandTree.Root) {
+		if commandtree.IsHidden(child) {
+			continue
+		}
+		descriptions[child.Name] = commandtree.HelpText(child)
+		names = append(names, child.Name)
+	}
+	// 根视图的子视图在任意视图中都可以进入
+	for name, subMode := range s.context.GetRootMode().Children {
+		if _, exists := descriptions[name]; !exists && subMode != current {
+			descriptions[name] = fmt.Sprintf("Enter %s mode", subMode.Description)
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var result strings.Builder
+	if current.HelpHeader != "" {
+		result.WriteString(current.HelpHeader + "\n\n")
+	}
+
+	listed := make(map[string]bool)
+	for _, category := range current.Categories {
+		var lines []string
+		for _, name := range category.Keywords {
+			if description, exists := descriptions[name]; exists && !listed[name] {
+				lines = append(lines, fmt.Sprintf("  %-30s %s\n", name, description))
+				listed[name] = true
+			}
+		}
+		if len(lines) == 0 {
+			continue
+		}
+		result.WriteString(category.Name + ":\n")
+		result.WriteString(strings.Join(lines, ""))
+		result.WriteString("\n")
+	}
+
+	heading := "Commands:"
+	if len(listed) > 0 {
+		heading = "Other commands:"
+	}
+	var others []string
+	for _, name := range names {
+		if !listed[name] {
+			others = append(others, fmt.Sprintf("  %-30s %s\n", name, descriptions[name]))
+		}
+	}
+	if len(others) > 0 {
+		result.WriteString(heading + "\n")
+		result.WriteString(strings.Join(others, ""))
+	}
+	return result.String()
+}
+
+// helpCommand 显示命令的完整语法和说明，命令可以缩写，与在命令之后输入 ? 查找的方式相同
+func (s *Session) helpCommand(args []string) string {
+	usage, ok := s.completer.CommandUsage(args[0])
+	if !ok {
+		return fmt.Sprintf("%% Unknown command: %s\n", args[0])
+	}
+	return usage
+}
+
+// apropos 列出当前视图中命令、说明或记号帮助包含所给文字的命令
+func (s *Session) apropos(args []string) string {
+	lines := s.completer.SearchCommands(args[0])
+	if len(lines) == 0 {
+		return fmt.Sprintf("%% No commands matching: %s\n", args[0])
+	}
+	return "  " + strings.Join(lines, "\n  ") + "\n"
+}
+
+// onOff 将布尔值格式化为 on/off
+func onOff(b bool) string {
+	if b {
+		return "on"
+	}
+	return "off"
+}
@@ -0,0 +1,212 @@
+package session
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/TrailHuang/tnlcmd/internal/commandtree"
+	"github.com/TrailHuang/tnlcmd/internal/runconfig"
+	"github.com/TrailHuang/tnlcmd/pkg/types"
+	"github.com/TrailHuang/tnlcmd/table"
+)
+
+func init() {
+	registerGlobalBuiltin("commit", "Apply the candidate configuration", (*Session).commit)
+	registerGlobalBuiltin("commit confirmed <1-120>", "Apply the candidate configuration and roll back after N minutes unless confirmed", (*Session).commitConfirmed)
+	registerGlobalBuiltin("abort", "Discard the candidate configuration", (*Session).abort)
+	registerGlobalBuiltin("exit discard", "Discard the candidate configuration and exit to privileged EXEC mode", (*Session).exitDiscard)
+	registerGlobalBuiltin("show candidate-config", "Show changes not yet committed in this session", (*Session).showCandidateConfig)
+	registerGlobalBuiltin("rollback <1-50>", "Load a previous configuration into the candidate configuration", (*Session).rollback)
+	registerGlobalBuiltin("show archive", "Show previous configurations kept for rollback", (*Session).showArchive)
+	registerGlobalBuiltin("show archive <1-50>", "Show a previous configuration and the commit that replaced it", (*Session).showArchiveVersion)
+}
+
+// addCandidate 将配置视图中输入的命令记入候选配置，调用者需持有注册表读锁
+func (s *Session) addCandidate(cmd string, node *commandtree.CommandNode, args []string) {
+	s.candidate = append(s.candidate, runconfig.NewCommand(s.configChange(cmd), node, args))
+}
+
+// configChange 返回在当前视图中输入的配置命令
+func (s *Session) configChange(cmd string) types.ConfigChange {
+	return types.ConfigChange{
+		Mode:    s.context.CurrentMode.Path(),
+		Command: strings.Join(strings.Fields(cmd), " "),
+		User:    s.Username(),
+	}
+}
+
+// userChanges 将配置修改记为本会话的用户所做
+func (s *Session) userChanges(changes []types.ConfigChange) []types.ConfigChange {
+	user := s.Username()
+	for i := range changes {
+		changes[i].User = user
+	}
+	return changes
+}
+
+// candidateEnabled 返回是否开启了候选配置
+func (s *Session) candidateEnabled() bool {
+	return s.settings.Load().CandidateConfig
+}
+
+// commit 执行候选配置中的命令，由应用的提交回调决定是否整体生效；失败时保留候选配置。
+// 同时确认等待确认的提交，候选配置为空时只确认
+func (s *Session) commit(args []string) string {
+	if !s.candidateEnabled() {
+		s.setStatus(types.StatusInvalid)
+		return "% Candidate configuration is not enabled\n"
+	}
+	if len(s.candidate) == 0 {
+		if s.store.Confirm() {
+			return "Commit confirmed, automatic rollback cancelled\n"
+		}
+		return "No changes to commit\n"
+	}
+
+	if err := s.commitCandidate(0); err != nil {
+		s.setStatus(types.StatusOf(err))
+		return fmt.Sprintf("%% Commit failed: %v\n", err)
+	}
+	if s.store.Confirm() {
+		return "Commit complete, previous commit confirmed\n"
+	}
+	return "Commit complete\n"
+}
+
+// commitConfirmed 执行候选配置中的命令，N 分钟之内没有再次 commit 时自动恢复为提交之前的配置
+func (s *Session) commitConfirmed(args []string) string {
+	if !s.candidateEnabled() {
+		s.setStatus(types.StatusInvalid)
+		return "% Candidate configuration is not enabled\n"
+	}
+	if len(s.candidate) == 0 {
+		return "No changes to commit\n"
+	}
+	minutes, _ := strconv.Atoi(args[0])
+	if err := s.commitCandidate(time.Duration(minutes) * time.Minute); err != nil {
+		s.setStatus(types.StatusOf(err))
+		return fmt.Sprintf("%% Commit failed: %v\n", err)
+	}
+	return fmt.Sprintf("Commit complete, will be rolled back in %d minute(s) unless confirmed with commit\n", minutes)
+}
+
+// commitCandidate 提交候选配置，confirm 大于 0 时到期没有确认则自动恢复；失败时保留候选配置
+func (s *Session) commitCandidate(confirm time.Duration) error {
+	// 命令的处理函数像其他命令一样执行，输出显示在本会话中，可以读取输入和被 Ctrl-C 取消
+	pending := s.candidate
+	err := s.runHandler(types.HandlerFunc(func(ctx *types.Ctx) error {
+		if confirm > 0 {
+			return s.store.CommitConfirmed(ctx, pending, confirm)
+		}
+		return s.store.Commit(ctx, pending)
+	}), nil, nil, types.OutputText, lineWriter{s})
+	if err == nil {
+		s.candidate = nil
+	}
+	return err
+}
+
+// rollback 计算将当前配置恢复为历史配置 N 需要执行的命令，替换候选配置，由 commit 生效
+func (s *Session) rollback(args []string) string {
+	if !s.candidateEnabled() {
+		s.setStatus(types.StatusInvalid)
+		return "% Candidate configuration is not enabled\n"
+	}
+	n, _ := strconv.Atoi(args[0])
+	version, ok := s.store.Archive.Get(n)
+	if !ok {
+		s.setStatus(types.StatusError)
+		return fmt.Sprintf("%% Rollback %d is not available\n", n)
+	}
+
+	root := s.context.GetRootMode()
+	pending, err := runconfig.ResolveAll(root, s.userChanges(runconfig.Diff(runconfig.Sections(root), version.Sections)))
+	if err != nil {
+		s.setStatus(types.StatusError)
+		return fmt.Sprintf("%% Cannot roll back, %v\n", err)
+	}
+	if len(pending) == 0 {
+		return fmt.Sprintf("No differences from rollback %d\n", n)
+	}
+	s.candidate = pending
+	return fmt.Sprintf("Loaded rollback %d into the candidate configuration (%d change(s)), use commit to apply\n", n, len(pending))
+}
+
+// showArchive 列出保存的历史配置，编号 N 的配置是第 N 次之前的提交所替换的配置
+func (s *Session) showArchive(args []string) string {
+	var result strings.Builder
+	if deadline, ok := s.store.PendingConfirm(); ok {
+		result.WriteString(fmt.Sprintf("Last commit will be rolled back at %s unless confirmed\n", deadline.Format("2006-01-02 15:04:05")))
+	}
+	versions := s.store.Archive.Versions()
+	if len(versions) == 0 {
+		result.WriteString("No previous configurations\n")
+		return result.String()
+	}
+	t := table.New("Rollback", "Replaced at", "By", "Changes")
+	for i, v := range versions {
+		user := v.User
+		if user == "" {
+			user = "-"
+		}
+		t.AddRow(i+1, v.Time.Format("2006-01-02 15:04:05"), user, len(v.Changes))
+	}
+	width, _ := s.TerminalSize()
+	t.Render(&result, width)
+	return result.String()
+}
+
+// showArchiveVersion 显示历史配置 N 及替换它的那次提交中的命令
+func (s *Session) showArchiveVersion(args []string) string {
+	n, _ := strconv.Atoi(args[0])
+	version, ok := s.store.Archive.Get(n)
+	if !ok {
+		s.setStatus(types.StatusError)
+		return fmt.Sprintf("%% Rollback %d is not available\n", n)
+	}
+	var result strings.Builder
+	result.WriteString(fmt.Sprintf("Replaced at %s by the commit of:\n", version.Time.Format("2006-01-02 15:04:05")))
+	for _, c := range version.Changes {
+		result.WriteString(fmt.Sprintf("  [edit %s] %s\n", c.Mode, c.Command))
+	}
+	result.WriteString("\n")
+	result.WriteString(runconfig.Format(version.Sections))
+	return result.String()
+}
+
+// abort 丢弃候选配置
+func (s *Session) abort(args []string) string {
+	if len(s.candidate) == 0 {
+		return "No changes to discard\n"
+	}
+	n := len(s.candidate)
+	s.candidate = nil
+	return fmt.Sprintf("Discarded %d uncommitted change(s)\n", n)
+}
+
+// exitDiscard 丢弃候选配置并返回根视图
+func (s *Session) exitDiscard(args []string) string {
+	result := s.abort(args)
+	s.context.ChangeMode(s.context.GetRootMode())
+	s.refreshCommands()
+	return result + "Exiting to privileged EXEC mode\n"
+}
+
+// showCandidateConfig 按输入顺序列出尚未提交的命令及其所在的视图
+func (s *Session) showCandidateConfig(args []string) string {
+	if len(s.candidate) == 0 {
+		return "No uncommitted changes\n"
+	}
+	var result strings.Builder
+	mode := ""
+	for i, c := range runconfig.Changes(s.candidate) {
+		if i == 0 || c.Mode != mode {
+			mode = c.Mode
+			result.WriteString(fmt.Sprintf("[edit %s]\n", mode))
+		}
+		result.WriteString("  " + c.Command + "\n")
+	}
+	return result.String()
+}
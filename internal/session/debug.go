@@ -0,0 +1,144 @@
+package session
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/TrailHuang/tnlcmd/internal/commandtree"
+	"github.com/TrailHuang/tnlcmd/pkg/types"
+)
+
+// 会话跟踪的类别，见 debug cli
+const (
+	debugCLIParser     int32 = 1 << iota // 命令的拆分、匹配和参数
+	debugCLICompletion                   // Tab 补全和 ? 帮助的候选项
+	debugCLITelnet                       // telnet 选项协商
+	debugCLISession                      // 命令的执行、视图切换、终端属性和输入请求
+)
+
+// debugCategories 跟踪类别的名称，按 show debugging 的显示顺序排列
+var debugCategories = []struct {
+	name string
+	flag int32
+}{
+	{"parser", debugCLIParser},
+	{"completion", debugCLICompletion},
+	{"telnet", debugCLITelnet},
+	{"session", debugCLISession},
+}
+
+func init() {
+	for _, c := range debugCategories {
+		flag := c.flag
+		registerGlobalBuiltin("debug cli "+c.name, "Trace "+debugDescriptions[c.name]+" in this session", func(s *Session, args []string) string {
+			return s.setDebug(flag, true)
+		})
+		registerGlobalBuiltin("undebug cli "+c.name, "Stop tracing "+debugDescriptions[c.name]+" in this session", func(s *Session, args []string) string {
+			return s.setDebug(flag, false)
+		})
+	}
+	registerGlobalBuiltin("undebug all", "Turn off all debugging in this session", (*Session).undebugAll)
+	registerGlobalBuiltin("show debugging", "Show debugging enabled in this session", (*Session).showDebugging)
+}
+
+// debugDescriptions 各类别在帮助中的说明
+var debugDescriptions = map[string]string{
+	"parser":     "command parsing and matching",
+	"completion": "Tab completion and ? help",
+	"telnet":     "telnet option negotiation",
+	"session":    "command execution, mode changes and terminal settings",
+}
+
+// debugName 返回跟踪类别的名称
+func debugName(flag int32) string {
+	for _, c := range debugCategories {
+		if c.flag == flag {
+			return c.name
+		}
+	}
+	return "debug"
+}
+
+// setDebug 开启或关闭本会话的一类跟踪
+func (s *Session) setDebug(flag int32, on bool) string {
+	for {
+		old := s.debug.Load()
+		value := old &^ flag
+		if on {
+			value = old | flag
+		}
+		if s.debug.CompareAndSwap(old, value) {
+			break
+		}
+	}
+	state := "off"
+	if on {
+		state = "on"
+	}
+	return fmt.Sprintf("CLI %s debugging is %s\n", debugName(flag), state)
+}
+
+// undebugAll 关闭本会话的全部跟踪
+func (s *Session) undebugAll(args []string) string {
+	s.debug.Store(0)
+	return "All possible debugging has been turned off\n"
+}
+
+// showDebugging 列出本会话开启的跟踪
+func (s *Session) showDebugging(args []string) string {
+	flags := s.debug.Load()
+	var b strings.Builder
+	for _, c := range debugCategories {
+		if flags&c.flag != 0 {
+			fmt.Fprintf(&b, "  CLI %s debugging is on\n", c.name)
+		}
+	}
+	if b.Len() == 0 {
+		return "No debugging is enabled\n"
+	}
+	return "CLI:\n" + b.String()
+}
+
+// debugf 开启了 flag 类别的跟踪时在本会话中显示一条跟踪信息；
+// 编辑输入行期间与通知一样显示在输入行之前，命令执行期间直接显示在命令的输出之前
+func (s *Session) debugf(flag int32, format string, args ...interface{}) {
+	if s.debug.Load()&flag == 0 {
+		return
+	}
+	message := debugName(flag) + ": " + fmt.Sprintf(format, args...)
+	if s.editing != nil {
+		s.showNotice(message)
+		return
+	}
+	s.writerWrite(normalizeLineEndings(message) + "\r\n")
+}
+
+// traceMatch 跟踪 FindCommand 的匹配结果
+func (s *Session) traceMatch(node *commandtree.CommandNode, matchedPath, args []string, err error) {
+	if s.debug.Load()&debugCLIParser == 0 {
+		return
+	}
+	if err != nil || node == nil {
+		s.debugf(debugCLIParser, "no match after %q: %v", matchedPath, err)
+		return
+	}
+	kind := "no handler"
+	if _, exists := lookupBuiltin(node); exists {
+		kind = "builtin"
+	} else if node.Type == types.NodeTypeModeSwitch {
+		kind = "mode switch"
+	} else if node.Handler != nil {
+		kind = "handler"
+	}
+	s.debugf(debugCLIParser, "matched %q in mode %q (%s), args %q", node.Path(), s.CurrentMode(), kind, args)
+	// 关键字映射为自身，只显示参数的取值
+	params := make(map[string]string)
+	for name, value := range commandtree.NamedParams(node, args) {
+		if name != value {
+			params[name] = value
+		}
+	}
+	if len(params) > 0 {
+		s.debugf(debugCLIParser, "params %v", params)
+	}
+}
@@ -0,0 +1,170 @@
+package session
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/TrailHuang/tnlcmd/internal/history"
+	"github.com/TrailHuang/tnlcmd/pkg/types"
+	"github.com/TrailHuang/tnlcmd/table"
+)
+
+// Histories 开启 Config.SharedHistory 时同一用户的所有会话共用的命令历史，
+// 由 CmdLine 持有并传给它的会话，见 Shared
+type Histories struct {
+	mu     sync.Mutex
+	byUser map[string]*history.CommandHistory
+}
+
+func init() {
+	registerGlobalBuiltin("show history", "Show commands entered in this session", (*Session).showHistory)
+	registerGlobalBuiltin("show history detail", "Show commands entered in this session with time and status", (*Session).showHistoryDetail)
+	registerGlobalBuiltin("clear history", "Clear the command history of this session", (*Session).clearHistory)
+}
+
+// commandHistory 返回会话的命令历史，设置用户名后可能切换为该用户共用的历史
+func (s *Session) commandHistory() *history.CommandHistory {
+	s.userMu.RLock()
+	defer s.userMu.RUnlock()
+	return s.history
+}
+
+// newHistory 创建命令历史并放入 Config.InitialHistory 中的命令
+func newHistory(config *types.Config) *history.CommandHistory {
+	hist := history.NewCommandHistory(config.MaxHistory)
+	for _, cmd := range config.InitialHistory {
+		hist.Inject(cmd)
+	}
+	return hist
+}
+
+// forUser 返回用户 username 共用的命令历史，第一次使用时按 config 创建
+func (h *Histories) forUser(config *types.Config, username string) *history.CommandHistory {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	hist, exists := h.byUser[username]
+	if !exists {
+		if h.byUser == nil {
+			h.byUser = make(map[string]*history.CommandHistory)
+		}
+		hist = newHistory(config)
+		h.byUser[username] = hist
+	}
+	return hist
+}
+
+// showHistory 按编号列出本会话输入的命令，最早的在前
+func (s *Session) showHistory(args []string) string {
+	var result strings.Builder
+	hist := s.commandHistory()
+	first := hist.FirstNumber()
+	for i, cmd := range hist.GetAll() {
+		result.WriteString(fmt.Sprintf("%5d  %s\n", first+i, cmd))
+	}
+	return result.String()
+}
+
+// showHistoryDetail 按编号列出本会话输入的命令及其开始执行的时间、所用的时间和执行状态
+func (s *Session) showHistoryDetail(args []string) string {
+	t := table.New("No.", "Time", "Duration", "Status", "Command")
+	for _, entry := range s.commandHistory().Entries() {
+		duration, status := "-", "running"
+		if entry.Done {
+			duration = entry.Duration.Round(time.Millisecond).String()
+			status = statusText(entry.Status)
+		}
+		t.AddRow(entry.Number, entry.Time.Format("2006-01-02 15:04:05"), duration, status, entry.Command)
+	}
+	var result strings.Builder
+	width, _ := s.TerminalSize()
+	t.Render(&result, width)
+	return result.String()
+}
+
+// statusText 返回执行状态的说明
+func statusText(status int) string {
+	switch status {
+	case types.StatusOK:
+		return "ok"
+	case types.StatusInvalid:
+		return "invalid"
+	case types.StatusInterrupted:
+		return "interrupted"
+	}
+	return fmt.Sprintf("error %d", status)
+}
+
+// clearHistory 清除本会话的命令历史，之后的命令继续编号
+func (s *Session) clearHistory(args []string) string {
+	s.commandHistory().Clear()
+	return ""
+}
+
+// History 返回会话的命令历史，最早的在前
+func (s *Session) History() []types.HistoryEntry {
+	return s.commandHistory().Entries()
+}
+
+// AddHistory 将命令按已经成功执行追加到会话的命令历史中，空命令被忽略
+func (s *Session) AddHistory(commands ...string) {
+	hist := s.commandHistory()
+	for _, cmd := range commands {
+		if strings.TrimSpace(cmd) != "" {
+			hist.Inject(cmd)
+		}
+	}
+}
+
+// ClearHistory 清除会话的命令历史
+func (s *Session) ClearHistory() {
+	s.commandHistory().Clear()
+}
+
+// expandHistory 展开行首的历史引用：!! 为上一条命令，!N 为编号为 N 的命令，!-N 为倒数第 N 条命令，
+// 引用之后的内容（如管道过滤器）原样保留；不以历史引用开头的行原样返回
+func (s *Session) expandHistory(line string) (string, error) {
+	trimmed := strings.TrimLeftFunc(line, unicode.IsSpace)
+	if !strings.HasPrefix(trimmed, "!") {
+		return line, nil
+	}
+	end := strings.IndexFunc(trimmed, unicode.IsSpace)
+	if end < 0 {
+		end = len(trimmed)
+	}
+	event, rest := trimmed[:end], trimmed[end:]
+
+	hist := s.commandHistory()
+	var cmd string
+	var ok bool
+	switch n, err := strconv.Atoi(event[1:]); {
+	case event == "!!":
+		cmd, ok = hist.Number(hist.FirstNumber() + hist.Len() - 1)
+	case err != nil || n == 0:
+		// 不是历史引用，如以 ! 开头的注释
+		return line, nil
+	case n < 0:
+		cmd, ok = hist.Number(hist.FirstNumber() + hist.Len() + n)
+	default:
+		cmd, ok = hist.Number(n)
+	}
+	if !ok {
+		return "", fmt.Errorf("%s: event not found", event)
+	}
+	return cmd + rest, nil
+}
+
+// recordHistory 将输入行记入 hist 并返回其编号，与 Config.HistoryExclude 中任何一个正则表达式匹配的行不记录，返回 0
+func (s *Session) recordHistory(hist *history.CommandHistory, line string) int {
+	for _, pattern := range s.settings.Load().HistoryExclude {
+		// 无效的正则表达式忽略，AddHistoryExclude 会拒绝
+		if re, err := regexp.Compile(pattern); err == nil && re.MatchString(line) {
+			return 0
+		}
+	}
+	return hist.Record(line)
+}
@@ -0,0 +1,91 @@
+package session
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// 客户端没有报告窗口大小时使用的终端大小
+const (
+	defaultTerminalWidth  = 80
+	defaultTerminalHeight = 24
+)
+
+// lastSessionID 最近创建的会话的编号
+var lastSessionID atomic.Int64
+
+// ID 返回会话编号，按创建顺序从 1 开始分配，在进程内不重复
+func (s *Session) ID() int {
+	return s.id
+}
+
+// Prompt 返回会话最近一次显示的命令提示符，如 "router(configure)# "，可以在任意协程中调用
+func (s *Session) Prompt() string {
+	prompt, _ := s.shownPrompt.Load().(string)
+	return prompt
+}
+
+// SetUsername 设置会话的登录用户名，应用完成认证后调用，提示符中的 Username 使用该值
+// 开启了 Config.SharedHistory 时，会话改用该用户所有会话共用的命令历史
+func (s *Session) SetUsername(username string) {
+	config := s.settings.Load()
+
+	s.userMu.Lock()
+	defer s.userMu.Unlock()
+	s.username = username
+	if config.SharedHistory && username != "" {
+		s.history = s.histories.forUser(config, username)
+	}
+}
+
+// Username 返回会话的登录用户名
+func (s *Session) Username() string {
+	s.userMu.RLock()
+	defer s.userMu.RUnlock()
+	return s.username
+}
+
+// RemoteAddr 返回客户端地址
+func (s *Session) RemoteAddr() string {
+	return s.remoteAddr
+}
+
+// LoginTime 返回连接建立的时间
+func (s *Session) LoginTime() time.Time {
+	return s.loginTime
+}
+
+// TerminalSize 返回客户端终端的宽度和高度，客户端没有报告窗口大小时为 80x24
+func (s *Session) TerminalSize() (width, height int) {
+	width, height = int(s.width.Load()), int(s.height.Load())
+	if width == 0 || height == 0 {
+		return defaultTerminalWidth, defaultTerminalHeight
+	}
+	return width, height
+}
+
+// CurrentMode 返回会话当前视图的路径，如 configure/interface，根视图为空
+// 每个会话的当前视图相互独立
+func (s *Session) CurrentMode() string {
+	return s.context.CurrentMode.Path()
+}
+
+// LastStatus 返回上一条命令的执行状态，空行不改变状态
+func (s *Session) LastStatus() int {
+	return int(s.status.Load())
+}
+
+// setStatus 记录命令的执行状态
+func (s *Session) setStatus(status int) {
+	s.status.Store(int32(status))
+}
+
+// ModeValue 返回当前视图实例中保存的会话数据
+func (s *Session) ModeValue(key string) (interface{}, bool) {
+	return s.context.Value(key)
+}
+
+// SetModeValue 在当前视图实例中保存会话数据，离开视图时丢弃
+func (s *Session) SetModeValue(key string, value interface{}) {
+	s.context.SetValue(key, value)
+}
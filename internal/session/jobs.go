@@ -0,0 +1,314 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/TrailHuang/tnlcmd/internal/commandtree"
+	"github.com/TrailHuang/tnlcmd/pkg/types"
+)
+
+const (
+	jobOutputLimit  = 64 * 1024 // 每个后台任务缓存的输出字节数，超出时丢弃最早的输出
+	maxFinishedJobs = 10        // 任务表中保留的已结束任务数
+)
+
+// job 会话的后台任务
+type job struct {
+	id      int
+	command string
+	start   time.Time
+	cancel  context.CancelFunc
+
+	mu       sync.Mutex
+	output   []byte    // 缓存的输出，最多 jobOutputLimit 字节
+	stream   bool      // 输出同时写到终端
+	end      time.Time // 结束时间，运行中为零值
+	err      error     // 处理函数返回的错误
+	killed   bool      // 被 kill job 取消
+	reported bool      // 已在提示符前报告结束
+}
+
+func init() {
+	registerGlobalBuiltin("show jobs", "Show background jobs of this session", (*Session).showJobs)
+	registerGlobalBuiltin("show jobs <1-65535>", "Show the output of a background job", (*Session).showJobOutput)
+	registerGlobalBuiltin("kill job <1-65535>", "Stop a background job", (*Session).killJob)
+	registerGlobalBuiltin("attach job <1-65535>", "Show the output of a background job as it is produced", (*Session).attachJob)
+	registerGlobalBuiltin("detach job <1-65535>", "Stop showing the output of a background job", (*Session).detachJob)
+}
+
+// jobWriter 后台任务的输出，写入缓存，任务处于 attach 状态时同时写到终端
+type jobWriter struct {
+	s   *Session
+	job *job
+}
+
+func (w jobWriter) Write(p []byte) (int, error) {
+	j := w.job
+	j.mu.Lock()
+	j.output = append(j.output, p...)
+	if len(j.output) > jobOutputLimit {
+		j.output = append([]byte(nil), j.output[len(j.output)-jobOutputLimit:]...)
+	}
+	stream := j.stream
+	j.mu.Unlock()
+
+	if stream {
+		lineWriter{w.s}.Write(p)
+	}
+	return len(p), nil
+}
+
+// jobSession 后台任务看到的会话，后台任务不能读取终端输入
+type jobSession struct {
+	*Session
+}
+
+func (jobSession) ReadLine(prompt string) (string, error) {
+	return "", errors.New("background jobs cannot read input")
+}
+
+// StartJob 在后台运行 handler，返回任务编号
+// 任务的输出缓存在任务表中，用 show jobs <id> 查看；会话结束或执行 kill job 时取消任务的上下文
+func (s *Session) StartJob(command string, handler types.HandlerFunc) int {
+	id, _ := s.startJob(command, handler, nil, nil, pipeline{})
+	return id
+}
+
+// startJob 在后台运行处理函数，args 和 params 为命令的参数，输出经过 pipe 过滤后缓存
+// 过滤器无法创建（如重定向的文件无法打开）时不启动任务并返回错误
+func (s *Session) startJob(command string, handler types.Handler, args []string, params map[string]string, pipe pipeline) (int, error) {
+	format, err := s.outputFormat(handler, pipe)
+	if err != nil {
+		return 0, err
+	}
+	j := &job{
+		command: command,
+		start:   time.Now(),
+	}
+	out, flush, err := pipe.writer(jobWriter{s: s, job: j})
+	if err != nil {
+		return 0, err
+	}
+	ctx, cancel := context.WithCancel(s.ctx)
+	j.cancel = cancel
+
+	s.jobsMu.Lock()
+	if s.jobs == nil {
+		s.jobs = make(map[int]*job)
+	}
+	s.nextJob++
+	j.id = s.nextJob
+	s.jobs[j.id] = j
+	s.pruneJobs()
+	s.jobsMu.Unlock()
+
+	jctx := &types.Ctx{
+		Context: ctx,
+		Args:    args,
+		Params:  params,
+		Writer:  out,
+		Session: jobSession{s},
+		Mode:    s.context.CurrentMode.Path(),
+		Format:  format,
+	}
+	go func() {
+		err := handler.Run(jctx)
+		flush()
+		cancel()
+		j.mu.Lock()
+		j.end = time.Now()
+		j.err = err
+		j.mu.Unlock()
+	}()
+	return j.id, nil
+}
+
+// pruneJobs 任务表中已结束的任务超过 maxFinishedJobs 时删除最早的，调用者需持有 jobsMu
+func (s *Session) pruneJobs() {
+	var finished []int
+	for id, j := range s.jobs {
+		if j.finished() {
+			finished = append(finished, id)
+		}
+	}
+	sort.Ints(finished)
+	for len(finished) > maxFinishedJobs {
+		delete(s.jobs, finished[0])
+		finished = finished[1:]
+	}
+}
+
+// finished 返回任务是否已结束
+func (j *job) finished() bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return !j.end.IsZero()
+}
+
+// state 返回任务状态的描述
+func (j *job) state() string {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	switch {
+	case j.end.IsZero():
+		return "Running"
+	case j.killed || errors.Is(j.err, context.Canceled):
+		return "Killed"
+	case j.err != nil && !isSessionAction(j.err):
+		return "Failed: " + j.err.Error()
+	}
+	return "Done"
+}
+
+// isSessionAction 错误是否为要求会话执行动作的错误，后台任务中忽略这些错误
+func isSessionAction(err error) bool {
+	return errors.Is(err, types.ErrCloseSession) || errors.Is(err, types.ErrExitToRoot) || errors.Is(err, types.ErrLeaveMode)
+}
+
+// sortedJobs 按编号返回任务表中的任务
+func (s *Session) sortedJobs() []*job {
+	s.jobsMu.Lock()
+	defer s.jobsMu.Unlock()
+	jobs := make([]*job, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		jobs = append(jobs, j)
+	}
+	sort.Slice(jobs, func(a, b int) bool { return jobs[a].id < jobs[b].id })
+	return jobs
+}
+
+// findJob 按参数中的编号查找任务
+func (s *Session) findJob(args []string) (*job, string) {
+	id, _ := strconv.Atoi(args[len(args)-1])
+	s.jobsMu.Lock()
+	defer s.jobsMu.Unlock()
+	j, exists := s.jobs[id]
+	if !exists {
+		return nil, fmt.Sprintf("%% No such job: %d\n", id)
+	}
+	return j, ""
+}
+
+// reportJobs 在提示符前报告上次提示之后结束的任务
+func (s *Session) reportJobs() {
+	for _, j := range s.sortedJobs() {
+		if !j.finished() {
+			continue
+		}
+		j.mu.Lock()
+		reported := j.reported
+		j.reported = true
+		j.mu.Unlock()
+		if !reported {
+			s.writerWrite(fmt.Sprintf("[%d] %-20s %s\r\n", j.id, j.state(), j.command))
+		}
+	}
+}
+
+// showJobs 列出会话的后台任务
+func (s *Session) showJobs(args []string) string {
+	jobs := s.sortedJobs()
+	if len(jobs) == 0 {
+		return "No background jobs\n"
+	}
+
+	var result strings.Builder
+	result.WriteString(fmt.Sprintf("  %-4s %-20s %-10s %s\n", "ID", "State", "Time", "Command"))
+	for _, j := range jobs {
+		j.mu.Lock()
+		end := j.end
+		j.mu.Unlock()
+		if end.IsZero() {
+			end = time.Now()
+		}
+		elapsed := end.Sub(j.start).Round(time.Second)
+		result.WriteString(fmt.Sprintf("  %-4d %-20s %-10s %s\n", j.id, j.state(), elapsed, j.command))
+	}
+	return result.String()
+}
+
+// showJobOutput 显示后台任务缓存的输出
+func (s *Session) showJobOutput(args []string) string {
+	j, msg := s.findJob(args)
+	if j == nil {
+		return msg
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return string(j.output)
+}
+
+// killJob 取消后台任务的上下文
+func (s *Session) killJob(args []string) string {
+	j, msg := s.findJob(args)
+	if j == nil {
+		return msg
+	}
+	if j.finished() {
+		return fmt.Sprintf("%% Job %d has already finished\n", j.id)
+	}
+	j.mu.Lock()
+	j.killed = true
+	j.mu.Unlock()
+	j.cancel()
+	return ""
+}
+
+// attachJob 显示后台任务已缓存的输出，之后的输出直接写到终端
+func (s *Session) attachJob(args []string) string {
+	j, msg := s.findJob(args)
+	if j == nil {
+		return msg
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.stream = true
+	return string(j.output)
+}
+
+// detachJob 停止将后台任务的输出写到终端
+func (s *Session) detachJob(args []string) string {
+	j, msg := s.findJob(args)
+	if j == nil {
+		return msg
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.stream = false
+	return ""
+}
+
+// backgroundSuffix 命令末尾的后台执行标记
+const backgroundSuffix = "&"
+
+// splitBackground 去掉命令末尾的 & 标记，返回去掉后的命令和是否在后台执行
+func splitBackground(cmd string) (string, bool) {
+	trimmed := strings.TrimRightFunc(cmd, unicode.IsSpace)
+	if !strings.HasSuffix(trimmed, backgroundSuffix) {
+		return cmd, false
+	}
+	rest := strings.TrimSuffix(trimmed, backgroundSuffix)
+	if rest != "" && !unicode.IsSpace(rune(rest[len(rest)-1])) {
+		// & 是参数的一部分，如 a&b
+		return cmd, false
+	}
+	return strings.TrimRightFunc(rest, unicode.IsSpace), true
+}
+
+// runInBackground 在后台执行命令的处理函数并报告任务编号
+func (s *Session) runInBackground(cmd string, node *commandtree.CommandNode, args []string, pipe pipeline) {
+	id, err := s.startJob(strings.Join(strings.Fields(cmd), " "), node.Handler, commandtree.SpecArgs(node, args), commandtree.NamedParams(node, args), pipe)
+	if err != nil {
+		s.finishCommand(cmd, err)
+		return
+	}
+	s.writerWrite(fmt.Sprintf("[%d] %s\r\n", id, strings.Join(strings.Fields(cmd), " ")))
+}
@@ -0,0 +1,149 @@
+package session
+
+import (
+	"fmt"
+	"unicode"
+
+	"github.com/TrailHuang/tnlcmd/internal/textwidth"
+)
+
+// lineBuffer 正在编辑的输入行和光标位置
+type lineBuffer struct {
+	text   []rune
+	cursor int // 光标之前的字符数
+}
+
+// String 返回输入行
+func (b *lineBuffer) String() string {
+	return string(b.text)
+}
+
+// Reset 清空输入行
+func (b *lineBuffer) Reset() {
+	b.text = nil
+	b.cursor = 0
+}
+
+// Set 替换输入行，光标移到行尾
+func (b *lineBuffer) Set(text string) {
+	b.text = []rune(text)
+	b.cursor = len(b.text)
+}
+
+// Insert 在光标处插入文本，光标移到插入的文本之后
+func (b *lineBuffer) Insert(text string) {
+	runes := []rune(text)
+	b.text = append(b.text[:b.cursor], append(runes, b.text[b.cursor:]...)...)
+	b.cursor += len(runes)
+}
+
+// Backspace 删除光标前的字符，光标在行首时返回 false
+func (b *lineBuffer) Backspace() bool {
+	if b.cursor == 0 {
+		return false
+	}
+	b.text = append(b.text[:b.cursor-1], b.text[b.cursor:]...)
+	b.cursor--
+	return true
+}
+
+// Delete 删除光标处的字符，光标在行尾时返回 false
+func (b *lineBuffer) Delete() bool {
+	if b.cursor == len(b.text) {
+		return false
+	}
+	b.text = append(b.text[:b.cursor], b.text[b.cursor+1:]...)
+	return true
+}
+
+// AtEnd 返回光标是否在行尾
+func (b *lineBuffer) AtEnd() bool {
+	return b.cursor == len(b.text)
+}
+
+// beforeCursor 返回光标之前的文本
+func (b *lineBuffer) beforeCursor() string {
+	return string(b.text[:b.cursor])
+}
+
+// afterCursor 返回光标之后的文本
+func (b *lineBuffer) afterCursor() string {
+	return string(b.text[b.cursor:])
+}
+
+// inQuotes 返回光标是否在未闭合的双引号之内，引号内的 ? 作为普通字符输入
+func (b *lineBuffer) inQuotes() bool {
+	quotes := 0
+	for _, r := range b.text[:b.cursor] {
+		if r == '"' {
+			quotes++
+		}
+	}
+	return quotes%2 == 1
+}
+
+// tokenEnd 返回光标所在记号的结束位置，光标在空白处时为光标位置
+func (b *lineBuffer) tokenEnd() int {
+	end := b.cursor
+	for end < len(b.text) && !unicode.IsSpace(b.text[end]) {
+		end++
+	}
+	return end
+}
+
+// completionInput 返回补全和帮助使用的输入：从行首到光标所在记号的末尾
+func (b *lineBuffer) completionInput() string {
+	return string(b.text[:b.tokenEnd()])
+}
+
+// replaceCompletionInput 用补全的结果替换 completionInput 返回的部分，光标移到补全的结果之后
+func (b *lineBuffer) replaceCompletionInput(completed string) {
+	rest := b.text[b.tokenEnd():]
+	// 补全的结果以空格结尾时，去掉后面重复的一个空格
+	if len(completed) > 0 && completed[len(completed)-1] == ' ' && len(rest) > 0 && unicode.IsSpace(rest[0]) {
+		rest = rest[1:]
+	}
+	b.text = append([]rune(completed), rest...)
+	b.cursor = len([]rune(completed))
+}
+
+// moveLeft 光标左移一个字符，返回终端上移动光标的控制序列
+func (b *lineBuffer) moveLeft() string {
+	if b.cursor == 0 {
+		return ""
+	}
+	b.cursor--
+	return cursorLeft(textwidth.RuneWidth(b.text[b.cursor]))
+}
+
+// moveRight 光标右移一个字符，返回终端上移动光标的输出
+func (b *lineBuffer) moveRight() string {
+	if b.cursor == len(b.text) {
+		return ""
+	}
+	b.cursor++
+	// 重新输出光标经过的字符，不依赖终端对右移序列的支持
+	return string(b.text[b.cursor-1])
+}
+
+// moveHome 光标移到行首，返回终端上移动光标的控制序列
+func (b *lineBuffer) moveHome() string {
+	width := textwidth.Width(b.beforeCursor())
+	b.cursor = 0
+	return cursorLeft(width)
+}
+
+// moveEnd 光标移到行尾，返回终端上移动光标的输出
+func (b *lineBuffer) moveEnd() string {
+	after := b.afterCursor()
+	b.cursor = len(b.text)
+	return after
+}
+
+// cursorLeft 返回光标左移 n 列的控制序列
+func cursorLeft(n int) string {
+	if n <= 0 {
+		return ""
+	}
+	return fmt.Sprintf("\x1b[%dD", n)
+}
@@ -0,0 +1,75 @@
+package session
+
+import (
+	"errors"
+	"io"
+	"strings"
+)
+
+// noticeBufferSize 每个会话等待显示的通知条数，超出时丢弃新的通知
+const noticeBufferSize = 256
+
+func init() {
+	registerGlobalBuiltin("terminal monitor", "Show log and alarm messages in this session", (*Session).terminalMonitor)
+	registerGlobalBuiltin("terminal no monitor", "Stop showing log and alarm messages in this session", (*Session).terminalNoMonitor)
+}
+
+// terminalMonitor 开启本会话的通知显示
+func (s *Session) terminalMonitor(args []string) string {
+	s.monitor.Store(true)
+	return ""
+}
+
+// terminalNoMonitor 关闭本会话的通知显示
+func (s *Session) terminalNoMonitor(args []string) string {
+	s.monitor.Store(false)
+	return ""
+}
+
+// Monitoring 返回会话是否开启了 terminal monitor
+func (s *Session) Monitoring() bool {
+	return s.monitor.Load()
+}
+
+// Notify 向开启了 terminal monitor 的会话发送一条通知，如日志或告警，未开启时忽略
+// 通知在会话等待输入时显示在正在编辑的输入行之前，命令执行期间到达的通知在命令结束后显示；
+// 可以在任意协程中调用，不会阻塞
+func (s *Session) Notify(message string) {
+	if !s.monitor.Load() {
+		return
+	}
+	select {
+	case s.notices <- message:
+	default:
+		// 会话长时间没有读取输入，丢弃通知而不阻塞调用者
+	}
+}
+
+// errNoticesFull 会话等待显示的消息过多
+var errNoticesFull = errors.New("too many messages waiting to be shown in the session")
+
+// Write 实现 io.Writer，向会话显示一条消息，不受 terminal monitor 影响，显示方式与 Notify 相同；
+// 每次写入显示为单独的一行或几行。可以在任意协程中调用，不会阻塞，等待显示的消息过多时返回错误
+func (s *Session) Write(p []byte) (int, error) {
+	if s.closed() {
+		return 0, io.ErrClosedPipe
+	}
+	select {
+	case s.notices <- string(p):
+		return len(p), nil
+	default:
+		return 0, errNoticesFull
+	}
+}
+
+// showNotice 显示一条通知并重新显示提示符和正在编辑的输入行
+func (s *Session) showNotice(message string) {
+	message = s.stripColor(normalizeLineEndings(strings.TrimRight(message, "\r\n")))
+	if s.lineMode {
+		// 行模式下无法改写客户端的输入行，在新的一行显示
+		s.writerWrite("\r\n" + message + "\r\n")
+	} else {
+		s.writerWrite("\r\x1b[K" + message + "\r\n")
+	}
+	s.redrawLine(s.editingLine())
+}
@@ -0,0 +1,73 @@
+package session
+
+import (
+	"time"
+
+	"github.com/TrailHuang/tnlcmd/pkg/types"
+)
+
+// observer 返回配置的 Observer
+func (s *Session) observer() types.Observer {
+	return s.settings.Load().Observer
+}
+
+// observe 记录一个事件；命令执行期间会话持有锁，事件由 publishEvents 在命令结束后发送
+func (s *Session) observe(event func(o types.Observer)) {
+	s.events = append(s.events, event)
+}
+
+// publishEvents 依次发送记录的事件，调用者不能持有会话和注册表的锁
+func (s *Session) publishEvents() {
+	events := s.events
+	s.events = nil
+	if len(events) == 0 {
+		return
+	}
+	if o := s.observer(); o != nil {
+		for _, event := range events {
+			event(o)
+		}
+	}
+}
+
+// observeCommand 记录一条命令执行结束的事件，modePath 为执行命令时所在的视图
+func (s *Session) observeCommand(line, modePath, command string, started time.Time) {
+	event := types.CommandEvent{
+		Session:  s,
+		Line:     line,
+		Mode:     modePath,
+		Command:  command,
+		Status:   s.LastStatus(),
+		Start:    started,
+		Duration: time.Since(started),
+	}
+	s.observe(func(o types.Observer) { o.CommandExecuted(event) })
+}
+
+// observeMode 记录一次视图切换
+func (s *Session) observeMode(from, to string) {
+	event := types.ModeEvent{Session: s, From: from, To: to}
+	s.observe(func(o types.Observer) { o.ModeChanged(event) })
+}
+
+// observeCompletion 发送一次补全或帮助请求的事件，在编辑输入行时调用
+func (s *Session) observeCompletion(input string, help bool, candidates []string) {
+	event := types.CompletionEvent{Session: s, Input: input, Help: help, Candidates: candidates}
+	s.observe(func(o types.Observer) { o.CompletionRequested(event) })
+	s.publishEvents()
+}
+
+// observeOpened 发送会话打开的事件
+func (s *Session) observeOpened() {
+	if o := s.observer(); o != nil {
+		o.SessionOpened(s)
+	}
+}
+
+// observeClosed 发送尚未发送的事件和会话关闭的事件，在会话关闭之后调用
+func (s *Session) observeClosed() {
+	s.publishEvents()
+	if o := s.observer(); o != nil {
+		o.SessionClosed(s)
+	}
+}
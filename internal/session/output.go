@@ -0,0 +1,60 @@
+package session
+
+import "github.com/TrailHuang/tnlcmd/pkg/types"
+
+// errNoStructuredOutput 用 | json 执行了不提供结构化结果的命令
+var errNoStructuredOutput = &types.Error{
+	Code:    types.StatusInvalid,
+	Message: "Command does not support JSON output",
+	Hint:    "only commands registered with RegisterDataHandler support | json",
+}
+
+func init() {
+	registerGlobalBuiltin("terminal output json", "Display output of commands that support it in JSON format", (*Session).terminalOutputJSON)
+	registerGlobalBuiltin("terminal output text", "Display output of commands as text", (*Session).terminalOutputText)
+}
+
+// OutputFormat 返回本会话的默认输出格式，OutputText 或 OutputJSON
+func (s *Session) OutputFormat() string {
+	if s.jsonOutput.Load() {
+		return types.OutputJSON
+	}
+	return types.OutputText
+}
+
+// outputFormat 返回处理函数的输出格式
+// 命令后的 | json 要求处理函数提供结构化结果，否则返回错误；
+// 会话的默认格式为 JSON 时，不提供结构化结果的命令仍输出文本
+func (s *Session) outputFormat(handler types.Handler, pipe pipeline) (string, error) {
+	structured := hasData(handler)
+	switch {
+	case pipe.format != "" && !structured:
+		return "", errNoStructuredOutput
+	case pipe.format != "":
+		return pipe.format, nil
+	case structured:
+		return s.OutputFormat(), nil
+	}
+	return types.OutputText, nil
+}
+
+// hasData 判断处理函数是否提供结构化结果
+func hasData(handler types.Handler) bool {
+	switch handler.(type) {
+	case types.DataHandler, *types.DataHandler:
+		return true
+	}
+	return false
+}
+
+// terminalOutputJSON 本会话中支持结构化结果的命令输出 JSON
+func (s *Session) terminalOutputJSON(args []string) string {
+	s.jsonOutput.Store(true)
+	return ""
+}
+
+// terminalOutputText 本会话中的命令输出文本
+func (s *Session) terminalOutputText(args []string) string {
+	s.jsonOutput.Store(false)
+	return ""
+}
@@ -0,0 +1,83 @@
+package session
+
+import (
+	"strings"
+
+	"github.com/TrailHuang/tnlcmd/color"
+)
+
+// morePrompt 分页输出暂停时显示的提示
+const morePrompt = "--More--"
+
+// pager 按终端高度分页输出，每输出一屏暂停并显示 --More--：
+// 空格显示下一屏，回车显示下一行，q 或 Ctrl-C 停止输出，其余部分被丢弃
+// 只能在会话协程中使用，处理函数执行期间输入由 runHandler 读取
+type pager struct {
+	s     *Session
+	rows  int  // 本屏已经输出的行数，超过终端宽度的行按折行后的行数计算
+	limit int  // 每屏的行数，为 0 时不分页
+	quit  bool // 用户停止了输出
+}
+
+// newPager 创建按当前终端高度分页的输出，最后一行留给 --More-- 提示；执行脚本时不分页
+func (s *Session) newPager() *pager {
+	if s.scripting {
+		return &pager{s: s}
+	}
+	_, height := s.TerminalSize()
+	return &pager{s: s, limit: height - 1}
+}
+
+// Write 实现 io.Writer，停止输出之后丢弃写入的数据
+func (p *pager) Write(data []byte) (int, error) {
+	text := string(data)
+	for text != "" && !p.quit {
+		line := text
+		if i := strings.IndexByte(text, '\n'); i >= 0 {
+			line = text[:i+1]
+		}
+		text = text[len(line):]
+
+		if p.limit > 1 && p.rows >= p.limit {
+			p.more()
+			if p.quit {
+				break
+			}
+		}
+		lineWriter{p.s}.Write([]byte(line))
+		if strings.HasSuffix(line, "\n") {
+			p.rows += p.lineRows(line)
+		}
+	}
+	return len(data), nil
+}
+
+// lineRows 返回一行在终端上占用的行数
+func (p *pager) lineRows(line string) int {
+	width, _ := p.s.TerminalSize()
+	n := color.Len(strings.TrimRight(line, "\r\n"))
+	if width <= 1 || n <= width {
+		return 1
+	}
+	return (n + width - 1) / width
+}
+
+// more 显示 --More-- 并等待用户按键
+func (p *pager) more() {
+	p.s.writerWrite(morePrompt)
+	p.s.flushWriter()
+	b, err := p.s.readByte()
+	// 行模式下客户端自己回显按键和换行，无法清除提示
+	if !p.s.lineMode {
+		p.s.writerWrite("\r\x1b[K")
+	}
+
+	switch {
+	case err != nil || b == 'q' || b == 'Q' || b == 0x03:
+		p.quit = true
+	case b == '\r' || b == '\n':
+		p.rows = p.limit - 1
+	default:
+		p.rows = 0
+	}
+}
@@ -0,0 +1,423 @@
+package session
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"regexp/syntax"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/TrailHuang/tnlcmd/color"
+	"github.com/TrailHuang/tnlcmd/internal/commandtree"
+	"github.com/TrailHuang/tnlcmd/pkg/types"
+)
+
+// pipeSymbol 分隔命令和输出过滤器的记号
+const pipeSymbol = "|"
+
+// pipeStage 输出过滤器链中的一级，包装下一级的输出
+type pipeStage func(next io.Writer) (io.WriteCloser, error)
+
+// pipeCommand 命令后 | 之后可以使用的输出过滤器
+type pipeCommand struct {
+	name        string
+	description string
+	argument    string // 参数的说明，为空表示过滤器不接受参数
+	optional    bool   // 参数可以省略
+	final       bool   // 只能是最后一个过滤器，如 redirect
+	format      string // 选择命令的输出格式，如 json，为空表示不改变
+	// build 创建过滤器，为空表示不过滤输出
+	build func(arg string) (pipeStage, error)
+	// fileBuild 创建写文件的过滤器，root 为会话的文件根目录
+	fileBuild func(root, arg string) (pipeStage, error)
+}
+
+// pipeCommands 输出过滤器表，按名称索引
+var pipeCommands = map[string]pipeCommand{}
+
+// registerPipeCommand 注册输出过滤器
+func registerPipeCommand(cmd pipeCommand) {
+	pipeCommands[cmd.name] = cmd
+}
+
+func init() {
+	registerPipeCommand(pipeCommand{
+		name: "include", description: "Include lines that match", argument: "Regular expression",
+		build: regexpFilter(func(re *regexp.Regexp) lineFunc {
+			return func(line string) bool { return re.MatchString(line) }
+		}),
+	})
+	registerPipeCommand(pipeCommand{
+		name: "exclude", description: "Exclude lines that match", argument: "Regular expression",
+		build: regexpFilter(func(re *regexp.Regexp) lineFunc {
+			return func(line string) bool { return !re.MatchString(line) }
+		}),
+	})
+	registerPipeCommand(pipeCommand{
+		name: "begin", description: "Begin with the line that matches", argument: "Regular expression",
+		build: regexpFilter(func(re *regexp.Regexp) lineFunc {
+			started := false
+			return func(line string) bool {
+				started = started || re.MatchString(line)
+				return started
+			}
+		}),
+	})
+	registerPipeCommand(pipeCommand{
+		name: "section", description: "Filter a section of output", argument: "Regular expression",
+		build: regexpFilter(func(re *regexp.Regexp) lineFunc {
+			// 不缩进的行开始一节，匹配的行连同其后缩进的行一起输出
+			inSection := false
+			return func(line string) bool {
+				if line != "" && line[0] != ' ' && line[0] != '\t' {
+					inSection = re.MatchString(line)
+					return inSection
+				}
+				return inSection || re.MatchString(line)
+			}
+		}),
+	})
+	registerPipeCommand(pipeCommand{
+		name: "match", description: "Show lines that match (-v: that do not match)", argument: "[-v] Regular expression",
+		build: matchFilter,
+	})
+	registerPipeCommand(pipeCommand{
+		name: "count", description: "Count number of lines", argument: "Regular expression", optional: true,
+		build: countFilter,
+	})
+	registerPipeCommand(pipeCommand{
+		name: "json", description: "Display output in JSON format", format: types.OutputJSON,
+	})
+}
+
+// lineFunc 判断一行输出是否保留，参数不包括行尾的换行
+type lineFunc func(line string) bool
+
+// regexpFilter 返回按正则表达式逐行过滤的过滤器构造函数
+func regexpFilter(keep func(re *regexp.Regexp) lineFunc) func(arg string) (pipeStage, error) {
+	return func(arg string) (pipeStage, error) {
+		re, err := compilePattern(arg)
+		if err != nil {
+			return nil, err
+		}
+		return func(next io.Writer) (io.WriteCloser, error) {
+			return &lineFilter{next: next, keep: keep(re)}, nil
+		}, nil
+	}
+}
+
+// compilePattern 编译过滤器的正则表达式（RE2 语法），错误信息包括表达式和原因
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		var syntaxErr *syntax.Error
+		if errors.As(err, &syntaxErr) {
+			return nil, fmt.Errorf("invalid regular expression %q: %s", pattern, syntaxErr.Code)
+		}
+		return nil, fmt.Errorf("invalid regular expression %q", pattern)
+	}
+	return re, nil
+}
+
+// matchFilter 只保留匹配的行，以 -v 开头时只保留不匹配的行
+func matchFilter(arg string) (pipeStage, error) {
+	invert := false
+	if fields := strings.Fields(arg); len(fields) > 0 && fields[0] == "-v" {
+		invert = true
+		arg = strings.TrimSpace(strings.TrimPrefix(arg, "-v"))
+		if arg == "" {
+			return nil, fmt.Errorf("incomplete filter: match -v")
+		}
+	}
+	return regexpFilter(func(re *regexp.Regexp) lineFunc {
+		return func(line string) bool { return re.MatchString(line) != invert }
+	})(arg)
+}
+
+// countFilter 统计输出的行数，指定正则表达式时只统计匹配的行
+func countFilter(arg string) (pipeStage, error) {
+	var re *regexp.Regexp
+	if arg != "" {
+		var err error
+		if re, err = compilePattern(arg); err != nil {
+			return nil, err
+		}
+	}
+	return func(next io.Writer) (io.WriteCloser, error) {
+		count := 0
+		return &lineFilter{
+			next: next,
+			keep: func(line string) bool {
+				if re == nil || re.MatchString(line) {
+					count++
+				}
+				return false
+			},
+			end: func() {
+				if re == nil {
+					fmt.Fprintf(next, "Number of lines = %d\n", count)
+				} else {
+					fmt.Fprintf(next, "Number of lines which match regexp = %d\n", count)
+				}
+			},
+		}, nil
+	}, nil
+}
+
+// lineFilter 按行过滤输出，不完整的行缓存到下一次写入或 Close
+type lineFilter struct {
+	next    io.Writer
+	keep    lineFunc
+	end     func() // Close 时调用，用于输出统计结果
+	partial []byte
+}
+
+func (f *lineFilter) Write(p []byte) (int, error) {
+	f.partial = append(f.partial, p...)
+	for {
+		i := bytes.IndexByte(f.partial, '\n')
+		if i < 0 {
+			break
+		}
+		if err := f.emit(f.partial[:i+1]); err != nil {
+			return len(p), err
+		}
+		f.partial = f.partial[i+1:]
+	}
+	return len(p), nil
+}
+
+// emit 判断一行是否保留，保留的行原样写到下一级
+func (f *lineFilter) emit(line []byte) error {
+	// 按去掉颜色后的文本匹配
+	if !f.keep(color.Strip(strings.TrimRight(string(line), "\r\n"))) {
+		return nil
+	}
+	_, err := f.next.Write(line)
+	return err
+}
+
+// Close 处理最后不完整的一行
+func (f *lineFilter) Close() error {
+	if len(f.partial) > 0 {
+		line := f.partial
+		f.partial = nil
+		if err := f.emit(line); err != nil {
+			return err
+		}
+	}
+	if f.end != nil {
+		f.end()
+	}
+	return nil
+}
+
+// pipeline 命令输出经过的过滤器链和选择的输出格式
+type pipeline struct {
+	stages []pipeStage
+	format string // 为空表示使用会话的输出格式
+}
+
+// writer 返回写入过滤器链的输出，命令结束后调用返回的函数处理各级缓存的内容并关闭打开的文件
+func (p pipeline) writer(sink io.Writer) (io.Writer, func(), error) {
+	if len(p.stages) == 0 {
+		return sink, func() {}, nil
+	}
+
+	// 从最后一级开始包装，第一级过滤器接收命令的输出
+	stages := make([]io.WriteCloser, len(p.stages))
+	closeAll := func() {
+		for _, stage := range stages {
+			if stage != nil {
+				stage.Close()
+			}
+		}
+	}
+	w := sink
+	for i := len(p.stages) - 1; i >= 0; i-- {
+		stage, err := p.stages[i](w)
+		if err != nil {
+			closeAll()
+			return nil, nil, err
+		}
+		stages[i] = stage
+		w = stage
+	}
+	return w, closeAll, nil
+}
+
+// findPipeCommand 按名称或唯一的前缀查找输出过滤器
+func findPipeCommand(word string) (pipeCommand, bool) {
+	if cmd, exists := pipeCommands[word]; exists {
+		return cmd, true
+	}
+	var found []pipeCommand
+	for name, cmd := range pipeCommands {
+		if strings.HasPrefix(name, word) {
+			found = append(found, cmd)
+		}
+	}
+	if len(found) == 1 {
+		return found[0], true
+	}
+	return pipeCommand{}, false
+}
+
+// pipeRef 命令行中的一个过滤器
+type pipeRef struct {
+	index int // 分隔符是第几个记号
+	arg   int // 参数从第几个记号开始
+	cmd   pipeCommand
+}
+
+// splitPipeline 将命令行拆分为命令和输出过滤器链，如 "show running-config | include bgp"
+// 只有后面跟着过滤器名称的 | 才作为分隔符，其余的 | 保留在命令中；
+// 设置了文件根目录时，> FILE 和 >> FILE 分别等同于 | redirect FILE 和 | append FILE。
+// inText 报告之前的记号是否已经匹配到 LINE 参数，此后的 | 和 > 都属于参数文本，如 "description a | b"
+// fileRoot 为会话的文件根目录，为空时不识别 > 和 >>
+func splitPipeline(line, fileRoot string, inText func(fields []string) bool) (string, pipeline, error) {
+	fields, offsets := commandtree.SplitFields(line)
+
+	var refs []pipeRef
+	for i := 0; i < len(fields)-1; i++ {
+		if (fields[i] == pipeSymbol || redirectSymbols[fields[i]] != "") && len(refs) == 0 && inText(fields[:i]) {
+			break
+		}
+		if fields[i] == pipeSymbol {
+			if cmd, ok := findPipeCommand(fields[i+1]); ok {
+				refs = append(refs, pipeRef{index: i, arg: i + 2, cmd: cmd})
+			}
+		} else if name, ok := redirectSymbols[fields[i]]; ok && fileRoot != "" {
+			refs = append(refs, pipeRef{index: i, arg: i + 1, cmd: pipeCommands[name]})
+		}
+	}
+	if len(refs) == 0 {
+		return line, pipeline{}, nil
+	}
+
+	var p pipeline
+	for n, ref := range refs {
+		cmd := ref.cmd
+		end := len(line)
+		if n+1 < len(refs) {
+			end = offsets[refs[n+1].index]
+		}
+		arg := ""
+		if ref.arg < len(offsets) && offsets[ref.arg] < end {
+			arg = strings.TrimSpace(line[offsets[ref.arg]:end])
+		}
+		switch {
+		case arg == "" && cmd.argument != "" && !cmd.optional:
+			return "", pipeline{}, fmt.Errorf("incomplete filter: %s", cmd.name)
+		case arg != "" && cmd.argument == "":
+			return "", pipeline{}, fmt.Errorf("filter %s takes no argument", cmd.name)
+		case cmd.final && n+1 < len(refs):
+			return "", pipeline{}, fmt.Errorf("%s must be the last filter", cmd.name)
+		case cmd.format != "" && n > 0:
+			// 输出格式决定命令输出的内容，只能紧跟在命令之后
+			return "", pipeline{}, fmt.Errorf("%s must be the first filter", cmd.name)
+		}
+		if cmd.format != "" {
+			p.format = cmd.format
+		}
+		var stage pipeStage
+		var err error
+		switch {
+		case cmd.fileBuild != nil:
+			stage, err = cmd.fileBuild(fileRoot, arg)
+		case cmd.build != nil:
+			stage, err = cmd.build(arg)
+		default:
+			continue
+		}
+		if err != nil {
+			return "", pipeline{}, err
+		}
+		p.stages = append(p.stages, stage)
+	}
+	return strings.TrimSpace(line[:offsets[refs[0].index]]), p, nil
+}
+
+// pipeHelp 输入位于 | 之后时返回输出过滤器的帮助，不在 | 之后或 | 属于 LINE 参数的文本时返回 false
+func pipeHelp(input string, inText func(fields []string) bool) ([]string, bool) {
+	fields := strings.Fields(input)
+	endsWithSpace := strings.HasSuffix(input, " ")
+
+	// 找到最后一个 |，计算正在输入的是过滤器名称还是参数
+	first, last := -1, -1
+	for i, field := range fields {
+		if field == pipeSymbol {
+			if first < 0 {
+				first = i
+			}
+			last = i
+		}
+	}
+	if last < 0 || inText(fields[:first]) {
+		return nil, false
+	}
+	after := fields[last+1:]
+
+	switch {
+	case len(after) == 0 || (len(after) == 1 && !endsWithSpace):
+		// 输入过滤器名称
+		prefix := ""
+		if len(after) == 1 {
+			prefix = after[0]
+		}
+		var help []string
+		for _, name := range sortedPipeCommands() {
+			if strings.HasPrefix(name, prefix) {
+				help = append(help, fmt.Sprintf("%-32s %s", name, pipeCommands[name].description))
+			}
+		}
+		return help, true
+	default:
+		cmd, ok := findPipeCommand(after[0])
+		if !ok {
+			return nil, true
+		}
+		var help []string
+		if cmd.argument != "" {
+			help = append(help, fmt.Sprintf("%-32s %s", "LINE", cmd.argument))
+		}
+		if !cmd.final && (cmd.argument == "" || cmd.optional || len(after) > 1) {
+			help = append(help, fmt.Sprintf("%-32s %s", pipeSymbol, "Output modifiers"))
+		}
+		return help, true
+	}
+}
+
+// completePipe 补全 | 之后的过滤器名称，返回补全后的输入，不在过滤器名称位置或没有唯一匹配时返回 false
+func completePipe(input string, inText func(fields []string) bool) (string, bool) {
+	if strings.HasSuffix(input, " ") {
+		return "", false
+	}
+	fields := strings.Fields(input)
+	if len(fields) < 2 || fields[len(fields)-2] != pipeSymbol {
+		return "", false
+	}
+	if first := slices.Index(fields, pipeSymbol); inText(fields[:first]) {
+		return "", false
+	}
+	word := fields[len(fields)-1]
+	cmd, ok := findPipeCommand(word)
+	if !ok {
+		return "", false
+	}
+	return input[:len(input)-len(word)] + cmd.name + " ", true
+}
+
+// sortedPipeCommands 按名称返回所有输出过滤器
+func sortedPipeCommands() []string {
+	names := make([]string, 0, len(pipeCommands))
+	for name := range pipeCommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
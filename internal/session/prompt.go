@@ -0,0 +1,82 @@
+package session
+
+import (
+	"log"
+	"strings"
+	"text/template"
+
+	"github.com/TrailHuang/tnlcmd/internal/mode"
+	"github.com/TrailHuang/tnlcmd/pkg/types"
+)
+
+// hostnameTemplate 设置了主机名而没有设置提示符模板时使用的模板，如 router1(configure)#
+const hostnameTemplate = "{{.Hostname}}{{.ModeSuffix}}"
+
+// renderPrompt 返回当前视图的提示符，依次使用提示符回调、提示符模板和视图固定的提示符
+// 模板无法解析或求值失败时使用视图固定的提示符
+func (s *Session) renderPrompt() string {
+	config := s.settings.Load()
+	if config.PromptFunc != nil {
+		if prompt := config.PromptFunc(s); prompt != "" {
+			return prompt
+		}
+	}
+
+	current := s.context.CurrentMode
+	text := config.PromptTemplate
+	if text == "" && config.Hostname != "" {
+		text = hostnameTemplate
+	}
+	if text == "" {
+		return current.Prompt
+	}
+
+	tmpl, err := s.promptTemplate(text)
+	if err != nil {
+		log.Printf("Invalid prompt template: %v", err)
+		return current.Prompt
+	}
+
+	var b strings.Builder
+	if err := tmpl.Execute(&b, s.promptData(config)); err != nil {
+		log.Printf("Prompt template execution error: %v", err)
+		return current.Prompt
+	}
+	return b.String()
+}
+
+// promptTemplate 返回解析后的提示符模板，模板文本改变时重新解析
+func (s *Session) promptTemplate(text string) (*template.Template, error) {
+	if s.promptTmpl != nil && s.promptText == text {
+		return s.promptTmpl, nil
+	}
+
+	tmpl, err := template.New("prompt").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	s.promptTmpl = tmpl
+	s.promptText = text
+	return tmpl, nil
+}
+
+// promptData 按配置 config 收集提示符模板的变量
+func (s *Session) promptData(config *types.Config) types.PromptData {
+	current := s.context.CurrentMode
+	data := types.PromptData{
+		Hostname:   config.Hostname,
+		Username:   s.Username(),
+		ModePath:   current.Path(),
+		ModeSuffix: "> ",
+		Privilege:  ">",
+		Status:     s.LastStatus(),
+	}
+	if data.Hostname == "" {
+		data.Hostname = strings.TrimSpace(strings.TrimRight(strings.TrimSpace(config.Prompt), ">#"))
+	}
+	if current.Parent != nil {
+		data.ModeSuffix = "(" + strings.ReplaceAll(data.ModePath, mode.PathSeparator, "-") + ")# "
+		data.Privilege = "#"
+	}
+	return data
+}
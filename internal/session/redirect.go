@@ -0,0 +1,111 @@
+package session
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/TrailHuang/tnlcmd/color"
+	"github.com/TrailHuang/tnlcmd/internal/commandtree"
+)
+
+// devicePrefix 重定向路径可以带的设备前缀，如 flash:/tech.txt，表示文件根目录
+const devicePrefix = "flash:"
+
+// redirectSymbols shell 风格的重定向记号及对应的过滤器
+var redirectSymbols = map[string]string{
+	">":  "redirect",
+	">>": "append",
+}
+
+func init() {
+	registerPipeCommand(pipeCommand{
+		name: "redirect", description: "Redirect output to a file", argument: "Destination file", final: true,
+		fileBuild: fileFilter(os.O_TRUNC, false),
+	})
+	registerPipeCommand(pipeCommand{
+		name: "append", description: "Append output to a file", argument: "Destination file", final: true,
+		fileBuild: fileFilter(os.O_APPEND, false),
+	})
+	registerPipeCommand(pipeCommand{
+		name: "tee", description: "Copy output to a file", argument: "Destination file",
+		fileBuild: fileFilter(os.O_TRUNC, true),
+	})
+}
+
+// fileFilter 返回将输出写到文件的过滤器构造函数，目标在解析命令行时确定，文件在命令开始执行时打开，
+// show 为 true 时输出同时交给下一级显示
+func fileFilter(flag int, show bool) func(root, arg string) (pipeStage, error) {
+	return func(root, arg string) (pipeStage, error) {
+		target, err := resolveRedirectPath(root, arg)
+		if err != nil {
+			return nil, err
+		}
+		return func(next io.Writer) (io.WriteCloser, error) {
+			file, err := target.open(os.O_WRONLY | os.O_CREATE | flag)
+			if err != nil {
+				return nil, fmt.Errorf("cannot open %s", arg)
+			}
+			if show {
+				return teeWriter{file: file, next: next}, nil
+			}
+			return teeWriter{file: file}, nil
+		}, nil
+	}
+}
+
+// redirectTarget 重定向的目标文件，之后修改文件根目录不影响已经解析的目标
+type redirectTarget struct {
+	root string // 解析时会话的文件根目录
+	name string // 相对于 root 的路径
+}
+
+// resolveRedirectPath 在文件根目录 root 之内解析重定向目标，目标必须在 root 之内
+func resolveRedirectPath(root, arg string) (redirectTarget, error) {
+	if root == "" {
+		return redirectTarget{}, fmt.Errorf("output redirection is not enabled")
+	}
+	clean := commandtree.CleanPath(strings.TrimPrefix(arg, devicePrefix))
+	if clean == "/" {
+		return redirectTarget{}, fmt.Errorf("invalid file name: %s", arg)
+	}
+
+	// 提前报告越过根目录的路径，打开文件时仍由 os.Root 保证不会越过
+	if _, err := commandtree.ResolvePath(root, clean); err != nil {
+		return redirectTarget{}, err
+	}
+	return redirectTarget{root: root, name: filepath.FromSlash(strings.TrimPrefix(clean, "/"))}, nil
+}
+
+// open 在目标的文件根目录之内打开文件，路径中的符号链接（包括最后一段）不能指向根目录之外
+func (t redirectTarget) open(flag int) (*os.File, error) {
+	root, err := os.OpenRoot(t.root)
+	if err != nil {
+		return nil, err
+	}
+	defer root.Close()
+	return root.OpenFile(t.name, flag, 0644)
+}
+
+// teeWriter 将输出去掉颜色后写到文件，next 不为空时原样交给下一级
+type teeWriter struct {
+	file *os.File
+	next io.Writer
+}
+
+func (w teeWriter) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(w.file, color.Strip(string(p))); err != nil {
+		return 0, err
+	}
+	if w.next == nil {
+		return len(p), nil
+	}
+	return w.next.Write(p)
+}
+
+// Close 关闭文件
+func (w teeWriter) Close() error {
+	return w.file.Close()
+}
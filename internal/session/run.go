@@ -0,0 +1,137 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/TrailHuang/tnlcmd/internal/history"
+	"github.com/TrailHuang/tnlcmd/pkg/types"
+)
+
+// errInterrupted 为处理函数读取输入期间用户按下了 Ctrl-C
+var errInterrupted = errors.New("interrupted")
+
+// readRequest 处理函数读取一行输入的请求
+type readRequest struct {
+	prompt string
+	reply  chan readReply
+}
+
+// readReply 读取的结果
+type readReply struct {
+	line string
+	err  error
+}
+
+// runHandler 执行处理函数并等待其返回
+// 执行期间继续读取输入：Ctrl-C 或 telnet 中断命令取消处理函数的上下文，其他字符留作下一行输入；
+// 处理函数通过 ReadLine 读取输入时，由本函数所在的协程代为读取；format 为输出格式，out 为处理函数的输出
+func (s *Session) runHandler(handler types.Handler, args []string, params map[string]string, format string, out io.Writer) error {
+	ctx, cancel := context.WithCancel(s.ctx)
+	defer cancel()
+
+	hctx := &types.Ctx{
+		Context: ctx,
+		Args:    args,
+		Params:  params,
+		Writer:  out,
+		Session: s,
+		Mode:    s.context.CurrentMode.Path(),
+		Format:  format,
+	}
+	done := make(chan error, 1)
+	readReq := make(chan readRequest)
+	s.readReq = readReq
+	s.cancelRun = cancel
+	s.interrupted = false
+	s.subHistory = history.NewCommandHistory(s.settings.Load().MaxHistory)
+	defer func() {
+		s.readReq = nil
+		s.cancelRun = nil
+		s.subHistory = nil
+	}()
+
+	go func() {
+		done <- handler.Run(hctx)
+	}()
+
+	input := s.input
+	for {
+		select {
+		case err := <-done:
+			return err
+		case req := <-readReq:
+			s.debugf(debugCLISession, "handler reads input with prompt %q", req.prompt)
+			line, err := s.readSubLine(req.prompt)
+			req.reply <- readReply{line: line, err: err}
+		case b, ok := <-input:
+			if !ok {
+				// 连接已断开，会话上下文随之取消，等待处理函数返回
+				input = nil
+				continue
+			}
+			data, ok := s.feedByte(b)
+			if !ok {
+				continue
+			}
+			if data == 0x03 { // Ctrl+C
+				s.interrupt()
+				continue
+			}
+			s.typeahead = append(s.typeahead, data)
+		}
+	}
+}
+
+// interrupt 取消正在执行的命令并丢弃执行期间输入的字符，处理函数返回后显示新的提示符
+func (s *Session) interrupt() {
+	s.interrupted = true
+	s.typeahead = nil
+	s.writerWrite("^C\r\n")
+	s.debugf(debugCLISession, "command interrupted")
+	s.cancelRun()
+}
+
+// readSubLine 为处理函数显示 prompt 并读取一行输入，输入行的编辑与命令行相同
+func (s *Session) readSubLine(prompt string) (string, error) {
+	if s.interrupted {
+		return "", errInterrupted
+	}
+
+	saved := s.prompt
+	s.prompt = prompt
+	s.subRead = true
+	defer func() {
+		s.prompt = saved
+		s.subRead = false
+	}()
+
+	line, err := s.readLine()
+	// 隐藏输入的行（口令）不能用上下键调出
+	if err == nil && strings.TrimSpace(line) != "" && !s.hidden.Load() {
+		s.recordHistory(s.subHistory, line)
+	}
+	return line, err
+}
+
+// ReadLine 在处理函数中显示 prompt 并读取一行输入
+// 用户按下 Ctrl-C 时返回 context.Canceled，按下 Ctrl-D 或连接断开时返回 io.EOF
+func (s *Session) ReadLine(prompt string) (string, error) {
+	readReq := s.readReq
+	if readReq == nil {
+		return "", errors.New("no command is running on this session")
+	}
+
+	reply := make(chan readReply, 1)
+	readReq <- readRequest{prompt: prompt, reply: reply}
+	r := <-reply
+	if errors.Is(r.err, errInterrupted) {
+		return "", context.Canceled
+	}
+	if r.err != nil {
+		return "", io.EOF
+	}
+	return r.line, nil
+}
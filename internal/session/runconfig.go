@@ -0,0 +1,147 @@
+package session
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+
+	"github.com/TrailHuang/tnlcmd/internal/commandtree"
+	"github.com/TrailHuang/tnlcmd/internal/runconfig"
+	"github.com/TrailHuang/tnlcmd/pkg/types"
+)
+
+func init() {
+	registerGlobalBuiltin("show running-config", "Show the current operating configuration", (*Session).showRunningConfig)
+	registerGlobalBuiltin("show running-config json", "Show the current operating configuration as JSON", (*Session).showRunningConfigJSON)
+	registerGlobalBuiltin("show running-config yaml", "Show the current operating configuration as YAML", (*Session).showRunningConfigYAML)
+	registerGlobalBuiltin("show startup-config", "Show the configuration saved in the startup file", (*Session).showStartupConfig)
+	registerGlobalBuiltin("write memory", "Save the running configuration to the startup file", (*Session).writeMemory)
+	registerGlobalBuiltin("copy running-config startup-config", "Save the running configuration to the startup file", (*Session).writeMemory)
+	registerGlobalBuiltin("load merge PATH", "Add the configuration in a JSON or YAML file to the current configuration", (*Session).loadMerge)
+	registerGlobalBuiltin("load replace PATH", "Replace the current configuration with the one in a JSON or YAML file", (*Session).loadReplace)
+}
+
+// showRunningConfig 调用各视图注册的渲染函数，显示当前的配置
+func (s *Session) showRunningConfig(args []string) string {
+	return runconfig.Running(s.context.GetRootMode())
+}
+
+// showRunningConfigJSON 以 JSON 格式显示当前的配置
+func (s *Session) showRunningConfigJSON(args []string) string {
+	return s.exportConfig(runconfig.FormatJSON)
+}
+
+// showRunningConfigYAML 以 YAML 格式显示当前的配置
+func (s *Session) showRunningConfigYAML(args []string) string {
+	return s.exportConfig(runconfig.FormatYAML)
+}
+
+// exportConfig 将当前的配置编码为指定格式
+func (s *Session) exportConfig(format string) string {
+	data, err := runconfig.Marshal(runconfig.Sections(s.context.GetRootMode()), format)
+	if err != nil {
+		s.setStatus(types.StatusError)
+		return fmt.Sprintf("%% %v\n", err)
+	}
+	return string(data)
+}
+
+// loadMerge 将文件中的配置加入当前配置
+func (s *Session) loadMerge(args []string) string {
+	return s.loadConfig(args[0], false)
+}
+
+// loadReplace 将当前配置替换为文件中的配置
+func (s *Session) loadReplace(args []string) string {
+	return s.loadConfig(args[0], true)
+}
+
+// loadConfig 读取 show running-config json/yaml 格式的配置文件，计算需要执行的命令；
+// 开启候选配置时记入候选配置由 commit 生效，否则立即执行
+func (s *Session) loadConfig(input string, replace bool) string {
+	path, err := commandtree.ResolvePath(s.fileRoot(), input)
+	if err != nil {
+		s.setStatus(types.StatusError)
+		return fmt.Sprintf("%% %v\n", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		s.setStatus(types.StatusError)
+		return fmt.Sprintf("%% Cannot read configuration: %v\n", err)
+	}
+	target, err := runconfig.Unmarshal(data)
+	if err != nil {
+		s.setStatus(types.StatusError)
+		return fmt.Sprintf("%% Invalid configuration file: %v\n", err)
+	}
+
+	root := s.context.GetRootMode()
+	current := runconfig.Sections(root)
+	changes := runconfig.Merge(current, target)
+	if replace {
+		changes = runconfig.Diff(current, target)
+	}
+	pending, err := runconfig.ResolveAll(root, s.userChanges(changes))
+	if err != nil {
+		s.setStatus(types.StatusError)
+		return fmt.Sprintf("%% Cannot load configuration, %v\n", err)
+	}
+	if len(pending) == 0 {
+		return "No differences from the current configuration\n"
+	}
+
+	if s.candidateEnabled() {
+		if replace {
+			s.candidate = pending
+		} else {
+			s.candidate = append(s.candidate, pending...)
+		}
+		return fmt.Sprintf("Loaded %d change(s) into the candidate configuration, use commit to apply\n", len(pending))
+	}
+	err = s.runHandler(types.HandlerFunc(func(ctx *types.Ctx) error {
+		return s.store.Commit(ctx, pending)
+	}), nil, nil, types.OutputText, lineWriter{s})
+	if err != nil {
+		s.setStatus(types.StatusOf(err))
+		return fmt.Sprintf("%% Load failed: %v\n", err)
+	}
+	return fmt.Sprintf("Loaded %d change(s)\n", len(pending))
+}
+
+// startupConfig 返回启动配置文件的路径，没有设置时为空
+func (s *Session) startupConfig() string {
+	return s.settings.Load().StartupConfig
+}
+
+// showStartupConfig 显示启动配置文件的内容
+func (s *Session) showStartupConfig(args []string) string {
+	path := s.startupConfig()
+	if path == "" {
+		s.setStatus(types.StatusError)
+		return fmt.Sprintf("%% %v\n", runconfig.ErrNoStartupConfig)
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "% Startup configuration is not present\n"
+	}
+	if err != nil {
+		s.setStatus(types.StatusError)
+		return fmt.Sprintf("%% Cannot read startup configuration: %v\n", err)
+	}
+	return string(data)
+}
+
+// writeMemory 将当前配置保存到启动配置文件
+func (s *Session) writeMemory(args []string) string {
+	path := s.startupConfig()
+	if path == "" {
+		s.setStatus(types.StatusError)
+		return fmt.Sprintf("%% %v\n", runconfig.ErrNoStartupConfig)
+	}
+	if err := runconfig.WriteFile(path, runconfig.Running(s.context.GetRootMode())); err != nil {
+		s.setStatus(types.StatusError)
+		return fmt.Sprintf("%% Cannot save configuration: %v\n", err)
+	}
+	return "Building configuration...\n[OK]\n"
+}
@@ -0,0 +1,183 @@
+package session
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/TrailHuang/tnlcmd/internal/commandtree"
+	"github.com/TrailHuang/tnlcmd/internal/mode"
+	"github.com/TrailHuang/tnlcmd/pkg/types"
+)
+
+func init() {
+	registerGlobalBuiltin("load script PATH", "Run the commands in a file line by line, stopping at the first error", (*Session).loadScript)
+	registerGlobalBuiltin("load script PATH continue", "Run the commands in a file line by line, continuing after errors", (*Session).loadScriptContinue)
+}
+
+// pendingScript load script 读取的脚本，在释放会话锁之后由 Handle 执行
+type pendingScript struct {
+	data    []byte
+	options types.ScriptOptions
+}
+
+// loadScript 读取脚本，遇到失败的命令时停止
+func (s *Session) loadScript(args []string) string {
+	return s.queueScript(args[0], types.ScriptOptions{})
+}
+
+// loadScriptContinue 读取脚本，命令失败后继续执行
+func (s *Session) loadScriptContinue(args []string) string {
+	return s.queueScript(args[0], types.ScriptOptions{ContinueOnError: true})
+}
+
+// queueScript 读取 PATH 指定的脚本，脚本中的命令需要再次获取会话锁，由 Handle 在本命令结束后执行
+func (s *Session) queueScript(input string, options types.ScriptOptions) string {
+	path, err := commandtree.ResolvePath(s.fileRoot(), input)
+	if err != nil {
+		s.setStatus(types.StatusError)
+		return fmt.Sprintf("%% %v\n", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		s.setStatus(types.StatusError)
+		return fmt.Sprintf("%% Cannot read script: %v\n", err)
+	}
+	s.script = &pendingScript{data: data, options: options}
+	return ""
+}
+
+// runPendingScript 执行 load script 读取的脚本，返回 io.EOF 以外的结果不影响会话
+func (s *Session) runPendingScript() {
+	script := s.script
+	if script == nil {
+		return
+	}
+	s.script = nil
+	result, _ := s.RunScript(bytes.NewReader(script.data), script.options)
+	if len(result.Failed) > 0 {
+		s.setStatus(types.StatusError)
+	} else {
+		s.setStatus(types.StatusOK)
+	}
+}
+
+// RunScript 逐行执行 r 中的命令，输出和执行结果的摘要写到会话；空行和以 ! 或 # 开头的行为注释。
+// 脚本从会话当前的视图开始执行，进入和离开视图的命令对之后的行生效，结束后恢复会话原来的视图；
+// exit 结束脚本而不关闭会话。返回的错误为读取 r 的错误
+func (s *Session) RunScript(r io.Reader, options types.ScriptOptions) (types.ScriptResult, error) {
+	saved := s.context
+	s.context = saved.Clone()
+	s.scripting = true
+	defer func() {
+		s.context = saved
+		s.scripting = false
+		s.refreshCommands()
+	}()
+
+	var result types.ScriptResult
+	scanner := bufio.NewScanner(r)
+	number := 0
+	for scanner.Scan() {
+		number++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '!' || line[0] == '#' {
+			continue
+		}
+
+		s.refreshCommands()
+		s.writerWrite(s.prompt + line + "\r\n")
+		modePath := s.context.CurrentMode.Path()
+		started := time.Now()
+		err := s.processCommand(line)
+		var corrected *correctedCommand
+		if errors.As(err, &corrected) {
+			err = s.processCommand(corrected.line)
+		}
+		matched := s.matched
+		if s.script != nil {
+			// 脚本中的 load script 不执行，避免脚本递归加载自己
+			s.script = nil
+			s.setStatus(types.StatusInvalid)
+			s.writerWrite("% Scripts cannot be nested\r\n")
+		}
+		result.Executed++
+		if err != nil && err != io.EOF {
+			s.setStatus(types.StatusInvalid)
+		}
+		s.observeCommand(line, modePath, matched, started)
+		s.publishEvents()
+		if err == io.EOF {
+			result.Stopped = true
+			break
+		}
+		if status := s.LastStatus(); status != types.StatusOK {
+			result.Failed = append(result.Failed, types.ScriptError{Line: number, Mode: modePath, Command: line, Status: status})
+			if !options.ContinueOnError {
+				result.Stopped = true
+				break
+			}
+		}
+	}
+	err := scanner.Err()
+	s.writerWrite(normalizeLineEndings(scriptReport(result, number, err)))
+	return result, err
+}
+
+// scriptReport 返回脚本执行结果的摘要，number 为最后读取的行号
+func scriptReport(result types.ScriptResult, number int, err error) string {
+	var b strings.Builder
+	switch {
+	case err != nil:
+		fmt.Fprintf(&b, "%% Cannot read script after line %d: %v\n", number, err)
+	case result.Stopped:
+		fmt.Fprintf(&b, "Script stopped at line %d: ", number)
+	default:
+		b.WriteString("Script completed: ")
+	}
+	if err == nil {
+		fmt.Fprintf(&b, "%d command(s) executed, %d failed\n", result.Executed, len(result.Failed))
+	}
+	for _, f := range result.Failed {
+		if f.Mode == "" {
+			fmt.Fprintf(&b, "  line %d: %s\n", f.Line, f.Command)
+		} else {
+			fmt.Fprintf(&b, "  line %d (%s): %s\n", f.Line, f.Mode, f.Command)
+		}
+	}
+	return b.String()
+}
+
+// NewScriptSession 创建不连接客户端的会话，用于执行命令脚本，输出写到 w，换行为 \n；
+// 处理函数读取输入时立即得到 io.EOF，输出不分页。使用完毕后调用 Close
+func NewScriptSession(shared *Shared, commandCtx *mode.CommandContext, w io.Writer) *Session {
+	s := NewStreamSession(scriptConn{w: w}, shared, commandCtx, types.StreamOptions{RemoteAddr: "script", LineMode: true})
+	s.scripting = true
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+
+	// 没有输入，处理函数读取输入时得到 io.EOF
+	s.input = make(chan byte)
+	close(s.input)
+	s.inputErr = io.EOF
+	return s
+}
+
+// scriptConn 脚本会话的数据流，不能读取，写入的 \r\n 转换为 \n
+type scriptConn struct {
+	w io.Writer
+}
+
+func (c scriptConn) Read(b []byte) (int, error) { return 0, io.EOF }
+
+func (c scriptConn) Write(b []byte) (int, error) {
+	if _, err := c.w.Write(bytes.ReplaceAll(b, []byte("\r\n"), []byte("\n"))); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
@@ -3,33 +3,113 @@ package session
 import (
 	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"text/template"
 	"time"
+	"unicode"
 
 	"github.com/TrailHuang/tnlcmd/internal/commandtree"
 	"github.com/TrailHuang/tnlcmd/internal/completer"
 	"github.com/TrailHuang/tnlcmd/internal/history"
 	"github.com/TrailHuang/tnlcmd/internal/mode"
+	"github.com/TrailHuang/tnlcmd/internal/runconfig"
+	"github.com/TrailHuang/tnlcmd/internal/settings"
+	"github.com/TrailHuang/tnlcmd/internal/telnet"
+	"github.com/TrailHuang/tnlcmd/internal/textwidth"
 	"github.com/TrailHuang/tnlcmd/pkg/types"
 )
 
 // Session 会话结构
 type Session struct {
-	conn       net.Conn
-	config     *types.Config
+	conn       io.ReadWriter         // 与客户端之间的数据流，实现了 io.Closer 时随会话关闭
+	remoteAddr string                // 客户端地址
+	settings   *settings.Settings    // 所属 CmdLine 的配置，每次使用时读取快照
+	registry   *commandtree.Registry // 所属 CmdLine 的命令注册表，读取命令树时持有读锁
+	store      *runconfig.Store      // 所属 CmdLine 的提交状态，见 Shared
+	histories  *Histories            // 所属 CmdLine 中同一用户共用的命令历史
 	commands   map[string]types.CommandInfo
 	mu         sync.RWMutex
 	lastActive time.Time
+	closeMu    sync.Mutex // 保护 isClosed 和 cancel，Close 可能在本会话的处理函数中调用，不能使用 mu
 	isClosed   bool
-	history    *history.CommandHistory
+	id         int                     // 会话编号，见 ID
+	history    *history.CommandHistory // 由 userMu 保护，见 commandHistory
 	completer  *completer.CommandCompleter
 	context    *mode.CommandContext
 	prompt     string
+	userMu     sync.RWMutex
+	username   string // 登录用户名，没有认证时为空，由 userMu 保护
+
+	shownPrompt atomic.Value // 最近一次显示的命令提示符，供其他协程读取，见 Prompt
+
+	promptText string             // promptTmpl 对应的模板文本
+	promptTmpl *template.Template // 解析后的提示符模板
+
+	// 会话上下文，客户端断开连接、会话关闭或服务器停止时取消，执行中的命令随之取消
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// 读取协程从连接读取的字节，连接断开后关闭，inputErr 为读取结束的原因
+	input     chan byte
+	inputErr  error
+	typeahead []byte             // 命令执行期间输入的字符，命令结束后作为下一行输入
+	cancelRun context.CancelFunc // 取消正在执行的命令，没有命令执行时为 nil
+
+	// 处理函数读取输入的请求，由 runHandler 处理，没有命令执行时为 nil
+	readReq     chan readRequest
+	subRead     bool                    // 正在为处理函数读取输入，? 和 Tab 作为普通字符
+	subHistory  *history.CommandHistory // 本次命令中为处理函数读取的行
+	interrupted bool                    // 读取输入期间按下了 Ctrl-C
+
+	jobsMu  sync.Mutex   // 保护后台任务表
+	jobs    map[int]*job // 后台任务，见 jobs.go
+	nextJob int          // 上一个后台任务的编号
+
+	status atomic.Int32 // 上一条命令的执行状态，处理函数执行期间仍持有 mu，不能用 mu 保护
+
+	loginTime time.Time    // 连接建立的时间
+	width     atomic.Int32 // 客户端通过 NAWS 报告的窗口宽度，未报告时为 0
+	height    atomic.Int32 // 客户端通过 NAWS 报告的窗口高度，未报告时为 0
+
+	monitor atomic.Bool // 是否开启了 terminal monitor，见 monitor.go
+
+	debug    atomic.Int32 // debug cli 开启的跟踪类别，见 debug.go
+	lastMode string       // 上一次更新命令列表时的视图路径，用于跟踪视图切换
+
+	events  []func(types.Observer) // 尚未发送给 Observer 的事件，见 observer.go
+	matched string                 // processCommand 最近匹配到的命令规格，用于命令事件
+
+	termType  atomic.Value // 客户端报告的终端类型，见 terminal.go
+	colorMode atomic.Int32 // terminal color 设置
+	notices   chan string  // 等待显示的通知
+
+	jsonOutput atomic.Bool // 是否执行了 terminal output json，见 output.go
+
+	completionKey atomic.Int32 // terminal autocomplete 设置，见 terminal.go
+	helpKey       atomic.Int32 // terminal help-key 设置
+
+	candidate []runconfig.Command // 尚未提交的候选配置，只在会话协程中访问，见 candidate.go
+
+	script    *pendingScript // load script 读取的待执行脚本，见 script.go
+	scripting bool           // 正在执行脚本，输出不分页
+
+	// telnet 协议状态
+	raw      bool // 数据流中没有 telnet 协议字节，见 enableStreamMode
+	reader   *bufio.Reader
+	parser   *telnet.Parser
+	telnet   *telnet.Negotiator
+	lineMode bool        // 客户端拒绝字符模式时回退到行模式
+	gotData  bool        // 是否已收到过数据字节
+	echo     atomic.Bool // 服务端是否回显输入字符，处理函数可以在自己的协程中修改
+	hidden   atomic.Bool // 隐藏输入（口令输入），保持 WILL ECHO 但不回显
+	editing  *lineBuffer // 正在编辑的输入行，供 telnet 命令处理使用
 }
 
 // NewSession 创建新的会话
@@ -39,16 +119,25 @@ func NewSession(conn net.Conn, config *types.Config, commands map[string]types.C
 		CurrentMode: config.RootMode.(*mode.CommandMode),
 	}
 
+	shared := NewShared(settings.New(config), context.CurrentMode)
 	s := &Session{
-		conn:     conn,
-		config:   config,
-		commands: commands,
-		context:  context,
-		prompt:   config.Prompt,
+		id:         int(lastSessionID.Add(1)),
+		conn:       conn,
+		remoteAddr: conn.RemoteAddr().String(),
+		settings:   shared.Settings,
+		registry:   shared.Registry,
+		store:      shared.Store,
+		histories:  shared.Histories,
+		commands:   commands,
+		context:    context,
+		loginTime:  time.Now(),
+		prompt:     config.Prompt,
+		notices:    make(chan string, noticeBufferSize),
 	}
 
-	s.history = history.NewCommandHistory(config.MaxHistory)
+	s.history = newHistory(config)
 	s.completer = completer.NewCommandCompleterWithContext(s.context)
+	s.completer.SetFileRoot(s.fileRoot)
 
 	// 启用telnet字符模式
 	s.enableTelnetCharacterMode()
@@ -57,23 +146,42 @@ func NewSession(conn net.Conn, config *types.Config, commands map[string]types.C
 }
 
 // NewSessionWithContext 使用现有上下文创建新的会话
-func NewSessionWithContext(conn net.Conn, config *types.Config, context *mode.CommandContext) *Session {
+func NewSessionWithContext(conn net.Conn, shared *Shared, context *mode.CommandContext) *Session {
+	return NewStreamSession(conn, shared, context, types.StreamOptions{RemoteAddr: conn.RemoteAddr().String(), Telnet: true})
+}
+
+// NewStreamSession 在任意数据流上创建会话，shared 为所属 CmdLine 的共用状态，
+// options 指定数据流是否为 telnet 协议以及终端的属性
+func NewStreamSession(rw io.ReadWriter, shared *Shared, context *mode.CommandContext, options types.StreamOptions) *Session {
+	config := shared.Settings.Load()
 	s := &Session{
-		conn:       conn,
-		config:     config,
+		id:         int(lastSessionID.Add(1)),
+		conn:       rw,
+		remoteAddr: options.RemoteAddr,
+		settings:   shared.Settings,
+		registry:   shared.Registry,
+		store:      shared.Store,
+		histories:  shared.Histories,
 		context:    context,
 		lastActive: time.Now(),
+		loginTime:  time.Now(),
 		prompt:     config.Prompt,
+		notices:    make(chan string, noticeBufferSize),
 	}
 
-	s.history = history.NewCommandHistory(config.MaxHistory)
+	s.history = newHistory(config)
 	s.completer = completer.NewCommandCompleterWithTree(context.CommandTree)
+	s.completer.SetFileRoot(s.fileRoot)
 
 	// 更新命令列表
-	s.updateCommands()
+	s.refreshCommands()
 
-	// 启用telnet字符模式
-	s.enableTelnetCharacterMode()
+	if options.Telnet {
+		// 启用telnet字符模式
+		s.enableTelnetCharacterMode()
+	} else {
+		s.enableStreamMode(options)
+	}
 
 	return s
 }
@@ -82,17 +190,45 @@ func NewSessionWithContext(conn net.Conn, config *types.Config, context *mode.Co
 func (s *Session) updateCommands() {
 	if s.context != nil {
 		s.commands = s.context.GetAvailableCommands()
-		s.prompt = s.context.CurrentMode.Prompt
+		s.prompt = s.renderPrompt()
+		s.shownPrompt.Store(s.prompt)
+		if current := s.context.CurrentMode.Path(); current != s.lastMode {
+			s.debugf(debugCLISession, "mode %q -> %q", s.lastMode, current)
+			s.observeMode(s.lastMode, current)
+			s.lastMode = current
+		}
 		// 更新补全器的上下文（不再需要更新命令树，因为补全器使用上下文）
 		s.completer.UpdateContext(s.context)
 	} else {
 		s.commands = make(map[string]types.CommandInfo)
-		s.prompt = s.config.Prompt
+		s.prompt = s.settings.Load().Prompt
+		s.shownPrompt.Store(s.prompt)
 	}
 }
 
+// refreshCommands 在注册表读锁下更新当前可用的命令列表
+func (s *Session) refreshCommands() {
+	s.registry.RLock()
+	defer s.registry.RUnlock()
+	s.updateCommands()
+}
+
 // Handle 处理会话
 func (s *Session) Handle(ctx context.Context) error {
+	s.closeMu.Lock()
+	s.ctx, s.cancel = context.WithCancel(ctx)
+	if s.isClosed {
+		s.cancel()
+	}
+	s.closeMu.Unlock()
+	defer s.observeClosed()
+	defer s.Close()
+	s.observeOpened()
+
+	// 命令执行期间也持续读取连接，以便及时发现客户端断开
+	s.input = make(chan byte, inputBufferSize)
+	go s.readInput()
+
 	// 发送欢迎消息
 	s.sendWelcomeMessage()
 
@@ -103,9 +239,14 @@ func (s *Session) Handle(ctx context.Context) error {
 		default:
 		}
 
+		// 每次提示前刷新，运行时注册的命令和视图在下一次提示时生效
+		s.refreshCommands()
+		s.reportJobs()
+
 		line, err := s.readLine()
 		if err != nil {
-			if err == io.EOF {
+			// 调用 Close 断开的会话正常结束
+			if err == io.EOF || s.closed() {
 				return nil
 			}
 			return err
@@ -113,157 +254,524 @@ func (s *Session) Handle(ctx context.Context) error {
 
 		s.lastActive = time.Now()
 
-		line = strings.TrimSpace(line)
-		if line == "" {
+		// 保留行首空白，错误标记 ^ 需要与回显的输入对齐
+		line = strings.TrimRightFunc(line, unicode.IsSpace)
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		// !! 和 !N 展开为历史中的命令，执行前显示展开后的命令
+		expanded, err := s.expandHistory(line)
+		if err != nil {
+			s.setStatus(types.StatusInvalid)
+			s.writerWrite(fmt.Sprintf("%% %v\r\n", err))
 			continue
 		}
+		if expanded != line {
+			line = expanded
+			s.writerWrite(line + "\r\n")
+		}
 
-		s.history.Add(line)
+		hist := s.commandHistory()
+		number := s.recordHistory(hist, strings.TrimSpace(line))
+		s.debugf(debugCLISession, "command %q started", line)
+		started := time.Now()
+		modePath := s.CurrentMode()
 		err = s.processCommand(line)
-		if err == io.EOF {
-			return nil
+		var corrected *correctedCommand
+		if errors.As(err, &corrected) {
+			err = s.processCommand(corrected.line)
 		}
-		if err != nil {
+		matched := s.matched
+		s.runPendingScript()
+		if err != nil && err != io.EOF {
 			// 参数验证错误等非致命错误，只记录日志，不关闭连接
+			s.setStatus(types.StatusInvalid)
 			log.Printf("Command execution error: %v", err)
 		}
+		if number > 0 {
+			hist.Finish(number, s.LastStatus())
+		}
+		s.debugf(debugCLISession, "command %q finished with status %d in %v", line, s.LastStatus(), time.Since(started).Round(time.Microsecond))
+		s.observeCommand(line, modePath, matched, started)
+		s.publishEvents()
+		if err == io.EOF {
+			return nil
+		}
 	}
 }
 
 // readLine 读取一行输入
 func (s *Session) readLine() (string, error) {
-	reader := bufio.NewReader(s.conn)
+	var buffer lineBuffer
 
-	var buffer strings.Builder
-	var historyIndex int = -1
+	// 为处理函数读取输入时，上下键浏览本次命令中已输入的行
+	hist := s.commandHistory()
+	if s.subRead {
+		hist = s.subHistory
+	}
+	browser := newHistoryBrowser(hist)
+
+	s.editing = &buffer
+	defer func() { s.editing = nil }()
 
 	// 显示初始提示符
 	s.writerWrite(s.prompt)
 	s.flushWriter()
 
 	for {
-		// 使用缓冲区读取，更好地处理telnet协议
-		data := make([]byte, 1024)
-		n, err := reader.Read(data)
+		b, err := s.readByte()
 		if err != nil {
 			return "", err
 		}
 
-		if n == 0 {
+		// 行模式：客户端本地编辑，服务端只做整行解析
+		if s.lineMode && b != 0x03 && b != 0x04 {
+			line, done := s.handleLineModeByte(b, &buffer)
+			if done {
+				return line, nil
+			}
 			continue
 		}
 
-		// 处理接收到的数据
-		for i := 0; i < n; i++ {
-			b := data[i]
-
-			// 处理telnet协议选项协商
-			if b == 0xFF { // IAC (Interpret As Command)
-				// 跳过telnet命令序列（3字节）
-				if i+2 < n {
-					i += 2
-					continue
-				}
+		switch b {
+		case 0x03: // Ctrl+C - 放弃当前输入行，与 telnet 中断命令相同
+			if s.subRead {
+				s.interrupt()
+				return "", errInterrupted
 			}
-
-			switch b {
-			case 0x03: // Ctrl+C
-				return "", io.EOF
-			case 0x04: // Ctrl+D
-				return "", io.EOF
-			case 0x7F, 0x08: // Backspace
-				if buffer.Len() > 0 {
-					current := buffer.String()
-					buffer.Reset()
-					buffer.WriteString(current[:len(current)-1])
-					s.redrawLine(buffer.String())
-				}
-			case 0x09: // Tab - 命令补全
-				if !s.handleTabCompletion(&buffer) {
-					continue
-				}
-			case 0x3F: // ? - 显示命令提示
-				currentInput := buffer.String()
-				s.showCommandHelp(currentInput)
+			s.discardLine()
+			browser = newHistoryBrowser(hist)
+		case 0x04: // Ctrl+D
+			return "", io.EOF
+		case 0x7F, 0x08: // Backspace
+			if buffer.Backspace() {
+				s.redrawLine(buffer.String())
+			}
+		case 0x01: // Ctrl+A - 光标移到行首
+			s.echoCursor(buffer.moveHome())
+		case 0x05: // Ctrl+E - 光标移到行尾
+			s.echoCursor(buffer.moveEnd())
+		case 0x02: // Ctrl+B - 光标左移
+			s.echoCursor(buffer.moveLeft())
+		case 0x06: // Ctrl+F - 光标右移
+			s.echoCursor(buffer.moveRight())
+		case 0x09: // Tab - 命令补全
+			if s.subRead {
 				continue
+			}
+			if !s.CompletionEnabled() {
+				s.insertChar(&buffer, ' ')
+				continue
+			}
+			if !s.handleTabCompletion(&buffer) {
+				continue
+			}
+		case 0x16: // Ctrl+V - 下一个字符按原样输入，如 ?
+			next, err := s.readByte()
+			if err != nil {
+				return "", err
+			}
+			if next >= 0x20 && next <= 0x7E {
+				s.insertChar(&buffer, next)
+			}
+		case 0x3F: // ? - 显示命令提示
+			if s.subRead || buffer.inQuotes() || !s.HelpKeyEnabled() {
+				s.insertChar(&buffer, b)
+				continue
+			}
+			s.showCommandHelp(buffer.completionInput())
+			continue
 
-			case 0x0D, 0x0A: // Enter
+		case 0x0D, 0x0A: // Enter
+			// 客户端本地回显时换行也由客户端输出
+			if s.echo.Load() {
 				s.writerWrite("\r\n")
 				s.flushWriter()
-				return buffer.String(), nil
-			case 0x1B: // Escape sequence - 可能是箭头键
-				// 检查是否有足够的字节用于转义序列
-				if i+2 < n {
-					if data[i+1] == '[' {
-						switch data[i+2] {
-						case 'A': // Up arrow - 浏览更早的历史命令
-							if s.history.Len() == 0 {
-								// 没有历史命令时，保持当前输入为空
-								buffer.Reset()
-								s.redrawLine("")
-							} else {
-								if historyIndex < 0 {
-									historyIndex = s.history.Len() - 1
-								} else if historyIndex > 0 {
-									historyIndex--
-								}
-								cmd := s.history.Get(historyIndex)
-								buffer.Reset()
-								buffer.WriteString(cmd)
-								s.redrawLine(buffer.String())
-							}
-							i += 2 // 跳过已处理的转义序列字节
-							continue
-						case 'B': // Down arrow - 浏览更新的历史命令
-							if historyIndex >= 0 && historyIndex < s.history.Len()-1 {
-								historyIndex++
-								cmd := s.history.Get(historyIndex)
-								buffer.Reset()
-								buffer.WriteString(cmd)
-								s.redrawLine(buffer.String())
-							} else if historyIndex == s.history.Len()-1 {
-								historyIndex = -1
-								buffer.Reset()
-								s.redrawLine("")
-							}
-							i += 2 // 跳过已处理的转义序列字节
-							continue
-						}
-					}
+			}
+			return buffer.String(), nil
+		case 0x1B: // Escape sequence - 可能是箭头键
+			if next, err := s.readByte(); err != nil {
+				return "", err
+			} else if next != '[' {
+				continue
+			}
+			key, err := s.readByte()
+			if err != nil {
+				return "", err
+			}
+			switch key {
+			case 'C': // Right arrow
+				s.echoCursor(buffer.moveRight())
+			case 'D': // Left arrow
+				s.echoCursor(buffer.moveLeft())
+			case 'H': // Home
+				s.echoCursor(buffer.moveHome())
+			case 'F': // End
+				s.echoCursor(buffer.moveEnd())
+			case '3': // Delete，序列为 ESC [ 3 ~
+				if next, err := s.readByte(); err != nil {
+					return "", err
+				} else if next == '~' && buffer.Delete() {
+					s.redrawLine(buffer.String())
 				}
-			default:
-				if b >= 0x20 && b <= 0x7E {
-					buffer.WriteByte(b)
-					s.writerWrite(string([]byte{b}))
+			case 'A': // Up arrow - 浏览更早的历史命令，已输入内容时只浏览以它开头的命令
+				if cmd, ok := browser.previous(buffer.String()); ok {
+					buffer.Set(cmd)
+					s.redrawLine(buffer.String())
+				} else {
+					s.writerWrite("\x07")
 					s.flushWriter()
 				}
+			case 'B': // Down arrow - 浏览更新的历史命令
+				if cmd, ok := browser.next(buffer.String()); ok {
+					buffer.Set(cmd)
+					s.redrawLine(buffer.String())
+				}
+			}
+		default:
+			if b >= 0x20 && b <= 0x7E {
+				s.insertChar(&buffer, b)
 			}
 		}
 	}
 }
 
+// insertChar 在光标处插入字符并回显，光标不在行尾时重绘输入行
+func (s *Session) insertChar(buffer *lineBuffer, b byte) {
+	atEnd := buffer.AtEnd()
+	buffer.Insert(string(b))
+	if !atEnd {
+		s.redrawLine(buffer.String())
+		return
+	}
+	if s.echo.Load() && !s.hidden.Load() {
+		s.writerWrite(string([]byte{b}))
+		s.flushWriter()
+	}
+}
+
+// echoCursor 回显光标移动的输出，隐藏输入时不显示
+func (s *Session) echoCursor(output string) {
+	if output != "" && s.echo.Load() && !s.hidden.Load() {
+		s.writerWrite(output)
+		s.flushWriter()
+	}
+}
+
+// handleLineModeByte 行模式下处理一个输入字节，整行结束时返回 (line, true)
+func (s *Session) handleLineModeByte(b byte, buffer *lineBuffer) (string, bool) {
+	switch b {
+	case 0x7F, 0x08: // 部分客户端不做本地编辑，仍发送退格
+		buffer.Backspace()
+	case 0x09:
+		buffer.Insert(" ")
+	case 0x0D, 0x0A:
+		line := buffer.String()
+		buffer.Reset()
+
+		// 以 ? 结尾的整行视为帮助请求，? 在未闭合的双引号之内时除外
+		trimmed := strings.TrimRight(line, " ")
+		if strings.HasSuffix(trimmed, "?") && !s.subRead && strings.Count(trimmed, "\"")%2 == 0 && s.HelpKeyEnabled() {
+			s.showCommandHelp(strings.TrimSuffix(trimmed, "?"))
+			return "", false
+		}
+		return line, true
+	default:
+		if b >= 0x20 && b <= 0x7E {
+			buffer.Insert(string(b))
+		}
+	}
+	return "", false
+}
+
+// inputBufferSize 读取协程缓存的字节数，命令执行期间输入的字符在此等待
+const inputBufferSize = 4096
+
+// readInput 持续读取连接，连接断开或会话上下文取消时关闭 input 并取消会话上下文
+func (s *Session) readInput() {
+	defer close(s.input)
+	defer s.cancel()
+
+	for {
+		b, err := s.reader.ReadByte()
+		if err != nil {
+			s.inputErr = err
+			return
+		}
+		select {
+		case s.input <- b:
+		case <-s.ctx.Done():
+			s.inputErr = s.ctx.Err()
+			return
+		}
+	}
+}
+
+// readByte 读取一个数据字节，telnet 命令序列在此处被解析并分发
+func (s *Session) readByte() (byte, error) {
+	// 先返回命令执行期间输入的字符
+	if len(s.typeahead) > 0 {
+		data := s.typeahead[0]
+		s.typeahead = s.typeahead[1:]
+		return data, nil
+	}
+
+	for {
+		var b byte
+		var ok bool
+		select {
+		case b, ok = <-s.input:
+		case message := <-s.notices:
+			s.showNotice(message)
+			continue
+		}
+		if !ok {
+			return 0, s.inputErr
+		}
+		if data, ok := s.feedByte(b); ok {
+			return data, nil
+		}
+		// 为处理函数读取输入期间收到 telnet 中断命令
+		if s.interrupted && s.subRead {
+			return 0, errInterrupted
+		}
+	}
+}
+
+// feedByte 将从连接读取的字节交给 telnet 解析器，得到数据字节时返回 (data, true)
+func (s *Session) feedByte(b byte) (byte, bool) {
+	if s.raw {
+		return b, true
+	}
+	data, ok := s.parser.Feed(b)
+	if !ok {
+		return 0, false
+	}
+
+	// 首个数据字节到达时对端仍未回应任何协商，说明它不是真正的 telnet 客户端
+	if !s.gotData {
+		s.gotData = true
+		if !s.lineMode && !s.telnet.Replied() {
+			s.enterLineMode("no reply to option negotiation")
+		}
+	}
+	return data, true
+}
+
+// enterLineMode 回退到行模式
+func (s *Session) enterLineMode(reason string) {
+	if s.lineMode {
+		return
+	}
+	s.lineMode = true
+	log.Printf("Session %s falls back to line mode: %s", s.remoteAddr, reason)
+	s.debugf(debugCLISession, "line mode: %s", reason)
+}
+
+// LineMode 返回会话是否运行在行模式
+func (s *Session) LineMode() bool {
+	return s.lineMode
+}
+
+// SetEcho 开启或关闭服务端回显，通过 IAC WILL/WONT ECHO 与客户端协商
+// 关闭后由客户端本地回显，适用于原始数据录入
+func (s *Session) SetEcho(on bool) {
+	s.echo.Store(on)
+	if s.lineMode {
+		return
+	}
+	s.telnet.SetLocal(telnet.OptEcho, on)
+}
+
+// EchoEnabled 返回协商后服务端回显是否生效
+func (s *Session) EchoEnabled() bool {
+	if s.raw {
+		return s.echo.Load() && !s.lineMode
+	}
+	return s.telnet.Local(telnet.OptEcho)
+}
+
+// SetInputHidden 隐藏或恢复输入回显，适用于口令输入
+// 隐藏期间服务端保持 WILL ECHO，客户端与服务端都不回显字符
+func (s *Session) SetInputHidden(hidden bool) {
+	s.hidden.Store(hidden)
+	if hidden && !s.lineMode {
+		s.telnet.SetLocal(telnet.OptEcho, true)
+	}
+}
+
+// HandleCommand 处理 telnet 单字节命令
+func (s *Session) HandleCommand(cmd byte) {
+	switch cmd {
+	case telnet.AYT: // Are You There：输出状态行，编辑输入行时随后恢复当前输入
+		reply := fmt.Sprintf("[%s: yes]\r\n", strings.TrimSpace(s.settings.Load().Prompt))
+		if s.editing == nil {
+			// 命令执行期间只输出状态行，不在命令的输出中插入提示符
+			s.writerWrite(reply)
+			s.flushWriter()
+			return
+		}
+		s.writerWrite("\r\n" + reply)
+		s.redrawLine(s.editingLine())
+	case telnet.IP, telnet.BRK: // 中断：取消正在执行的命令，或放弃当前输入行
+		if s.cancelRun != nil {
+			s.interrupt()
+			return
+		}
+		s.discardLine()
+	case telnet.EC: // 删除光标前的一个字符
+		if s.editing != nil && s.editing.Backspace() {
+			s.redrawLine(s.editing.String())
+		}
+	case telnet.EL: // 删除整行
+		if s.editing != nil {
+			s.editing.Reset()
+			s.redrawLine("")
+		}
+	}
+}
+
+// discardLine 放弃正在编辑的输入行，显示 ^C 后重新显示提示符
+func (s *Session) discardLine() {
+	if s.editing != nil {
+		s.editing.Reset()
+	}
+	s.writerWrite("^C\r\n")
+	s.writerWrite(s.prompt)
+	s.flushWriter()
+}
+
+// editingLine 返回正在编辑的输入行
+func (s *Session) editingLine() string {
+	if s.editing == nil {
+		return ""
+	}
+	return s.editing.String()
+}
+
+// HandleNegotiation 处理 telnet 选项协商
+func (s *Session) HandleNegotiation(verb, opt byte) {
+	res := s.telnet.Receive(verb, opt)
+
+	// 客户端拒绝服务端回显或抑制 GA 时无法逐字符编辑
+	if res.Local && !res.Enabled && (opt == telnet.OptEcho || opt == telnet.OptSGA) {
+		if res.Refused || res.Changed {
+			s.enterLineMode(fmt.Sprintf("client refused option %d", opt))
+		}
+	}
+
+	// 客户端同意报告终端类型后请求它发送
+	if !res.Local && res.Enabled && res.Changed && opt == telnet.OptTType {
+		s.telnet.Subnegotiate(telnet.OptTType, []byte{telnet.TTypeSend})
+	}
+}
+
+// HandleSubnegotiation 处理 telnet 子协商
+func (s *Session) HandleSubnegotiation(opt byte, data []byte) {
+	switch opt {
+	case telnet.OptNAWS:
+		if width, height, ok := telnet.ParseWindowSize(data); ok {
+			s.width.Store(int32(width))
+			s.height.Store(int32(height))
+			s.debugf(debugCLISession, "window size %dx%d", width, height)
+		}
+	case telnet.OptTType:
+		if termType, ok := telnet.ParseTerminalType(data); ok {
+			s.termType.Store(termType)
+			s.debugf(debugCLISession, "terminal type %s", termType)
+		}
+	}
+}
+
+// inLineText 判断记号是否匹配到当前视图中以 LINE 参数结尾的命令，此后的输入都属于参数的文本
+func (s *Session) inLineText(fields []string) bool {
+	if len(fields) == 0 {
+		return false
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	s.registry.RLock()
+	defer s.registry.RUnlock()
+
+	if s.context == nil || s.context.CurrentMode == nil || s.context.CurrentMode.CommandTree == nil {
+		return false
+	}
+	node, _, _, err := s.context.CurrentMode.CommandTree.FindCommand(fields)
+	return err == nil && node != nil && node.Type == types.NodeTypeLine
+}
+
 // processCommand 处理命令
-func (s *Session) processCommand(cmd string) error {
+func (s *Session) processCommand(line string) error {
+	s.matched = ""
+	// 以 & 结尾的命令在后台执行
+	full, background := splitBackground(line)
+
+	// | 之后的输出过滤器作用于命令的全部输出
+	cmd, pipe, err := splitPipeline(full, s.fileRoot(), s.inLineText)
+	if err != nil {
+		s.setStatus(types.StatusInvalid)
+		s.writerWrite(fmt.Sprintf("%% %v\r\n", err))
+		return nil
+	}
 	parts := strings.Fields(cmd)
 	if len(parts) == 0 {
 		return nil
 	}
+	s.setStatus(types.StatusOK)
+	if filter := strings.TrimSpace(strings.TrimPrefix(full, cmd)); filter != "" || background {
+		s.debugf(debugCLIParser, "tokens %q, filter %q, background %v", parts, filter, background)
+	} else {
+		s.debugf(debugCLIParser, "tokens %q", parts)
+	}
 
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
+	// 处理函数可能在运行时注册命令，执行前释放注册表读锁
+	s.registry.RLock()
+	locked := true
+	unlock := func() {
+		if locked {
+			s.registry.RUnlock()
+			locked = false
+		}
+	}
+	defer unlock()
+
 	// 首先检查当前视图的命令树
 	if s.context != nil && s.context.CurrentMode != nil && s.context.CurrentMode.CommandTree != nil {
 		node, matchedPath, args, err := s.context.CurrentMode.CommandTree.FindCommand(parts)
+		s.traceMatch(node, matchedPath, args, err)
+
+		// 缩写匹配多个关键字时列出候选项
+		var ambiguous *commandtree.AmbiguousError
+		if errors.As(err, &ambiguous) {
+			s.setStatus(types.StatusInvalid)
+			s.writerWrite(fmt.Sprintf("%% Ambiguous command: \"%s\"\r\n", strings.Join(parts[:ambiguous.Index+1], " ")))
+			for _, expansion := range ambiguous.Expansions {
+				s.writerWrite(fmt.Sprintf("  %s\r\n", expansion))
+			}
+			return nil
+		}
+
 		if err == nil && node != nil {
+			s.matched = node.Path()
+			if background {
+				if _, exists := lookupBuiltin(node); exists || node.Handler == nil || node.Type == types.NodeTypeModeSwitch {
+					s.setStatus(types.StatusInvalid)
+					s.writerWrite("% Command cannot run in background\r\n")
+					return nil
+				}
+			}
+
 			// 处理视图切换命令
 			if node.Type == types.NodeTypeModeSwitch {
 				if s.context != nil && len(parts) == len(matchedPath) {
 					// 查找要切换到的视图
-					modeName := node.ModeName
 					rootMode := s.context.GetRootMode()
-					if subMode, exists := rootMode.Children[modeName]; exists {
-						s.context.ChangeMode(subMode)
+					if subMode := rootMode.FindMode(node.ModeName); subMode != nil {
+						s.context.EnterMode(subMode)
 						s.writerWrite(fmt.Sprintf("Entering %s mode\r\n", subMode.Description))
 						s.updateCommands()
 						return nil
@@ -271,43 +779,82 @@ func (s *Session) processCommand(cmd string) error {
 				}
 			}
 
+			// 行尾文本参数保留用户输入的原始空白
+			if node.Type == types.NodeTypeLine && len(args) > 0 {
+				consumed := len(strings.Fields(args[len(args)-1]))
+				args[len(args)-1] = commandtree.RestOfLine(cmd, len(parts)-consumed)
+			}
+
+			// 会话内置命令需要访问会话状态，由会话直接处理
+			if builtin, exists := lookupBuiltin(node); exists {
+				if err := s.validateCommandParameters(cmd, node, matchedPath, args); err != nil {
+					return err
+				}
+				s.warnDeprecated(node)
+				// help 等只读取命令树的内置命令在释放注册表读锁之前生成输出，
+				// 其他内置命令可能调用 CmdLine 的方法，释放之后执行
+				run := func() string { return builtin.handler(s, args) }
+				if builtin.tree {
+					text := run()
+					run = func() string { return text }
+				}
+				unlock()
+				if pipe.format != "" {
+					return s.finishCommand(cmd, errNoStructuredOutput)
+				}
+				// 内置命令在会话协程中执行，输出较长时（如 help）可以分页
+				out, flush, err := pipe.writer(s.newPager())
+				if err != nil {
+					return s.finishCommand(cmd, err)
+				}
+				io.WriteString(out, run())
+				flush()
+				return nil
+			}
+
 			if node.Handler != nil {
 				//args := parts[len(matchedPath):]
-				if err := s.validateCommandParameters(node, matchedPath, args); err != nil {
+				if err := s.validateCommandParameters(cmd, node, matchedPath, args); err != nil {
 					return err
 				}
 
-				result := node.Handler(args)
-				if result != "" {
-					// 检查是否为退出命令的特殊标记
-					if result == "__EXIT__" {
-						s.writerWrite("Goodbye!\r\n")
-						s.flushWriter()
-						return io.EOF
-					}
-
-					// 检查是否为退出到根模式的特殊标记
-					if result == "__EXIT_TO_ROOT__" {
-						s.writerWrite("Exiting to privileged EXEC mode\r\n")
-						rootMode := s.context.GetRootMode()
-						s.context.ChangeMode(rootMode)
-						s.updateCommands()
-						return nil
-					}
-
-					// 规范化换行符，确保使用 \r\n
-					normalizedResult := normalizeLineEndings(result)
-					s.writerWrite(normalizedResult)
+				s.warnDeprecated(node)
+				// 开启候选配置时，配置视图中的命令记入候选配置，commit 时再执行
+				configCommand := !background && s.context.CurrentMode.Parent != nil && !node.Operational
+				if configCommand && s.settings.Load().CandidateConfig {
+					s.addCandidate(cmd, node, args)
+					return nil
 				}
-
-				s.updateCommands()
-				return nil
+				unlock()
+				format, err := s.outputFormat(node.Handler, pipe)
+				if err != nil {
+					return s.finishCommand(cmd, err)
+				}
+				if background {
+					s.runInBackground(full, node, args, pipe)
+					return nil
+				}
+				out, flush, err := pipe.writer(lineWriter{s})
+				if err != nil {
+					return s.finishCommand(cmd, err)
+				}
+				run := func() error {
+					return s.runHandler(node.Handler, commandtree.SpecArgs(node, args), commandtree.NamedParams(node, args), format, out)
+				}
+				// 配置视图中的命令执行成功后发布配置修改事件
+				if configCommand {
+					err = s.store.Events.Track(s.context.GetRootMode(), s.configChange(cmd), run)
+				} else {
+					err = run()
+				}
+				flush()
+				return s.finishCommand(cmd, err)
 			}
 
 			if s.context != nil && len(parts) == len(matchedPath) {
-				modeName := parts[len(parts)-1]
+				modeName := matchedPath[len(matchedPath)-1]
 				if subMode, exists := s.context.CurrentMode.Children[modeName]; exists {
-					s.context.ChangeMode(subMode)
+					s.context.EnterMode(subMode)
 					s.writerWrite(fmt.Sprintf("Entering %s mode\r\n", subMode.Description))
 					s.updateCommands()
 					return nil
@@ -316,13 +863,164 @@ func (s *Session) processCommand(cmd string) error {
 		}
 	}
 
+	// 用 ^ 标出第一个无法匹配的记号并给出原因
+	s.setStatus(types.StatusInvalid)
+	if s.context != nil && s.context.CurrentMode != nil && s.context.CurrentMode.CommandTree != nil {
+		index, msg := s.context.CurrentMode.CommandTree.ExplainMismatch(parts)
+		if msg != "" {
+			s.debugf(debugCLIParser, "mismatch at token %d: %s", index, msg)
+		} else {
+			s.debugf(debugCLIParser, "mismatch at token %d", index)
+		}
+		if index >= len(parts) {
+			s.writerWrite("% Incomplete command.\r\n")
+			return nil
+		}
+
+		// 回调执行前释放注册表读锁，拼写建议在此之前计算
+		corrections := s.context.CurrentMode.CommandTree.Corrections(parts)
+		config := s.settings.Load()
+
+		// 应用注册的回调可以接管无法匹配的输入
+		if notFound := config.NotFound; notFound != nil {
+			unlock()
+			if pipe.format != "" {
+				return s.finishCommand(line, errNoStructuredOutput)
+			}
+			out, flush, err := pipe.writer(lineWriter{s})
+			if err != nil {
+				return s.finishCommand(line, err)
+			}
+			handled, err := s.runNotFound(notFound, line, parts, out)
+			flush()
+			if handled {
+				return s.finishCommand(line, err)
+			}
+		}
+
+		if len(corrections) == 1 && config.AutoCorrect {
+			s.writerWrite(fmt.Sprintf("%% Corrected to: %s\r\n", corrections[0]))
+			return &correctedCommand{line: corrections[0] + afterCommand(line, cmd)}
+		}
+		s.showInvalidInput(cmd, index, msg)
+		if len(corrections) > 0 {
+			s.writerWrite(fmt.Sprintf("%% Did you mean: %s?\r\n", strings.Join(corrections, ", ")))
+		}
+		if msg != "" {
+			return fmt.Errorf("invalid parameter value")
+		}
+		return nil
+	}
+
 	s.writerWrite(fmt.Sprintf("Unknown command: %s\r\n", strings.Join(parts, " ")))
 	s.writerWrite("Type '?' for available commands\r\n")
 	return nil
 }
 
+// correctedCommand 输入无法识别而自动纠正了拼写，由调用者在释放会话锁后执行纠正后的行
+type correctedCommand struct {
+	line string
+}
+
+// Error 实现 error 接口
+func (c *correctedCommand) Error() string {
+	return "corrected to: " + c.line
+}
+
+// afterCommand 返回输入行中命令 cmd 之后的部分，即管道过滤器和后台执行标记
+func afterCommand(line, cmd string) string {
+	index := strings.Index(line, cmd)
+	if index < 0 {
+		return ""
+	}
+	return line[index+len(cmd):]
+}
+
+// runNotFound 以输入的原始行调用无法匹配命令时的回调，返回回调是否处理了该行
+func (s *Session) runNotFound(notFound types.NotFoundFunc, line string, args []string, out io.Writer) (bool, error) {
+	handled := false
+	err := s.runHandler(types.HandlerFunc(func(ctx *types.Ctx) error {
+		var err error
+		handled, err = notFound(ctx, line)
+		return err
+	}), args, nil, types.OutputText, out)
+	return handled, err
+}
+
+// finishCommand 记录处理函数的执行状态，并按返回的错误执行会话动作或打印错误
+// 处理函数要求关闭会话时返回 io.EOF
+func (s *Session) finishCommand(cmd string, err error) error {
+	s.setStatus(types.StatusOf(err))
+	var cliErr *types.Error
+	switch {
+	case errors.Is(err, types.ErrCloseSession):
+		s.writerWrite("Goodbye!\r\n")
+		s.flushWriter()
+		return io.EOF
+
+	case errors.Is(err, types.ErrLeaveMode):
+		// 返回进入当前视图之前所在的视图
+		previous := s.context.LeaveMode()
+		if previous.Parent == nil {
+			s.writerWrite("Exiting to privileged EXEC mode\r\n")
+		} else {
+			s.writerWrite(fmt.Sprintf("Exiting to %s mode\r\n", previous.Description))
+		}
+
+	case errors.Is(err, types.ErrExitToRoot):
+		s.writerWrite("Exiting to privileged EXEC mode\r\n")
+		rootMode := s.context.GetRootMode()
+		s.context.ChangeMode(rootMode)
+
+	case errors.Is(err, context.Canceled):
+		// 用户已经按下 Ctrl-C，不再提示
+
+	case err != nil:
+		log.Printf("Session %s: command %q failed with status %d: %v", s.RemoteAddr(), cmd, s.LastStatus(), err)
+		s.writerWrite(fmt.Sprintf("%% %v\r\n", err))
+		if errors.As(err, &cliErr) && cliErr.Hint != "" {
+			s.writerWrite(fmt.Sprintf("%% Hint: %s\r\n", cliErr.Hint))
+		}
+	}
+
+	s.refreshCommands()
+	return nil
+}
+
+// showInvalidInput 在回显的输入下方用 ^ 标出第 index 个记号，并说明原因
+func (s *Session) showInvalidInput(cmd string, index int, reason string) {
+	_, offsets := commandtree.SplitFields(cmd)
+	column := len(cmd)
+	if index < len(offsets) {
+		column = offsets[index]
+	}
+
+	// 提示符可能包含换行，只计算最后一行的宽度
+	prompt := s.prompt[strings.LastIndex(s.prompt, "\n")+1:]
+	width := textwidth.Width(prompt) + textwidth.Width(cmd[:column])
+	s.writerWrite(strings.Repeat(" ", width) + "^\r\n")
+
+	if reason != "" {
+		s.writerWrite(fmt.Sprintf("%% Invalid input detected at '^' marker: %s\r\n", reason))
+	} else {
+		s.writerWrite("% Invalid input detected at '^' marker.\r\n")
+	}
+}
+
+// warnDeprecated 执行废弃命令前提示改用新命令
+func (s *Session) warnDeprecated(node *commandtree.CommandNode) {
+	if !node.Deprecated {
+		return
+	}
+	if node.Replacement != "" {
+		s.writerWrite(fmt.Sprintf("%% Warning: '%s' is deprecated, use '%s' instead\r\n", node.Path(), node.Replacement))
+	} else {
+		s.writerWrite(fmt.Sprintf("%% Warning: '%s' is deprecated\r\n", node.Path()))
+	}
+}
+
 // validateCommandParameters 验证命令参数数量和值是否正确
-func (s *Session) validateCommandParameters(node *commandtree.CommandNode, matchedPath []string, args []string) error {
+func (s *Session) validateCommandParameters(cmd string, node *commandtree.CommandNode, matchedPath []string, args []string) error {
 	// 计算命令需要的参数数量
 	requiredParams := 0
 	optionalParams := 0
@@ -351,61 +1049,117 @@ func (s *Session) validateCommandParameters(node *commandtree.CommandNode, match
 		return fmt.Errorf("insufficient arguments")
 	}
 
-	if len(args) > requiredParams+optionalParams {
-		s.writerWrite(fmt.Sprintf("Error: Too many arguments for command '%s'\r\n", strings.Join(matchedPath, " ")))
-		s.writerWrite(fmt.Sprintf("Expected at most %d arguments, got %d\r\n", requiredParams+optionalParams, len(args)))
+	// 末尾的可重复参数不限制参数个数
+	repeat := len(paramNodes) > 0 && paramNodes[len(paramNodes)-1].Repeat
+
+	// 参数位于输入的末尾，first 为第一个参数在输入中的位置
+	first := len(strings.Fields(cmd)) - len(args)
+	if node.Type == types.NodeTypeLine && len(args) > 0 {
+		first = len(strings.Fields(cmd)) - len(strings.Fields(args[len(args)-1])) - (len(args) - 1)
+	}
+
+	if !repeat && len(args) > requiredParams+optionalParams {
+		// 命名参数的关键字重复输入时指出该关键字，而不是报告参数个数
+		reason := fmt.Sprintf("too many arguments, expected at most %d", requiredParams+optionalParams)
+		if keyword := commandtree.RepeatedKeyword(node, args[requiredParams+optionalParams]); keyword != "" {
+			reason = fmt.Sprintf("duplicate keyword '%s'", keyword)
+		}
+		s.showInvalidInput(cmd, first+requiredParams+optionalParams, reason)
 		return fmt.Errorf("too many arguments")
 	}
 
-	// 验证参数值的合法性
+	// 验证参数值的合法性，并换算为传给处理函数的值
+	fileRoot := s.fileRoot()
 	for i, arg := range args {
-		if i < len(paramNodes) {
-			paramNode := paramNodes[i]
+		if i < len(paramNodes) || repeat {
+			paramNode := paramNodes[min(i, len(paramNodes)-1)]
 
 			// 使用参数验证函数检查参数值
 			if !commandtree.IsParameterMatch(paramNode, arg) {
 				// 获取具体的验证错误信息
-				errorMsg := s.getParameterValidationError(paramNode, arg)
-				s.writerWrite(fmt.Sprintf("Error: Invalid parameter value for command '%s'\r\n", strings.Join(matchedPath, " ")))
-				s.writerWrite(fmt.Sprintf("Parameter %d: %s\r\n", i+1, errorMsg))
+				errorMsg := commandtree.GetParameterValidationError(paramNode, arg)
+				s.showInvalidInput(cmd, first+i, errorMsg)
 				return fmt.Errorf("invalid parameter value")
 			}
+			args[i] = commandtree.NormalizeParameter(paramNode, arg, fileRoot)
 		}
 	}
 
 	return nil
 }
 
-// getParameterValidationError 获取参数验证错误信息
-func (s *Session) getParameterValidationError(node *commandtree.CommandNode, input string) string {
-	switch node.Type {
-	case types.NodeTypeEnum:
-		return commandtree.GetEnumValidationError(node, input)
-	case types.NodeTypeNum:
-		return commandtree.GetNumberValidationError(node, input)
-	default:
-		return fmt.Sprintf("无效的参数值: '%s'", input)
-	}
+// fileRoot 返回路径参数和输出重定向的根目录，为空表示未设置
+func (s *Session) fileRoot() string {
+	return s.settings.Load().FileRoot
 }
 
-// redrawLine 重绘当前行
+// redrawLine 重绘当前行，line 为正在编辑的输入行，光标不在行尾时移回光标位置
 func (s *Session) redrawLine(line string) {
+	// 行模式下无法改写客户端的输入行，只重新显示提示符
+	if s.lineMode {
+		s.writerWrite(s.prompt)
+		s.flushWriter()
+		return
+	}
+
 	// 清除当前行并重新显示
 	s.writerWrite("\r\x1b[K") // 回到行首并清除整行
 	s.writerWrite(s.prompt)
-	s.writerWrite(line)
+	if s.echo.Load() && !s.hidden.Load() {
+		s.writerWrite(line)
+		if s.editing != nil {
+			s.writerWrite(cursorLeft(textwidth.Width(s.editing.afterCursor())))
+		}
+	}
 	s.flushWriter()
 }
 
-// showCompletions 显示补全选项
+// showCompletions 显示补全选项，超过一屏时分页显示
 func (s *Session) showCompletions(completions []string) {
 	s.writerWrite("\r\n")
+	out := s.newPager()
 	for _, comp := range completions {
-		s.writerWrite(comp + "\r\n")
+		io.WriteString(out, comp+"\n")
 	}
 	s.flushWriter()
 }
 
+// completionGap 补全候选项分列显示时列之间的空格数
+const completionGap = 2
+
+// showCompletionColumns 按终端宽度将补全候选项分列显示，先从上到下再从左到右排列
+func (s *Session) showCompletionColumns(words []string) {
+	columnWidth := 0
+	for _, word := range words {
+		if n := textwidth.Width(word); n > columnWidth {
+			columnWidth = n
+		}
+	}
+	columnWidth += completionGap
+
+	// 终端最后一列写满时会自动换行，可用宽度比终端少一列
+	width, _ := s.TerminalSize()
+	columns := (width - 1 + completionGap) / columnWidth
+	if columns < 1 {
+		columns = 1
+	}
+	rows := (len(words) + columns - 1) / columns
+
+	lines := make([]string, rows)
+	for row := range lines {
+		var line strings.Builder
+		for i := row; i < len(words); i += rows {
+			if i+rows < len(words) {
+				line.WriteString(textwidth.Pad(words[i], columnWidth))
+			} else {
+				line.WriteString(words[i])
+			}
+		}
+		lines[row] = line.String()
+	}
+	s.showCompletions(lines)
+}
+
 // writerWrite 写入数据
 func (s *Session) writerWrite(data string) {
 	s.conn.Write([]byte(data))
@@ -424,9 +1178,10 @@ func (s *Session) UpdatePrompt(prompt string) {
 	defer s.mu.Unlock()
 
 	s.prompt = prompt
+	s.shownPrompt.Store(prompt)
 
 	// 如果当前有活动连接，重新显示提示符
-	if s.conn != nil && !s.isClosed {
+	if s.conn != nil && !s.closed() {
 		// 清除当前行并显示新的提示符
 		s.writerWrite("\r\x1b[K")
 		s.writerWrite(s.prompt)
@@ -434,6 +1189,16 @@ func (s *Session) UpdatePrompt(prompt string) {
 	}
 }
 
+// lineWriter 处理函数的输出，写入前将换行转换为 \r\n
+type lineWriter struct {
+	s *Session
+}
+
+func (w lineWriter) Write(p []byte) (int, error) {
+	w.s.writerWrite(w.s.stripColor(normalizeLineEndings(string(p))))
+	return len(p), nil
+}
+
 // normalizeLineEndings 规范化换行符，确保使用 \r\n
 func normalizeLineEndings(text string) string {
 	// 如果已经是 \r\n，直接返回
@@ -450,23 +1215,57 @@ func normalizeLineEndings(text string) string {
 
 // sendWelcomeMessage 发送欢迎消息
 func (s *Session) sendWelcomeMessage() {
-	s.writerWrite(s.config.WelcomeMsg)
+	s.writerWrite(s.settings.Load().WelcomeMsg)
 }
 
 // enableTelnetCharacterMode 启用telnet字符模式
 func (s *Session) enableTelnetCharacterMode() {
-	// Telnet选项协商命令
+	s.reader = bufio.NewReader(s.conn)
+	s.parser = telnet.NewParser(s)
+	s.telnet = telnet.NewNegotiator(s.conn)
+	s.telnet.SupportLocal(telnet.OptEcho, telnet.OptSGA)
+	s.telnet.SupportRemote(telnet.OptSGA, telnet.OptNAWS, telnet.OptTType)
+	s.telnet.Trace = s.traceTelnet
+	s.echo.Store(true)
+
 	// IAC WILL ECHO: 告诉客户端我们将处理回显
-	// IAC DO SUPPRESS_GO_AHEAD: 禁用前进抑制
-	// IAC WILL SUPPRESS_GO_AHEAD: 禁用前进抑制
+	// IAC DO/WILL SUPPRESS_GO_AHEAD: 双向禁用 Go Ahead，进入字符模式
+	s.telnet.SetLocal(telnet.OptEcho, true)
+	s.telnet.SetRemote(telnet.OptSGA, true)
+	s.telnet.SetLocal(telnet.OptSGA, true)
 
-	telnetCommands := []byte{
-		0xFF, 0xFB, 0x01, // IAC WILL ECHO
-		0xFF, 0xFD, 0x03, // IAC DO SUPPRESS_GO_AHEAD
-		0xFF, 0xFB, 0x03, // IAC WILL SUPPRESS_GO_AHEAD
+	// IAC DO NAWS: 请求客户端报告窗口大小，窗口改变时客户端会再次报告
+	s.telnet.SetRemote(telnet.OptNAWS, true)
+
+	// IAC DO TERMINAL-TYPE: 请求客户端报告终端类型，用于判断是否支持颜色
+	s.telnet.SetRemote(telnet.OptTType, true)
+}
+
+// enableStreamMode 数据流中没有 telnet 协议时按 options 设置终端属性，不发送选项协商
+func (s *Session) enableStreamMode(options types.StreamOptions) {
+	s.raw = true
+	s.reader = bufio.NewReader(s.conn)
+	s.parser = telnet.NewParser(s)
+	// 协商器只记录选项状态，SetEcho 等调用不向数据流写入协议字节
+	s.telnet = telnet.NewNegotiator(io.Discard)
+	s.lineMode = options.LineMode
+	s.echo.Store(true)
+
+	if options.Width > 0 && options.Height > 0 {
+		s.width.Store(int32(options.Width))
+		s.height.Store(int32(options.Height))
 	}
+	if options.TerminalType != "" {
+		s.termType.Store(strings.ToUpper(options.TerminalType))
+	}
+}
 
-	s.conn.Write(telnetCommands)
+// traceTelnet 将协商记录转发给配置的日志钩子
+func (s *Session) traceTelnet(entry telnet.LogEntry) {
+	s.debugf(debugCLITelnet, "%s", entry)
+	if logger := s.settings.Load().TelnetLogger; logger != nil {
+		logger(s.remoteAddr, entry.String())
+	}
 }
 
 // IsStale 检查会话是否过期
@@ -477,24 +1276,53 @@ func (s *Session) IsStale() bool {
 }
 
 // handleTabCompletion 处理Tab键补全
-func (s *Session) handleTabCompletion(buffer *strings.Builder) bool {
-	currentInput := buffer.String()
+// 补全光标所在的记号，光标之后的内容保留在补全的结果之后
+func (s *Session) handleTabCompletion(buffer *lineBuffer) bool {
+	currentInput := buffer.completionInput()
+
+	// | 之后补全输出过滤器的名称
+	if completed, ok := completePipe(currentInput, s.inLineText); ok {
+		buffer.replaceCompletionInput(completed)
+		s.redrawLine(buffer.String())
+		return true
+	}
 	inputParts := strings.Fields(currentInput)
 
+	// 只在计算补全时持有注册表读锁，输出到客户端时不阻塞命令注册
+	var suggestions, nextLevelCompletions, paramCompletions []string
+	s.registry.RLock()
+	if len(inputParts) == 0 {
+		suggestions = s.completer.GetCommandTreeSuggestions(currentInput)
+	} else {
+		nextLevelCompletions = s.completer.GetNextLevelCompletions(currentInput)
+		if len(nextLevelCompletions) == 0 {
+			paramCompletions = s.completer.GetParameterCompletions(currentInput)
+		}
+		if len(nextLevelCompletions) == 0 && len(paramCompletions) == 0 && s.settings.Load().FuzzyCompletion {
+			nextLevelCompletions = s.completer.FuzzyCompletions(currentInput)
+		}
+	}
+	s.registry.RUnlock()
+	s.debugf(debugCLICompletion, "tab %q: commands %q, next %q, parameters %q", currentInput, suggestions, nextLevelCompletions, paramCompletions)
+	candidates := suggestions
+	if len(inputParts) > 0 {
+		candidates = nextLevelCompletions
+		if len(candidates) == 0 {
+			candidates = paramCompletions
+		}
+	}
+	s.observeCompletion(currentInput, false, candidates)
+
 	if len(inputParts) == 0 {
-		suggestions := s.completer.GetCommandTreeSuggestions(currentInput)
 		if len(suggestions) > 0 {
 			s.showCompletions(suggestions)
-			s.redrawLine(currentInput)
+			s.redrawLine(buffer.String())
 		}
 		return false
 	}
 
-	nextLevelCompletions := s.completer.GetNextLevelCompletions(currentInput)
-
 	switch len(nextLevelCompletions) {
 	case 0:
-		paramCompletions := s.completer.GetParameterCompletions(currentInput)
 		if len(paramCompletions) > 0 {
 			s.showCompletions(paramCompletions)
 			s.flushWriter()
@@ -504,11 +1332,10 @@ func (s *Session) handleTabCompletion(buffer *strings.Builder) bool {
 			s.flushWriter()
 		}
 	case 1:
-		buffer.Reset()
-		buffer.WriteString(nextLevelCompletions[0])
+		buffer.replaceCompletionInput(nextLevelCompletions[0])
 		s.redrawLine(buffer.String())
 	default:
-		s.showCompletions(nextLevelCompletions)
+		s.showCompletionColumns(nextLevelCompletions)
 		s.flushWriter()
 		s.redrawLine(buffer.String())
 	}
@@ -516,40 +1343,70 @@ func (s *Session) handleTabCompletion(buffer *strings.Builder) bool {
 	return true
 }
 
-// showCommandHelp 显示命令帮助（处理?键）
+// showCommandHelp 显示命令帮助（处理?键），currentInput 为行首到光标所在记号末尾的输入
 func (s *Session) showCommandHelp(currentInput string) {
+	// | 之后显示输出过滤器
+	if help, ok := pipeHelp(currentInput, s.inLineText); ok {
+		if len(help) > 0 {
+			s.showCompletions(help)
+		} else {
+			s.writerWrite("\r\n% Unrecognized filter\r\n")
+		}
+		s.redrawLine(s.editingLine())
+		return
+	}
+
 	// 分析输入，按空格拆分
 	inputParts := strings.Fields(currentInput)
 
+	// 空输入显示所有一级命令，否则显示下一级补全选项
+	query := currentInput
+	if len(inputParts) == 0 {
+		query = ""
+	}
+	s.registry.RLock()
+	completions := s.completer.GetCommandTreeSuggestions(query)
+	s.registry.RUnlock()
+	s.debugf(debugCLICompletion, "help %q: %d item(s)", currentInput, len(completions))
+	s.observeCompletion(currentInput, true, completions)
+
 	// 使用命令树进行智能提示
 	if len(inputParts) == 0 {
-		// 空输入，显示所有一级命令
-		completions := s.completer.GetCommandTreeSuggestions("")
 		if len(completions) > 0 {
 			s.showCompletions(completions)
-			s.redrawLine(currentInput)
+			s.redrawLine(s.editingLine())
 		}
 	} else {
-		// 获取下一级补全选项
-		nextLevelCompletions := s.completer.GetCommandTreeSuggestions(currentInput)
-		if len(nextLevelCompletions) > 0 {
-			s.showCompletions(nextLevelCompletions)
-			s.redrawLine(currentInput)
+		if len(completions) > 0 {
+			s.showCompletions(completions)
+			s.redrawLine(s.editingLine())
 		} else {
 			// 没有可用命令，显示提示信息
 			s.writerWrite("\r\nNo commands available\r\n")
-			s.redrawLine(currentInput)
+			s.redrawLine(s.editingLine())
 		}
 	}
 }
 
-// Close 关闭会话
+// Close 关闭会话并断开连接，可以在任意协程中调用，包括本会话的处理函数
 func (s *Session) Close() {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	s.closeMu.Lock()
+	defer s.closeMu.Unlock()
 
 	if !s.isClosed {
 		s.isClosed = true
-		s.conn.Close()
+		if closer, ok := s.conn.(io.Closer); ok {
+			closer.Close()
+		}
+		if s.cancel != nil {
+			s.cancel()
+		}
 	}
 }
+
+// closed 返回会话是否已经关闭
+func (s *Session) closed() bool {
+	s.closeMu.Lock()
+	defer s.closeMu.Unlock()
+	return s.isClosed
+}
@@ -0,0 +1,26 @@
+package session
+
+import (
+	"github.com/TrailHuang/tnlcmd/internal/commandtree"
+	"github.com/TrailHuang/tnlcmd/internal/mode"
+	"github.com/TrailHuang/tnlcmd/internal/runconfig"
+	"github.com/TrailHuang/tnlcmd/internal/settings"
+)
+
+// Shared 同一个 CmdLine 的所有会话共用的状态，由 CmdLine 创建，经服务器传给每个会话
+type Shared struct {
+	Settings  *settings.Settings    // 运行期间可以修改的配置
+	Registry  *commandtree.Registry // 保护所有视图的命令树
+	Store     *runconfig.Store      // 提交、历史配置和配置修改事件
+	Histories *Histories            // 同一用户的会话共用的命令历史
+}
+
+// NewShared 创建配置 settings 对应的共用状态，root 为根视图，其命令树所属的注册表为所有视图共用
+func NewShared(settings *settings.Settings, root *mode.CommandMode) *Shared {
+	return &Shared{
+		Settings:  settings,
+		Registry:  root.CommandTree.Registry(),
+		Store:     runconfig.NewStore(settings, root),
+		Histories: &Histories{},
+	}
+}
@@ -0,0 +1,145 @@
+package session
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	"github.com/TrailHuang/tnlcmd/color"
+	"github.com/TrailHuang/tnlcmd/pkg/types"
+)
+
+// 会话的颜色设置
+const (
+	colorAuto int32 = iota // 按终端类型判断
+	colorOn                // terminal color
+	colorOff               // terminal no color
+)
+
+// 会话的按键设置，未设置时按 Config.DisableCompletion 和 Config.DisableHelpKey
+const (
+	keyDefault int32 = iota
+	keyOn
+	keyOff
+)
+
+// monochromeTerminals 不支持 ANSI 颜色的终端类型
+var monochromeTerminals = map[string]bool{
+	"":                         true,
+	"DUMB":                     true,
+	"UNKNOWN":                  true,
+	"NETWORK-VIRTUAL-TERMINAL": true,
+	"VT52":                     true,
+}
+
+func init() {
+	registerGlobalBuiltin("terminal color", "Show colored output in this session", (*Session).terminalColor)
+	registerGlobalBuiltin("terminal no color", "Show output in this session without colors", (*Session).terminalNoColor)
+	registerGlobalBuiltin("terminal autocomplete", "Complete commands with Tab in this session", (*Session).terminalAutocomplete)
+	registerGlobalBuiltin("terminal no autocomplete", "Input Tab as a space in this session", (*Session).terminalNoAutocomplete)
+	registerGlobalBuiltin("terminal help-key", "Show help when ? is pressed in this session", (*Session).terminalHelpKey)
+	registerGlobalBuiltin("terminal no help-key", "Input ? as an ordinary character in this session", (*Session).terminalNoHelpKey)
+	registerGlobalBuiltin("show terminal", "Show terminal settings of this session", (*Session).showTerminal)
+}
+
+// TerminalType 返回客户端通过 TERMINAL-TYPE 选项报告的终端类型（大写，如 XTERM），没有报告时为空
+func (s *Session) TerminalType() string {
+	termType, _ := s.termType.Load().(string)
+	return termType
+}
+
+// ColorEnabled 返回是否向客户端输出 ANSI 颜色
+// 执行 terminal color 或 terminal no color 后按设置，否则按终端类型判断，没有报告终端类型时不输出颜色
+func (s *Session) ColorEnabled() bool {
+	switch s.colorMode.Load() {
+	case colorOn:
+		return true
+	case colorOff:
+		return false
+	}
+	return !monochromeTerminals[s.TerminalType()]
+}
+
+// stripColor 终端不显示颜色时去掉文本中的 ANSI 控制序列
+func (s *Session) stripColor(text string) string {
+	if s.ColorEnabled() {
+		return text
+	}
+	return color.Strip(text)
+}
+
+// terminalColor 开启本会话的颜色输出
+func (s *Session) terminalColor(args []string) string {
+	s.colorMode.Store(colorOn)
+	return ""
+}
+
+// terminalNoColor 关闭本会话的颜色输出
+func (s *Session) terminalNoColor(args []string) string {
+	s.colorMode.Store(colorOff)
+	return ""
+}
+
+// CompletionEnabled 返回本会话按 Tab 时是否补全，关闭时 Tab 作为空格输入
+func (s *Session) CompletionEnabled() bool {
+	return s.keyEnabled(&s.completionKey, func(config *types.Config) bool { return config.DisableCompletion })
+}
+
+// HelpKeyEnabled 返回本会话按 ? 时是否显示帮助，关闭时 ? 作为普通字符输入
+func (s *Session) HelpKeyEnabled() bool {
+	return s.keyEnabled(&s.helpKey, func(config *types.Config) bool { return config.DisableHelpKey })
+}
+
+// keyEnabled 按会话的设置判断按键是否生效，没有设置时按配置，disabled 返回配置是否关闭了该按键
+func (s *Session) keyEnabled(setting *atomic.Int32, disabled func(config *types.Config) bool) bool {
+	switch setting.Load() {
+	case keyOn:
+		return true
+	case keyOff:
+		return false
+	}
+	return !disabled(s.settings.Load())
+}
+
+// terminalAutocomplete 开启本会话的 Tab 补全
+func (s *Session) terminalAutocomplete(args []string) string {
+	s.completionKey.Store(keyOn)
+	return ""
+}
+
+// terminalNoAutocomplete 关闭本会话的 Tab 补全
+func (s *Session) terminalNoAutocomplete(args []string) string {
+	s.completionKey.Store(keyOff)
+	return ""
+}
+
+// terminalHelpKey 开启本会话的 ? 帮助
+func (s *Session) terminalHelpKey(args []string) string {
+	s.helpKey.Store(keyOn)
+	return ""
+}
+
+// terminalNoHelpKey 关闭本会话的 ? 帮助
+func (s *Session) terminalNoHelpKey(args []string) string {
+	s.helpKey.Store(keyOff)
+	return ""
+}
+
+// showTerminal 显示本会话的终端设置
+func (s *Session) showTerminal(args []string) string {
+	termType := s.TerminalType()
+	if termType == "" {
+		termType = "unknown"
+	}
+	width, height := s.TerminalSize()
+
+	var result strings.Builder
+	result.WriteString(fmt.Sprintf("Terminal type: %s\n", termType))
+	result.WriteString(fmt.Sprintf("Width: %d columns, Length: %d lines\n", width, height))
+	result.WriteString(fmt.Sprintf("Color: %s\n", onOff(s.ColorEnabled())))
+	result.WriteString(fmt.Sprintf("Monitor: %s\n", onOff(s.Monitoring())))
+	result.WriteString(fmt.Sprintf("Output: %s\n", s.OutputFormat()))
+	result.WriteString(fmt.Sprintf("Autocomplete: %s\n", onOff(s.CompletionEnabled())))
+	result.WriteString(fmt.Sprintf("Help key: %s\n", onOff(s.HelpKeyEnabled())))
+	return result.String()
+}
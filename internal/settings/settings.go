@@ -0,0 +1,38 @@
+// Package settings 保存 CmdLine 运行期间可以修改的配置：修改时复制并替换整个配置，
+// 会话和后台任务读取不会再被修改的快照，不需要持有命令注册表的锁
+package settings
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/TrailHuang/tnlcmd/pkg/types"
+)
+
+// Settings 一个 CmdLine 的当前配置
+type Settings struct {
+	mu      sync.Mutex // 使修改依次进行，见 Update
+	current atomic.Pointer[types.Config]
+}
+
+// New 以 config 的副本为初始配置，之后修改 config 不影响 Settings
+func New(config *types.Config) *Settings {
+	s := &Settings{}
+	copied := *config
+	s.current.Store(&copied)
+	return s
+}
+
+// Load 返回当前配置的快照，调用者不能修改
+func (s *Settings) Load() *types.Config {
+	return s.current.Load()
+}
+
+// Update 复制当前配置，由 fn 修改副本后替换；fn 中修改切片字段时需要创建新的切片
+func (s *Settings) Update(fn func(config *types.Config)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	copied := *s.current.Load()
+	fn(&copied)
+	s.current.Store(&copied)
+}
@@ -0,0 +1,495 @@
+// Package telnet 实现 telnet 协议命令解析和选项协商状态跟踪
+package telnet
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Telnet 协议命令字节
+const (
+	IAC  byte = 255 // Interpret As Command
+	DONT byte = 254 // 要求对端禁用选项
+	DO   byte = 253 // 要求对端启用选项
+	WONT byte = 252 // 拒绝/禁用本端选项
+	WILL byte = 251 // 同意/启用本端选项
+	SB   byte = 250 // 子协商开始
+	GA   byte = 249 // Go Ahead
+	EL   byte = 248 // Erase Line
+	EC   byte = 247 // Erase Character
+	AYT  byte = 246 // Are You There
+	AO   byte = 245 // Abort Output
+	IP   byte = 244 // Interrupt Process
+	BRK  byte = 243 // Break
+	DM   byte = 242 // Data Mark
+	NOP  byte = 241 // No Operation
+	SE   byte = 240 // 子协商结束
+)
+
+// Telnet 选项
+const (
+	OptEcho  byte = 1  // ECHO
+	OptSGA   byte = 3  // SUPPRESS-GO-AHEAD
+	OptTType byte = 24 // TERMINAL-TYPE，客户端报告终端类型
+	OptNAWS  byte = 31 // NAWS，客户端报告窗口大小
+)
+
+// TERMINAL-TYPE 子协商命令
+const (
+	TTypeIs   byte = 0 // 客户端报告终端类型
+	TTypeSend byte = 1 // 请求客户端报告终端类型
+)
+
+// maxLogEntries 协商日志保留的最大条数
+const maxLogEntries = 256
+
+var commandNames = map[byte]string{
+	DONT: "DONT", DO: "DO", WONT: "WONT", WILL: "WILL",
+	SB: "SB", GA: "GA", EL: "EL", EC: "EC", AYT: "AYT", AO: "AO",
+	IP: "IP", BRK: "BRK", DM: "DM", NOP: "NOP", SE: "SE",
+}
+
+var optionNames = map[byte]string{
+	0: "BINARY", OptEcho: "ECHO", OptSGA: "SGA", 5: "STATUS", 6: "TIMING-MARK",
+	OptTType: "TERMINAL-TYPE", OptNAWS: "NAWS", 32: "TERMINAL-SPEED", 33: "TOGGLE-FLOW-CONTROL",
+	34: "LINEMODE", 35: "X-DISPLAY-LOCATION", 36: "OLD-ENVIRON", 39: "NEW-ENVIRON",
+}
+
+// CommandName 返回命令字节的名称
+func CommandName(cmd byte) string {
+	if name, exists := commandNames[cmd]; exists {
+		return name
+	}
+	return fmt.Sprintf("CMD%d", cmd)
+}
+
+// OptionName 返回选项的名称
+func OptionName(opt byte) string {
+	if name, exists := optionNames[opt]; exists {
+		return name
+	}
+	return fmt.Sprintf("OPT%d", opt)
+}
+
+// LogEntry 一条协商记录
+type LogEntry struct {
+	Time   time.Time
+	Sent   bool // true 表示服务端发出，false 表示收到
+	Verb   byte
+	Option byte
+}
+
+// String 返回记录的可读形式，如 "SENT WILL ECHO"
+func (e LogEntry) String() string {
+	dir := "RCVD"
+	if e.Sent {
+		dir = "SENT"
+	}
+	return fmt.Sprintf("%s %s %s", dir, CommandName(e.Verb), OptionName(e.Option))
+}
+
+// Handler telnet 事件处理接口
+type Handler interface {
+	// HandleCommand 处理 IAC 单字节命令（AYT、IP、BRK 等）
+	HandleCommand(cmd byte)
+	// HandleNegotiation 处理选项协商（WILL/WONT/DO/DONT）
+	HandleNegotiation(verb, opt byte)
+	// HandleSubnegotiation 处理子协商数据
+	HandleSubnegotiation(opt byte, data []byte)
+}
+
+// 解析器状态
+const (
+	stateData = iota
+	stateIAC
+	stateVerb
+	stateSB
+	stateSBData
+	stateSBIAC
+	stateCR
+)
+
+// Parser telnet 输入流解析器，过滤协议字节并返回纯数据字节
+type Parser struct {
+	handler Handler
+	state   int
+	verb    byte
+	sbOpt   byte
+	sbData  []byte
+}
+
+// NewParser 创建新的解析器
+func NewParser(handler Handler) *Parser {
+	return &Parser{handler: handler}
+}
+
+// Feed 输入一个字节，如果该字节是数据则返回 (b, true)
+// CR LF 与 CR NUL 会被折叠为单个 CR
+func (p *Parser) Feed(b byte) (byte, bool) {
+	switch p.state {
+	case stateCR:
+		p.state = stateData
+		if b == 0x0A || b == 0x00 {
+			return 0, false
+		}
+		return p.Feed(b)
+	case stateIAC:
+		switch b {
+		case IAC:
+			p.state = stateData
+			return IAC, true
+		case WILL, WONT, DO, DONT:
+			p.verb = b
+			p.state = stateVerb
+		case SB:
+			p.state = stateSB
+		default:
+			p.state = stateData
+			if p.handler != nil {
+				p.handler.HandleCommand(b)
+			}
+		}
+		return 0, false
+	case stateVerb:
+		p.state = stateData
+		if p.handler != nil {
+			p.handler.HandleNegotiation(p.verb, b)
+		}
+		return 0, false
+	case stateSB:
+		p.sbOpt = b
+		p.sbData = p.sbData[:0]
+		p.state = stateSBData
+		return 0, false
+	case stateSBData:
+		if b == IAC {
+			p.state = stateSBIAC
+		} else {
+			p.sbData = append(p.sbData, b)
+		}
+		return 0, false
+	case stateSBIAC:
+		switch b {
+		case SE:
+			p.state = stateData
+			if p.handler != nil {
+				data := make([]byte, len(p.sbData))
+				copy(data, p.sbData)
+				p.handler.HandleSubnegotiation(p.sbOpt, data)
+			}
+		case IAC:
+			p.sbData = append(p.sbData, IAC)
+			p.state = stateSBData
+		default:
+			// 非法序列，丢弃子协商
+			p.state = stateData
+		}
+		return 0, false
+	}
+
+	switch b {
+	case IAC:
+		p.state = stateIAC
+		return 0, false
+	case 0x0D:
+		p.state = stateCR
+	}
+	return b, true
+}
+
+// optionState 单个选项的协商状态
+type optionState struct {
+	local         bool // 本端已启用（我们 WILL，对端 DO）
+	remote        bool // 对端已启用（对端 WILL，我们 DO）
+	localPending  bool
+	remotePending bool
+}
+
+// Result 一次协商的处理结果
+type Result struct {
+	Option  byte
+	Local   bool // true 表示本端选项，false 表示对端选项
+	Enabled bool // 协商后的状态
+	Refused bool // 对端拒绝了我们发起的请求
+	Changed bool // 状态是否发生变化
+}
+
+// Negotiator 选项协商状态机（RFC 854/1143 简化实现）
+type Negotiator struct {
+	mu            sync.Mutex
+	w             io.Writer
+	opts          map[byte]*optionState
+	supportLocal  map[byte]bool
+	supportRemote map[byte]bool
+	replied       bool
+	log           []LogEntry
+	traced        []LogEntry // 持有锁期间产生、尚未交给 Trace 的记录，见 unlock
+
+	// Trace 每条协商记录产生时调用，用于诊断
+	Trace func(entry LogEntry)
+}
+
+// NewNegotiator 创建新的协商器
+func NewNegotiator(w io.Writer) *Negotiator {
+	return &Negotiator{
+		w:             w,
+		opts:          make(map[byte]*optionState),
+		supportLocal:  make(map[byte]bool),
+		supportRemote: make(map[byte]bool),
+	}
+}
+
+// SupportLocal 声明本端可以启用的选项
+func (n *Negotiator) SupportLocal(opts ...byte) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for _, opt := range opts {
+		n.supportLocal[opt] = true
+	}
+}
+
+// SupportRemote 声明允许对端启用的选项
+func (n *Negotiator) SupportRemote(opts ...byte) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for _, opt := range opts {
+		n.supportRemote[opt] = true
+	}
+}
+
+// option 获取选项状态（调用者需持有锁）
+func (n *Negotiator) option(opt byte) *optionState {
+	st, exists := n.opts[opt]
+	if !exists {
+		st = &optionState{}
+		n.opts[opt] = st
+	}
+	return st
+}
+
+// send 发送协商命令（调用者需持有锁）
+func (n *Negotiator) send(verb, opt byte) {
+	n.w.Write([]byte{IAC, verb, opt})
+	n.record(true, verb, opt)
+}
+
+// record 记录一次协商（调用者需持有锁）
+func (n *Negotiator) record(sent bool, verb, opt byte) {
+	entry := LogEntry{Time: time.Now(), Sent: sent, Verb: verb, Option: opt}
+	if len(n.log) >= maxLogEntries {
+		n.log = n.log[1:]
+	}
+	n.log = append(n.log, entry)
+	if n.Trace != nil {
+		n.traced = append(n.traced, entry)
+	}
+}
+
+// unlock 释放锁，再将持有锁期间产生的记录交给 Trace；Trace 可能写连接或调用应用的代码，不能在持有锁时调用
+func (n *Negotiator) unlock() {
+	entries, trace := n.traced, n.Trace
+	n.traced = nil
+	n.mu.Unlock()
+	if trace == nil {
+		return
+	}
+	for _, entry := range entries {
+		trace(entry)
+	}
+}
+
+// Log 返回协商记录的副本
+func (n *Negotiator) Log() []LogEntry {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	result := make([]LogEntry, len(n.log))
+	copy(result, n.log)
+	return result
+}
+
+// OptionStatus 选项的当前协商状态
+type OptionStatus struct {
+	Option byte
+	Local  bool
+	Remote bool
+}
+
+// Status 返回所有出现过的选项状态，按选项值排序
+func (n *Negotiator) Status() []OptionStatus {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	var result []OptionStatus
+	for opt := 0; opt < 256; opt++ {
+		if st, exists := n.opts[byte(opt)]; exists {
+			result = append(result, OptionStatus{Option: byte(opt), Local: st.local, Remote: st.remote})
+		}
+	}
+	return result
+}
+
+// SetLocal 请求启用或禁用本端选项（WILL/WONT）
+func (n *Negotiator) SetLocal(opt byte, enable bool) {
+	n.mu.Lock()
+	defer n.unlock()
+
+	st := n.option(opt)
+	if enable == (st.local || st.localPending) {
+		return
+	}
+	if enable {
+		n.send(WILL, opt)
+		st.localPending = true
+	} else {
+		n.send(WONT, opt)
+		st.local = false
+		st.localPending = false
+	}
+}
+
+// SetRemote 请求对端启用或禁用选项（DO/DONT）
+func (n *Negotiator) SetRemote(opt byte, enable bool) {
+	n.mu.Lock()
+	defer n.unlock()
+
+	st := n.option(opt)
+	if enable == (st.remote || st.remotePending) {
+		return
+	}
+	if enable {
+		n.send(DO, opt)
+		st.remotePending = true
+	} else {
+		n.send(DONT, opt)
+		st.remote = false
+		st.remotePending = false
+	}
+}
+
+// Local 返回本端选项是否已启用
+func (n *Negotiator) Local(opt byte) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.option(opt).local
+}
+
+// Remote 返回对端选项是否已启用
+func (n *Negotiator) Remote(opt byte) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.option(opt).remote
+}
+
+// Replied 返回对端是否回应过任何协商
+func (n *Negotiator) Replied() bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.replied
+}
+
+// Subnegotiate 发送子协商 IAC SB opt data IAC SE，数据中的 IAC 自动转义
+func (n *Negotiator) Subnegotiate(opt byte, data []byte) {
+	n.mu.Lock()
+	defer n.unlock()
+
+	buf := []byte{IAC, SB, opt}
+	for _, b := range data {
+		buf = append(buf, b)
+		if b == IAC {
+			buf = append(buf, IAC)
+		}
+	}
+	buf = append(buf, IAC, SE)
+	n.w.Write(buf)
+	n.record(true, SB, opt)
+}
+
+// Receive 处理对端发来的协商命令并按需应答
+func (n *Negotiator) Receive(verb, opt byte) Result {
+	n.mu.Lock()
+	defer n.unlock()
+
+	n.replied = true
+	n.record(false, verb, opt)
+	st := n.option(opt)
+	res := Result{Option: opt}
+
+	switch verb {
+	case DO:
+		res.Local = true
+		if st.localPending {
+			st.localPending = false
+			st.local = true
+			res.Changed = true
+		} else if !st.local {
+			if n.supportLocal[opt] {
+				n.send(WILL, opt)
+				st.local = true
+				res.Changed = true
+			} else {
+				n.send(WONT, opt)
+			}
+		}
+		res.Enabled = st.local
+	case DONT:
+		res.Local = true
+		if st.localPending {
+			st.localPending = false
+			res.Refused = true
+		} else if st.local {
+			n.send(WONT, opt)
+			res.Changed = true
+		}
+		st.local = false
+		res.Enabled = false
+	case WILL:
+		if st.remotePending {
+			st.remotePending = false
+			st.remote = true
+			res.Changed = true
+		} else if !st.remote {
+			if n.supportRemote[opt] {
+				n.send(DO, opt)
+				st.remote = true
+				res.Changed = true
+			} else {
+				n.send(DONT, opt)
+			}
+		}
+		res.Enabled = st.remote
+	case WONT:
+		if st.remotePending {
+			st.remotePending = false
+			res.Refused = true
+		} else if st.remote {
+			n.send(DONT, opt)
+			res.Changed = true
+		}
+		st.remote = false
+		res.Enabled = false
+	}
+
+	return res
+}
+
+// ParseWindowSize 解析 NAWS 子协商数据中的窗口宽度和高度，数据无效或为 0 时 ok 为 false
+func ParseWindowSize(data []byte) (width, height int, ok bool) {
+	if len(data) != 4 {
+		return 0, 0, false
+	}
+	width = int(data[0])<<8 | int(data[1])
+	height = int(data[2])<<8 | int(data[3])
+	return width, height, width > 0 && height > 0
+}
+
+// ParseTerminalType 解析 TERMINAL-TYPE IS 子协商数据中的终端类型，如 XTERM，统一为大写
+func ParseTerminalType(data []byte) (string, bool) {
+	if len(data) < 2 || data[0] != TTypeIs {
+		return "", false
+	}
+	return strings.ToUpper(string(data[1:])), true
+}
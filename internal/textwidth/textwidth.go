@@ -0,0 +1,75 @@
+// Package textwidth 计算文本在终端中的显示宽度：中日韩文字等全角字符占两列，组合符号等零宽字符不占列
+package textwidth
+
+import (
+	"strings"
+	"unicode"
+)
+
+// wideRanges 东亚宽字符和全角字符的范围，按起点排序
+var wideRanges = [][2]rune{
+	{0x1100, 0x115F},   // 谚文字母
+	{0x2E80, 0x303E},   // 中日韩部首、符号和标点
+	{0x3041, 0x33FF},   // 平假名、片假名、注音等
+	{0x3400, 0x4DBF},   // 中日韩统一表意文字扩展 A
+	{0x4E00, 0x9FFF},   // 中日韩统一表意文字
+	{0xA000, 0xA4CF},   // 彝文
+	{0xAC00, 0xD7A3},   // 谚文音节
+	{0xF900, 0xFAFF},   // 中日韩兼容表意文字
+	{0xFE30, 0xFE4F},   // 中日韩兼容形式
+	{0xFF00, 0xFF60},   // 全角 ASCII
+	{0xFFE0, 0xFFE6},   // 全角符号
+	{0x1F300, 0x1F64F}, // 表情符号
+	{0x1F900, 0x1F9FF}, // 补充表情符号
+	{0x20000, 0x2FFFD}, // 中日韩统一表意文字扩展 B 及之后
+	{0x30000, 0x3FFFD},
+}
+
+// RuneWidth 返回字符的显示宽度：控制字符和零宽字符为 0，宽字符为 2，其余为 1
+func RuneWidth(r rune) int {
+	switch {
+	case r < 0x20 || (r >= 0x7F && r < 0xA0):
+		return 0
+	case unicode.Is(unicode.Mn, r) || unicode.Is(unicode.Me, r) || unicode.Is(unicode.Cf, r):
+		return 0
+	}
+	for _, wide := range wideRanges {
+		if r < wide[0] {
+			break
+		}
+		if r <= wide[1] {
+			return 2
+		}
+	}
+	return 1
+}
+
+// Width 返回文本的显示宽度
+func Width(text string) int {
+	width := 0
+	for _, r := range text {
+		width += RuneWidth(r)
+	}
+	return width
+}
+
+// Pad 在文本后补空格到 width 列，超过 width 时原样返回，相当于按显示宽度计算的 %-*s
+func Pad(text string, width int) string {
+	if n := Width(text); n < width {
+		return text + strings.Repeat(" ", width-n)
+	}
+	return text
+}
+
+// Truncate 返回文本不超过 width 列的最长前缀，不会截断宽字符，之后的组合符号一并保留
+func Truncate(text string, width int) string {
+	used := 0
+	for i, r := range text {
+		w := RuneWidth(r)
+		if used+w > width {
+			return text[:i]
+		}
+		used += w
+	}
+	return text
+}
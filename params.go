@@ -0,0 +1,31 @@
+package tnlcmd
+
+import (
+	"net"
+	"time"
+
+	"github.com/TrailHuang/tnlcmd/internal/commandtree"
+)
+
+// ParsePrefix 将 A.B.C.D/M 参数解析为网段
+// 命令树在调用处理函数前已经完成校验，处理函数可以直接使用返回值
+func ParsePrefix(arg string) (*net.IPNet, error) {
+	return commandtree.ParseIPv4Prefix(arg)
+}
+
+// ParseSize 将带单位后缀的容量（如 10k、512M、2G）解析为字节数，单位按 1024 进制换算
+// <size> 参数传给处理函数前已经换算为字节数，也可以直接用 strconv.ParseUint 解析
+func ParseSize(arg string) (uint64, error) {
+	return commandtree.ParseSize(arg)
+}
+
+// ParseDuration 将时长参数解析为 time.Duration
+// <duration> 参数传给处理函数前已经转换为 time.Duration 的标准格式（如 1h30m0s），也可以用 time.ParseDuration 解析
+func ParseDuration(arg string) (time.Duration, error) {
+	return commandtree.ParseDuration(arg)
+}
+
+// ParseHex 将十六进制参数（0x 前缀可选）解析为数值
+func ParseHex(arg string) (uint64, error) {
+	return commandtree.ParseHex(arg)
+}
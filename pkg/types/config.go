@@ -0,0 +1,51 @@
+package types
+
+import "time"
+
+// ConfigSection 视图渲染函数生成的一段配置
+type ConfigSection struct {
+	// Command 进入视图实例的命令，如 "interface eth0"，配置行缩进一格列在其后；
+	// 为空时配置行直接属于上一级，如全局配置视图中的 hostname
+	Command string
+
+	// Lines 在视图中执行的配置命令，按输出顺序排列
+	Lines []string
+}
+
+// ConfigRenderer 视图的配置渲染函数，返回视图对 show running-config 贡献的配置；
+// parents 为外层视图各级段的 Command，如 interface/sub-interface 视图的渲染函数对 interface 视图生成的每一段
+// 调用一次，parents 为 ["interface eth0"]，根视图的子视图 parents 为空
+type ConfigRenderer func(parents []string) []ConfigSection
+
+// ConfigChange 候选配置中的一条命令
+type ConfigChange struct {
+	Mode    string // 输入命令时所在视图的路径，如 configure/interface
+	Command string // 输入的命令，不包括输出过滤器
+	User    string // 输入命令的会话的登录用户名，没有认证时为空
+}
+
+// CommitFunc 提交候选配置时调用，changes 为候选配置中按输入顺序排列的命令；apply 依次执行这些命令的处理函数，
+// 遇到第一个错误时停止并返回该错误。应用在自己的事务中调用 apply，失败时撤销已经执行的修改并返回错误，
+// 使候选配置整体生效或整体不生效；返回错误时会话保留候选配置。多个会话的提交依次进行
+type CommitFunc func(changes []ConfigChange, apply func() error) error
+
+// ConfigLine 渲染得到的配置中的一行
+type ConfigLine struct {
+	Parents []string // 外层各级视图实例的进入命令，如 ["interface eth0"]，全局配置为空
+	Line    string   // 配置行，视图实例的进入命令本身也作为一行
+}
+
+// ConfigEvent 配置视图中的一条命令执行成功后发布的事件
+type ConfigEvent struct {
+	ConfigChange           // 执行的命令、所在视图和用户名
+	Time         time.Time // 命令执行完成的时间
+
+	// Old 和 New 为命令执行前后渲染得到的配置中被删除和新增的行，如 hostname r2 的
+	// Old 为 hostname r1，New 为 hostname r2；视图没有注册渲染函数或配置没有变化时为空
+	Old []ConfigLine
+	New []ConfigLine
+}
+
+// ConfigEventFunc 配置修改事件的订阅函数，在执行命令的会话协程中依次调用，应当尽快返回；
+// 开启候选配置时在提交成功之后对每条命令调用一次，提交失败时不调用
+type ConfigEventFunc func(event ConfigEvent)
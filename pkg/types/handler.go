@@ -0,0 +1,210 @@
+package types
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// 处理函数返回这些错误时，会话执行相应的动作而不是打印错误
+var (
+	ErrCloseSession = errors.New("close session")     // 关闭连接
+	ErrExitToRoot   = errors.New("exit to root mode") // 返回根视图
+	ErrLeaveMode    = errors.New("leave mode")        // 返回进入当前视图之前所在的视图
+)
+
+// 命令的执行状态，见 Session.LastStatus
+const (
+	StatusOK          = 0   // 执行成功
+	StatusError       = 1   // 处理函数返回了错误
+	StatusInvalid     = 2   // 命令无法识别、不完整或参数无效
+	StatusInterrupted = 130 // 被 Ctrl-C 中断
+)
+
+// Error 结构化的命令错误，会话打印 "% <Message>"，Hint 不为空时另起一行打印 "% Hint: <Hint>"；
+// Code 为命令的执行状态，记录在日志中并可以通过 Session.LastStatus 读取，为 0 时按 StatusError 处理
+//
+//	return &tnlcmd.Error{Code: 3, Message: "Interface eth9 does not exist", Hint: "Use 'show interfaces' to list interfaces"}
+type Error struct {
+	Code    int
+	Message string
+	Hint    string
+}
+
+// Error 返回错误信息
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("error %d", e.Code)
+	}
+	return e.Message
+}
+
+// StatusOf 返回处理函数的错误对应的执行状态
+func StatusOf(err error) int {
+	var cliErr *Error
+	switch {
+	case err == nil:
+		return StatusOK
+	case errors.As(err, &cliErr) && cliErr.Code != 0:
+		return cliErr.Code
+	case errors.Is(err, context.Canceled):
+		return StatusInterrupted
+	case errors.Is(err, ErrCloseSession), errors.Is(err, ErrExitToRoot), errors.Is(err, ErrLeaveMode):
+		return StatusOK
+	}
+	return StatusError
+}
+
+// 旧式处理函数返回的特殊标记，对应上面的错误
+const (
+	exitMarker         = "__EXIT__"
+	exitToRootMarker   = "__EXIT_TO_ROOT__"
+	exitToParentMarker = "__EXIT_TO_PARENT__"
+)
+
+// Ctx 命令的执行上下文
+type Ctx struct {
+	// Context 在客户端断开连接、用户按下 Ctrl-C、会话关闭或服务器停止时取消，耗时的命令应当在取消后尽快返回
+	Context context.Context
+
+	Args    []string          // 命令参数，已经过校验，按命令规格中的位置排列，省略的可选参数为空字符串
+	Params  map[string]string // 按名称索引的参数，见 Param
+	Writer  io.Writer         // 命令输出，其中的 \n 自动转换为 \r\n
+	Session Session           // 执行命令的会话
+	Mode    string            // 执行命令时所在视图的路径，根视图为空
+	Format  string            // 输出格式，OutputText 或 OutputJSON，见 DataHandler
+}
+
+// 命令的输出格式
+const (
+	OutputText = "text" // 供人阅读的文本
+	OutputJSON = "json" // JSON，用 | json 或 terminal output json 选择
+)
+
+// Param 返回名称为 name 的参数值，参数被省略时返回空字符串
+// 名称为命令规格中 <name:TOKEN> 指定的名称，如 "set debug <level:1-10>" 中的 "level"；
+// 未指定名称时为去掉尖括号的记号，如 "1-10"、"WORD"，紧跟在关键字之后的参数也可以用该关键字访问；
+// 之后没有参数的关键字返回关键字本身，可选关键字如 "show log [detail]" 中的 detail 是否输入可以用 Param("detail") != "" 判断。
+// Args 按命令规格中的位置排列，与输入顺序无关，省略的可选参数为空字符串
+func (c *Ctx) Param(name string) string {
+	return c.Params[name]
+}
+
+// ReadPassword 显示 prompt 并读取用户输入的一行，输入的字符不回显，用于读取口令；
+// 错误与 Session.ReadLine 相同
+func (c *Ctx) ReadPassword(prompt string) (string, error) {
+	c.Session.SetInputHidden(true)
+	defer c.Session.SetInputHidden(false)
+	return c.Session.ReadLine(prompt)
+}
+
+// Confirm 显示 prompt 并读取用户的回答，回答 y 或 yes（不区分大小写）时返回 true，
+// 直接回车、其他回答、按下 Ctrl-C 或连接断开时返回 false，如：
+//
+//	if !ctx.Confirm("Reload device? [y/N] ") {
+//		return nil
+//	}
+func (c *Ctx) Confirm(prompt string) bool {
+	answer, err := c.Session.ReadLine(prompt)
+	if err != nil {
+		return false
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	}
+	return false
+}
+
+// ReadUntil 以 prompt 为提示逐行读取输入，直到输入只包含 delimiter 的一行，返回之前的各行，
+// 用于 banner 等多行输入；各行可以像命令行一样编辑，上下键浏览本次已输入的行。
+// 读取被 Ctrl-C 中断或连接断开时返回已读取的行和错误
+func (c *Ctx) ReadUntil(prompt, delimiter string) ([]string, error) {
+	var lines []string
+	for {
+		line, err := c.Session.ReadLine(prompt)
+		if err != nil {
+			return lines, err
+		}
+		if strings.TrimSpace(line) == delimiter {
+			return lines, nil
+		}
+		lines = append(lines, line)
+	}
+}
+
+// Handler 命令处理接口，命令树中的可执行节点都通过它执行
+type Handler interface {
+	Run(ctx *Ctx) error
+}
+
+// HandlerFunc 标准的命令处理函数，返回的错误以 "% <错误>" 的形式打印给用户
+type HandlerFunc func(ctx *Ctx) error
+
+// Run 调用处理函数
+func (f HandlerFunc) Run(ctx *Ctx) error {
+	return f(ctx)
+}
+
+// WriterHandler 直接写输出的处理函数
+type WriterHandler func(args []string, w io.Writer) error
+
+// Run 调用处理函数
+func (f WriterHandler) Run(ctx *Ctx) error {
+	return f(ctx.Args, ctx.Writer)
+}
+
+// DataFunc 返回结构化结果的处理函数，结果应当可以用 encoding/json 编码
+type DataFunc func(ctx *Ctx) (interface{}, error)
+
+// DataHandler 同时支持文本和 JSON 输出的命令：Data 返回结构化的结果，
+// 输出格式为 OutputJSON 时会话将结果编码为 JSON，否则按 Template 渲染，没有模板时调用 Text 格式化为文本
+type DataHandler struct {
+	Data     DataFunc
+	Text     func(w io.Writer, data interface{}) error
+	Template string // text/template 模板，以 Data 的结果为数据，可以用 SetTemplate 在运行时替换
+}
+
+// Run 调用 Data 并按 ctx.Format 输出结果
+func (h DataHandler) Run(ctx *Ctx) error {
+	data, err := h.Data(ctx)
+	if err != nil {
+		return err
+	}
+	switch {
+	case ctx.Format != OutputJSON && h.Template != "":
+		tmpl, err := ParseTemplate(h.Template)
+		if err != nil {
+			return err
+		}
+		return tmpl.Execute(ctx.Writer, data)
+	case ctx.Format != OutputJSON && h.Text != nil:
+		return h.Text(ctx.Writer, data)
+	}
+	encoded, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = ctx.Writer.Write(append(encoded, '\n'))
+	return err
+}
+
+// Run 调用处理函数并将返回的文本写到 ctx.Writer，特殊标记转换为对应的错误
+func (f CommandHandler) Run(ctx *Ctx) error {
+	result := f(ctx.Args)
+	switch result {
+	case "":
+		return nil
+	case exitMarker:
+		return ErrCloseSession
+	case exitToRootMarker:
+		return ErrExitToRoot
+	case exitToParentMarker:
+		return ErrLeaveMode
+	}
+	_, err := io.WriteString(ctx.Writer, result)
+	return err
+}
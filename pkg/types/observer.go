@@ -0,0 +1,55 @@
+package types
+
+import "time"
+
+// Observer 接收会话的事件，用于统计、审计和同步应用自己的界面；方法在产生事件的会话协程中依次调用，
+// 调用时不持有命令行的锁，可以调用 CmdLine 的方法，但应当尽快返回。只关心部分事件时嵌入 NopObserver
+type Observer interface {
+	// SessionOpened 会话开始处理输入，SessionClosed 会话结束，连接已经关闭
+	SessionOpened(session Session)
+	SessionClosed(session Session)
+
+	// CommandExecuted 一条命令执行结束，包括无法识别的输入和脚本中的命令
+	CommandExecuted(event CommandEvent)
+
+	// ModeChanged 会话的当前视图改变，命令执行期间的视图切换在命令结束后依次发送
+	ModeChanged(event ModeEvent)
+
+	// CompletionRequested 用户按 Tab 补全或按 ? 查看帮助
+	CompletionRequested(event CompletionEvent)
+}
+
+// CommandEvent 执行结束的一条命令
+type CommandEvent struct {
+	Session  Session
+	Line     string        // 输入的命令行，包括输出过滤器，!N 等为展开后的命令
+	Mode     string        // 执行命令时所在视图的路径，如 configure/interface
+	Command  string        // 匹配到的命令规格，如 "show interface WORD"；无法匹配时为空
+	Status   int           // 执行状态，见 StatusOK
+	Start    time.Time     // 开始执行的时间
+	Duration time.Duration // 执行所用的时间
+}
+
+// ModeEvent 会话的一次视图切换
+type ModeEvent struct {
+	Session Session
+	From    string // 切换之前所在视图的路径，根视图为空
+	To      string // 切换之后所在视图的路径
+}
+
+// CompletionEvent 一次补全或帮助请求
+type CompletionEvent struct {
+	Session    Session
+	Input      string   // 行首到光标所在记号末尾的输入
+	Help       bool     // 按 ? 查看帮助，否则为 Tab 补全
+	Candidates []string // 补全或显示的候选项，没有候选项时为空
+}
+
+// NopObserver 不处理任何事件的 Observer，嵌入后只实现关心的方法
+type NopObserver struct{}
+
+func (NopObserver) SessionOpened(Session)               {}
+func (NopObserver) SessionClosed(Session)               {}
+func (NopObserver) CommandExecuted(CommandEvent)        {}
+func (NopObserver) ModeChanged(ModeEvent)               {}
+func (NopObserver) CompletionRequested(CompletionEvent) {}
@@ -0,0 +1,22 @@
+package types
+
+// ScriptOptions 批量执行命令脚本的选项
+type ScriptOptions struct {
+	// ContinueOnError 为 true 时命令失败后继续执行之后的行，否则在第一条失败的命令处停止
+	ContinueOnError bool
+}
+
+// ScriptError 脚本中执行失败的一行
+type ScriptError struct {
+	Line    int    // 行号，从 1 开始
+	Mode    string // 执行时所在视图的路径，根视图为空
+	Command string // 该行的命令
+	Status  int    // 执行状态，见 StatusOK
+}
+
+// ScriptResult 脚本的执行结果
+type ScriptResult struct {
+	Executed int           // 执行的命令数，不包括空行和注释
+	Failed   []ScriptError // 执行失败的行
+	Stopped  bool          // 因命令失败或 exit 在脚本结束之前停止
+}
@@ -0,0 +1,30 @@
+package types
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/TrailHuang/tnlcmd/internal/textwidth"
+)
+
+// templateFuncs 输出模板中可以使用的函数
+var templateFuncs = template.FuncMap{
+	// pad 将值格式化后用空格补齐到 width 列，用于对齐列，如 {{pad 10 .Name}}
+	"pad": func(width int, value interface{}) string {
+		return textwidth.Pad(fmt.Sprint(value), width)
+	},
+	"join":  strings.Join,
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+}
+
+// ParseTemplate 解析 DataHandler 的输出模板，模板中除了 text/template 的内置函数，
+// 还可以使用 pad、join、upper 和 lower
+func ParseTemplate(text string) (*template.Template, error) {
+	tmpl, err := template.New("output").Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid output template: %v", err)
+	}
+	return tmpl, nil
+}
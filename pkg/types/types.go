@@ -1,14 +1,22 @@
 // Package types 定义 TNLCMD 库的公共类型
 package types
 
-// CommandHandler 命令处理函数类型
+import "time"
+
+// CommandHandler 返回文本输出的命令处理函数，实现了 Handler
 type CommandHandler func(args []string) string
 
+// NegatableHandler 可否定命令的处理函数，通过 "no" 前缀执行时 negate 为 true
+type NegatableHandler func(args []string, negate bool) string
+
+// ValueProvider 参数取值提供者，返回参数当前的合法取值（如已存在的接口名）
+type ValueProvider func() []string
+
 // CommandInfo 命令信息
 type CommandInfo struct {
 	Name        string
 	Description string
-	Handler     CommandHandler
+	Handler     Handler
 }
 
 // CommandNodeType 命令节点类型
@@ -22,8 +30,41 @@ const (
 	NodeTypeString                            // 字符串参数节点（大写字母）
 	NodeTypeModeSwitch                        // 视图切换节点
 	NodeTypeExit                              // 退出节点
+	NodeTypeIPv4                              // IPv4 地址参数节点 A.B.C.D
+	NodeTypeIPv4Prefix                        // IPv4 前缀参数节点 A.B.C.D/M
+	NodeTypeHex                               // 十六进制范围节点 <0x0-0xFFFF>
+	NodeTypeLine                              // 行尾文本参数节点 LINE，消耗剩余全部输入
+	NodeTypeSize                              // 容量参数节点 <size>，如 10k、512M、2G
+	NodeTypeDuration                          // 时长参数节点 <duration>，如 30s、5m、1h30m
+	NodeTypePath                              // 文件路径参数节点 PATH，在配置的根目录内补全
 )
 
+// NodeInfo 命令树节点的只读描述，遍历命令树时传给回调函数
+type NodeInfo struct {
+	Mode        string          // 节点所在视图的路径，如 configure/interface，根视图为空
+	Path        string          // 从根到该节点的命令路径，如 "set debug <1-10>"
+	Name        string          // 节点记号，如 "debug"、"<1-10>"
+	Depth       int             // 节点深度，第一个记号为 1
+	Type        CommandNodeType // 节点类型
+	TypeName    string          // 类型名称，如 "Command"、"Range"
+	Description string          // 描述，叶子节点为命令描述
+	Help        string          // 记号帮助
+
+	EnumValues []string          // 枚举参数的取值
+	EnumHelp   map[string]string // 枚举值的说明，没有说明时为 nil
+	RangeMin   int64             // 十进制范围参数的下限
+	RangeMax   int64             // 十进制范围参数的上限
+	Unsigned   bool              // 范围超出 int64 或为十六进制范围时为 true，此时使用 URangeMin/URangeMax
+	URangeMin  uint64
+	URangeMax  uint64
+
+	Repeat      bool    // 可重复参数
+	Hidden      bool    // 隐藏命令
+	Deprecated  bool    // 废弃命令
+	Replacement string  // 废弃命令的替代命令
+	Handler     Handler // 处理函数，节点可以执行时非 nil
+}
+
 // Config 命令行配置
 type Config struct {
 	Prompt     string
@@ -31,4 +72,192 @@ type Config struct {
 	WelcomeMsg string
 	MaxHistory int
 	RootMode   interface{} // 使用 interface{} 避免循环导入
+
+	// StrictRegistration 为 true 时拒绝注册与已有命令冲突的命令（如同一位置范围重叠的参数），
+	// 否则冲突只打印警告
+	StrictRegistration bool
+
+	// FileRoot PATH 参数的根目录，Tab 补全列出该目录下的文件，路径不能越过该目录；
+	// 命令输出重定向（| redirect、> FILE）也只能写到该目录之下。为空时 PATH 参数按普通字符串处理，不能重定向输出
+	FileRoot string
+
+	// PromptTemplate 提示符模板，使用 text/template 语法，如 "{{.Hostname}}{{.ModeSuffix}}"；
+	// 每次显示提示符时按会话当前的视图求值，可用的变量见 PromptData。为空时使用各视图固定的提示符
+	PromptTemplate string
+
+	// PromptFunc 每次显示提示符时调用，返回会话的提示符，优先于 PromptTemplate；
+	// 返回空字符串时按 PromptTemplate 或视图固定的提示符显示
+	PromptFunc PromptFunc
+
+	// NotFound 输入无法匹配当前视图的任何命令时调用，不完整的命令和有歧义的缩写不调用；
+	// 可以将未知的词当作主机名执行 ping、给出拼写建议等
+	NotFound NotFoundFunc
+
+	// Hostname 提示符模板中的主机名，为空时取 Prompt 去掉末尾 > 和 # 后的部分；
+	// 设置了主机名而 PromptTemplate 为空时，提示符为主机名加视图后缀，如 router1(configure)#
+	Hostname string
+
+	// FuzzyCompletion 为 true 时，Tab 没有普通的补全结果时按子序列模糊匹配命令，
+	// 如 shrc 补全为 show running-config，适用于命令很多的命令行
+	FuzzyCompletion bool
+
+	// DisableCompletion 为 true 时 Tab 不补全而作为空格输入，DisableHelpKey 为 true 时 ? 不显示帮助而作为普通字符输入，
+	// 适用于按原样发送这些字符的脚本客户端；会话可以用 terminal autocomplete、terminal help-key 等命令单独设置
+	DisableCompletion bool
+	DisableHelpKey    bool
+
+	// AutoCorrect 为 true 时，无法识别的输入只有一条拼写建议则直接执行建议的命令，
+	// 否则只提示 Did you mean
+	AutoCorrect bool
+
+	// SharedHistory 为 true 时同一用户名的所有会话共用一份命令历史，一个会话输入的命令在其他会话中
+	// 也可以用上下键、show history 和 !N 调出；没有用户名的会话仍然各自保存
+	SharedHistory bool
+
+	// HistoryExclude 正则表达式，与其中任何一个匹配的输入行不记入命令历史，如 "(?i)password"，
+	// 也不能用上下键和 !N 重新调出；处理函数用 ReadLine 读取的行同样适用
+	HistoryExclude []string
+
+	// InitialHistory 每个会话开始时已有的命令历史，最早的在前，如常用的命令；
+	// 开启 SharedHistory 时用于每个用户第一次创建的共用历史
+	InitialHistory []string
+
+	// StartupConfig 启动配置文件的路径，write memory 和 copy running-config startup-config 将 show running-config
+	// 的内容保存到该文件，show startup-config 显示其内容；为空时不能保存配置
+	StartupConfig string
+
+	// AutoSaveInterval 大于 0 时每隔该时长将当前配置保存到 StartupConfig，与文件内容相同时不写入
+	AutoSaveInterval time.Duration
+
+	// AutoSaveDelay 大于 0 时配置视图中的命令执行成功后（见 ConfigEvent）等待该时长，期间没有新的修改则保存到 StartupConfig
+	AutoSaveDelay time.Duration
+
+	// AutoSaveJitter 自动保存每次等待时增加 0 到该时长之间的随机时长，避免多台设备同时保存；
+	// 自动保存的设置在 Start 时读取，保存失败时记录日志
+	AutoSaveJitter time.Duration
+
+	// CandidateConfig 为 true 时配置视图中的命令不立即执行，而是记入会话的候选配置，
+	// commit 时一起执行，abort 或 exit discard 丢弃；全局命令和标记为不修改配置的命令仍然直接执行
+	CandidateConfig bool
+
+	// Commit 提交候选配置时调用，为 nil 时直接依次执行候选配置中的命令，见 CommitFunc
+	Commit CommitFunc
+
+	// ArchiveSize 保存的历史配置数，每次提交（commit、导入配置）之前保存当时的配置，rollback N 恢复到第 N 次之前的提交所替换的配置；
+	// 最多 50，为 0 时不保存
+	ArchiveSize int
+
+	// TelnetLogger 记录每个会话的 telnet 选项协商过程，用于诊断客户端问题
+	TelnetLogger func(remoteAddr string, event string)
+
+	// Observer 接收会话的打开和关闭、命令执行、视图切换和补全请求等事件，为 nil 时不发送
+	Observer Observer
+}
+
+// PromptData 提示符模板可以使用的变量
+type PromptData struct {
+	Hostname   string // 主机名
+	Username   string // 会话的登录用户名，没有认证时为空
+	ModePath   string // 当前视图的路径，如 configure/interface，根视图为空
+	ModeSuffix string // 视图后缀，根视图为 "> "，其他视图如 "(configure-interface)# "
+	Privilege  string // 特权标记，根视图为 ">"，配置视图为 "#"
+	Status     int    // 上一条命令的执行状态，见 Session.LastStatus
+}
+
+// HistoryEntry 会话命令历史中的一条记录
+type HistoryEntry struct {
+	Number   int           // 编号，从 1 开始，丢弃和清除的记录不重新编号
+	Command  string        // 输入的命令
+	Time     time.Time     // 开始执行的时间
+	Duration time.Duration // 执行所用的时间
+	Status   int           // 执行状态，见 Session.LastStatus
+	Done     bool          // 是否已经执行完成，正在执行的命令为 false
+}
+
+// Session 会话的信息和交互接口，供处理函数、提示符回调等应用代码使用
+type Session interface {
+	ID() int              // 会话编号，按创建顺序从 1 开始分配，在进程内不重复
+	RemoteAddr() string   // 客户端地址
+	Username() string     // 登录用户名，没有认证时为空
+	CurrentMode() string  // 当前视图的路径，如 configure/interface，根视图为空
+	Prompt() string       // 最近一次显示的命令提示符，如 "router(configure)# "
+	LoginTime() time.Time // 连接建立的时间
+
+	// Write 向会话显示一条消息，如应用主动推送的通知：会话等待输入时显示在正在编辑的输入行之前，
+	// 命令执行期间在命令结束后显示，不受 terminal monitor 影响。可以在任意协程中调用，不会阻塞；
+	// 会话已经关闭或等待显示的消息过多时返回错误。处理函数输出命令结果应当写到 Ctx.Writer
+	Write(p []byte) (int, error)
+
+	// Close 关闭会话并断开连接，可以在任意协程中调用，包括本会话的处理函数
+	Close()
+
+	// SetUsername 设置登录用户名，应用在处理函数中完成认证（如 login 命令）后调用；
+	// 开启了 Config.SharedHistory 时会话随之改用该用户共用的命令历史
+	SetUsername(username string)
+
+	// TerminalSize 返回客户端终端的宽度和高度，客户端通过 telnet NAWS 选项报告，窗口改变时更新；
+	// 客户端没有报告时为 80x24
+	TerminalSize() (width, height int)
+
+	// TerminalType 返回客户端通过 telnet TERMINAL-TYPE 选项报告的终端类型（大写，如 XTERM），没有报告时为空；
+	// ColorEnabled 返回是否向客户端输出颜色，不输出时会话自动去掉处理函数输出中的 ANSI 控制序列
+	TerminalType() string
+	ColorEnabled() bool
+
+	// ModeValue 返回当前视图实例中保存的数据，SetModeValue 保存数据，value 为 nil 时删除；
+	// 数据属于本会话进入视图的这一次，离开视图（quit 或切换到根视图）时丢弃，进入下一级视图再返回时保留
+	ModeValue(key string) (interface{}, bool)
+	SetModeValue(key string, value interface{})
+
+	// ReadLine 在处理函数中显示 prompt 并读取用户输入的一行，只能在处理函数执行期间调用；
+	// 用户按下 Ctrl-C 时返回 context.Canceled，按下 Ctrl-D 或连接断开时返回 io.EOF
+	ReadLine(prompt string) (string, error)
+
+	// SetEcho 开启或关闭服务端回显，通过 telnet ECHO 选项与客户端协商，关闭后由客户端本地回显；
+	// EchoEnabled 返回协商后服务端回显是否生效。SetInputHidden 隐藏或恢复输入的回显，
+	// 隐藏期间客户端和服务端都不回显字符，用于读取口令，见 Ctx.ReadPassword
+	SetEcho(on bool)
+	EchoEnabled() bool
+	SetInputHidden(hidden bool)
+
+	// StartJob 在后台运行 handler 并返回任务编号，用于 monitor start 之类的长时间任务；
+	// 输出缓存在会话的任务表中，用 show jobs <id> 查看或 attach job <id> 实时显示，
+	// 执行 kill job <id> 或会话结束时取消 handler 的 Context。后台任务不能读取输入
+	StartJob(command string, handler HandlerFunc) int
+
+	// History 返回会话的命令历史，最早的在前；AddHistory 将命令追加到历史中，如预先放入常用的命令，
+	// 追加的命令按已经成功执行记录；ClearHistory 清除历史。开启 Config.SharedHistory 时操作的是用户共用的历史
+	History() []HistoryEntry
+	AddHistory(commands ...string)
+	ClearHistory()
+
+	// LastStatus 返回上一条命令的执行状态，类似 shell 的 $?：成功为 StatusOK，
+	// 处理函数返回 *Error 时为其 Code，其他错误为 StatusError，命令无效为 StatusInvalid
+	LastStatus() int
 }
+
+// StreamOptions 在任意 io.ReadWriter 上运行会话的方式，如 SSH 通道、网页终端的 WebSocket 或聊天机器人的消息流
+type StreamOptions struct {
+	// RemoteAddr 会话信息和日志中显示的客户端地址
+	RemoteAddr string
+
+	// Telnet 为 true 时数据流是 telnet 协议，与 TCP 连接一样协商选项，以下的终端设置由协商得到；
+	// 否则数据流中没有 telnet 协议字节
+	Telnet bool
+
+	// LineMode 为 true 时对端自己编辑和回显，每次发送一整行，如聊天机器人；
+	// 否则逐个字符读取，由会话回显和处理编辑键，如 SSH 终端和网页终端
+	LineMode bool
+
+	// Width 和 Height 终端窗口大小，为 0 时按 80x24；TerminalType 终端类型，如 xterm，为空时不输出颜色
+	Width        int
+	Height       int
+	TerminalType string
+}
+
+// PromptFunc 动态提示符回调，根据会话状态计算提示符
+type PromptFunc func(sess Session) string
+
+// NotFoundFunc 输入无法匹配任何命令时的回调，line 为用户输入的原始行，ctx.Args 为按空白拆分的各个词；
+// 返回 true 表示已经处理了该行，返回的错误按处理函数的错误打印，返回 false 时打印无法识别的提示
+type NotFoundFunc func(ctx *Ctx, line string) (bool, error)
@@ -0,0 +1,152 @@
+// Package table 将处理函数的输出排列为带表头的对齐的列，列宽按内容计算并适应客户端终端的宽度
+package table
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/TrailHuang/tnlcmd/color"
+	"github.com/TrailHuang/tnlcmd/internal/textwidth"
+	"github.com/TrailHuang/tnlcmd/pkg/types"
+)
+
+const (
+	columnGap = 2 // 列之间的空格数
+	minWidth  = 4 // 适应终端宽度时列宽最少缩减到的宽度
+	ellipsis  = "..."
+)
+
+// Table 表格，用 New 创建，AddRow 添加行，Print 或 Render 输出
+//
+//	t := table.New("Interface", "Status", "Description")
+//	t.AddRow("eth0", "up", "uplink to core")
+//	return t.Print(ctx)
+type Table struct {
+	headers  []string
+	rows     [][]string
+	maxWidth map[int]int
+}
+
+// New 创建以 headers 为表头的表格
+func New(headers ...string) *Table {
+	return &Table{headers: headers, maxWidth: make(map[int]int)}
+}
+
+// AddRow 添加一行，单元格按 fmt.Sprint 格式化；单元格少于列数时其余为空，多于列数时忽略
+func (t *Table) AddRow(cells ...interface{}) {
+	row := make([]string, len(t.headers))
+	for i := range row {
+		if i < len(cells) {
+			// 单元格中的换行会打乱对齐，替换为空格
+			row[i] = strings.NewReplacer("\r", " ", "\n", " ").Replace(fmt.Sprint(cells[i]))
+		}
+	}
+	t.rows = append(t.rows, row)
+}
+
+// SetMaxWidth 设置第 column 列（从 0 开始）的最大宽度，超出的内容截断并以 ... 结尾
+func (t *Table) SetMaxWidth(column, width int) {
+	t.maxWidth[column] = width
+}
+
+// Print 按执行命令的会话的终端宽度输出到 ctx.Writer
+func (t *Table) Print(ctx *types.Ctx) error {
+	width := 0
+	if ctx.Session != nil {
+		width, _ = ctx.Session.TerminalSize()
+	}
+	return t.Render(ctx.Writer, width)
+}
+
+// Render 输出表格，总宽度超过 width 时从最宽的列开始缩减；width 为 0 时不限制宽度
+func (t *Table) Render(w io.Writer, width int) error {
+	widths := t.columnWidths(width)
+
+	var b strings.Builder
+	t.writeRow(&b, t.headers, widths)
+	separators := make([]string, len(widths))
+	for i, n := range widths {
+		separators[i] = strings.Repeat("-", n)
+	}
+	t.writeRow(&b, separators, widths)
+	for _, row := range t.rows {
+		t.writeRow(&b, row, widths)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// columnWidths 计算各列的宽度
+func (t *Table) columnWidths(limit int) []int {
+	widths := make([]int, len(t.headers))
+	for i, header := range t.headers {
+		widths[i] = color.Len(header)
+	}
+	for _, row := range t.rows {
+		for i, cell := range row {
+			if n := color.Len(cell); n > widths[i] {
+				widths[i] = n
+			}
+		}
+	}
+	for i := range widths {
+		if m, exists := t.maxWidth[i]; exists && m > 0 && widths[i] > m {
+			widths[i] = m
+		}
+	}
+
+	if limit <= 0 {
+		return widths
+	}
+	// 终端最后一列写满时会自动换行，总宽度比终端少一列
+	for total(widths) > limit-1 {
+		widest := 0
+		for i, n := range widths {
+			if n > widths[widest] {
+				widest = i
+			}
+		}
+		if widths[widest] <= minWidth {
+			break
+		}
+		widths[widest]--
+	}
+	return widths
+}
+
+// total 返回表格的总宽度
+func total(widths []int) int {
+	sum := 0
+	for _, n := range widths {
+		sum += n
+	}
+	if len(widths) > 1 {
+		sum += columnGap * (len(widths) - 1)
+	}
+	return sum
+}
+
+// writeRow 输出一行，最后一列不补空格
+func (t *Table) writeRow(b *strings.Builder, cells []string, widths []int) {
+	for i, cell := range cells {
+		cell = truncate(cell, widths[i])
+		b.WriteString(cell)
+		if i < len(cells)-1 {
+			b.WriteString(strings.Repeat(" ", widths[i]-color.Len(cell)+columnGap))
+		}
+	}
+	b.WriteString("\n")
+}
+
+// truncate 将单元格截断到 width 列，截断时以 ... 结尾并去掉颜色
+func truncate(cell string, width int) string {
+	if color.Len(cell) <= width {
+		return cell
+	}
+	plain := color.Strip(cell)
+	if width <= len(ellipsis) {
+		return textwidth.Truncate(plain, width)
+	}
+	return textwidth.Truncate(plain, width-len(ellipsis)) + ellipsis
+}
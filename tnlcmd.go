@@ -3,26 +3,151 @@
 package tnlcmd
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"log"
-	"strings"
+	"net"
 
 	"github.com/TrailHuang/tnlcmd/internal/cmdline"
+	"github.com/TrailHuang/tnlcmd/internal/commandtree"
 	"github.com/TrailHuang/tnlcmd/pkg/types"
 )
 
-// CommandHandler 命令处理函数类型
+// Ctx 命令的执行上下文，包括参数、输出、会话和所在视图
+type Ctx = types.Ctx
+
+// Handler 命令处理接口，HandlerFunc、CommandHandler、WriterHandler 和 DataHandler 都实现了它
+type Handler = types.Handler
+
+// HandlerFunc 标准的命令处理函数，返回的错误以 "% <错误>" 的形式打印给用户
+type HandlerFunc = types.HandlerFunc
+
+// CommandHandler 返回文本输出的命令处理函数
 type CommandHandler = types.CommandHandler
 
+// WriterHandler 直接写输出的处理函数，可以通过 Adapt 注册
+type WriterHandler = types.WriterHandler
+
+// DataHandler 同时支持文本和 JSON 输出的命令，Data 返回结构化的结果，Template 或 Text 将结果格式化为文本
+type DataHandler = types.DataHandler
+
+// DataFunc 返回结构化结果的处理函数
+type DataFunc = types.DataFunc
+
+// 命令的输出格式，见 Ctx.Format
+const (
+	OutputText = types.OutputText
+	OutputJSON = types.OutputJSON
+)
+
 // Config 命令行配置
 type Config = types.Config
 
+// NegatableHandler 可否定命令的处理函数，通过 "no" 前缀执行时 negate 为 true
+type NegatableHandler = types.NegatableHandler
+
+// ValueProvider 参数取值提供者
+type ValueProvider = types.ValueProvider
+
+// PromptData 提示符模板可以使用的变量
+type PromptData = types.PromptData
+
+// HistoryEntry 会话命令历史中的一条记录
+type HistoryEntry = types.HistoryEntry
+
+// ConfigSection 视图渲染函数生成的一段配置
+type ConfigSection = types.ConfigSection
+
+// ConfigRenderer 视图的配置渲染函数，见 RegisterConfigRenderer
+type ConfigRenderer = types.ConfigRenderer
+
+// ConfigChange 候选配置中的一条命令
+type ConfigChange = types.ConfigChange
+
+// CommitFunc 提交候选配置时的回调，见 SetCommitFunc
+type CommitFunc = types.CommitFunc
+
+// ConfigEvent 配置修改事件，见 SubscribeConfigChanges
+type ConfigEvent = types.ConfigEvent
+
+// ConfigEventFunc 配置修改事件的订阅函数
+type ConfigEventFunc = types.ConfigEventFunc
+
+// ConfigLine 配置修改事件中变化的一行配置
+type ConfigLine = types.ConfigLine
+
+// ScriptOptions 批量执行命令脚本的选项，见 RunScript
+type ScriptOptions = types.ScriptOptions
+
+// ScriptResult 命令脚本的执行结果
+type ScriptResult = types.ScriptResult
+
+// ScriptError 命令脚本中执行失败的一行
+type ScriptError = types.ScriptError
+
+// StreamOptions 在任意数据流上运行会话的方式，见 ServeStream
+type StreamOptions = types.StreamOptions
+
+// Session 会话的只读信息
+type Session = types.Session
+
+// PromptFunc 动态提示符回调
+type PromptFunc = types.PromptFunc
+
+// NotFoundFunc 输入无法匹配命令时的回调
+type NotFoundFunc = types.NotFoundFunc
+
+// Observer 接收会话事件的接口，见 SetObserver
+type Observer = types.Observer
+
+// NopObserver 不处理任何事件的 Observer，嵌入后只实现关心的方法
+type NopObserver = types.NopObserver
+
+// CommandEvent 执行结束的一条命令
+type CommandEvent = types.CommandEvent
+
+// ModeEvent 会话的一次视图切换
+type ModeEvent = types.ModeEvent
+
+// CompletionEvent 一次补全或帮助请求
+type CompletionEvent = types.CompletionEvent
+
+// NodeInfo 命令树节点的只读描述
+type NodeInfo = types.NodeInfo
+
+// 处理函数返回这些错误时，会话关闭连接、返回根视图或返回进入当前视图之前所在的视图
+var (
+	ErrCloseSession = types.ErrCloseSession
+	ErrExitToRoot   = types.ErrExitToRoot
+	ErrLeaveMode    = types.ErrLeaveMode
+)
+
+// Error 结构化的命令错误，会话打印 "% <Message>" 和提示，Code 作为命令的执行状态
+type Error = types.Error
+
+// 命令的执行状态，见 Session.LastStatus
+const (
+	StatusOK          = types.StatusOK
+	StatusError       = types.StatusError
+	StatusInvalid     = types.StatusInvalid
+	StatusInterrupted = types.StatusInterrupted
+)
+
+// Adapt 将任意形式的处理函数转换为 HandlerFunc，用于通过 RegisterHandler 等方法注册旧式处理函数
+func Adapt(handler Handler) HandlerFunc {
+	return handler.Run
+}
+
+// SkipChildren Walk 回调返回该错误时跳过当前节点的子节点
+var SkipChildren = commandtree.SkipChildren
+
 // CmdLine 命令行接口
 type CmdLine struct {
 	*cmdline.CmdLine
 }
 
-// NewCmdLine 创建新的命令行接口
+// NewCmdLine 创建新的命令行接口，使用 config 的副本，之后用 SetConfig 等方法修改配置
 func NewCmdLine(config *Config) *CmdLine {
 	return &CmdLine{
 		CmdLine: cmdline.NewCmdLine(config),
@@ -34,22 +159,317 @@ func (c *CmdLine) RegisterCommand(name, description string, handler CommandHandl
 	c.CmdLine.RegisterCommand(name, description, handler, detailedDescription...)
 }
 
-// RegisterModeCommand 注册命令到指定模式
+// RegisterHandler 注册使用标准处理函数的命令到根模式
+// 处理函数通过 ctx 获取参数、会话和所在视图，输出写到 ctx.Writer
+func (c *CmdLine) RegisterHandler(name, description string, handler HandlerFunc, detailedDescription ...string) {
+	c.CmdLine.RegisterHandler(name, description, handler, detailedDescription...)
+}
+
+// RegisterDataHandler 注册同时支持文本和 JSON 输出的命令到根模式
+// 命令后加 | json 或会话执行了 terminal output json 时，会话将 Data 返回的结果编码为 JSON 输出，
+// 否则调用 Text 输出文本，便于自动化脚本和用户使用同一套命令
+func (c *CmdLine) RegisterDataHandler(name, description string, handler DataHandler, detailedDescription ...string) {
+	c.CmdLine.RegisterDataHandler(name, description, handler, detailedDescription...)
+}
+
+// SetHostname 设置主机名，当前会话和其他会话在下一次显示提示符时使用新的主机名，
+// 之后连接的会话也使用新的主机名；name 为空时恢复为由 Prompt 得到的主机名。
+// 可以在处理函数中调用，如 hostname 命令
+func (c *CmdLine) SetHostname(name string) {
+	c.CmdLine.SetHostname(name)
+}
+
+// Hostname 返回 SetHostname 设置的主机名
+func (c *CmdLine) Hostname() string {
+	return c.CmdLine.Hostname()
+}
+
+// SetPrompt 设置根视图的提示符，所有会话在下一次显示提示符时生效，可以在处理函数中调用
+func (c *CmdLine) SetPrompt(prompt string) {
+	c.CmdLine.SetPrompt(prompt)
+}
+
+// SetPromptFunc 设置动态提示符回调，每次显示提示符前调用，可以在提示符中显示未提交的配置、告警数量等实时状态
+// 回调优先于提示符模板，返回空字符串时按提示符模板或视图固定的提示符显示；fn 为 nil 时取消。
+// 回调在会话读取命令树期间调用，不能在回调中注册命令
+func (c *CmdLine) SetPromptFunc(fn PromptFunc) {
+	c.CmdLine.SetPromptFunc(fn)
+}
+
+// SetCommitFunc 设置提交候选配置时的回调，开启 Config.CandidateConfig 后配置视图中的命令先记入会话的候选配置，
+// commit 时调用 fn；fn 在应用自己的事务中调用 apply 依次执行这些命令，失败时撤销修改并返回错误，使候选配置整体生效或整体不生效。
+// fn 为 nil 时 commit 直接依次执行，遇到错误时停止
+func (c *CmdLine) SetCommitFunc(fn CommitFunc) {
+	c.CmdLine.SetCommitFunc(fn)
+}
+
+// SubscribeConfigChanges 订阅配置修改事件，配置视图（根视图之外的视图）中的命令执行成功后调用 fn，
+// 事件包括命令、所在视图、用户名以及由渲染函数得到的执行前后变化的配置行，应用可以据此下发到硬件或同步到控制器，
+// 而不必包装每个处理函数。开启候选配置时在提交成功之后对每条命令调用，导入配置和回滚同样发布事件；
+// 全局命令和标记为不修改配置的命令不发布。返回取消订阅的函数，可以多次订阅
+func (c *CmdLine) SubscribeConfigChanges(fn ConfigEventFunc) func() {
+	return c.CmdLine.SubscribeConfigChanges(fn)
+}
+
+// AddHistoryExclude 添加不记入命令历史的输入行的正则表达式，如 "(?i)password" 使含有口令的命令不留在历史中；
+// 对所有会话之后输入的行生效，也可以直接设置 Config.HistoryExclude
+func (c *CmdLine) AddHistoryExclude(pattern string) error {
+	return c.CmdLine.AddHistoryExclude(pattern)
+}
+
+// SetNotFoundHandler 设置输入无法匹配当前视图的任何命令时的回调，回调收到原始输入行和执行上下文，
+// 返回 true 表示已经处理，如将未知的词当作主机名执行 ping；返回 false 时打印无法识别的提示。
+// 回调像处理函数一样执行，可以输出、读取输入并被 Ctrl+C 取消；fn 为 nil 时取消
+func (c *CmdLine) SetNotFoundHandler(fn NotFoundFunc) {
+	c.CmdLine.SetNotFoundHandler(fn)
+}
+
+// SetObserver 设置接收会话事件的 Observer，用于统计命令、审计和同步应用的界面，
+// 事件见 Observer 的各个方法；o 为 nil 时取消
+func (c *CmdLine) SetObserver(o Observer) {
+	c.CmdLine.SetObserver(o)
+}
+
+// RegisterGlobalCommand 注册在所有视图中都可以执行和补全的命令，如 ping、show clock
+// 之后创建的视图也会自动注册这些命令
+func (c *CmdLine) RegisterGlobalCommand(name, description string, handler CommandHandler, detailedDescription ...string) {
+	c.CmdLine.RegisterGlobalCommand(name, description, handler, detailedDescription...)
+}
+
+// RegisterGlobalHandler 注册使用标准处理函数的全局命令
+func (c *CmdLine) RegisterGlobalHandler(name, description string, handler HandlerFunc, detailedDescription ...string) {
+	c.CmdLine.RegisterGlobalHandler(name, description, handler, detailedDescription...)
+}
+
+// RegisterModeCommand 注册命令到指定模式，modePath 为以 / 分隔的视图路径，如 configure/interface
 func (c *CmdLine) RegisterModeCommand(modePath string, name, description string, handler CommandHandler, detailedDescription ...string) {
 	c.CmdLine.RegisterModeCommand(modePath, name, description, handler, detailedDescription...)
 }
 
-// CreateMode 创建新的命令模式
+// RegisterModeHandler 注册使用标准处理函数的命令到指定模式，modePath 为以 / 分隔的视图路径
+func (c *CmdLine) RegisterModeHandler(modePath string, name, description string, handler HandlerFunc, detailedDescription ...string) {
+	c.CmdLine.RegisterModeHandler(modePath, name, description, handler, detailedDescription...)
+}
+
+// RegisterModeDataHandler 注册同时支持文本和 JSON 输出的命令到指定模式，见 RegisterDataHandler
+func (c *CmdLine) RegisterModeDataHandler(modePath string, name, description string, handler DataHandler, detailedDescription ...string) {
+	c.CmdLine.RegisterModeDataHandler(modePath, name, description, handler, detailedDescription...)
+}
+
+// SetTemplate 替换用 RegisterDataHandler 注册的命令的文本输出模板（text/template 语法），
+// 所有会话之后执行该命令时生效，用于在运行时定制 show 命令的输出；text 为空时恢复为 DataHandler 的 Text 输出
+func (c *CmdLine) SetTemplate(name, text string) error {
+	return c.CmdLine.SetTemplate(name, text)
+}
+
+// SetModeTemplate 替换指定模式中用 RegisterModeDataHandler 注册的命令的输出模板，见 SetTemplate
+func (c *CmdLine) SetModeTemplate(modePath string, name, text string) error {
+	return c.CmdLine.SetModeTemplate(modePath, name, text)
+}
+
+// RegisterNegatableCommand 注册可否定命令到根模式
+// 同时注册 "no" 形式，如 "hostname HOSTNAME" 同时可以执行 "no hostname [HOSTNAME]"，
+// 否定形式中末尾的参数可以省略，处理函数通过 negate 区分两种形式
+func (c *CmdLine) RegisterNegatableCommand(name, description string, handler NegatableHandler, detailedDescription ...string) {
+	c.CmdLine.RegisterNegatableCommand(name, description, handler, detailedDescription...)
+}
+
+// RegisterNegatableModeCommand 注册可否定命令到指定模式
+func (c *CmdLine) RegisterNegatableModeCommand(modePath string, name, description string, handler NegatableHandler, detailedDescription ...string) {
+	c.CmdLine.RegisterNegatableModeCommand(modePath, name, description, handler, detailedDescription...)
+}
+
+// RegisterNegation 为根模式命令注册独立的否定处理函数，name 为 "no" 之后的完整命令规格
+func (c *CmdLine) RegisterNegation(name, description string, handler CommandHandler, detailedDescription ...string) {
+	c.CmdLine.RegisterNegation(name, description, handler, detailedDescription...)
+}
+
+// RegisterModeNegation 为指定模式命令注册独立的否定处理函数
+func (c *CmdLine) RegisterModeNegation(modePath string, name, description string, handler CommandHandler, detailedDescription ...string) {
+	c.CmdLine.RegisterModeNegation(modePath, name, description, handler, detailedDescription...)
+}
+
+// RegisterHiddenCommand 注册隐藏命令到根模式
+// 隐藏命令可以正常执行，但不出现在帮助、? 提示和补全中，适用于工厂调试和诊断命令
+func (c *CmdLine) RegisterHiddenCommand(name, description string, handler CommandHandler, detailedDescription ...string) {
+	c.CmdLine.RegisterHiddenCommand(name, description, handler, detailedDescription...)
+}
+
+// RegisterHiddenModeCommand 注册隐藏命令到指定模式
+func (c *CmdLine) RegisterHiddenModeCommand(modePath string, name, description string, handler CommandHandler, detailedDescription ...string) {
+	c.CmdLine.RegisterHiddenModeCommand(modePath, name, description, handler, detailedDescription...)
+}
+
+// DeprecateCommand 将根模式中已注册的命令标记为废弃
+// 废弃命令仍然可以执行，但会先提示改用 replacement，帮助中单独列出；replacement 可以为空
+func (c *CmdLine) DeprecateCommand(name, replacement string) error {
+	return c.CmdLine.DeprecateCommand(name, replacement)
+}
+
+// DeprecateModeCommand 将指定模式中已注册的命令标记为废弃
+func (c *CmdLine) DeprecateModeCommand(modePath string, name, replacement string) error {
+	return c.CmdLine.DeprecateModeCommand(modePath, name, replacement)
+}
+
+// RegisterValueProvider 为字符串参数记号（如 IFNAME）注册取值提供者
+// 使用该记号的参数只接受提供者当前返回的值，Tab 补全和 ? 帮助也列出这些值
+func (c *CmdLine) RegisterValueProvider(name string, provider ValueProvider) {
+	c.CmdLine.RegisterValueProvider(name, provider)
+}
+
+// RegisterInterfaceProvider 为 IFNAME 参数注册接口名提供者，如 SystemInterfaces
+// IFNAME 参数只接受提供者当前返回的接口名，Tab 补全列出这些接口
+func (c *CmdLine) RegisterInterfaceProvider(provider ValueProvider) {
+	c.CmdLine.RegisterInterfaceProvider(provider)
+}
+
+// ResolvePath 返回 PATH 参数在服务器上对应的路径，路径限制在 Config.FileRoot 之内
+// 处理函数收到的 PATH 参数是以 / 开头、相对于根目录的路径，打开文件前用该方法转换
+func (c *CmdLine) ResolvePath(arg string) (string, error) {
+	return c.CmdLine.ResolvePath(arg)
+}
+
+// SystemInterfaces 返回本机网络接口名，可以直接传给 RegisterInterfaceProvider
+func SystemInterfaces() []string {
+	return commandtree.SystemInterfaces()
+}
+
+// Walk 深度优先遍历所有视图中注册的命令树节点，同一层关键字在前并按名称排序，参数在后
+// 节点信息包括类型、范围、枚举值、描述和处理函数，可用于生成文档或自定义界面；
+// 回调返回 SkipChildren 跳过该节点的子节点，返回其他错误时停止遍历
+func (c *CmdLine) Walk(fn func(node NodeInfo) error) error {
+	return c.CmdLine.Walk(fn)
+}
+
+// MarshalJSON 将所有视图的命令树导出为 JSON，描述每个命令的记号、参数类型、取值范围和说明，
+// 供自动化脚本、Web 界面或测试生成工具使用
+func (c *CmdLine) MarshalJSON() ([]byte, error) {
+	return c.CmdLine.MarshalJSON()
+}
+
+// WriteMarkdown 将所有视图中注册的命令生成 Markdown 格式的命令参考，
+// 包括视图、命令描述、参数类型、取值范围和枚举值，隐藏命令不输出
+func (c *CmdLine) WriteMarkdown(w io.Writer) error {
+	return c.CmdLine.WriteMarkdown(w)
+}
+
+// WriteManPage 将所有视图中注册的命令生成 troff 格式的手册页，name 为手册页名称
+func (c *CmdLine) WriteManPage(w io.Writer, name string) error {
+	return c.CmdLine.WriteManPage(w, name)
+}
+
+// MarkOperationalCommand 将指定模式中已注册的命令（如配置视图中的 show 命令）标记为不修改配置的命令，
+// 开启候选配置时直接执行而不记入候选配置；全局命令、exit 和 quit 已经是这样的命令
+func (c *CmdLine) MarkOperationalCommand(modePath string, name string) error {
+	return c.CmdLine.MarkOperationalCommand(modePath, name)
+}
+
+// SetModeInherit 设置视图是否继承上一级视图的命令，继承后上一级视图的命令在本视图中也可以执行和补全，
+// 包括之后注册的命令；本视图注册以同一关键字开头的命令时覆盖继承的命令
+func (c *CmdLine) SetModeInherit(modePath string, inherit bool) {
+	c.CmdLine.SetModeInherit(modePath, inherit)
+}
+
+// SetModeHelp 设置视图中 help 命令在命令列表之前显示的说明，modePath 为空表示根视图
+func (c *CmdLine) SetModeHelp(modePath string, header string) {
+	c.CmdLine.SetModeHelp(modePath, header)
+}
+
+// AddCommandCategory 将视图中以 keywords 开头的命令归入 help 命令的一个分组，如 "Routing"
+// help 先按添加顺序列出各分组，组内按 keywords 的顺序排列，未分组的命令按名称列在最后
+func (c *CmdLine) AddCommandCategory(modePath string, category string, keywords ...string) {
+	c.CmdLine.AddCommandCategory(modePath, category, keywords...)
+}
+
+// RegisterConfigRenderer 为视图注册配置渲染函数，show running-config 按视图嵌套的顺序调用各视图的渲染函数，
+// 把应用的当前状态排列为可以重新执行的配置命令；modePath 为空表示根视图，同一视图可以注册多个，按注册顺序输出。
+// 嵌套视图的渲染函数对上一级视图生成的每一段调用一次，输出缩进在该段之中。渲染函数在会话协程中调用，
+// 不持有注册表锁，可以调用 CmdLine 的方法
+func (c *CmdLine) RegisterConfigRenderer(modePath string, renderer ConfigRenderer) {
+	c.CmdLine.RegisterConfigRenderer(modePath, renderer)
+}
+
+// RunningConfig 返回与 show running-config 相同的配置文本，可用于保存配置或与其他系统同步
+func (c *CmdLine) RunningConfig() string {
+	return c.CmdLine.RunningConfig()
+}
+
+// WriteStartupConfig 将当前配置保存到 Config.StartupConfig 指定的文件，与 write memory 相同；
+// 先写到同一目录的临时文件再改名，保存失败时原有的文件保持不变
+func (c *CmdLine) WriteStartupConfig() error {
+	return c.CmdLine.WriteStartupConfig()
+}
+
+// ExportConfig 返回与 show running-config json 或 show running-config yaml 相同的结构化配置，format 为 "json" 或 "yaml"；
+// 文档是各段组成的列表，每段包括生成该段的视图 mode、进入视图实例的命令 command、配置行 lines 和嵌套视图的段 sections
+func (c *CmdLine) ExportConfig(format string) ([]byte, error) {
+	return c.CmdLine.ExportConfig(format)
+}
+
+// ImportConfig 导入 ExportConfig 格式的配置，与 load merge 和 load replace 相同：与当前配置比较，
+// 执行需要增加的配置命令，replace 为 true 时还用 no 形式的命令删除文档中没有的配置。
+// 命令作为一次提交执行，经过 SetCommitFunc 设置的提交回调，并保存历史配置；
+// 处理函数的 Ctx 中 Session 为 nil，输出被丢弃
+func (c *CmdLine) ImportConfig(data []byte, replace bool) error {
+	return c.CmdLine.ImportConfig(data, replace)
+}
+
+// RunScript 从根视图开始逐行执行 r 中的命令，与 load script 相同：空行和以 ! 或 # 开头的行为注释，
+// 进入视图的命令对之后的行生效，exit 结束脚本；每条命令前写出提示符和命令，最后写出执行结果的摘要，换行为 \n。
+// 默认在第一条失败的命令处停止，options.ContinueOnError 为 true 时继续执行。处理函数读取输入时得到 io.EOF，
+// 开启候选配置时脚本需要以 commit 结束，否则修改被丢弃。返回的错误为读取 r 的错误，命令的失败记录在结果中
+func (c *CmdLine) RunScript(r io.Reader, w io.Writer, options ScriptOptions) (ScriptResult, error) {
+	return c.CmdLine.RunScript(r, w, options)
+}
+
+// CreateMode 创建新的命令模式，modePath 中不存在的上级视图会一并创建
+// 根视图的子视图可以在任意视图中进入，嵌套视图在上一级视图中输入其名称进入，quit 返回进入视图之前所在的视图
 func (c *CmdLine) CreateMode(modePath string, description string) {
 	c.CmdLine.CreateMode(modePath, description)
 }
 
+// ServeConn 在已经建立的连接上运行一个 telnet 会话，会话结束时返回并关闭连接。不需要调用 Start，
+// 第一次调用时注册内置命令；可用于由应用自己接受连接，或在测试中通过 net.Pipe 驱动命令行，见 tnlcmdtest 包
+func (c *CmdLine) ServeConn(conn net.Conn) {
+	c.CmdLine.ServeConn(conn)
+}
+
+// ServeStream 在任意数据流上运行一个会话，会话结束时返回，rw 实现了 io.Closer 时将其关闭。不需要调用 Start，
+// 用于把命令行嵌入 SSH 服务、网页终端或聊天机器人，options 指定客户端地址、是否为 telnet 协议和终端属性
+func (c *CmdLine) ServeStream(rw io.ReadWriter, options StreamOptions) {
+	c.CmdLine.ServeStream(rw, options)
+}
+
 // Start 启动命令行服务
 func (c *CmdLine) Start() error {
 	return c.CmdLine.Start()
 }
 
-// Stop 停止命令行服务
+// StartContext 启动命令行服务，ctx 取消时停止接受连接并关闭所有会话，与调用 Stop 相同；
+// 可以与应用的根上下文一起取消，如 signal.NotifyContext 返回的上下文。停止在后台进行，
+// 需要等待停止完成（如最后一次自动保存）时在 ctx 取消后调用 Stop
+func (c *CmdLine) StartContext(ctx context.Context) error {
+	return c.CmdLine.StartContext(ctx)
+}
+
+// Sessions 按连接建立的时间返回所有活动的会话，用于实现 show users 等命令，服务没有启动时为空
+func (c *CmdLine) Sessions() []Session {
+	return c.CmdLine.Sessions()
+}
+
+// Notify 向执行了 terminal monitor 的会话推送一条日志或告警，可以在任意协程中调用，不会阻塞
+// 会话等待输入时通知显示在正在编辑的输入行之前，随后重新显示提示符和已输入的内容；
+// 命令执行期间到达的通知在命令结束后显示。terminal no monitor 停止接收
+func (c *CmdLine) Notify(message string) {
+	c.CmdLine.Notify(message)
+}
+
+// Notifyf 按格式推送通知，见 Notify
+func (c *CmdLine) Notifyf(format string, args ...interface{}) {
+	c.CmdLine.Notify(fmt.Sprintf(format, args...))
+}
+
+// Stop 停止命令行服务，ctx 取消引起的停止正在进行时等待其完成
 func (c *CmdLine) Stop() {
 	c.CmdLine.Stop()
 }
@@ -62,10 +482,11 @@ func (c *CmdLine) SetConfig(key, value string) {
 // DefaultConfig 返回默认配置
 func DefaultConfig() *Config {
 	return &Config{
-		Prompt:     "cmdline",
-		Port:       2323,
-		WelcomeMsg: "Welcome to Command Line Interface!\r\nType '?' for available commands.\r\n",
-		MaxHistory: 100,
+		Prompt:      "cmdline",
+		Port:        2323,
+		WelcomeMsg:  "Welcome to Command Line Interface!\r\nType '?' for available commands.\r\n",
+		MaxHistory:  100,
+		ArchiveSize: 10,
 	}
 }
 
@@ -77,16 +498,7 @@ func SimpleExample() {
 	// 创建命令行接口
 	cmdline := NewCmdLine(config)
 
-	// 注册一些基本命令
-	cmdline.RegisterCommand("help", "Show help information", func(args []string) string {
-		var result strings.Builder
-		result.WriteString("Available commands:\n")
-		result.WriteString("  help    - Show this help\n")
-		result.WriteString("  version - Show version\n")
-		result.WriteString("  exit    - Exit the program\n")
-		return result.String()
-	})
-
+	// 注册一些基本命令，help 由库内置
 	cmdline.RegisterCommand("version", "Show version information", func(args []string) string {
 		return "TNLCMD v1.0.0\n"
 	})
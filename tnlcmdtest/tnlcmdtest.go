@@ -0,0 +1,60 @@
+// Package tnlcmdtest 通过内存中的连接驱动 CmdLine，模拟 telnet 终端发送按键并读取输出，
+// 用于为应用的命令树编写确定的测试，不需要监听端口
+//
+//	term, err := tnlcmdtest.New(cmdline, tnlcmdtest.Options{})
+//	if err != nil {
+//		t.Fatal(err)
+//	}
+//	defer term.Close()
+//	out, err := term.Run("show version")
+package tnlcmdtest
+
+import (
+	"net"
+
+	"github.com/TrailHuang/tnlcmd/client"
+)
+
+// 常用按键
+const (
+	Enter     = client.Enter
+	Tab       = client.Tab
+	Help      = client.Help
+	Backspace = client.Backspace
+	Up        = client.Up
+	Down      = client.Down
+	Right     = client.Right
+	Left      = client.Left
+	CtrlA     = client.CtrlA
+	CtrlC     = client.CtrlC
+	CtrlD     = client.CtrlD
+	CtrlE     = client.CtrlE
+	CtrlU     = client.CtrlU
+)
+
+// ErrClosed 会话已经结束，没有更多的输出
+var ErrClosed = client.ErrClosed
+
+// Options 模拟终端的设置，见 client.Options
+type Options = client.Options
+
+// Server 可以在连接上运行会话的命令行，*tnlcmd.CmdLine 实现了该接口
+type Server interface {
+	ServeConn(conn net.Conn)
+}
+
+// Terminal 连接到 CmdLine 的模拟终端，提供 client.Client 的 Send、Expect、WaitPrompt、Run、Output 和 Close
+type Terminal struct {
+	*client.Client
+}
+
+// New 通过 net.Pipe 连接到 server 并等待第一个提示符
+func New(server Server, options Options) (*Terminal, error) {
+	conn, serverConn := net.Pipe()
+	go server.ServeConn(serverConn)
+	c, err := client.New(conn, options)
+	if err != nil {
+		return nil, err
+	}
+	return &Terminal{Client: c}, nil
+}
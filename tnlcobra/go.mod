@@ -0,0 +1,13 @@
+module github.com/TrailHuang/tnlcmd/tnlcobra
+
+go 1.24.9
+
+require (
+	github.com/TrailHuang/tnlcmd v0.0.0
+	github.com/spf13/cobra v1.9.1
+	github.com/spf13/pflag v1.0.6
+)
+
+require github.com/inconshreveable/mousetrap v1.1.0 // indirect
+
+replace github.com/TrailHuang/tnlcmd => ../
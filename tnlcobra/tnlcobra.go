@@ -0,0 +1,277 @@
+// Package tnlcobra 把已有的 cobra 命令树注册到 tnlcmd 命令行，应用不需要重复定义命令即可提供 telnet 管理界面。
+// 子命令按层次注册为关键字，标志注册为命名参数：带值的标志为 [name WORD]，布尔标志为 [name]，
+// Use 中列出的位置参数依次注册在标志之后。执行时按输入重新组成参数列表交给 cobra 执行，
+// 输出写到会话
+//
+//	tnlcobra.Mount(cmdline, rootCmd)
+//	// backup create --name b1 --compress 在会话中输入为
+//	// backup create name b1 compress
+//
+// 单独维护为一个模块，tnlcmd 本身不依赖 cobra
+package tnlcobra
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/TrailHuang/tnlcmd"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// Mount 把 root 之下可用的子命令注册到根视图，root 本身的名称不作为关键字，隐藏和废弃的命令不注册
+func Mount(cmdline *tnlcmd.CmdLine, root *cobra.Command) {
+	MountMode(cmdline, "", root)
+}
+
+// MountMode 把 root 之下可用的子命令注册到视图 modePath，modePath 为空时注册到根视图。
+// 同一个 root 的命令依次执行，cobra 的标志变量在多个会话之间共享
+func MountMode(cmdline *tnlcmd.CmdLine, modePath string, root *cobra.Command) {
+	m := &mount{root: root}
+	for _, cmd := range root.Commands() {
+		m.register(cmdline, modePath, cmd, nil)
+	}
+}
+
+// mount 注册到命令行的一棵 cobra 命令树
+type mount struct {
+	root *cobra.Command
+	mu   sync.Mutex // cobra 的参数和标志是命令树的状态，同时只能执行一条命令
+}
+
+// register 注册 cmd 及其子命令，parents 为 root 之下的各级父命令
+func (m *mount) register(cmdline *tnlcmd.CmdLine, modePath string, cmd *cobra.Command, parents []*cobra.Command) {
+	if !cmd.IsAvailableCommand() || cmd.Name() == "help" || cmd.Name() == "completion" {
+		return
+	}
+	path := append(append([]*cobra.Command(nil), parents...), cmd)
+
+	if cmd.Runnable() {
+		spec := newCommandSpec(path)
+		handler := func(ctx *tnlcmd.Ctx) error {
+			return m.execute(ctx, spec)
+		}
+		if modePath == "" {
+			cmdline.RegisterHandler(spec.syntax(), cmd.Short, handler, spec.help())
+		} else {
+			cmdline.RegisterModeHandler(modePath, spec.syntax(), cmd.Short, handler, spec.help())
+		}
+	}
+	for _, child := range cmd.Commands() {
+		m.register(cmdline, modePath, child, path)
+	}
+}
+
+// execute 按会话中输入的参数执行 cobra 命令，cobra 的输出和错误写到会话
+func (m *mount) execute(ctx *tnlcmd.Ctx, spec *commandSpec) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	cmd := spec.path[len(spec.path)-1]
+	resetFlags(cmd.LocalFlags())
+	resetFlags(cmd.InheritedFlags())
+	args, err := spec.args(ctx)
+	if err != nil {
+		return err
+	}
+
+	root := m.root
+	silenceErrors, silenceUsage := root.SilenceErrors, root.SilenceUsage
+	root.SilenceErrors, root.SilenceUsage = true, true
+	root.SetArgs(args)
+	root.SetOut(ctx.Writer)
+	root.SetErr(ctx.Writer)
+	defer func() {
+		root.SilenceErrors, root.SilenceUsage = silenceErrors, silenceUsage
+		root.SetArgs(nil)
+		root.SetOut(nil)
+		root.SetErr(nil)
+	}()
+
+	runCtx := ctx.Context
+	if runCtx == nil {
+		runCtx = context.Background()
+	}
+	_, err = root.ExecuteContextC(runCtx)
+	return err
+}
+
+// commandSpec 一条 cobra 命令对应的命令规格
+type commandSpec struct {
+	path        []*cobra.Command // root 之下各级命令，最后一个为要执行的命令
+	flags       []*pflag.Flag    // 注册为命名参数的标志
+	positionals []positional     // Use 中列出的位置参数
+}
+
+// positional Use 中的一个位置参数
+type positional struct {
+	name     string // 参数在命令规格中的名称，如 arg-1
+	optional bool   // 在 Use 中写在 [] 之内
+	repeat   bool   // 以 ... 结尾，接收其后的全部输入
+}
+
+// newCommandSpec 收集命令的标志和位置参数，与上级命令或子命令同名的标志不注册
+func newCommandSpec(path []*cobra.Command) *commandSpec {
+	spec := &commandSpec{path: path}
+	cmd := path[len(path)-1]
+
+	keywords := make(map[string]bool)
+	for _, c := range path {
+		keywords[c.Name()] = true
+	}
+	for _, child := range cmd.Commands() {
+		keywords[child.Name()] = true
+	}
+	collect := func(f *pflag.Flag) {
+		if f.Hidden || f.Deprecated != "" || f.Name == "help" || keywords[f.Name] {
+			return
+		}
+		keywords[f.Name] = true
+		spec.flags = append(spec.flags, f)
+	}
+	cmd.LocalFlags().VisitAll(collect)
+	cmd.InheritedFlags().VisitAll(collect)
+
+	spec.positionals = parseUse(cmd.Use)
+	return spec
+}
+
+// parseUse 解析 Use 中命令名之后的位置参数，如 "cp SRC... DST" 和 "get [NAME]"；
+// 可重复的参数接收其后的全部输入，之后列出的参数不再单独注册。没有列出时命令不接受位置参数
+func parseUse(use string) []positional {
+	fields := strings.Fields(use)
+	var result []positional
+	for _, field := range fields[min(1, len(fields)):] {
+		if strings.EqualFold(strings.Trim(field, "[]"), "flags") {
+			continue
+		}
+		p := positional{name: "arg-" + strconv.Itoa(len(result)+1)}
+		p.optional = strings.HasPrefix(field, "[")
+		p.repeat = strings.HasSuffix(strings.TrimRight(field, "]"), "...")
+		result = append(result, p)
+		if p.repeat {
+			break
+		}
+	}
+	return result
+}
+
+// syntax 返回注册的命令规格，如 "backup create [name WORD] [compress] <arg-1:WORD>"
+func (s *commandSpec) syntax() string {
+	var parts []string
+	for _, c := range s.path {
+		parts = append(parts, c.Name())
+	}
+	for _, f := range s.flags {
+		if isSwitch(f) {
+			parts = append(parts, "["+f.Name+"]")
+		} else {
+			parts = append(parts, "["+f.Name+" "+valueToken(f)+"]")
+		}
+	}
+	for _, p := range s.positionals {
+		token := "<" + p.name + ":WORD>"
+		if p.repeat {
+			token += "..."
+		}
+		if p.optional {
+			token = "[" + token + "]"
+		}
+		parts = append(parts, token)
+	}
+	return strings.Join(parts, " ")
+}
+
+// help 返回各记号的帮助：命令的简介、标志的说明和值的类型，位置参数没有帮助
+func (s *commandSpec) help() string {
+	var lines []string
+	for _, c := range s.path {
+		lines = append(lines, c.Short)
+	}
+	for _, f := range s.flags {
+		lines = append(lines, f.Usage)
+		if !isSwitch(f) {
+			lines = append(lines, f.Value.Type())
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// args 按会话中输入的参数组成交给 cobra 的参数列表；可重复的标志直接设置，不经过 cobra 解析
+func (s *commandSpec) args(ctx *tnlcmd.Ctx) ([]string, error) {
+	var args []string
+	for _, c := range s.path {
+		args = append(args, c.Name())
+	}
+	for _, f := range s.flags {
+		value := ctx.Param(f.Name)
+		if value == "" {
+			continue
+		}
+		if isSwitch(f) {
+			args = append(args, "--"+f.Name)
+			continue
+		}
+		// 可重复标志的 Set 在第一次之后追加而不是替换，重置后用 Replace 设置
+		if slice, ok := f.Value.(pflag.SliceValue); ok {
+			if err := slice.Replace(strings.Split(value, ",")); err != nil {
+				return nil, err
+			}
+			f.Changed = true
+			continue
+		}
+		args = append(args, "--"+f.Name+"="+value)
+	}
+
+	args = append(args, "--")
+	for _, p := range s.positionals {
+		value := ctx.Param(p.name)
+		switch {
+		case value == "":
+		case p.repeat:
+			args = append(args, strings.Fields(value)...)
+		default:
+			args = append(args, value)
+		}
+	}
+	return args, nil
+}
+
+// isSwitch 检查标志是否不带值，如布尔标志和计数标志
+func isSwitch(f *pflag.Flag) bool {
+	return f.NoOptDefVal != ""
+}
+
+// valueToken 返回标志的值在命令规格中的记号，时长使用 <duration>，其余由 cobra 校验
+func valueToken(f *pflag.Flag) string {
+	if f.Value.Type() == "duration" {
+		return "<duration>"
+	}
+	return "WORD"
+}
+
+// resetFlags 将上一次执行设置过的标志恢复为默认值
+func resetFlags(flags *pflag.FlagSet) {
+	flags.VisitAll(func(f *pflag.Flag) {
+		if !f.Changed {
+			return
+		}
+		if slice, ok := f.Value.(pflag.SliceValue); ok {
+			slice.Replace(defaultSlice(f.DefValue))
+		} else {
+			f.Value.Set(f.DefValue)
+		}
+		f.Changed = false
+	})
+}
+
+// defaultSlice 解析可重复标志的默认值，如 "[a,b]"
+func defaultSlice(value string) []string {
+	value = strings.TrimSuffix(strings.TrimPrefix(value, "["), "]")
+	if value == "" {
+		return nil
+	}
+	return strings.Split(value, ",")
+}